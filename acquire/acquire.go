@@ -0,0 +1,132 @@
+package acquire
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// 运行状态键前缀：已提交下载的缺失季只提交一次，没有找到发布的缺失季在searchInterval之后才重新搜索
+const (
+	submittedKeyPrefix = "acquire:submitted:"
+	searchedKeyPrefix  = "acquire:searched:"
+)
+
+// searchInterval 守护进程没有找到发布时重新搜索的间隔，避免每次处理都请求索引器
+const searchInterval = 24 * time.Hour
+
+// Plan 一个缺失季的搜索结果
+type Plan struct {
+	Season     database.MissingSeason
+	Best       *Release // 画质最好的发布，没有符合要求的发布时为nil
+	Candidates int      // 匹配季数且符合画质要求的发布数
+	Err        error    // 搜索失败的原因
+}
+
+// Result 一次提交下载的统计
+type Result struct {
+	Submitted int // 新提交的下载数
+	Skipped   int // 之前已提交过或最近已搜索过的缺失季数
+	NotFound  int // 没有符合要求的发布的缺失季数
+	Failed    int
+}
+
+// SearchMissing 为之前没有提交过下载的缺失季搜索索引器，选出画质最好的发布
+// recentOnly为true时跳过searchInterval内已搜索过但没有找到发布的缺失季，返回的第二个值为跳过的数量
+func SearchMissing(cfg *config.Config, recentOnly bool) ([]Plan, int, error) {
+	if !cfg.Acquire.Enabled() {
+		return nil, 0, fmt.Errorf("没有配置acquire的downloader和rpc_url")
+	}
+	if _, err := NewIndexer(cfg); err != nil {
+		return nil, 0, err
+	}
+	seasons, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+
+	var plans []Plan
+	skipped := 0
+	for _, season := range seasons {
+		submitted, err := database.GetRunState(submittedKey(season.ID))
+		if err != nil {
+			return nil, skipped, err
+		}
+		if submitted != "" {
+			skipped++
+			continue
+		}
+		if recentOnly {
+			searched, err := database.GetRunState(searchedKey(season.ID))
+			if err != nil {
+				return nil, skipped, err
+			}
+			if last, err := time.Parse(time.RFC3339, searched); err == nil && time.Since(last) < searchInterval {
+				skipped++
+				continue
+			}
+		}
+
+		plan := Plan{Season: season}
+		ranked, _, err := Search(cfg, querySeason(season))
+		if err != nil {
+			plan.Err = err
+		} else if plan.Candidates = len(ranked); len(ranked) > 0 {
+			plan.Best = &ranked[0]
+		}
+		plans = append(plans, plan)
+	}
+	return plans, skipped, nil
+}
+
+// Run 为缺失季搜索发布并把画质最好的提交到下载器，提交成功的缺失季之后不再重复提交
+func Run(cfg *config.Config, recentOnly bool) (*Result, error) {
+	plans, skipped, err := SearchMissing(cfg, recentOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Skipped: skipped}
+	now := time.Now().Format(time.RFC3339)
+	for _, plan := range plans {
+		season := plan.Season
+		if plan.Err != nil {
+			logging.Error("搜索 '%s' 第 %d 季失败: %v", season.Title, season.Season, plan.Err)
+			result.Failed++
+			continue
+		}
+		if plan.Best == nil {
+			logging.Info("没有找到 '%s' 第 %d 季符合画质要求的发布", season.Title, season.Season)
+			result.NotFound++
+			if err := database.SetRunState(searchedKey(season.ID), now); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if err := submit(cfg.Acquire, *plan.Best); err != nil {
+			logging.Error("提交 '%s' 第 %d 季的下载失败: %v", season.Title, season.Season, err)
+			result.Failed++
+			continue
+		}
+		if err := database.SetRunState(submittedKey(season.ID), plan.Best.Title); err != nil {
+			return result, err
+		}
+		logging.Info("已将 '%s' 第 %d 季的发布 '%s'（%s）提交到%s", season.Title, season.Season, plan.Best.Title, plan.Best.Quality, cfg.Acquire.Downloader)
+		result.Submitted++
+	}
+	return result, nil
+}
+
+// submittedKey 返回缺失季已提交下载的运行状态键
+func submittedKey(id int) string {
+	return fmt.Sprintf("%s%d", submittedKeyPrefix, id)
+}
+
+// searchedKey 返回缺失季上次没有找到发布的运行状态键
+func searchedKey(id int) string {
+	return fmt.Sprintf("%s%d", searchedKeyPrefix, id)
+}
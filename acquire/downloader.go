@@ -0,0 +1,128 @@
+package acquire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/user/media-manager/config"
+)
+
+// submit 把发布的链接提交到配置的下载器
+func submit(cfg config.AcquireConfig, release Release) error {
+	switch cfg.Downloader {
+	case config.DownloaderAria2:
+		return submitAria2(cfg, release.Link)
+	case config.DownloaderQBittorrent:
+		return submitQBittorrent(cfg, release.Link)
+	default:
+		return fmt.Errorf("不支持的下载器: %s", cfg.Downloader)
+	}
+}
+
+// submitAria2 通过aria2的JSON-RPC接口（aria2.addUri）添加下载
+func submitAria2(cfg config.AcquireConfig, link string) error {
+	var params []interface{}
+	if cfg.Password != "" {
+		params = append(params, "token:"+cfg.Password)
+	}
+	options := map[string]string{}
+	if cfg.SavePath != "" {
+		options["dir"] = cfg.SavePath
+	}
+	params = append(params, []string{link}, options)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "media-manager",
+		"method":  "aria2.addUri",
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(cfg.RPCURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("请求aria2失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析aria2响应失败（HTTP %d）: %w", resp.StatusCode, err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("aria2返回错误 %d: %s", result.Error.Code, result.Error.Message)
+	}
+	return nil
+}
+
+// submitQBittorrent 登录qBittorrent WebUI后通过 /api/v2/torrents/add 添加下载
+func submitQBittorrent(cfg config.AcquireConfig, link string) error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: httpClient.Timeout, Jar: jar}
+	baseURL := strings.TrimSuffix(cfg.RPCURL, "/")
+
+	if cfg.Username != "" {
+		if err := qbittorrentPost(client, baseURL+"/api/v2/auth/login", url.Values{
+			"username": {cfg.Username},
+			"password": {cfg.Password},
+		}); err != nil {
+			return fmt.Errorf("登录qBittorrent失败: %w", err)
+		}
+	}
+
+	form := url.Values{"urls": {link}}
+	if cfg.SavePath != "" {
+		form.Set("savepath", cfg.SavePath)
+	}
+	if cfg.Category != "" {
+		form.Set("category", cfg.Category)
+	}
+	if err := qbittorrentPost(client, baseURL+"/api/v2/torrents/add", form); err != nil {
+		return fmt.Errorf("添加qBittorrent任务失败: %w", err)
+	}
+	return nil
+}
+
+// qbittorrentPost 以表单提交qBittorrent WebUI接口，接口失败时返回 "Fails."
+func qbittorrentPost(client *http.Client, endpoint string, form url.Values) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent校验Referer防止CSRF
+	req.Header.Set("Referer", endpoint)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	text := strings.TrimSpace(string(message))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, text)
+	}
+	if strings.EqualFold(text, "Fails.") {
+		return fmt.Errorf("qBittorrent返回 %s", text)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package acquire
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/parser"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// 发布名称中的季数标记，只匹配整季（S02E05这样的单集不匹配）
+var seasonPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bS(\d{1,2})\b`),
+	regexp.MustCompile(`(?i)\bSeason[ ._]?(\d{1,2})\b`),
+	regexp.MustCompile(`第\s*(` + parser.ChineseNumberPattern + `)\s*季`),
+}
+
+// Release 索引器返回的一个发布
+type Release struct {
+	Title   string
+	Link    string // 种子文件地址或磁力链接
+	Size    int64  // 字节，未知时为0
+	Seeders int    // 做种数，索引器没有提供时为-1
+	Quality classifier.VideoQuality
+}
+
+// Query 搜索条件
+type Query struct {
+	Title         string
+	OriginalTitle string // 原标题，英文索引器通常用原标题搜索效果更好，为空时使用Title
+	Season        int    // 搜索电视剧的某一季，0表示不限
+	TMDbID        string
+}
+
+// Indexer 可以按标题搜索发布的索引器
+type Indexer interface {
+	Search(query Query) ([]Release, error)
+}
+
+// NewIndexer 根据配置返回索引器：配置了indexer（Torznab）时优先使用，其次是acquire.search_url的RSS地址模板
+func NewIndexer(cfg *config.Config) (Indexer, error) {
+	if cfg.Indexer.Enabled() {
+		return &torznabIndexer{cfg: cfg.Indexer}, nil
+	}
+	if cfg.Acquire.SearchURL != "" {
+		return &rssIndexer{template: cfg.Acquire.SearchURL}, nil
+	}
+	return nil, fmt.Errorf("没有配置indexer的url或acquire的search_url")
+}
+
+// querySeason 返回缺失季的搜索条件
+func querySeason(season database.MissingSeason) Query {
+	return Query{Title: season.Title, OriginalTitle: season.OriginalTitle, Season: season.Season, TMDbID: season.TMDbID}
+}
+
+// searchTitle 返回用于搜索的标题
+func (q Query) searchTitle() string {
+	if q.OriginalTitle != "" {
+		return q.OriginalTitle
+	}
+	return q.Title
+}
+
+// rssIndexer 通过地址模板请求返回RSS的搜索页面
+type rssIndexer struct {
+	template string
+}
+
+// Search 填充地址模板后请求RSS
+func (r *rssIndexer) Search(query Query) ([]Release, error) {
+	return fetchReleases(SearchURL(r.template, query))
+}
+
+// SearchURL 用搜索条件填充地址模板，各值按URL查询参数转义
+func SearchURL(template string, query Query) string {
+	return strings.NewReplacer(
+		"{title}", url.QueryEscape(query.Title),
+		"{original_title}", url.QueryEscape(query.searchTitle()),
+		"{season2}", fmt.Sprintf("%02d", query.Season),
+		"{season}", strconv.Itoa(query.Season),
+		"{tmdb_id}", url.QueryEscape(query.TMDbID),
+	).Replace(template)
+}
+
+// torznabIndexer Torznab接口的索引器，如Jackett、Prowlarr
+type torznabIndexer struct {
+	cfg config.IndexerConfig
+}
+
+// Search 调用Torznab的search接口，指定季时调用tvsearch接口
+func (t *torznabIndexer) Search(query Query) ([]Release, error) {
+	params := url.Values{"apikey": {t.cfg.APIKey}, "q": {query.searchTitle()}}
+	if query.Season > 0 {
+		params.Set("t", "tvsearch")
+		params.Set("season", strconv.Itoa(query.Season))
+	} else {
+		params.Set("t", "search")
+	}
+	if len(t.cfg.Categories) > 0 {
+		categories := make([]string, len(t.cfg.Categories))
+		for i, category := range t.cfg.Categories {
+			categories[i] = strconv.Itoa(category)
+		}
+		params.Set("cat", strings.Join(categories, ","))
+	}
+
+	separator := "?"
+	if strings.Contains(t.cfg.URL, "?") {
+		separator = "&"
+	}
+	return fetchReleases(t.cfg.URL + separator + params.Encode())
+}
+
+// rssFeed 索引器返回的RSS 2.0文档中用到的部分，包括Torznab扩展属性；Torznab出错时根元素为error
+type rssFeed struct {
+	XMLName     xml.Name
+	Code        string `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+	Items       []struct {
+		Title     string `xml:"title"`
+		Link      string `xml:"link"`
+		Size      int64  `xml:"size"`
+		Enclosure struct {
+			URL    string `xml:"url,attr"`
+			Length int64  `xml:"length,attr"`
+		} `xml:"enclosure"`
+		Attrs []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"attr"`
+	} `xml:"channel>item"`
+}
+
+// fetchReleases 请求索引器的RSS搜索结果，并从标题中解析画质
+func fetchReleases(searchURL string) ([]Release, error) {
+	resp, err := httpClient.Get(searchURL)
+	if err != nil {
+		return nil, fmt.Errorf("请求索引器失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("索引器返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var feed rssFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("解析索引器RSS失败: %w", err)
+	}
+	if feed.XMLName.Local == "error" {
+		return nil, fmt.Errorf("索引器返回错误 %s: %s", feed.Code, feed.Description)
+	}
+
+	var releases []Release
+	for _, item := range feed.Items {
+		release := Release{Title: strings.TrimSpace(item.Title), Link: item.Enclosure.URL, Size: item.Size, Seeders: -1}
+		if release.Link == "" {
+			release.Link = strings.TrimSpace(item.Link)
+		}
+		if release.Size == 0 {
+			release.Size = item.Enclosure.Length
+		}
+		for _, attr := range item.Attrs {
+			switch attr.Name {
+			case "seeders":
+				if seeders, err := strconv.Atoi(attr.Value); err == nil {
+					release.Seeders = seeders
+				}
+			case "size":
+				if size, err := strconv.ParseInt(attr.Value, 10, 64); err == nil && release.Size == 0 {
+					release.Size = size
+				}
+			case "magneturl":
+				// 没有种子文件地址时使用磁力链接
+				if release.Link == "" {
+					release.Link = attr.Value
+				}
+			}
+		}
+		if release.Title == "" || release.Link == "" {
+			continue
+		}
+		release.Quality = classifier.QualityFromName(release.Title)
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// matchSeason 判断发布是否为搜索的剧集的整季：标题包含剧集的标题或原标题，且标注的季数相同
+func matchSeason(release Release, query Query) bool {
+	if !matchTitle(release, query) {
+		return false
+	}
+	for _, pattern := range seasonPatterns {
+		for _, match := range pattern.FindAllStringSubmatch(release.Title, -1) {
+			if n, ok := parser.ParseChineseNumber(match[1]); ok && n == query.Season {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchTitle 判断发布的标题是否包含搜索的标题或原标题
+func matchTitle(release Release, query Query) bool {
+	name := normalizeTitle(release.Title)
+	return containsTitle(name, query.Title) || containsTitle(name, query.OriginalTitle)
+}
+
+// containsTitle 判断规范化后的发布名称是否包含标题
+func containsTitle(name string, title string) bool {
+	title = normalizeTitle(title)
+	return title != "" && strings.Contains(name, title)
+}
+
+// normalizeTitle 转为小写并去掉空格和标点，"The.Expanse" 与 "The Expanse" 视为相同
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Rank 去掉不符合画质要求（min_quality的最低分辨率和拒绝的片源、acquire的最高分辨率）和已知没有做种的发布，
+// 其余按画质从高到低排序，画质相同时做种多的在前，再按体积从大到小；返回排序后的发布和去掉的数量
+func Rank(cfg *config.Config, releases []Release) ([]Release, int) {
+	var ranked []Release
+	for _, release := range releases {
+		if release.Seeders != 0 && acceptable(cfg, release.Quality) {
+			ranked = append(ranked, release)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if si, sj := ranked[i].Quality.Score(), ranked[j].Quality.Score(); si != sj {
+			return si > sj
+		}
+		if ranked[i].Seeders != ranked[j].Seeders {
+			return ranked[i].Seeders > ranked[j].Seeders
+		}
+		return ranked[i].Size > ranked[j].Size
+	})
+	return ranked, len(releases) - len(ranked)
+}
+
+// acceptable 判断画质是否符合要求，无法识别分辨率的发布不受分辨率限制
+func acceptable(cfg *config.Config, quality classifier.VideoQuality) bool {
+	for _, source := range cfg.MinQuality.RejectSources {
+		if strings.EqualFold(source, quality.Source) {
+			return false
+		}
+	}
+	if quality.Height > 0 {
+		if cfg.MinQuality.MinHeight > 0 && quality.Height < cfg.MinQuality.MinHeight {
+			return false
+		}
+		if cfg.Acquire.MaxHeight > 0 && quality.Height > cfg.Acquire.MaxHeight {
+			return false
+		}
+	}
+	return true
+}
+
+// Search 按标题搜索发布，指定季时只保留标题和季数匹配的整季，并按画质要求排序
+// 返回排序后的发布和因画质要求或没有做种去掉的数量
+func Search(cfg *config.Config, query Query) ([]Release, int, error) {
+	indexer, err := NewIndexer(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	releases, err := indexer.Search(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if query.Season > 0 {
+		var matched []Release
+		for _, release := range releases {
+			if matchSeason(release, query) {
+				matched = append(matched, release)
+			}
+		}
+		releases = matched
+	}
+	ranked, rejected := Rank(cfg, releases)
+	return ranked, rejected, nil
+}
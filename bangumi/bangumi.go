@@ -0,0 +1,222 @@
+package bangumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/config"
+)
+
+// subjectTypeAnime Bangumi条目类型中的动画
+const subjectTypeAnime = 2
+
+// userAgent Bangumi API要求请求带有能识别应用的User-Agent
+const userAgent = "media-manager (https://github.com/user/media-manager)"
+
+// BaseURL Bangumi API的根地址（不以/结尾），为空时使用 https://api.bgm.tv，测试时指向模拟服务器
+var BaseURL string
+
+// HTTPClient 请求Bangumi API使用的HTTP客户端
+var HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// 同一次运行中多个函数请求同一条目时只访问一次Bangumi
+var cache sync.Map // 键为请求路径，值为解析后的结果
+
+// Subject Bangumi条目
+type Subject struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`    // 原名，通常为日文
+	NameCN   string `json:"name_cn"` // 中文名
+	Summary  string `json:"summary"`
+	Date     string `json:"date"`     // 放送开始日期（YYYY-MM-DD）
+	Platform string `json:"platform"` // TV、剧场版、OVA等
+	Tags     []Tag  `json:"tags"`
+}
+
+// Tag 用户为条目标记的标签
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"` // 标记人数
+}
+
+// Episode 条目的一集
+type Episode struct {
+	Ep      float64 `json:"ep"`   // 条目内的集数
+	Sort    float64 `json:"sort"` // 同一系列中的排序，ep为0时使用
+	Name    string  `json:"name"`
+	NameCN  string  `json:"name_cn"`
+	Airdate string  `json:"airdate"`
+}
+
+// Year 返回放送开始年份，没有日期时返回0
+func (s *Subject) Year() int {
+	if len(s.Date) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(s.Date[:4])
+	return year
+}
+
+// TopTags 返回标记人数最多的前limit个标签
+func (s *Subject) TopTags(limit int) []string {
+	tags := append([]Tag(nil), s.Tags...)
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].Count > tags[j].Count })
+	var names []string
+	for _, tag := range tags {
+		if len(names) >= limit {
+			break
+		}
+		if name := strings.TrimSpace(tag.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Number 返回集数
+func (e *Episode) Number() int {
+	if e.Ep > 0 {
+		return int(e.Ep)
+	}
+	return int(e.Sort)
+}
+
+// Title 返回集标题，优先使用中文名
+func (e *Episode) Title() string {
+	if e.NameCN != "" {
+		return e.NameCN
+	}
+	return e.Name
+}
+
+// SearchAnime 按关键词搜索动画条目，year大于0时优先选择放送年份相差不超过1年的条目，没有结果时返回nil
+func SearchAnime(keyword string, year int) (*Subject, error) {
+	key := fmt.Sprintf("search/%s/%d", keyword, year)
+	if cached, ok := cache.Load(key); ok {
+		return cached.(*Subject), nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"keyword": keyword,
+		"filter":  map[string]interface{}{"type": []int{subjectTypeAnime}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []Subject `json:"data"`
+	}
+	if err := request(http.MethodPost, "/v0/search/subjects?limit=10", body, &resp); err != nil {
+		return nil, err
+	}
+
+	var found *Subject
+	for i := range resp.Data {
+		subject := &resp.Data[i]
+		if year == 0 || subject.Year() == 0 {
+			found = subject
+			break
+		}
+		if diff := subject.Year() - year; diff >= -1 && diff <= 1 {
+			found = subject
+			break
+		}
+	}
+	if found != nil {
+		// 搜索结果中的简介和标签不完整，使用条目详情
+		if found, err = GetSubject(found.ID); err != nil {
+			return nil, err
+		}
+	}
+	cache.Store(key, found)
+	return found, nil
+}
+
+// GetSubject 获取条目详情
+func GetSubject(id int) (*Subject, error) {
+	path := fmt.Sprintf("/v0/subjects/%d", id)
+	if cached, ok := cache.Load(path); ok {
+		return cached.(*Subject), nil
+	}
+
+	var subject Subject
+	if err := request(http.MethodGet, path, nil, &subject); err != nil {
+		return nil, err
+	}
+	cache.Store(path, &subject)
+	return &subject, nil
+}
+
+// GetEpisodes 获取条目的本篇剧集列表
+func GetEpisodes(subjectID int) ([]Episode, error) {
+	path := "/v0/episodes?" + url.Values{
+		"subject_id": {strconv.Itoa(subjectID)},
+		"type":       {"0"},
+		"limit":      {"200"},
+	}.Encode()
+	if cached, ok := cache.Load(path); ok {
+		return cached.([]Episode), nil
+	}
+
+	var resp struct {
+		Data []Episode `json:"data"`
+	}
+	if err := request(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	cache.Store(path, resp.Data)
+	return resp.Data, nil
+}
+
+// request 请求Bangumi API并解析JSON响应
+func request(method string, path string, body []byte, result interface{}) error {
+	baseURL := BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.bgm.tv"
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if token := cfg.Bangumi.AccessToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Bangumi API请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Bangumi API返回错误状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析Bangumi API响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/paths"
+)
+
+// 缓存目录中的分类，每类使用一个子目录
+const (
+	KindTMDB       = "tmdb"        // TMDB详情
+	KindTMM        = "tmm"         // tinyMediaManager每次运行的输出
+	KindNFOBackups = "nfo_backups" // 修改NFO文件前保留的历史版本
+)
+
+// Kinds 所有缓存分类
+var Kinds = []string{KindTMDB, KindTMM, KindNFOBackups}
+
+// Dir 返回缓存目录：配置了cache.dir时使用配置的目录（不存在则创建），否则按paths.Dir的顺序查找
+func Dir() (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+	if dir := cfg.Cache.Dir; dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("无法创建缓存目录: %w", err)
+		}
+		return dir, nil
+	}
+	dir, err := paths.Dir(paths.Cache)
+	if err != nil {
+		return "", fmt.Errorf("无法创建缓存目录: %w", err)
+	}
+	return dir, nil
+}
+
+// KindDir 返回缓存分类的子目录，不存在时创建
+func KindDir(kind string) (string, error) {
+	root, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("无法创建缓存目录: %w", err)
+	}
+	return dir, nil
+}
+
+// ValidKind 检查缓存分类名是否有效
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPath 返回键对应的缓存文件，键中的路径分隔符替换为下划线
+func keyPath(kind string, key string) (string, error) {
+	dir, err := KindDir(kind)
+	if err != nil {
+		return "", err
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key)
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Load 读取未超过maxAge的缓存到value，没有缓存、已过期或无法解析时返回false
+func Load(kind string, key string, maxAge time.Duration, value interface{}) bool {
+	path, err := keyPath(kind, key)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > maxAge {
+		return false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(content, value) == nil
+}
+
+// Store 把value以JSON格式保存到缓存
+func Store(kind string, key string, value interface{}) error {
+	path, err := keyPath(kind, key)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("序列化缓存失败: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+	return nil
+}
+
+// Create 在缓存分类的子目录中创建文件，用于保存输出
+func Create(kind string, name string) (*os.File, error) {
+	dir, err := KindDir(kind)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("创建缓存文件失败: %w", err)
+	}
+	return file, nil
+}
+
+// cachedFile 缓存目录中的一个文件
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// listFiles 列出目录下的所有文件
+func listFiles(dir string) []cachedFile {
+	var files []cachedFile
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if !info.IsDir() {
+			files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	return files
+}
+
+// Usage 返回各缓存分类占用的文件数和字节数
+func Usage() (map[string]int, map[string]int64, error) {
+	root, err := Dir()
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make(map[string]int)
+	sizes := make(map[string]int64)
+	for _, kind := range Kinds {
+		for _, file := range listFiles(filepath.Join(root, kind)) {
+			counts[kind]++
+			sizes[kind] += file.size
+		}
+	}
+	return counts, sizes, nil
+}
+
+// Clear 删除缓存分类中的所有文件，kind为空时清除所有分类，返回删除的文件数和字节数
+func Clear(kind string) (int, int64, error) {
+	root, err := Dir()
+	if err != nil {
+		return 0, 0, err
+	}
+	kinds := Kinds
+	if kind != "" {
+		if !ValidKind(kind) {
+			return 0, 0, fmt.Errorf("未知的缓存分类: %s（可用: %s）", kind, strings.Join(Kinds, ", "))
+		}
+		kinds = []string{kind}
+	}
+
+	count, size := 0, int64(0)
+	for _, k := range kinds {
+		dir := filepath.Join(root, k)
+		for _, file := range listFiles(dir) {
+			count++
+			size += file.size
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return count, size, fmt.Errorf("清除缓存失败: %w", err)
+		}
+	}
+	return count, size, nil
+}
+
+// Prune 缓存目录超过maxBytes时从最早修改的文件开始删除，直到不超过上限，返回删除的文件数和字节数
+// maxBytes小于等于0时不限制
+func Prune(maxBytes int64) (int, int64, error) {
+	if maxBytes <= 0 {
+		return 0, 0, nil
+	}
+	root, err := Dir()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var files []cachedFile
+	var total int64
+	for _, kind := range Kinds {
+		for _, file := range listFiles(filepath.Join(root, kind)) {
+			files = append(files, file)
+			total += file.size
+		}
+	}
+	if total <= maxBytes {
+		return 0, 0, nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	count, size := 0, int64(0)
+	for _, file := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			continue
+		}
+		total -= file.size
+		count++
+		size += file.size
+	}
+	return count, size, nil
+}
@@ -0,0 +1,150 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// ffprobeTimeout 单个视频文件ffprobe的超时时间
+const ffprobeTimeout = 30 * time.Second
+
+// ffprobeOutput ffprobe输出的音轨信息
+type ffprobeOutput struct {
+	Streams []struct {
+		Tags struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+	} `json:"streams"`
+}
+
+// AudioLanguages 返回目录中视频文件的音轨语言
+// 能找到ffprobe时读取音轨的语言标记，没有结果时使用目录名和文件名中标注的音轨语言
+func AudioLanguages(mediaDir string) []string {
+	var languages []string
+	if ffprobe := ffprobePath(); ffprobe != "" {
+		utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // 忽略访问错误
+			}
+			if info.IsDir() || !isVideoFile(path) {
+				return nil
+			}
+			probed, err := probeAudioLanguages(ffprobe, path)
+			if err != nil {
+				logging.Warning("读取音轨信息失败: %s: %v", path, err)
+				return nil
+			}
+			languages = appendMissing(languages, probed...)
+			return nil
+		})
+	}
+	if len(languages) > 0 {
+		return languages
+	}
+	return collectReleaseTags(mediaDir).AudioLanguages
+}
+
+// ffprobePath 返回配置的ffprobe路径，没有配置时在PATH中查找，找不到时返回空字符串
+func ffprobePath() string {
+	if cfg, err := config.Load(); err == nil && cfg.FFprobePath != "" {
+		return cfg.FFprobePath
+	}
+	path, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// probeAudioLanguages 通过ffprobe读取视频文件所有音轨的语言
+// 音轨标题中写明国语、粤语时优先使用标题（chi、zho无法区分普通话和粤语）
+func probeAudioLanguages(ffprobe string, videoPath string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream_tags=language,title",
+		"-of", "json",
+		videoPath,
+	).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var result ffprobeOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, err
+	}
+
+	var languages []string
+	for _, stream := range result.Streams {
+		if titled := parser.ParseReleaseTags(stream.Tags.Title).AudioLanguages; len(titled) > 0 {
+			languages = appendMissing(languages, titled...)
+			continue
+		}
+		code := strings.ToLower(stream.Tags.Language)
+		if code == "" || code == "und" {
+			continue
+		}
+		languages = appendMissing(languages, parser.NormalizeAudioLanguage(code))
+	}
+	return languages, nil
+}
+
+// hasAudioLanguage 判断音轨语言列表中是否包含指定语言
+func hasAudioLanguage(languages []string, language string) bool {
+	language = parser.NormalizeAudioLanguage(language)
+	for _, item := range languages {
+		if parser.NormalizeAudioLanguage(item) == language {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMissing 追加列表中还没有的值
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		exists := false
+		for _, item := range list {
+			if item == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// replaceTargetDirectory 将源目录移动到目标目录位置，目标目录原有的内容移动到源目录位置
+// 移动新版本失败时把旧版本移回目标目录
+func replaceTargetDirectory(mediaDir string, targetMediaPath string) error {
+	oldVersionPath := mediaDir + ".old"
+	if err := MoveDirectory(targetMediaPath, oldVersionPath); err != nil {
+		return err
+	}
+
+	if err := MoveDirectory(mediaDir, targetMediaPath); err != nil {
+		if restoreErr := MoveDirectory(oldVersionPath, targetMediaPath); restoreErr != nil {
+			logging.Error("恢复旧版本失败: %v，旧版本位于 %s", restoreErr, oldVersionPath)
+		}
+		return err
+	}
+
+	return MoveDirectory(oldVersionPath, mediaDir)
+}
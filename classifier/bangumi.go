@@ -0,0 +1,149 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/bangumi"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/processor"
+	"github.com/user/media-manager/utils"
+)
+
+// 刮削器在没有集标题时生成的占位标题，如 "Episode 5"、"第5集"
+var placeholderEpisodeTitleRe = regexp.MustCompile(`(?i)^\s*(episode\s*\d+|ep?\s*\d+|第\s*\d+\s*[集话話])\s*$`)
+
+// episodeNFO 单集NFO中需要的字段
+type episodeNFO struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Season  string `xml:"season"`
+	Episode string `xml:"episode"`
+}
+
+// usesBangumi 判断分类是否使用Bangumi补充元数据：开启后动漫分类自动使用，其他分类需要在categories中配置
+func usesBangumi(cfg *config.Config, category string) bool {
+	if !cfg.Bangumi.Enabled {
+		return false
+	}
+	if category == CategoryDmShow || category == CategoryDmMovie {
+		return true
+	}
+	for _, c := range cfg.Bangumi.Categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichFromBangumi 在Bangumi中查找动漫条目，补充NFO的中文标题、简介和标签，电视剧同时补充第1季各集NFO的标题
+// 返回补充后重新解析的NFO，查找失败或没有找到条目时返回原NFO
+func enrichFromBangumi(cfg *config.Config, nfoPath string, nfo *parser.NFO) *parser.NFO {
+	year, _ := strconv.Atoi(strings.TrimSpace(nfo.Year))
+	var subject *bangumi.Subject
+	for _, keyword := range []string{nfo.OriginalTitle, nfo.Title} {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		found, err := bangumi.SearchAnime(keyword, year)
+		if err != nil {
+			logging.Warning("在Bangumi中搜索 '%s' 失败: %v", keyword, err)
+			return nfo
+		}
+		if found != nil {
+			subject = found
+			break
+		}
+	}
+	if subject == nil {
+		logging.Info("Bangumi中没有找到 '%s' (%s)", nfo.Title, nfo.Year)
+		return nfo
+	}
+	logging.Info("Bangumi条目: %s（%s，ID %d）", subject.NameCN, subject.Name, subject.ID)
+
+	doc, err := parser.LoadDocument(nfoPath)
+	if err != nil {
+		logging.Error("加载NFO文件失败: %v", err)
+		return nfo
+	}
+	if _, err := processor.ProcessBangumi(doc, subject, cfg.Bangumi.MaxTags); err != nil {
+		logging.Error("%v", err)
+		return nfo
+	}
+	if _, err := doc.Save(); err != nil {
+		logging.Error("保存NFO文件失败: %v", err)
+		return nfo
+	}
+
+	if nfo.IsTVShow() {
+		episodes, err := bangumi.GetEpisodes(subject.ID)
+		if err != nil {
+			logging.Warning("获取Bangumi剧集列表失败: %v", err)
+		} else if count := applyBangumiEpisodeTitles(filepath.Dir(nfoPath), episodes); count > 0 {
+			logging.Info("使用Bangumi剧集列表补充了 %d 集的标题", count)
+		}
+	}
+	return doc.NFO
+}
+
+// applyBangumiEpisodeTitles 为剧集目录中第1季标题为空、是占位标题或不含中文的单集NFO写入Bangumi的集标题，返回修改的数量
+// Bangumi的一个条目通常只对应一季，其他季不处理
+func applyBangumiEpisodeTitles(mediaDir string, episodes []bangumi.Episode) int {
+	titles := make(map[int]string)
+	for i := range episodes {
+		if title := strings.TrimSpace(episodes[i].Title()); title != "" {
+			titles[episodes[i].Number()] = title
+		}
+	}
+	if len(titles) == 0 {
+		return 0
+	}
+
+	count := 0
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".nfo") {
+			return nil
+		}
+		content, _, err := parser.ReadNFOFile(path)
+		if err != nil || bytes.Count(content, []byte("<episodedetails")) != 1 {
+			return nil // 不是单集NFO，或一个文件包含多集
+		}
+		var episode episodeNFO
+		if err := xml.Unmarshal(content, &episode); err != nil || episode.XMLName.Local != "episodedetails" {
+			return nil
+		}
+		season, _ := strconv.Atoi(strings.TrimSpace(episode.Season))
+		number, err := strconv.Atoi(strings.TrimSpace(episode.Episode))
+		title := titles[number]
+		if season != 1 || err != nil || title == "" || !replaceableEpisodeTitle(episode.Title, title) {
+			return nil
+		}
+
+		updated := parser.SetElements(string(content), "episodedetails", "title", []string{title})
+		if err := parser.WriteNFOFile(path, []byte(updated)); err != nil {
+			logging.Error("写入单集NFO失败: %v", err)
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// replaceableEpisodeTitle 判断原有的集标题是否需要替换：为空、是占位标题，或新标题是中文而原标题不是
+func replaceableEpisodeTitle(current string, title string) bool {
+	current = strings.TrimSpace(current)
+	if current == "" || placeholderEpisodeTitleRe.MatchString(current) {
+		return true
+	}
+	return !utils.IsChinese(current) && utils.IsChinese(title)
+}
@@ -0,0 +1,113 @@
+package classifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// caseInsensitiveDirs 缓存各目录所在文件系统是否不区分大小写
+var caseInsensitiveDirs sync.Map
+
+// IsCaseInsensitiveFS 检测目录所在的文件系统是否不区分大小写（exFAT、NTFS、默认的APFS等）
+// 在目录中创建一个小写名称的临时文件，再检查大写名称是否指向同一个文件；目录不存在或无法写入时按区分大小写处理
+// 模拟运行时不创建文件，改为检查目录名称改变大小写后是否指向同一个目录
+func IsCaseInsensitiveFS(dir string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	if cached, ok := caseInsensitiveDirs.Load(absDir); ok {
+		return cached.(bool)
+	}
+	if DryRun {
+		insensitive := sameDirWithSwappedCase(absDir)
+		caseInsensitiveDirs.Store(absDir, insensitive)
+		return insensitive
+	}
+
+	probe, err := os.CreateTemp(absDir, ".mm-case-probe-")
+	if err != nil {
+		return false
+	}
+	probePath := probe.Name()
+	probe.Close()
+	defer os.Remove(probePath)
+
+	lowerInfo, err := os.Lstat(probePath)
+	if err != nil {
+		return false
+	}
+	upperInfo, err := os.Lstat(filepath.Join(absDir, strings.ToUpper(filepath.Base(probePath))))
+	insensitive := err == nil && os.SameFile(lowerInfo, upperInfo)
+
+	caseInsensitiveDirs.Store(absDir, insensitive)
+	return insensitive
+}
+
+// sameDirWithSwappedCase 检查目录名称改变大小写后是否指向同一个目录，名称中没有字母时按区分大小写处理
+func sameDirWithSwappedCase(absDir string) bool {
+	name := filepath.Base(absDir)
+	swapped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, name)
+	if swapped == name {
+		return false
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return false
+	}
+	swappedInfo, err := os.Stat(filepath.Join(filepath.Dir(absDir), swapped))
+	return err == nil && os.SameFile(info, swappedInfo)
+}
+
+// existingName 返回目录中与name对应的已有条目名称
+// 文件系统不区分大小写时，"season 1"与已有的"Season 1"是同一个目录，返回已有的"Season 1"；否则原样返回name
+func existingName(dir, name string) string {
+	if !IsCaseInsensitiveFS(dir) {
+		return name
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return name
+		}
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return entry.Name()
+		}
+	}
+	return name
+}
+
+// existingPath 返回路径在文件系统中已有的大小写形式，只调整最后一级
+func existingPath(path string) string {
+	dir := filepath.Dir(path)
+	return filepath.Join(dir, existingName(dir, filepath.Base(path)))
+}
+
+// samePath 检查两个路径是否指向同一个文件或目录，不区分大小写的文件系统中大小写不同的路径也视为相同
+func samePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
@@ -1,19 +1,30 @@
 package classifier
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/user/media-manager/config"
 	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/hooks"
 	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/metrics"
 	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/permissions"
+	"github.com/user/media-manager/plugins"
+	"github.com/user/media-manager/policy"
+	"github.com/user/media-manager/processor"
+	"github.com/user/media-manager/safety"
 	"github.com/user/media-manager/tmdb"
 	"github.com/user/media-manager/utils"
 )
@@ -32,20 +43,40 @@ const (
 	CategoryXSShow    = "XSShow"     // 综艺节目
 )
 
-// isProjectDirectory检查目录是否为项目目录
-func isProjectDirectory(dirPath string) bool {
-	// 检查目录是否包含项目标志性文件
-	projectFiles := []string{
-		"go.mod", "main.go", "go.sum", // Go项目
-		"CMakeLists.txt", "Makefile", // C++/C项目
-		"package.json",            // Node.js项目
-		"requirements.txt",        // Python项目
-		"README.md", "README.txt", // 文档文件
-		".git", // Git版本控制
-	}
+// projectFiles 项目目录的标志性文件
+var projectFiles = []string{
+	"go.mod", "main.go", "go.sum", // Go项目
+	"CMakeLists.txt", "Makefile", // C++/C项目
+	"package.json",            // Node.js项目
+	"requirements.txt",        // Python项目
+	"README.md", "README.txt", // 文档文件
+	".git", // Git版本控制
+}
+
+// ProjectMarker 返回目录中找到的第一个项目标志性文件，不是项目目录时返回空字符串
+func ProjectMarker(dirPath string) string {
 	for _, file := range projectFiles {
 		filePath := filepath.Join(dirPath, file)
 		if _, err := os.Stat(filePath); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// IsUnderTempDirs 检查目录是否位于配置的某个Temp目录之下（不含Temp目录本身），也用于检查媒体库目录
+// 只有Temp目录中的影片才允许被移动
+func IsUnderTempDirs(dirPath string, tempDirs []string) bool {
+	absDir, err := filepath.Abs(dirPath)
+	if err != nil {
+		return false
+	}
+	for _, tempDir := range tempDirs {
+		absTemp, err := filepath.Abs(tempDir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(absTemp, absDir); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
 			return true
 		}
 	}
@@ -53,42 +84,47 @@ func isProjectDirectory(dirPath string) bool {
 }
 
 // ClassifyAndMove根据国家/地区和类型分类并移动影片
+// 被门禁规则拒绝或不在本次处理范围内时返回*errs.SkipError（可用errs.IsSkip判断），原因已记录到日志和数据库
+// 分类使用的输入和结果按replay_runs保存到数据库，用于replay离线重现
 func ClassifyAndMove(nfoPath string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	rec := startRecording(cfg, nfoPath)
+	err = classifyAndMove(cfg, nfoPath, rec)
+	if errs.IsSkip(err) {
+		// 跳过的规则已记录在结果中，不作为错误保存
+		rec.save(nil)
+	} else {
+		rec.save(err)
+	}
+	return err
+}
+
+// classifyAndMove 分类并移动影片，rec不为空时记录分类过程中的输入和结果
+func classifyAndMove(cfg *config.Config, nfoPath string, rec *Recording) error {
 	// 解析NFO文件
 	nfo, err := parser.ParseNFO(nfoPath)
 	if err != nil {
 		return fmt.Errorf("分类时解析NFO文件失败: %w", err)
 	}
 
-	// 加载配置
-	cfg := config.LoadConfig()
-
 	// 获取影片目录
 	mediaDir := filepath.Dir(nfoPath)
 	mediaName := filepath.Base(mediaDir)
 
-	// 检查NFO文件所在目录是否有多个NFO文件
-	var nfoCount int
-	entries, err := os.ReadDir(mediaDir)
-	if err != nil {
-		return fmt.Errorf("读取目录失败: %w", err)
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.ToLower(filepath.Ext(entry.Name())) == ".nfo" {
-			nfoCount++
+	// 解析NFO后检查来源目录
+	ruleCtx := &RuleContext{Config: cfg, NFOPath: nfoPath, NFO: nfo, MediaDir: mediaDir}
+	if denial := EvaluateRules(SourceRules, ruleCtx); denial != nil {
+		recordDenial(denial, mediaDir)
+		rec.deny(denial)
+		if denial.Rule == RuleUnresolvedNFO {
+			if err := TrackUnresolvedItem(mediaDir, nfo.IsTVShow(), "NFO信息不完整"); err != nil {
+				logging.Error("跟踪未解决项目失败: %v", err)
+			}
 		}
-	}
-
-	if nfoCount > 1 {
-		logging.Error("目录 %s 下存在 %d 个NFO文件，跳过移动。请手动选择正确的NFO文件后再处理。", mediaDir, nfoCount)
-		return nil // 跳过移动，不返回错误
-	}
-
-	// 检查NFO文件是否包含足够信息
-	if !isNFOResolved(nfo) {
-		logging.Info("NFO文件信息不完整（可能未正确刮削），跳过移动: %s", nfoPath)
-		return nil
+		return denial.Err(mediaDir)
 	}
 
 	// 确定分类
@@ -96,64 +132,255 @@ func ClassifyAndMove(nfoPath string) error {
 
 	// 使用TMDB API获取原始产地信息（如果有TMDbID）
 	countries := nfo.Country
+	originalLanguage := ""
+	spokenLanguages := nfo.GetLanguages()
+	// 记录标题、国家、类型、原始语言和分类的来源，用于以后排查分类原因
+	sources := database.FieldSources{}
+	sources.Set(database.FieldTitle, sourceIf(nfo.Title != "", database.SourceNFO))
+	sources.Set(database.FieldCountry, sourceIf(len(countries) > 0, database.SourceNFO))
+	sources.Set(database.FieldGenres, sourceIf(len(nfo.Genres) > 0, database.SourceNFO))
 	if nfo.TMDbID != "" {
-		cfg := config.LoadConfig()
 		if cfg.TMDBApiKey != "" {
-			// 尝试从TMDB获取制作国家信息
-			tmdbCountries, err := tmdb.GetProductionCountries(nfo.TMDbID, isTVShow)
-			if err != nil {
-				logging.Warning("从TMDB获取制作国家信息失败: %v，将使用NFO文件中的国家信息", err)
+			// 一次请求获取制作国家、原始语言和对白语言
+			details, err := tmdb.GetDetails(nfo.TMDbID, isTVShow)
+			rec.recordTMDB(details, err)
+			if errors.Is(err, tmdb.ErrCachedNotFound) {
+				logging.Debug("%v，将使用NFO文件中的国家和语言信息", err)
+			} else if err != nil {
+				logging.Warning("从TMDB获取详情失败: %v，将使用NFO文件中的国家和语言信息", err)
 			} else {
-				countries = tmdbCountries
+				countries = details.Countries
+				sources.Set(database.FieldCountry, sourceIf(len(countries) > 0, database.SourceTMDB))
 				logging.Info("从TMDB获取到的制作国家: %v", countries)
+
+				originalLanguage = details.OriginalLanguage
+				sources.Set(database.FieldOriginalLanguage, sourceIf(originalLanguage != "", database.SourceTMDB))
+				if len(details.SpokenLanguages) > 0 {
+					spokenLanguages = details.SpokenLanguages
+				}
+				logging.Info("从TMDB获取到的原始语言: %s，对白语言: %v", originalLanguage, spokenLanguages)
 			}
 		}
 	}
 
-	// 检查国家信息是否为空，如果为空则跳过移动
-	if len(countries) == 0 {
-		logging.Warning("没有获取到有效的国家信息，跳过移动: %s", mediaDir)
-		return nil
+	// 使用元数据插件补充缺失的国家和语言信息
+	if len(countries) == 0 || originalLanguage == "" {
+		metadata := plugins.FetchMetadata(&plugins.MetadataRequest{
+			Title:         nfo.Title,
+			OriginalTitle: nfo.OriginalTitle,
+			Year:          nfo.Year,
+			IsTVShow:      isTVShow,
+			TMDbID:        nfo.TMDbID,
+			IMDbID:        nfo.IMDbID,
+			Language:      cfg.TMDBLanguage,
+		})
+		rec.recordMetadata(metadata)
+		if len(countries) == 0 && len(metadata.Countries) > 0 {
+			countries = metadata.Countries
+			sources.Set(database.FieldCountry, database.PluginSource(metadata.Sources["countries"]))
+			logging.Info("从元数据插件获取到的国家: %v", countries)
+		}
+		if originalLanguage == "" && metadata.OriginalLanguage != "" {
+			originalLanguage = metadata.OriginalLanguage
+			sources.Set(database.FieldOriginalLanguage, database.PluginSource(metadata.Sources["original_language"]))
+			logging.Info("从元数据插件获取到的原始语言: %s", originalLanguage)
+		}
 	}
 
-	category, err := DetermineCategory(countries, isTVShow, nfo.Genres)
-	if err != nil {
-		return fmt.Errorf("确定分类失败: %w", err)
+	// 获取元数据后检查，没有国家信息时按missing_country配置处理
+	ruleCtx.Countries = countries
+	if len(countries) == 0 && !nfo.IsMusicVideo() {
+		ruleCtx.CountryFallback = resolveMissingCountry(cfg, originalLanguage, isTVShow)
+	}
+	if denial := EvaluateRules(MetadataRules, ruleCtx); denial != nil {
+		recordDenial(denial, mediaDir)
+		rec.deny(denial)
+		return denial.Err(mediaDir)
+	}
+	fallback := ruleCtx.CountryFallback
+	if fallback != nil && fallback.Quarantine {
+		if rec != nil {
+			rec.Outcome.Category = fallback.Category
+		}
+		return quarantineMissingCountry(mediaDir, isTVShow, fallback, cfg)
 	}
 
-	// 检查是否为项目目录
-	if isProjectDirectory(mediaDir) {
-		logging.Info("跳过移动项目目录: %s", mediaDir)
-		return nil
+	var category string
+	if nfo.IsMusicVideo() {
+		// 音乐视频和演唱会不按国家分类，直接归入音乐分类
+		category = cfg.MusicCategory
+		logging.Info("识别为音乐视频/演唱会，归入分类: %s", category)
+	} else if fallback != nil && fallback.Category != "" {
+		category = fallback.Category
+	} else if fallback != nil {
+		category, err = DetermineCategory(fallback.Countries, isTVShow, nfo.Genres, originalLanguage)
+		if err != nil {
+			return fmt.Errorf("确定分类失败: %w", err)
+		}
+	} else {
+		category, err = DetermineCategory(countries, isTVShow, nfo.Genres, originalLanguage)
+		if err != nil {
+			return fmt.Errorf("确定分类失败: %w", err)
+		}
 	}
 
-	// 检查标题是否为简体中文
-	if !utils.IsSimplifiedChinese(nfo.Title) {
-		logging.Info("标题 '%s' 不是简体中文，跳过移动", nfo.Title)
-		return nil
+	// 分类插件可以覆盖内置规则得出的分类
+	pluginCategory, pluginName := plugins.Classify(&plugins.ClassifyRequest{
+		Title:            nfo.Title,
+		OriginalTitle:    nfo.OriginalTitle,
+		Year:             nfo.Year,
+		IsTVShow:         isTVShow,
+		Countries:        countries,
+		Genres:           nfo.Genres,
+		OriginalLanguage: originalLanguage,
+		TMDbID:           nfo.TMDbID,
+		IMDbID:           nfo.IMDbID,
+		SourcePath:       mediaDir,
+		DefaultCategory:  category,
+	})
+	sources.Set(database.FieldCategory, database.SourceRules)
+	if fallback != nil {
+		sources.Set(database.FieldCategory, database.SourceMissingCountry)
+	}
+	if pluginCategory != "" {
+		logging.Info("分类插件 %s 将分类从 %s 修改为 %s", pluginName, category, pluginCategory)
+		category = pluginCategory
+		sources.Set(database.FieldCategory, database.PluginSource(pluginName))
+	}
+	// classify set或db update手动指定过分类的影片不再按规则和插件重新分类
+	manual := manualCategory(mediaDir, nfo.TMDbID, isTVShow)
+	if manual != "" {
+		if manual != category {
+			logging.Info("'%s' 的分类已手动指定为 %s，不使用 %s", nfo.Title, manual, category)
+		}
+		category = manual
+		sources.Set(database.FieldCategory, database.SourceManual)
+	}
+	if rec != nil {
+		rec.PluginCategory, rec.PluginName = pluginCategory, pluginName
+		rec.ManualCategory = manual
 	}
 
-	// 检查所有类型是否为简体中文
-	for _, genre := range nfo.Genres {
-		if !utils.IsSimplifiedChinese(genre) {
-			logging.Info("类型 '%s' 不是简体中文，跳过移动", genre)
-			return nil
+	// 指定了-only-category时，确定最终分类后再检查一次，TMDB和插件可能改变按NFO预测的分类
+	if !ActiveFilter.MatchCategory(category) {
+		logging.Info("分类 %s 不在本次处理范围（%s）内，跳过: %s", category, ActiveFilter, mediaDir)
+		if rec != nil {
+			rec.Outcome.Category = category
+			rec.Outcome.Note = fmt.Sprintf("%s（不在本次处理范围内，未处理）", category)
 		}
+		return errs.Skip(errs.ErrOutOfScope, mediaDir, fmt.Sprintf("分类 %s 不在本次处理范围（%s）内", category, ActiveFilter))
+	}
+
+	// 动漫使用Bangumi补充中文标题、简介和标签，修改后的标题用于目标目录名和数据库记录
+	if usesBangumi(cfg, category) {
+		title := nfo.Title
+		nfo = enrichFromBangumi(cfg, nfoPath, nfo)
+		if nfo.Title != title {
+			sources.Set(database.FieldTitle, database.SourceBangumi)
+		}
+		ruleCtx.NFO = nfo
+		rec.recordEnrichedNFO(nfoPath)
 	}
 
 	// 目标目录路径
-	targetDir := filepath.Join(cfg.CloudDir, category)
+	targetDir := cfg.CategoryDir(category, isTVShow)
 
 	// 确保目标目录存在
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := mkdirAll(targetDir); err != nil {
 		return fmt.Errorf("创建目标目录失败: %w", err)
 	}
 
-	// 检查目标目录是否已存在同名文件夹
-	targetMediaPath := filepath.Join(targetDir, mediaName)
+	// 检测音轨语言和画质，用于目标目录命名和版本替换
+	audioLanguages := AudioLanguages(mediaDir)
+	quality := DetectVideoQuality(mediaDir)
+	// 目录名中标注的版本（如 导演剪辑版），不同版本作为不同的影片
+	edition := parser.ParseEdition(mediaName)
+
+	// 限制路径长度时，目标目录名的字节数不能超过除去分类目录和为文件预留的长度后剩余的长度
+	nameMaxBytes := 0
+	if cfg.MaxPathBytes > 0 {
+		nameMaxBytes = nameBudget(targetDir, cfg.MaxPathBytes)
+		if nameMaxBytes <= 0 {
+			return fmt.Errorf("%w: 分类目录 '%s' 过长，没有足够的长度用于目标目录名，超过max_path_bytes（%d）", ErrPathTooLong, targetDir, cfg.MaxPathBytes)
+		}
+	}
 
-	// 从文件名中提取分辨率信息 - 在移动前处理
-	resolution := extractResolutionFromFileName(filepath.Base(nfoPath))
+	// 配置了命名模板时按模板生成目标目录名
+	if name := RenderMediaNameWithin(cfg.NamingTemplate, NamingFields{
+		Title:         nfo.Title,
+		OriginalTitle: nfo.OriginalTitle,
+		Year:          nfo.Year,
+		TMDbID:        nfo.TMDbID,
+		Resolution:    quality.Resolution(),
+		HDR:           quality.HDR(),
+		Source:        quality.Source,
+		Audio:         strings.Join(audioLanguages, " "),
+		Edition:       edition,
+	}, nameMaxBytes); name != "" {
+		mediaName = withEdition(name, edition)
+	}
+	if truncated := TruncateName(mediaName, nameMaxBytes); truncated != mediaName {
+		logging.Info("目标目录名超过路径长度限制，缩短为 '%s'", truncated)
+		mediaName = truncated
+	}
+
+	// 不区分大小写的文件系统中使用已有目录的大小写，数据库记录与实际目录保持一致
+	targetMediaPath := existingPath(filepath.Join(targetDir, mediaName))
+	if isTVShow {
+		targetMediaPath = relocatedShowPath(targetDir, targetMediaPath, nfo.TMDbID)
+	}
+	if err := checkPathLength(targetMediaPath, mediaDir, cfg.MaxPathBytes); err != nil {
+		return err
+	}
+
+	// 并发处理时同一目标目录的项目依次检查和移动，避免同时合并同一部剧
+	unlockTarget := lockTarget(targetMediaPath)
+	defer unlockTarget()
+
+	// 移动前检查项目文件、简体中文和目标目录
+	ruleCtx.Category = category
+	ruleCtx.TargetMediaPath = targetMediaPath
+	ruleCtx.AudioLanguages = audioLanguages
+	ruleCtx.Quality = quality
+	if rec != nil {
+		rec.Quality = &quality
+		rec.AudioLanguages = audioLanguages
+		rec.Outcome.Category = category
+		rec.Outcome.TargetPath = targetMediaPath
+	}
+	if denial := EvaluateRules(MoveRules, ruleCtx); denial != nil {
+		recordDenial(denial, mediaDir)
+		rec.deny(denial)
+		if denial.Rule == RuleBelowMinQuality && cfg.MinQuality.Action == config.MinQualityQuarantine {
+			return quarantineItem(mediaDir, isTVShow, cfg)
+		}
+		return denial.Err(mediaDir)
+	}
+
+	// 按标签规则为NFO添加标签 - 在移动前处理
+	if len(cfg.TagRules) > 0 {
+		if err := applyTagRules(nfoPath, cfg.TagRules, processor.TagContext{
+			Category:         category,
+			Countries:        countries,
+			OriginalLanguage: originalLanguage,
+		}); err != nil {
+			logging.Error("%v", err)
+		}
+	}
+
+	// 按各视频文件名中标注的分辨率投票（忽略样片和预告片），都没有标注时使用检测到的画质 - 在移动前处理
+	resolutionVote := voteResolution(mediaDir)
+	resolution := resolutionVote.Resolution
+	if resolution == "" {
+		resolution = quality.Resolution()
+	}
+	if len(resolutionVote.Outliers) > 0 {
+		logging.Warning("'%s' 中有 %d 个视频文件的分辨率与多数文件（%s）不同: %s",
+			filepath.Base(mediaDir), len(resolutionVote.Outliers), resolution, resolutionVote.OutlierSummary())
+	}
+
+	// 从目录名和视频文件名中解析音轨语言、来源平台和画质标签
+	releaseTags := collectReleaseTags(mediaDir)
 
 	// 对于电视剧合并季数的情况，需要先获取现有记录 - 在移动前处理
 	var mediaRecord *database.MediaRecord
@@ -170,28 +397,37 @@ func ClassifyAndMove(nfoPath string) error {
 	// 如果没有现有记录或不是电视剧，创建新记录 - 在移动前处理
 	if mediaRecord == nil {
 		mediaRecord = &database.MediaRecord{
-			FileName:      filepath.Base(nfoPath),
-			Title:         nfo.Title,
-			OriginalTitle: nfo.OriginalTitle,
-			Year:          nfo.Year,
-			Country:       strings.Join(countries, ", "), // 使用获取到的国家信息（可能来自TMDB）
-			Genres:        strings.Join(nfo.Genres, ", "),
-			Actors:        formatActors(nfo.Actors),
-			Category:      category,
-			SourcePath:    mediaDir,
-			TargetPath:    targetMediaPath,
-			ProcessedAt:   time.Now(),
-			Runtime:       nfo.Runtime,
-			Plot:          nfo.Plot,
-			IMDbID:        nfo.IMDbID,
-			TMDbID:        nfo.TMDbID,
-			Season:        nfo.Season,
-			Episode:       nfo.Episode,
-			Director:      nfo.Director,
-			Writer:        nfo.Writer,
-			Rating:        nfo.Rating,
-			Resolution:    resolution,
-			IsComplete:    false, // 默认标记为不完整，后续会更新
+			FileName:         filepath.Base(nfoPath),
+			Title:            nfo.Title,
+			OriginalTitle:    nfo.OriginalTitle,
+			Year:             nfo.Year,
+			Country:          strings.Join(countries, ", "), // 使用获取到的国家信息（可能来自TMDB）
+			Genres:           strings.Join(nfo.Genres, ", "),
+			Actors:           formatActorsOrArtists(nfo),
+			Category:         category,
+			SourcePath:       mediaDir,
+			TargetPath:       targetMediaPath,
+			ProcessedAt:      time.Now(),
+			Runtime:          nfo.Runtime,
+			Plot:             nfo.Plot,
+			IMDbID:           nfo.IMDbID,
+			TMDbID:           nfo.TMDbID,
+			Season:           nfo.SeasonNumber(),
+			Episode:          nfo.EpisodeNumber(),
+			Director:         nfo.Director,
+			Writer:           nfo.Writer,
+			Rating:           nfo.Rating,
+			Resolution:       resolution,
+			IsComplete:       false, // 默认标记为不完整，后续会更新
+			OriginalLanguage: originalLanguage,
+			SpokenLanguages:  strings.Join(spokenLanguages, ","),
+			AudioLanguages:   strings.Join(audioLanguages, ","),
+			HDRFormat:        strings.Join(quality.HDRFormats, ","),
+			ReleaseTags:      strings.Join(append(releaseTags.Sources, releaseTags.Tags...), ","),
+			PlotSource:       nfo.PlotSource(),
+			Edition:          edition,
+			CountryFallback:  fallbackNote(fallback),
+			FieldSources:     sources.String(),
 		}
 	} else {
 		// 更新现有记录的信息 - 在移动前处理
@@ -200,7 +436,7 @@ func ClassifyAndMove(nfoPath string) error {
 		mediaRecord.Year = nfo.Year
 		mediaRecord.Country = strings.Join(countries, ", ")
 		mediaRecord.Genres = strings.Join(nfo.Genres, ", ")
-		mediaRecord.Actors = formatActors(nfo.Actors)
+		mediaRecord.Actors = formatActorsOrArtists(nfo)
 		mediaRecord.Category = category
 		mediaRecord.TargetPath = targetMediaPath
 		mediaRecord.Runtime = nfo.Runtime
@@ -211,107 +447,244 @@ func ClassifyAndMove(nfoPath string) error {
 		mediaRecord.Writer = nfo.Writer
 		mediaRecord.Rating = nfo.Rating
 		mediaRecord.Resolution = resolution
+		mediaRecord.OriginalLanguage = originalLanguage
+		mediaRecord.SpokenLanguages = strings.Join(spokenLanguages, ",")
+		mediaRecord.AudioLanguages = strings.Join(audioLanguages, ",")
+		mediaRecord.HDRFormat = strings.Join(quality.HDRFormats, ",")
+		mediaRecord.ReleaseTags = strings.Join(append(releaseTags.Sources, releaseTags.Tags...), ",")
+		mediaRecord.PlotSource = nfo.PlotSource()
+		mediaRecord.CountryFallback = fallbackNote(fallback)
+		mediaRecord.FieldSources = sources.String()
 	}
 
-	// 目标目录已存在同名文件夹
-	if _, err := os.Stat(targetMediaPath); err == nil {
-		// 只有电视剧才进行季数检测和合并
-		if isTVShow {
-			// 目标目录已存在，检查是否有新的季数
-			hasNew, seasonsToAdd, err := HasNewSeasons(mediaDir, targetMediaPath)
-			if err != nil {
-				logging.Error("检查新季数失败: %v，跳过移动", err)
-				return nil // 跳过移动，但不返回错误
-			}
+	// 钩子脚本收到的项目信息
+	hookItem := &hooks.Item{
+		Title:      nfo.Title,
+		Year:       nfo.Year,
+		IsTVShow:   isTVShow,
+		Category:   category,
+		TMDbID:     nfo.TMDbID,
+		IMDbID:     nfo.IMDbID,
+		NFOPath:    nfoPath,
+		SourcePath: mediaDir,
+		TargetPath: targetMediaPath,
+	}
 
-			if hasNew {
-				// 存在新的季数，允许移动并合并
-				logging.Info("目标目录已存在，但检测到新的季数 %v，将合并到目标目录", seasonsToAdd)
+	// 已在媒体库中且分类正确的项目不移动，只更新数据库记录
+	inPlace := ruleCtx.Adopt && samePath(targetMediaPath, mediaDir)
+	if !inPlace {
+		if err := CheckMounted(cfg, targetMediaPath); err != nil {
+			return err
+		}
+	}
 
-				// 遍历源目录下的所有内容
-				entries, err := os.ReadDir(mediaDir)
-				if err != nil {
-					return fmt.Errorf("读取源目录失败: %w", err)
-				}
+	// 模拟运行时只输出将要执行的操作
+	if DryRun {
+		planMove(cfg, mediaDir, targetMediaPath, inPlace, ruleCtx, mediaRecord)
+		return nil
+	}
+
+	// 整季打包发布的剧集文件平铺在根目录时，移动前整理到各季目录
+	if isTVShow && !inPlace && cfg.SortSeasonPacks {
+		if moved, err := sortSeasonPack(mediaDir, targetMediaPath); err != nil {
+			logging.Error("%v", err)
+		} else if moved > 0 {
+			logging.Info("已将 '%s' 中的 %d 个剧集文件整理到季目录", mediaName, moved)
+		}
+	}
 
-				for _, entry := range entries {
-					srcPath := filepath.Join(mediaDir, entry.Name())
-					dstPath := filepath.Join(targetMediaPath, entry.Name())
-
-					// 检查目标路径是否已存在
-					if _, err := os.Stat(dstPath); os.IsNotExist(err) {
-						// 目标路径不存在，直接移动
-						if entry.IsDir() {
-							if err := MoveDirectory(srcPath, dstPath); err != nil {
-								logging.Error("移动目录失败: %v，跳过该目录", err)
-								continue
-							}
-						} else {
-							if err := os.Rename(srcPath, dstPath); err != nil {
-								logging.Error("移动文件失败: %v，跳过该文件", err)
-								continue
-							}
+	// target_exists规则只对检测到新季的电视剧放行已存在的目标目录，合并新的季
+	if inPlace {
+		logging.Info("'%s' 已在正确的分类目录中，只更新数据库记录", mediaDir)
+	} else if ruleCtx.TargetExists && !ruleCtx.ReplaceTarget {
+		hookItem.Seasons = ruleCtx.NewSeasons
+		if err := hooks.RunItem(cfg.Hooks, hooks.StagePreMove, hookItem); err != nil {
+			return err
+		}
+		releaseMoveSlot := acquireMoveSlot(cfg.Workers(config.ConcurrencyMove), mediaDir)
+		stopMoveTimer := metrics.Start(metrics.OpMove, mediaDir)
+
+		// 遍历源目录下的所有内容
+		entries, err := os.ReadDir(mediaDir)
+		if err != nil {
+			releaseMoveSlot()
+			return fmt.Errorf("读取源目录失败: %w", err)
+		}
+
+		// 移动失败的项目数，有失败时保留源目录，其中的内容还没有移动到媒体库
+		failed := 0
+		for _, entry := range entries {
+			srcPath := filepath.Join(mediaDir, entry.Name())
+			// 目标文件系统不区分大小写时，"season 1"合并到已有的"Season 1"
+			dstPath := filepath.Join(targetMediaPath, existingName(targetMediaPath, entry.Name()))
+
+			// 跳过被忽略标记文件排除的子目录
+			if entry.IsDir() && utils.HasIgnoreMarker(srcPath) {
+				logging.Info("子目录 '%s' 包含忽略标记文件，跳过合并", entry.Name())
+				continue
+			}
+
+			// 检查目标路径是否已存在
+			if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+				// 目标路径不存在，直接移动
+				if entry.Type()&os.ModeSymlink != 0 {
+					if err := MoveSymlink(srcPath, dstPath); err != nil {
+						logging.Error("移动符号链接失败: %v，跳过该链接", err)
+						failed++
+						continue
+					}
+				} else if entry.IsDir() {
+					if err := MoveDirectory(srcPath, dstPath); err != nil {
+						logging.Error("移动目录失败: %v，跳过该目录", err)
+						failed++
+						continue
+					}
+				} else {
+					if err := safety.CheckAll(srcPath, dstPath); err != nil {
+						logging.Error("移动文件失败: %v，跳过该文件", err)
+						failed++
+						continue
+					}
+					if err := os.Rename(srcPath, dstPath); err != nil {
+						logging.Error("移动文件失败: %v，跳过该文件", err)
+						failed++
+						continue
+					}
+				}
+				logging.Info("已将 '%s' 合并到目标目录", entry.Name())
+			} else if !entry.IsDir() && !isVideoFile(entry.Name()) {
+				// 同名的伴随文件（海报、主题曲、tvshow.nfo等）按配置的冲突策略处理
+				if err := mergeCompanionFile(cfg, srcPath, dstPath); err != nil {
+					logging.Error("%v", err)
+				}
+			} else {
+				// 检查是否为季数目录，且季数不在现有目录中
+				seasonNum := GetSeasonNumberFromDirName(entry.Name())
+				if seasonNum > 0 {
+					// 检查该季数是否已存在于目标目录
+					existingSeasons, _ := GetExistingSeasons(targetMediaPath)
+					found := false
+					for _, existingSeason := range existingSeasons {
+						if existingSeason == seasonNum {
+							found = true
+							break
 						}
-						logging.Info("已将 '%s' 合并到目标目录", entry.Name())
-					} else {
-						// 检查是否为季数目录，且季数不在现有目录中
-						seasonNum := GetSeasonNumberFromDirName(entry.Name())
-						if seasonNum > 0 {
-							// 检查该季数是否已存在于目标目录
-							existingSeasons, _ := GetExistingSeasons(targetMediaPath)
-							found := false
-							for _, existingSeason := range existingSeasons {
-								if existingSeason == seasonNum {
-									found = true
-									break
-								}
-							}
-							if !found {
-								// 该季数不存在，允许移动
-								if err := MoveDirectory(srcPath, dstPath); err != nil {
-									logging.Error("移动季数目录失败: %v，跳过该目录", err)
-									continue
-								}
-								logging.Info("已将季数 %d 合并到目标目录", seasonNum)
-							} else {
-								logging.Warning("季数 %d 已存在于目标目录，跳过移动", seasonNum)
-							}
-						} else {
-							logging.Warning("目标目录已存在 '%s'，跳过移动", entry.Name())
+					}
+					if !found {
+						// 该季数不存在，允许移动
+						if err := MoveDirectory(srcPath, dstPath); err != nil {
+							logging.Error("移动季数目录失败: %v，跳过该目录", err)
+							failed++
+							continue
 						}
+						logging.Info("已将季数 %d 合并到目标目录", seasonNum)
+					} else {
+						logging.Warning("季数 %d 已存在于目标目录，跳过移动", seasonNum)
 					}
-				}
-
-				// 删除源目录（如果为空）
-				if err := os.RemoveAll(mediaDir); err != nil {
-					logging.Warning("删除源目录失败: %v", err)
 				} else {
-					logging.Info("已删除空的源目录: %s", mediaDir)
+					logging.Warning("目标目录已存在 '%s'，跳过移动", entry.Name())
 				}
-
-				logging.Info("已将影片 '%s' 的新季数合并到目标目录 '%s'", mediaName, targetDir)
-			} else {
-				logging.Warning("目标目录已存在同名文件夹 '%s'，且没有检测到新的季数，跳过移动", targetMediaPath)
-				return nil // 跳过移动，但不返回错误
 			}
+		}
+
+		// 删除源目录，其中剩下的是目标目录中已有的季和伴随文件，被忽略的子目录保留在原位置
+		if failed > 0 {
+			logging.Warning("有 %d 项没有移动到目标目录，保留源目录: %s", failed, mediaDir)
+		} else if err := safety.Check(mediaDir); err != nil {
+			logging.Warning("删除源目录失败: %v", err)
+		} else if removed, err := removeSourceRemains(mediaDir); err != nil {
+			logging.Warning("删除源目录失败: %v", err)
+		} else if removed {
+			logging.Info("已删除空的源目录: %s", mediaDir)
 		} else {
-			// 电影直接跳过移动
-			logging.Warning("目标目录已存在同名文件夹 '%s'，跳过移动", targetMediaPath)
-			return nil // 跳过移动，但不返回错误
+			logging.Info("源目录中包含忽略标记文件的子目录保留在原位置: %s", mediaDir)
 		}
+		stopMoveTimer()
+		releaseMoveSlot()
+
+		logging.Info("已将影片 '%s' 的新季数合并到目标目录 '%s'", mediaName, targetDir)
 	} else {
 		// 目标目录不存在，直接移动整个文件夹
-		// 移动文件夹
-		if err := MoveDirectory(mediaDir, targetMediaPath); err != nil {
-			return fmt.Errorf("移动影片失败: %w", err)
+		if err := hooks.RunItem(cfg.Hooks, hooks.StagePreMove, hookItem); err != nil {
+			return err
+		}
+
+		releaseMoveSlot := acquireMoveSlot(cfg.Workers(config.ConcurrencyMove), mediaDir)
+		stopMoveTimer := metrics.Start(metrics.OpMove, mediaDir)
+		if ruleCtx.ReplaceTarget {
+			// 用偏好音轨的新版本替换目标目录，旧版本放回源目录位置，由用户确认后删除
+			err := replaceTargetDirectory(mediaDir, targetMediaPath)
+			stopMoveTimer()
+			releaseMoveSlot()
+			if err != nil {
+				return fmt.Errorf("替换影片失败: %w", err)
+			}
+			logging.Info("已用新版本替换 '%s'，旧版本已移动到 '%s'", targetMediaPath, mediaDir)
+		} else {
+			// 移动文件夹
+			err := MoveDirectory(mediaDir, targetMediaPath)
+			stopMoveTimer()
+			releaseMoveSlot()
+			if err != nil {
+				return fmt.Errorf("移动影片失败: %w", err)
+			}
 		}
 
 		logging.Info("已将影片 '%s' 移动到 '%s'", mediaName, targetDir)
 	}
 
+	// 合并的季目录和移动的剧集目录中可能没有tvshow.nfo，生成后媒体服务器才能识别整部剧
+	if isTVShow && !inPlace {
+		if err := ensureShowNFO(cfg, targetMediaPath, nfo); err != nil {
+			logging.Error("%v", err)
+		}
+	}
+
+	// 按配置设置移动后的文件所有者和权限
+	if err := permissions.Apply(cfg.Permissions, targetMediaPath); err != nil {
+		logging.Warning("设置 '%s' 的所有者和权限失败: %v", targetMediaPath, err)
+	}
+
 	// 记录媒体信息到数据库 - 在移动后执行，确保路径正确
 	if err := database.InsertOrUpdateMediaRecord(mediaRecord); err != nil {
 		logging.Error("记录媒体信息到数据库失败: %v", err)
+	} else {
+		recordMoveEvent(mediaRecord.ID, mediaDir, targetMediaPath, category, inPlace, ruleCtx)
+		if len(resolutionVote.Outliers) > 0 {
+			detail := fmt.Sprintf("%d 个文件中多数为 %s，不一致: %s", resolutionVote.Files, resolution, resolutionVote.OutlierSummary())
+			if err := database.RecordEvent(mediaRecord.ID, mediaDir, database.EventResolutionMismatch, detail); err != nil {
+				logging.Error("%v", err)
+			}
+		}
+	}
+
+	if ruleCtx.Adopt {
+		// 记录媒体库内的分类更正
+		if !inPlace {
+			logging.Info("已将媒体库中的 '%s' 从 %s 更正到 %s", nfo.Title, mediaDir, targetMediaPath)
+			if err := database.RecordCorrection(&database.Correction{
+				Title:        nfo.Title,
+				FromPath:     mediaDir,
+				ToPath:       targetMediaPath,
+				FromCategory: filepath.Base(filepath.Dir(mediaDir)),
+				ToCategory:   category,
+			}); err != nil {
+				logging.Error("%v", err)
+			}
+		}
+	} else if err := AddRecentLink(cfg, targetMediaPath); err != nil {
+		// 在最近入库目录中创建链接
+		logging.Error("%v", err)
+	}
+
+	// 之前被规则跳过的记录已不再适用
+	if err := database.ClearSkip(mediaDir); err != nil {
+		logging.Error("%v", err)
+	}
+
+	// 如果之前被记录为问题项目，标记为已解决
+	if err := database.UpdateProblemItemStatus(problemItemPath(mediaDir), database.ProblemStatusResolved); err != nil {
+		logging.Error("更新问题项目状态失败: %v", err)
 	}
 
 	// 如果是电视剧，检测缺失的季和剧集 - 在移动后执行，确保路径正确
@@ -321,6 +694,13 @@ func ClassifyAndMove(nfoPath string) error {
 		}
 	}
 
+	// 如果是电影，检测所属系列中缺失的电影
+	if !isTVShow && !nfo.IsMusicVideo() && nfo.TMDbID != "" && cfg.TMDBApiKey != "" {
+		if err := DetectMissingCollectionMovies(mediaRecord); err != nil {
+			logging.Error("检测系列缺失电影失败: %v", err)
+		}
+	}
+
 	// 如果是电视剧，检查并报告季数状态 - 在移动后执行，确保路径正确
 	if isTVShow && nfo.TMDbID != "" {
 		if err := ReportSeasonStatus(nfo.Title, nfo.TMDbID, targetMediaPath); err != nil {
@@ -328,7 +708,48 @@ func ClassifyAndMove(nfoPath string) error {
 		}
 	}
 
-	return nil
+	// 执行该分类配置的移动后处理策略
+	if policies := cfg.CategoryPolicies[category]; len(policies) > 0 {
+		policy.Apply(policies, category, targetMediaPath)
+	}
+
+	plugins.Notify(plugins.EventMoved, hookItem)
+
+	// 移动完成后执行post_move钩子
+	return hooks.RunItem(cfg.Hooks, hooks.StagePostMove, hookItem)
+}
+
+// recordMoveEvent 将处理前记录的事件关联到媒体记录，并记录本次移动、合并新季、替换版本或确认目录的事件
+func recordMoveEvent(mediaID int, mediaDir, targetMediaPath, category string, inPlace bool, ruleCtx *RuleContext) {
+	if err := database.AttachEvents(mediaDir, mediaID); err != nil {
+		logging.Error("%v", err)
+	}
+
+	event := database.EventMoved
+	detail := fmt.Sprintf("%s: %s → %s", category, mediaDir, targetMediaPath)
+	switch {
+	case inPlace:
+		event = database.EventVerified
+		detail = fmt.Sprintf("%s: 已在正确的分类目录中 %s", category, targetMediaPath)
+	case ruleCtx.ReplaceTarget:
+		event = database.EventUpgraded
+		detail = fmt.Sprintf("%s: 替换 %s，旧版本移动到 %s", category, targetMediaPath, mediaDir)
+	case ruleCtx.TargetExists:
+		event = database.EventSeasonAdded
+		detail = fmt.Sprintf("%s: 第 %s 季合并到 %s", category, formatSeasons(ruleCtx.NewSeasons), targetMediaPath)
+	}
+	if err := database.RecordEvent(mediaID, mediaDir, event, detail); err != nil {
+		logging.Error("%v", err)
+	}
+}
+
+// formatSeasons 将季号格式化为 "1、2"
+func formatSeasons(seasons []int) string {
+	parts := make([]string, len(seasons))
+	for i, season := range seasons {
+		parts[i] = strconv.Itoa(season)
+	}
+	return strings.Join(parts, "、")
 }
 
 // isNFOResolved 检查NFO文件是否包含足够信息（是否已正确刮削）
@@ -341,6 +762,7 @@ func isNFOResolved(nfo *parser.NFO) bool {
 	// 检查关键信息（至少有一个即可认为已刮削）
 	if len(nfo.Genres) > 0 ||
 		len(nfo.Country) > 0 ||
+		len(nfo.Artists) > 0 ||
 		nfo.IMDbID != "" ||
 		nfo.TMDbID != "" ||
 		nfo.Plot != "" ||
@@ -357,8 +779,9 @@ func isNFOResolved(nfo *parser.NFO) bool {
 	return false
 }
 
-// DetermineCategory根据国家/地区、类型和 genres 确定分类
-func DetermineCategory(countries []string, isTVShow bool, genres []string) (string, error) {
+// DetermineCategory根据国家/地区、类型、genres 和原始语言确定分类
+// 原始语言为空时只根据国家/地区判断
+func DetermineCategory(countries []string, isTVShow bool, genres []string, originalLanguage string) (string, error) {
 	// 检查是否为纪录片
 	for _, genre := range genres {
 		if strings.Contains(strings.ToLower(genre), "纪录片") || strings.Contains(strings.ToLower(genre), "documentary") {
@@ -406,6 +829,9 @@ func DetermineCategory(countries []string, isTVShow bool, genres []string) (stri
 		return CategoryDmMovie, nil
 	}
 
+	// 多国合拍时，优先选择与原始语言对应的国家/地区
+	countries = preferLanguageCountry(countries, originalLanguage)
+
 	// 处理多国家情况：按照国家顺序优先判断第一个国家
 	for _, country := range countries {
 		countryLower := strings.ToLower(country)
@@ -454,54 +880,221 @@ func DetermineCategory(countries []string, isTVShow bool, genres []string) (stri
 	return "", fmt.Errorf("没有有效的国家信息")
 }
 
+// languageCountryKeywords 原始语言对应的国家/地区关键词
+var languageCountryKeywords = map[string][]string{
+	"zh":  {"中国", "香港", "台湾", "china", "hong kong", "taiwan"},
+	"cn":  {"中国", "香港", "台湾", "china", "hong kong", "taiwan"},
+	"yue": {"香港", "中国", "hong kong", "china"},
+	"ja":  {"日本", "japan"},
+	"ko":  {"韩国", "korea"},
+}
+
+// preferLanguageCountry 将与原始语言对应的国家/地区移动到列表首位
+// 例如美国和中国合拍的国语片，原始语言为zh时按中国处理
+func preferLanguageCountry(countries []string, originalLanguage string) []string {
+	keywords, ok := languageCountryKeywords[strings.ToLower(originalLanguage)]
+	if !ok || len(countries) < 2 {
+		return countries
+	}
+
+	for i, country := range countries {
+		countryLower := strings.ToLower(country)
+		for _, keyword := range keywords {
+			if strings.Contains(countryLower, keyword) {
+				if i == 0 {
+					return countries
+				}
+				reordered := append([]string{country}, countries[:i]...)
+				return append(reordered, countries[i+1:]...)
+			}
+		}
+	}
+
+	return countries
+}
+
 // MoveDirectory处理目录移动，支持跨设备移动
 func MoveDirectory(src, dst string) error {
+	if err := safety.CheckAll(src, dst); err != nil {
+		return err
+	}
+	if DryRun {
+		logging.Info("[模拟] 将移动 '%s' → '%s'", src, dst)
+		return nil
+	}
+
+	// 包含被忽略标记文件排除的子目录时逐项移动，被排除的子目录保留在源目录中
+	if utils.HasIgnoredSubtree(src) {
+		return moveEntriesExceptIgnored(src, dst)
+	}
+
 	// 首先尝试使用os.Rename，如果成功则直接返回
 	if err := os.Rename(src, dst); err == nil {
 		return nil
+	} else if errors.Is(err, syscall.EROFS) {
+		// 目标文件系统只读时复制同样会失败，不再尝试
+		return readOnlyError(filepath.Dir(dst), err)
 	} else if !os.IsNotExist(err) {
 		// 如果不是因为文件不存在而失败，可能是跨设备移动
-		// 此时需要复制目录然后删除源目录
+		// 此时先复制整个目录树，全部复制成功后才删除源目录
 		fmt.Printf("跨设备移动，使用复制模式: %s -> %s\n", src, dst)
 
-		// 创建目标目录
-		if err := os.MkdirAll(dst, 0755); err != nil {
+		// 复制失败时只删除本次复制创建的文件和目录，源目录保持完整，不在媒体库中留下只复制了一部分的影片
+		var created []string
+		if err := copyForMove(src, dst, &created); err != nil {
+			for i := len(created) - 1; i >= 0; i-- {
+				os.RemoveAll(created[i])
+			}
+			if errors.Is(err, syscall.EROFS) {
+				return readOnlyError(filepath.Dir(dst), err)
+			}
 			return err
 		}
 
-		// 遍历源目录并复制所有文件和子目录
-		entries, err := os.ReadDir(src)
+		// 复制完成后删除源目录
+		return os.RemoveAll(src)
+	}
+
+	// 如果是因为文件不存在而失败，返回错误
+	return fmt.Errorf("源目录不存在: %s", src)
+}
+
+// moveEntriesExceptIgnored 逐项移动目录中没有被忽略标记文件排除的内容，被排除的子目录及其上级目录保留在源位置
+func moveEntriesExceptIgnored(src, dst string) error {
+	if utils.HasIgnoreMarker(src) {
+		logging.Info("目录 '%s' 包含忽略标记文件，保留在原位置", src)
+		return nil
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		if errors.Is(err, syscall.EROFS) {
+			return readOnlyError(filepath.Dir(dst), err)
+		}
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			err = MoveSymlink(srcPath, dstPath)
+		case entry.IsDir():
+			err = MoveDirectory(srcPath, dstPath)
+		default:
+			err = moveFile(srcPath, dstPath)
+		}
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		for _, entry := range entries {
-			srcPath := filepath.Join(src, entry.Name())
-			dstPath := filepath.Join(dst, entry.Name())
+// moveFile 移动单个文件，跨设备时复制后删除源文件
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, syscall.EROFS) {
+		return readOnlyError(filepath.Dir(dst), err)
+	}
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		if errors.Is(err, syscall.EROFS) {
+			return readOnlyError(filepath.Dir(dst), err)
+		}
+		return err
+	}
+	return os.Remove(src)
+}
 
-			if entry.IsDir() {
-				// 递归复制子目录
-				if err := MoveDirectory(srcPath, dstPath); err != nil {
-					return err
-				}
-			} else {
-				// 复制文件
-				if err := copyFile(srcPath, dstPath); err != nil {
-					return err
+// removeSourceRemains 删除合并后源目录中剩下的内容（目标目录中已有的季和伴随文件）
+// 包含忽略标记文件的子目录保留在原位置，没有需要保留的内容时删除整个源目录，返回是否删除了源目录
+func removeSourceRemains(dir string) (bool, error) {
+	if !utils.HasIgnoredSubtree(dir) {
+		return true, os.RemoveAll(dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() && utils.HasIgnoredSubtree(path) {
+			if !utils.HasIgnoreMarker(path) {
+				if _, err := removeSourceRemains(path); err != nil {
+					return false, err
 				}
 			}
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return false, err
 		}
+	}
+	return false, nil
+}
 
-		// 复制完成后删除源目录
-		if err := os.RemoveAll(src); err != nil {
+// copyForMove 跨设备移动时复制目录树，不修改源目录；created按创建顺序记录新建的文件和目录，用于复制失败时回滚
+// 符号链接按symlinks.move配置复制链接本身或其指向的内容，skip时不复制
+func copyForMove(src, dst string, created *[]string) error {
+	if err := safety.CheckAll(src, dst); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		if err := os.Mkdir(dst, 0755); err != nil {
 			return err
 		}
-
-		return nil
+		*created = append(*created, dst)
+	} else if err != nil {
+		return err
 	}
 
-	// 如果是因为文件不存在而失败，返回错误
-	return fmt.Errorf("源目录不存在: %s", src)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		_, statErr := os.Lstat(dstPath)
+		isNew := os.IsNotExist(statErr)
+
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			copied, err := copySymlink(srcPath, dstPath)
+			if err != nil {
+				if isNew {
+					os.RemoveAll(dstPath)
+				}
+				return err
+			}
+			if !copied || !isNew {
+				continue
+			}
+		case entry.IsDir():
+			if err := copyForMove(srcPath, dstPath, created); err != nil {
+				return err
+			}
+			continue
+		default:
+			if err := copyFile(srcPath, dstPath); err != nil {
+				if isNew {
+					os.Remove(dstPath)
+				}
+				return err
+			}
+			if !isNew {
+				continue
+			}
+		}
+		*created = append(*created, dstPath)
+	}
+	return nil
 }
 
 // copyFile复制单个文件
@@ -543,23 +1136,39 @@ func formatActors(actors []parser.Actor) string {
 	return strings.Join(names, ", ")
 }
 
+// sourceIf 字段有值时返回来源，没有值时返回空字符串（不记录来源）
+func sourceIf(present bool, source string) string {
+	if !present {
+		return ""
+	}
+	return source
+}
+
+// formatActorsOrArtists格式化演员列表，音乐视频没有演员时使用艺术家
+func formatActorsOrArtists(nfo *parser.NFO) string {
+	if len(nfo.Actors) == 0 && nfo.IsMusicVideo() {
+		return strings.Join(nfo.Artists, ", ")
+	}
+	return formatActors(nfo.Actors)
+}
+
 // GetSeasonNumberFromDirName 从目录名中提取季数
 func GetSeasonNumberFromDirName(dirName string) int {
-	// 支持多种季数格式，如 "Season 1", "第1季", "S01", "S1"
+	// 支持多种季数格式，如 "Season 1", "第1季", "第十二季", "S01", "S1"
 	var seasonNumber int
 
 	// 正则表达式匹配季数
 	regexPatterns := []string{
 		`(?i)season\s*(\d+)`, // Season 1, season 2
-		`(?i)第(\d+)季`,        // 第1季, 第2季
-		`(?i)S(\d+)`,         // S01, S1
+		`(?i)第\s*(` + parser.ChineseNumberPattern + `)\s*季`, // 第1季, 第二季, 第十二季
+		`(?i)S(\d+)`, // S01, S1
 	}
 
 	for _, pattern := range regexPatterns {
 		re := regexp.MustCompile(pattern)
 		matches := re.FindStringSubmatch(dirName)
 		if len(matches) > 1 {
-			if n, err := strconv.Atoi(matches[1]); err == nil {
+			if n, ok := parser.ParseChineseNumber(matches[1]); ok {
 				seasonNumber = n
 				break
 			}
@@ -585,7 +1194,7 @@ func GetExistingSeasons(targetMediaPath string) ([]int, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if isDirEntry(targetMediaPath, entry) {
 			seasonNumber := GetSeasonNumberFromDirName(entry.Name())
 			if seasonNumber > 0 {
 				existingSeasons = append(existingSeasons, seasonNumber)
@@ -597,7 +1206,13 @@ func GetExistingSeasons(targetMediaPath string) ([]int, error) {
 }
 
 // GetNewSeasons 获取源目录中包含的季数
-func GetNewSeasons(mediaDir string) ([]int, error) {
+// 开启动漫模式时，没有季数目录的字幕组发布会根据绝对集数推算季数
+func GetNewSeasons(mediaDir string, tmdbID string) ([]int, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
 	var newSeasons []int
 
 	// 遍历源目录下的子目录
@@ -607,7 +1222,7 @@ func GetNewSeasons(mediaDir string) ([]int, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if isDirEntry(mediaDir, entry) {
 			seasonNumber := GetSeasonNumberFromDirName(entry.Name())
 			if seasonNumber > 0 {
 				newSeasons = append(newSeasons, seasonNumber)
@@ -615,6 +1230,15 @@ func GetNewSeasons(mediaDir string) ([]int, error) {
 		}
 	}
 
+	// 整季打包发布时剧集文件平铺在根目录，移动时会整理到对应的季目录
+	if cfg.SortSeasonPacks {
+		for _, season := range looseEpisodeSeasons(mediaDir) {
+			if !containsSeason(newSeasons, season) {
+				newSeasons = append(newSeasons, season)
+			}
+		}
+	}
+
 	// 如果源目录下没有季数子目录，检查当前目录的季数
 	if len(newSeasons) == 0 {
 		seasonNumber := GetSeasonNumberFromDirName(filepath.Base(mediaDir))
@@ -623,11 +1247,102 @@ func GetNewSeasons(mediaDir string) ([]int, error) {
 		}
 	}
 
+	// 仍然没有识别到季数时，尝试按字幕组命名解析
+	if len(newSeasons) == 0 && cfg.AnimeMode {
+		animeSeasons, err := GetAnimeSeasons(mediaDir, tmdbID)
+		if err != nil {
+			logging.Warning("解析字幕组命名失败: %v", err)
+		} else {
+			newSeasons = animeSeasons
+		}
+	}
+
 	return newSeasons, nil
 }
 
+// videoExtensions 常见的视频文件扩展名
+var videoExtensions = []string{".mkv", ".mp4", ".avi", ".wmv", ".flv", ".mov", ".rmvb", ".ts"}
+
+// isVideoFile 根据扩展名判断是否为视频文件
+func isVideoFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, videoExt := range videoExtensions {
+		if ext == videoExt {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAnimeSeasons 解析目录名和视频文件名中的字幕组命名，返回包含的季数
+// 名称中写明季数时直接使用，否则把绝对集数通过TMDB剧集组映射到季数
+func GetAnimeSeasons(mediaDir string, tmdbID string) ([]int, error) {
+	entries, err := os.ReadDir(mediaDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取源目录失败: %w", err)
+	}
+
+	var releases []*parser.AnimeRelease
+	if release, ok := parser.ParseAnimeRelease(filepath.Base(mediaDir)); ok {
+		releases = append(releases, release)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isVideoFile(entry.Name()) {
+			continue
+		}
+		if release, ok := parser.ParseAnimeRelease(entry.Name()); ok {
+			releases = append(releases, release)
+		}
+	}
+
+	if len(releases) == 0 {
+		return nil, nil
+	}
+
+	// 按需获取绝对集数到季数的映射
+	var absoluteOrder []tmdb.EpisodeRef
+	orderLoaded := false
+	seasonOf := func(absolute int) int {
+		if !orderLoaded {
+			orderLoaded = true
+			if tmdbID != "" {
+				order, err := tmdb.GetAbsoluteEpisodeOrder(tmdbID)
+				if err != nil {
+					logging.Warning("获取TMDB绝对集数映射失败: %v，按第1季处理", err)
+				} else {
+					absoluteOrder = order
+				}
+			}
+		}
+		if absolute >= 1 && absolute <= len(absoluteOrder) {
+			return absoluteOrder[absolute-1].Season
+		}
+		return 1
+	}
+
+	seasonSet := make(map[int]bool)
+	for _, release := range releases {
+		if release.Season > 0 {
+			seasonSet[release.Season] = true
+			continue
+		}
+		for episode := release.EpisodeStart; episode <= release.EpisodeEnd; episode++ {
+			seasonSet[seasonOf(episode)] = true
+		}
+	}
+
+	var seasons []int
+	for season := range seasonSet {
+		seasons = append(seasons, season)
+	}
+	sort.Ints(seasons)
+
+	logging.Info("根据字幕组命名识别到季数: %v", seasons)
+	return seasons, nil
+}
+
 // HasNewSeasons 检查源目录中是否包含目标目录中不存在的季数
-func HasNewSeasons(mediaDir string, targetMediaPath string) (bool, []int, error) {
+func HasNewSeasons(mediaDir string, targetMediaPath string, tmdbID string) (bool, []int, error) {
 	// 获取目标目录中已存在的季数
 	existingSeasons, err := GetExistingSeasons(targetMediaPath)
 	if err != nil {
@@ -635,7 +1350,7 @@ func HasNewSeasons(mediaDir string, targetMediaPath string) (bool, []int, error)
 	}
 
 	// 获取源目录中包含的季数
-	newSeasons, err := GetNewSeasons(mediaDir)
+	newSeasons, err := GetNewSeasons(mediaDir, tmdbID)
 	if err != nil {
 		return false, nil, err
 	}
@@ -684,24 +1399,19 @@ func checkSeasonCompleteness(tmdbID string, existingSeasons []int) (bool, []int,
 	return len(missingSeasons) == 0, missingSeasons, totalSeasons, nil
 }
 
-// extractResolutionFromFileName 从文件名中提取分辨率信息
-func extractResolutionFromFileName(fileName string) string {
-	// 支持的分辨率格式：1080p, 720p, 4K, 2160p, 1440p等
-	resolutionPatterns := []string{
-		`(?i)(\d{3,4}p)`, // 1080p, 720p, 2160p, 1440p
-		`(?i)(\d{3,4}i)`, // 1080i, 720i
-		`(?i)(4k|8k)`,    // 4K, 8K
-	}
-
-	for _, pattern := range resolutionPatterns {
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(fileName)
-		if len(matches) > 1 {
-			return strings.ToUpper(matches[1])
+// collectReleaseTags 合并目录名和目录中所有视频文件名里的发布标签
+func collectReleaseTags(mediaDir string) parser.ReleaseTags {
+	tags := parser.ParseReleaseTags(filepath.Base(mediaDir))
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
 		}
-	}
-
-	return ""
+		if !info.IsDir() && isVideoFile(path) {
+			tags.Merge(parser.ParseReleaseTags(info.Name()))
+		}
+		return nil
+	})
+	return tags
 }
 
 // DetectMissingSeasonsAndEpisodes 检测缺失的季和剧集（公共函数）
@@ -722,6 +1432,21 @@ func DetectMissingSeasonsAndEpisodes(mediaRecord *database.MediaRecord) error {
 		return fmt.Errorf("获取已存在季数失败: %w", err)
 	}
 
+	// 之前记录为缺失、现在已经存在的季标记为已获取
+	previouslyMissing, err := database.GetMissingSeasons(map[string]interface{}{"tmdb_id": mediaRecord.TMDbID})
+	if err != nil {
+		logging.Error("获取缺失季记录失败: %v", err)
+	}
+	for _, row := range previouslyMissing {
+		if containsSeason(existingSeasons, row.Season) {
+			if err := database.UpdateMissingItemStatus("missing_seasons", row.ID, database.MissingStatusFound); err != nil {
+				logging.Error("更新缺失季状态失败: %v", err)
+			} else {
+				logging.Info("'%s' 第 %d 季已获取，标记为已获取", row.Title, row.Season)
+			}
+		}
+	}
+
 	// 记录缺失的季数
 	for i := 1; i <= totalSeasons; i++ {
 		found := false
@@ -767,8 +1492,8 @@ func getExistingTVShowRecord(title, year string) (*database.MediaRecord, error)
 	}
 
 	for _, record := range mediaRecords {
-		// 检查是否为电视剧且标题和年份匹配
-		if strings.Contains(record.Category, "Show") && record.Title == title && record.Year == year {
+		// 检查是否为电视剧且标题和年份匹配，标题按规范化后比较，年份允许相差record_year_tolerance年
+		if strings.Contains(record.Category, "Show") && utils.SameTitle(record.Title, title) && database.YearsMatch(record.Year, year) {
 			return &record, nil
 		}
 	}
@@ -803,3 +1528,18 @@ func ReportSeasonStatus(title string, tmdbID string, targetMediaPath string) err
 
 	return nil
 }
+
+// applyTagRules 按标签规则为NFO文件添加<tag>元素并写回文件
+func applyTagRules(nfoPath string, rules []config.TagRule, ctx processor.TagContext) error {
+	doc, err := parser.LoadDocument(nfoPath)
+	if err != nil {
+		return fmt.Errorf("加载NFO文件失败: %w", err)
+	}
+	if _, err := processor.ProcessTags(doc, rules, ctx); err != nil {
+		return err
+	}
+	if _, err := doc.Save(); err != nil {
+		return fmt.Errorf("保存NFO文件失败: %w", err)
+	}
+	return nil
+}
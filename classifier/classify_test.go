@@ -0,0 +1,168 @@
+package classifier
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/internal/testkit"
+)
+
+func TestClassifyAndMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmdb     testkit.TMDBItem
+		movie    testkit.Movie
+		category string
+	}{
+		{
+			name:     "中国大陆电影",
+			tmdb:     testkit.TMDBItem{ID: 535167, Title: "流浪地球", ReleaseDate: "2019-02-05", Countries: []string{"CN"}, OriginalLanguage: "zh"},
+			movie:    testkit.Movie{Title: "流浪地球", Year: "2019", TMDbID: "535167", Countries: []string{"中国大陆"}, Genres: []string{"科幻"}},
+			category: "CnMovie",
+		},
+		{
+			name:     "美国电影",
+			tmdb:     testkit.TMDBItem{ID: 157336, Title: "星际穿越", ReleaseDate: "2014-11-07", Countries: []string{"US"}, OriginalLanguage: "en"},
+			movie:    testkit.Movie{Title: "星际穿越", Year: "2014", TMDbID: "157336", Countries: []string{"美国"}, Genres: []string{"科幻"}},
+			category: "EnMovie",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := testkit.New(t)
+			env.TMDB.AddMovie(tt.tmdb)
+			nfoPath, err := env.AddMovie(tt.movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ClassifyAndMove(nfoPath); err != nil {
+				t.Fatal(err)
+			}
+			dir := tt.movie.Title + " (" + tt.movie.Year + ")"
+			target := env.CloudPath(tt.category, dir)
+			if !testkit.Exists(target) || testkit.Exists(env.TempPath("Movie", dir)) {
+				t.Fatalf("应移动到 %s", target)
+			}
+
+			records, err := database.GetMediaRecords(map[string]interface{}{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 || records[0].Category != tt.category || records[0].TargetPath != target {
+				t.Errorf("数据库记录 = %+v，应为分类 %s、目标路径 %s", records, tt.category, target)
+			}
+		})
+	}
+}
+
+func TestClassifyAndMoveSkipsNonChineseTitle(t *testing.T) {
+	env := testkit.New(t)
+	env.TMDB.AddMovie(testkit.TMDBItem{ID: 157336, Title: "Interstellar", ReleaseDate: "2014-11-07", Countries: []string{"US"}, OriginalLanguage: "en"})
+	nfoPath, err := env.AddMovie(testkit.Movie{Title: "Interstellar", Year: "2014", TMDbID: "157336", Countries: []string{"美国"}, Genres: []string{"科幻"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ClassifyAndMove(nfoPath)
+	if !errs.IsSkip(err) || !errors.Is(err, errs.ErrNonChineseTitle) {
+		t.Fatalf("ClassifyAndMove() = %v，应因标题不是简体中文跳过", err)
+	}
+	if !testkit.Exists(env.TempPath("Movie", "Interstellar (2014)")) {
+		t.Error("跳过的影片应留在Temp目录")
+	}
+}
+
+func TestClassifyAndMoveMergesNewSeason(t *testing.T) {
+	env := testkit.New(t)
+	env.TMDB.AddTVShow(testkit.TMDBItem{ID: 108545, Title: "三体", ReleaseDate: "2023-01-15", Countries: []string{"CN"}, OriginalLanguage: "zh", Seasons: map[int]int{1: 2, 2: 2}})
+	show := testkit.Show{Title: "三体", Year: "2023", TMDbID: "108545", Countries: []string{"中国大陆"}, Genres: []string{"剧情"}}
+
+	// 第一季移动到媒体库
+	show.Seasons = map[int]int{1: 2}
+	nfoPath, err := env.AddShow(show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ClassifyAndMove(nfoPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二季合并到已有的目录
+	show.Seasons = map[int]int{2: 2}
+	if nfoPath, err = env.AddShow(show); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClassifyAndMove(nfoPath); err != nil {
+		t.Fatal(err)
+	}
+
+	target := env.CloudPath("CnShow", "三体 (2023)")
+	for _, episode := range []string{"Season 01/S01E01.mkv", "Season 01/S01E02.mkv", "Season 02/S02E01.mkv", "Season 02/S02E02.mkv"} {
+		if !testkit.Exists(filepath.Join(target, episode)) {
+			t.Errorf("媒体库中缺少 %s", episode)
+		}
+	}
+	if testkit.Exists(env.TempPath("TvShow", "三体 (2023)")) {
+		t.Error("合并后应删除Temp中的源目录")
+	}
+
+	// 已有的季不再合并
+	show.Seasons = map[int]int{2: 2}
+	if nfoPath, err = env.AddShow(show); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClassifyAndMove(nfoPath); !errors.Is(err, errs.Lookup(RuleTargetExists)) {
+		t.Errorf("ClassifyAndMove() = %v，没有新的季时应跳过", err)
+	}
+}
+
+func TestClassifyAndMoveTruncatesSeasonsToSameName(t *testing.T) {
+	env := testkit.New(t)
+	title := strings.Repeat("很长的标题", 8)
+	env.TMDB.AddTVShow(testkit.TMDBItem{ID: 108545, Title: title, ReleaseDate: "2023-01-15", Countries: []string{"CN"}, OriginalLanguage: "zh", Seasons: map[int]int{1: 1, 2: 1}})
+	show := testkit.Show{Title: title, Year: "2023", TMDbID: "108545", Countries: []string{"中国大陆"}, Genres: []string{"剧情"}}
+	// 目标目录名需要缩短，两季中最长的文件路径不同
+	env.Config.MaxPathBytes = len(env.CloudPath("CnShow")) + 180
+
+	show.Seasons = map[int]int{1: 1}
+	nfoPath, err := env.AddShow(show)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ClassifyAndMove(nfoPath); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(env.CloudPath("CnShow"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("CnShow中的目录 = %v, %v，应只有一个", entries, err)
+	}
+	target := env.CloudPath("CnShow", entries[0].Name())
+	// 删除tvshow.nfo后不能按TMDB ID找到已有目录，只能按目录名合并
+	if err := os.Remove(filepath.Join(target, "tvshow.nfo")); err != nil {
+		t.Fatal(err)
+	}
+
+	show.Seasons = map[int]int{2: 1}
+	if nfoPath, err = env.AddShow(show); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.Touch(filepath.Join(filepath.Dir(nfoPath), "Season 02", "S02E01.2023.2160p.WEB-DL.H265.DDP5.1.chs.ass")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClassifyAndMove(nfoPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, err := os.ReadDir(env.CloudPath("CnShow")); err != nil || len(entries) != 1 {
+		t.Errorf("CnShow中的目录 = %v, %v，第二季应合并到同一目录", entries, err)
+	}
+	if !testkit.Exists(filepath.Join(target, "Season 02", "S02E01.mkv")) {
+		t.Errorf("第二季应合并到 %s", target)
+	}
+}
@@ -0,0 +1,72 @@
+package classifier
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/tmdb"
+)
+
+// DetectMissingCollectionMovies 检测电影所属TMDB系列中尚未入库的电影
+// 尚未上映的电影不记录为缺失
+func DetectMissingCollectionMovies(mediaRecord *database.MediaRecord) error {
+	if mediaRecord.TMDbID == "" {
+		return nil
+	}
+
+	details, err := tmdb.GetDetails(mediaRecord.TMDbID, false)
+	if err != nil {
+		return fmt.Errorf("获取电影详情失败: %w", err)
+	}
+	if details.CollectionID == 0 {
+		return nil
+	}
+
+	collection, err := tmdb.GetCollection(details.CollectionID)
+	if err != nil {
+		return fmt.Errorf("获取电影系列失败: %w", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	missingCount := 0
+	for _, part := range collection.Parts {
+		if part.ReleaseDate == "" || part.ReleaseDate > today {
+			continue
+		}
+
+		partID := strconv.Itoa(part.ID)
+		owned := partID == mediaRecord.TMDbID
+		if !owned {
+			owned, err = database.HasMovieRecord(partID)
+			if err != nil {
+				return err
+			}
+		}
+
+		status := database.MissingStatusFound
+		if !owned {
+			status = database.MissingStatusMissing
+			missingCount++
+			logging.Info("系列 '%s' 缺少电影: %s (%s)", collection.Name, part.Title, part.ReleaseDate)
+		}
+
+		record := &database.MissingMovie{
+			CollectionID:   collection.ID,
+			CollectionName: collection.Name,
+			TMDbID:         partID,
+			Title:          part.Title,
+			ReleaseDate:    part.ReleaseDate,
+		}
+		if err := database.SetMissingMovieStatus(record, status); err != nil {
+			return err
+		}
+	}
+
+	if missingCount == 0 {
+		logging.Info("系列 '%s' 已上映的电影已全部入库", collection.Name)
+	}
+	return nil
+}
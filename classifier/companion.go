@@ -0,0 +1,130 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/safety"
+)
+
+// 伴随文件（海报、字幕、主题曲、NFO等非视频文件）的类型
+const (
+	companionImage    = "image"
+	companionAudio    = "audio"
+	companionNFO      = "nfo"
+	companionSubtitle = "subtitle"
+	companionOther    = "other"
+)
+
+// companionExtensions 伴随文件扩展名对应的类型
+var companionExtensions = map[string]string{
+	".jpg":  companionImage,
+	".jpeg": companionImage,
+	".png":  companionImage,
+	".webp": companionImage,
+	".gif":  companionImage,
+	".bmp":  companionImage,
+	".mp3":  companionAudio,
+	".flac": companionAudio,
+	".m4a":  companionAudio,
+	".ogg":  companionAudio,
+	".wav":  companionAudio,
+	".nfo":  companionNFO,
+	".srt":  companionSubtitle,
+	".ass":  companionSubtitle,
+	".ssa":  companionSubtitle,
+	".sub":  companionSubtitle,
+	".idx":  companionSubtitle,
+	".vtt":  companionSubtitle,
+	".sup":  companionSubtitle,
+}
+
+// companionFileType 返回伴随文件的类型
+func companionFileType(name string) string {
+	if fileType, ok := companionExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return fileType
+	}
+	return companionOther
+}
+
+// companionConflictPolicy 返回伴随文件类型配置的冲突处理策略，未配置时保留已有文件
+func companionConflictPolicy(cfg *config.Config, fileType string) string {
+	if policy, ok := cfg.MergeConflicts[fileType]; ok && policy != "" {
+		return policy
+	}
+	return config.ConflictKeepExisting
+}
+
+// shouldReplaceCompanion 按策略判断是否用源文件替换目标目录中的同名文件，返回判断原因
+func shouldReplaceCompanion(srcInfo, dstInfo os.FileInfo, policy string) (bool, string) {
+	switch policy {
+	case config.ConflictKeepNewest:
+		if srcInfo.ModTime().After(dstInfo.ModTime()) {
+			return true, "源文件较新"
+		}
+		return false, "已有文件较新"
+	case config.ConflictKeepLargest:
+		if srcInfo.Size() > dstInfo.Size() {
+			return true, fmt.Sprintf("源文件较大（%d > %d 字节）", srcInfo.Size(), dstInfo.Size())
+		}
+		return false, "已有文件不小于源文件"
+	case config.ConflictKeepExisting:
+		return false, "策略为保留已有文件"
+	default:
+		return false, fmt.Sprintf("未知的冲突策略 %s，保留已有文件", policy)
+	}
+}
+
+// mergeCompanionFile 合并季时处理与目标目录同名的伴随文件
+func mergeCompanionFile(cfg *config.Config, srcPath, dstPath string) error {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dstPath)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(srcPath)
+	fileType := companionFileType(name)
+	policy := companionConflictPolicy(cfg, fileType)
+
+	replace, reason := shouldReplaceCompanion(srcInfo, dstInfo, policy)
+	if !replace {
+		logging.Info("目标目录已存在 '%s'（%s），%s，跳过", name, fileType, reason)
+		return nil
+	}
+
+	if err := replaceFile(srcPath, dstPath); err != nil {
+		return fmt.Errorf("替换文件 '%s' 失败: %w", name, err)
+	}
+	logging.Info("已用源目录的 '%s'（%s）替换目标目录中的文件：%s", name, fileType, reason)
+	return nil
+}
+
+// replaceFile 用源文件覆盖目标文件，跨设备时复制后删除源文件
+func replaceFile(src, dst string) error {
+	if err := safety.CheckAll(src, dst); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// 先复制到临时文件再重命名，避免复制中断时损坏已有文件
+	tmpPath := dst + ".part"
+	if err := copyFile(src, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(src)
+}
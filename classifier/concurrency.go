@@ -0,0 +1,71 @@
+package classifier
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/user/media-manager/logging"
+)
+
+var (
+	moveMu    sync.Mutex
+	moveSlots chan struct{} // 容量为同时移动的影片数
+
+	targetMu    sync.Mutex
+	targetLocks = make(map[string]*targetLock) // 正在处理的目标目录
+)
+
+// targetLock 一个目标目录的锁，refs为持有或等待该锁的项目数
+type targetLock struct {
+	sync.Mutex
+	refs int
+}
+
+// acquireMoveSlot 等待空闲的移动任务，最多同时移动limit个影片，调用返回的函数释放
+// 配置修改后按新的数量创建，已占用旧任务的移动完成后释放到旧的通道中
+func acquireMoveSlot(limit int, mediaDir string) func() {
+	if limit < 1 {
+		limit = 1
+	}
+	moveMu.Lock()
+	if moveSlots == nil || cap(moveSlots) != limit {
+		moveSlots = make(chan struct{}, limit)
+	}
+	slots := moveSlots
+	moveMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	default:
+		logging.Info("已有 %d 个影片正在移动，等待后移动 '%s'", limit, mediaDir)
+		slots <- struct{}{}
+	}
+	return func() { <-slots }
+}
+
+// lockTarget 锁定目标目录，并发处理时同一剧集的多个项目依次检查和合并，调用返回的函数解锁
+// 不区分大小写的文件系统中大小写不同的路径也视为同一目录
+func lockTarget(targetPath string) func() {
+	key := strings.ToLower(filepath.Clean(targetPath))
+
+	targetMu.Lock()
+	lock := targetLocks[key]
+	if lock == nil {
+		lock = &targetLock{}
+		targetLocks[key] = lock
+	}
+	lock.refs++
+	targetMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		targetMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(targetLocks, key)
+		}
+		targetMu.Unlock()
+	}
+}
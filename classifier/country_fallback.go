@@ -0,0 +1,91 @@
+package classifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// languageCountries missing_country为language时按原始语言推断的国家，其他语言按其他国家处理
+var languageCountries = map[string]string{
+	"zh":  "中国",
+	"cn":  "中国香港", // TMDB用cn表示粤语
+	"yue": "中国香港",
+	"ja":  "日本",
+	"ko":  "韩国",
+}
+
+// otherLanguageCountry 原始语言不在languageCountries中时推断的国家，按其他国家分类
+const otherLanguageCountry = "其他"
+
+// countryFallback TMDB、NFO和元数据插件都没有国家信息时按missing_country采用的处理
+type countryFallback struct {
+	Countries  []string // language：按原始语言推断的国家，只用于确定分类
+	Category   string   // category、quarantine：直接使用的分类
+	Quarantine bool
+	Note       string // 记录到数据库的说明，如 language:ja、category:EnMovie、quarantine:Quarantine
+}
+
+// resolveMissingCountry 按missing_country配置选择没有国家信息时的处理，返回nil表示留在Temp目录
+// language没有原始语言时无法推断，同样留在Temp目录
+func resolveMissingCountry(cfg *config.Config, originalLanguage string, isTVShow bool) *countryFallback {
+	switch cfg.MissingCountry.Action {
+	case config.MissingCountryLanguage:
+		language := strings.ToLower(strings.TrimSpace(originalLanguage))
+		if language == "" {
+			return nil
+		}
+		country, ok := languageCountries[language]
+		if !ok {
+			country = otherLanguageCountry
+		}
+		return &countryFallback{Countries: []string{country}, Note: "language:" + language}
+	case config.MissingCountryCategory:
+		category := cfg.MissingCountry.MovieCategory
+		if isTVShow {
+			category = cfg.MissingCountry.ShowCategory
+		}
+		return &countryFallback{Category: category, Note: "category:" + category}
+	case config.MissingCountryQuarantine:
+		category := cfg.MissingCountry.QuarantineCategory
+		return &countryFallback{Category: category, Quarantine: true, Note: "quarantine:" + category}
+	}
+	return nil
+}
+
+// fallbackNote 返回记录到数据库的处理方式，有国家信息（fallback为nil）时为空
+func fallbackNote(fallback *countryFallback) string {
+	if fallback == nil {
+		return ""
+	}
+	return fallback.Note
+}
+
+// String 返回处理方式的说明，用于日志
+func (f *countryFallback) String() string {
+	switch {
+	case f.Quarantine:
+		return fmt.Sprintf("移动到隔离目录 %s", f.Category)
+	case f.Category != "":
+		return fmt.Sprintf("使用分类 %s", f.Category)
+	default:
+		return fmt.Sprintf("按原始语言 %s 推断国家为 %s", strings.TrimPrefix(f.Note, "language:"), strings.Join(f.Countries, ", "))
+	}
+}
+
+// quarantineMissingCountry 将没有国家信息的项目移动到隔离目录，在数据库记录中注明处理方式
+func quarantineMissingCountry(mediaDir string, isTVShow bool, fallback *countryFallback, cfg *config.Config) error {
+	record, err := moveUnresolvedItem(mediaDir, isTVShow, fallback.Category, nil, cfg)
+	if err != nil || record == nil {
+		return err
+	}
+	if err := database.SetCountryFallback(record.ID, fallback.Note); err != nil {
+		logging.Error("%v", err)
+	}
+	logging.Info("已将没有国家信息的 '%s' 移动到隔离目录 '%s'", filepath.Base(mediaDir), fallback.Category)
+	return database.ClearSkip(mediaDir)
+}
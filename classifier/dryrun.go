@@ -0,0 +1,74 @@
+package classifier
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// DryRun 为true时只模拟移动：确定分类和目标目录后输出将要执行的移动、合并、删除和数据库写入，不修改媒体库
+// 不执行pre_move、post_move钩子和移动后的处理策略；NFO的修改由parser.DryRun模拟
+var DryRun bool
+
+// mkdirAll 创建目录，模拟运行时只输出将要创建的目录
+func mkdirAll(dir string) error {
+	if !DryRun {
+		return os.MkdirAll(dir, 0755)
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		logging.Info("[模拟] 将创建目录: %s", dir)
+	}
+	return nil
+}
+
+// planMove 模拟运行时输出一个项目将要执行的移动、合并或替换，以及写入的数据库记录
+func planMove(cfg *config.Config, mediaDir, targetMediaPath string, inPlace bool, ruleCtx *RuleContext, record *database.MediaRecord) {
+	switch {
+	case inPlace:
+		logging.Info("[模拟] '%s' 已在正确的分类目录中，将只更新数据库记录", mediaDir)
+	case ruleCtx.TargetExists && !ruleCtx.ReplaceTarget:
+		logging.Info("[模拟] 将把 '%s' 合并到已有的目录 '%s'", mediaDir, targetMediaPath)
+		planMerge(cfg, mediaDir, targetMediaPath)
+		logging.Info("[模拟] 将删除源目录: %s", mediaDir)
+	case ruleCtx.ReplaceTarget:
+		logging.Info("[模拟] 将用 '%s' 替换 '%s'，旧版本移动到 '%s'", mediaDir, targetMediaPath, mediaDir)
+	default:
+		logging.Info("[模拟] 将移动 '%s' → '%s'", mediaDir, targetMediaPath)
+	}
+	logging.Info("[模拟] 将写入数据库记录: %s (%s)，分类 %s，目标路径 %s", record.Title, record.Year, record.Category, record.TargetPath)
+}
+
+// planMerge 按合并新季的规则输出源目录中每一项将被移动、按冲突策略处理还是跳过
+func planMerge(cfg *config.Config, mediaDir, targetMediaPath string) {
+	entries, err := os.ReadDir(mediaDir)
+	if err != nil {
+		logging.Warning("[模拟] 读取源目录失败: %v", err)
+		return
+	}
+	existingSeasons, _ := GetExistingSeasons(targetMediaPath)
+	for _, entry := range entries {
+		srcPath := filepath.Join(mediaDir, entry.Name())
+		dstPath := filepath.Join(targetMediaPath, existingName(targetMediaPath, entry.Name()))
+		if entry.IsDir() && utils.HasIgnoreMarker(srcPath) {
+			continue
+		}
+		if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+			logging.Info("[模拟]   将移动 '%s' → '%s'", entry.Name(), dstPath)
+			continue
+		}
+		if !entry.IsDir() && !isVideoFile(entry.Name()) {
+			policy := companionConflictPolicy(cfg, companionFileType(entry.Name()))
+			logging.Info("[模拟]   目标目录已存在 '%s'，将按冲突策略 %s 处理", entry.Name(), policy)
+			continue
+		}
+		if seasonNum := GetSeasonNumberFromDirName(entry.Name()); seasonNum > 0 && !containsSeason(existingSeasons, seasonNum) {
+			logging.Info("[模拟]   将合并季数 %d: '%s' → '%s'", seasonNum, entry.Name(), dstPath)
+			continue
+		}
+		logging.Info("[模拟]   目标目录已存在 '%s'，将跳过", entry.Name())
+	}
+}
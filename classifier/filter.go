@@ -0,0 +1,92 @@
+package classifier
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// ProcessFilter 只处理指定分类或标题的项目，用于不等待整个Temp积压、优先处理个别紧急的项目
+type ProcessFilter struct {
+	Categories []string // 分类名称，为空时不限制
+	Title      string   // 标题、原始标题或目录名包含的文字（规范化后比较），为空时不限制
+}
+
+// ActiveFilter 本次运行的处理范围，由main按-only-category、-title参数设置
+var ActiveFilter ProcessFilter
+
+// Empty 判断是否没有限制处理范围
+func (f ProcessFilter) Empty() bool {
+	return len(f.Categories) == 0 && f.Title == ""
+}
+
+// String 返回处理范围的说明
+func (f ProcessFilter) String() string {
+	var parts []string
+	if len(f.Categories) > 0 {
+		parts = append(parts, "分类 "+strings.Join(f.Categories, ", "))
+	}
+	if f.Title != "" {
+		parts = append(parts, "标题包含 '"+f.Title+"'")
+	}
+	return strings.Join(parts, "，")
+}
+
+// MatchCategory 判断分类是否在处理范围内，不区分大小写
+func (f ProcessFilter) MatchCategory(category string) bool {
+	if len(f.Categories) == 0 {
+		return true
+	}
+	for _, c := range f.Categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTitle 判断NFO的标题、原始标题或影片目录名是否包含指定的文字
+// 繁简、全角半角、大小写和标点的差异不影响比较
+func (f ProcessFilter) MatchTitle(nfo *parser.NFO, mediaDir string) bool {
+	if f.Title == "" {
+		return true
+	}
+	want := utils.NormalizeTitle(f.Title)
+	if want == "" {
+		return true
+	}
+	candidates := []string{filepath.Base(mediaDir)}
+	if nfo != nil {
+		candidates = append(candidates, nfo.Title, nfo.OriginalTitle)
+	}
+	for _, candidate := range candidates {
+		if strings.Contains(utils.NormalizeTitle(candidate), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchNFO 扫描和取出队列项目时按NFO文件检查是否在处理范围内
+// 分类只按NFO中的国家和类型预测，无法预测时不排除，确定最终分类后ClassifyAndMove会再次检查
+func (f ProcessFilter) MatchNFO(cfg *config.Config, nfoPath string) bool {
+	if f.Empty() {
+		return true
+	}
+	nfo, err := parser.ParseNFO(nfoPath)
+	if err != nil {
+		// 无法解析的NFO交给正常的处理流程记录错误
+		return f.Title == ""
+	}
+	if !f.MatchTitle(nfo, filepath.Dir(nfoPath)) {
+		return false
+	}
+	if len(f.Categories) == 0 {
+		return true
+	}
+	category, ok := predictFromNFO(cfg, nfo)
+	return !ok || f.MatchCategory(category)
+}
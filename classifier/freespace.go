@@ -0,0 +1,136 @@
+package classifier
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/hooks"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// lowSpaceStateKey 记录媒体库目录已发送过剩余空间不足通知的运行状态键，空间恢复后删除，避免每次运行重复通知
+const lowSpaceStateKey = "free_space:low:"
+
+// spaceTarget 需要检查剩余空间的媒体库目录
+type spaceTarget struct {
+	Category string // 为空时表示媒体库根目录
+	Path     string
+	MinBytes uint64
+}
+
+// spaceTargets 返回配置了最低剩余空间的媒体库目录：default对应各媒体库根目录，单独配置的分类对应其目标目录
+func spaceTargets(cfg *config.Config) []spaceTarget {
+	var targets []spaceTarget
+	seen := make(map[string]bool)
+	add := func(category string, path string) {
+		min := cfg.MinFreeSpace(category)
+		if min == 0 || seen[filepath.Clean(path)] {
+			return
+		}
+		seen[filepath.Clean(path)] = true
+		targets = append(targets, spaceTarget{Category: category, Path: path, MinBytes: min})
+	}
+
+	for _, root := range cfg.CloudRoots() {
+		add("", root)
+	}
+	var categories []string
+	for category := range cfg.CategoryDirs {
+		categories = append(categories, category)
+	}
+	for category := range cfg.MinFreeSpaceGB {
+		if _, ok := cfg.CategoryDirs[category]; !ok && category != config.MinFreeSpaceDefault {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		add(category, cfg.CategoryDir(category, false))
+		add(category, cfg.CategoryDir(category, true))
+	}
+	return targets
+}
+
+// MonitorFreeSpace 检查各媒体库目录所在文件系统的剩余空间，低于min_free_space_gb时记录警告并执行low_space钩子
+// 同一目录在空间恢复之前只通知一次
+func MonitorFreeSpace(cfg *config.Config) {
+	for _, target := range spaceTargets(cfg) {
+		free, err := utils.FreeSpace(target.Path)
+		if err != nil {
+			logging.Warning("%v", err)
+			continue
+		}
+		reportFreeSpace(cfg, target, free)
+	}
+}
+
+// reportFreeSpace 剩余空间低于下限时记录警告，第一次发现时执行low_space钩子；恢复后记录日志并清除通知状态
+func reportFreeSpace(cfg *config.Config, target spaceTarget, free uint64) {
+	key := lowSpaceStateKey + filepath.Clean(target.Path)
+	notified, err := database.GetRunState(key)
+	if err != nil {
+		logging.Error("%v", err)
+	}
+
+	if free >= target.MinBytes {
+		if notified != "" {
+			logging.Info("'%s' 的剩余空间已恢复到 %s", target.Path, utils.FormatBytes(int64(free)))
+			if err := database.DeleteRunState(key); err != nil {
+				logging.Error("%v", err)
+			}
+		}
+		return
+	}
+
+	logging.Warning("'%s' 的剩余空间只有 %s，低于min_free_space_gb要求的 %s，将不再向其中移动影片",
+		target.Path, utils.FormatBytes(int64(free)), utils.FormatBytes(int64(target.MinBytes)))
+	if notified != "" {
+		return
+	}
+	if err := hooks.RunLowSpace(cfg.Hooks, &hooks.Space{
+		Category:  target.Category,
+		Path:      target.Path,
+		FreeBytes: free,
+		MinBytes:  target.MinBytes,
+	}); err != nil {
+		logging.Error("%v", err)
+	}
+	if err := database.SetRunState(key, time.Now().Format(time.RFC3339)); err != nil {
+		logging.Error("%v", err)
+	}
+}
+
+// checkFreeSpace 移动后目标文件系统的剩余空间会低于分类的min_free_space_gb时拒绝，避免复制到一半时空间用尽
+// 源目录与目标目录在同一文件系统时移动不占用额外空间，只检查当前的剩余空间
+func checkFreeSpace(ctx *RuleContext) Decision {
+	min := ctx.Config.MinFreeSpace(ctx.Category)
+	if min == 0 || (ctx.Adopt && samePath(ctx.TargetMediaPath, ctx.MediaDir)) {
+		return allow()
+	}
+	targetDir := ctx.Config.CategoryDir(ctx.Category, ctx.NFO.IsTVShow())
+	free, err := utils.FreeSpace(targetDir)
+	if err != nil {
+		logging.Warning("%v，不检查剩余空间", err)
+		return allow()
+	}
+
+	var needed uint64
+	if !utils.SameFilesystem(ctx.MediaDir, targetDir) {
+		needed = uint64(utils.DirSize(ctx.MediaDir, ctx.Config.SymlinkMode(config.SymlinkOpMove)))
+	}
+	if free >= needed+min {
+		return allow()
+	}
+
+	if free < min {
+		reportFreeSpace(ctx.Config, spaceTarget{Category: ctx.Category, Path: targetDir, MinBytes: min}, free)
+		return denyWarning("'%s' 所在文件系统剩余空间不足：剩余 %s，分类 %s 要求至少保留 %s",
+			targetDir, utils.FormatBytes(int64(free)), ctx.Category, utils.FormatBytes(int64(min)))
+	}
+	return denyWarning("'%s' 所在文件系统剩余空间不足：剩余 %s，移动 %s 后将低于分类 %s 要求保留的 %s",
+		targetDir, utils.FormatBytes(int64(free)), utils.FormatBytes(int64(needed)), ctx.Category, utils.FormatBytes(int64(min)))
+}
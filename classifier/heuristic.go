@@ -0,0 +1,125 @@
+package classifier
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// HeuristicTag 按文件名推测分类的项目写入NFO的标签，表示分类置信度低，需要人工确认
+const HeuristicTag = "低置信度分类"
+
+// HeuristicGuess 没有任何元数据时按文件名推测的分类
+type HeuristicGuess struct {
+	Category string   // 推测的分类
+	Reasons  []string // 推测依据
+}
+
+var (
+	// 日文假名
+	kanaRe = regexp.MustCompile(`[\p{Hiragana}\p{Katakana}]`)
+	// 韩文
+	hangulRe = regexp.MustCompile(`\p{Hangul}`)
+	// 汉字
+	hanRe = regexp.MustCompile(`\p{Han}`)
+	// 国内发布组常用的后缀，如 -CMCT、@HHWEB
+	cnReleaseGroupRe = regexp.MustCompile(`(?i)[-@](CMCT|CMCTV|HHWEB|FRDS|OurTV|OurBits|CHDWEB|CHDTV|PTerWEB|MWeb|TJUPT|HDSWEB|HDCTV|QHstudIo|ADWeb|Audies|beAst)\b`)
+	// 国语、粤语音轨标记
+	cnAudioRe = regexp.MustCompile(`国语|國語|粤语|粵語|(?i)\b(Mandarin|Cantonese)\b`)
+	// 日韩发布标记
+	jpKrReleaseRe = regexp.MustCompile(`(?i)\b(JPN|KOR|Japanese|Korean)\b`)
+)
+
+// 按名称关键词推测的非地区分类
+var heuristicKeywordCategories = []struct {
+	keywords []string
+	category string
+	showOnly bool
+}{
+	{[]string{"纪录片", "紀錄片", "documentary"}, CategoryJlShow, false},
+	{[]string{"综艺", "綜藝", "真人秀", "variety"}, CategoryXSShow, true},
+}
+
+// GuessCategory 根据目录名和视频文件名推测分类，用于完全没有元数据的项目
+// 依次检查纪录片和综艺关键词、字幕组命名（动漫）、文字种类（假名、韩文、汉字）和发布组惯例，
+// 都没有命中时按其他国家处理，无法推测时Category为空
+func GuessCategory(mediaDir string, isTVShow bool) HeuristicGuess {
+	names := heuristicNames(mediaDir)
+	if len(names) == 0 {
+		return HeuristicGuess{}
+	}
+	joined := strings.Join(names, "\n")
+	lower := strings.ToLower(joined)
+
+	pick := func(movie, show string) string {
+		if isTVShow {
+			return show
+		}
+		return movie
+	}
+
+	for _, rule := range heuristicKeywordCategories {
+		if rule.showOnly && !isTVShow {
+			continue
+		}
+		for _, keyword := range rule.keywords {
+			if strings.Contains(lower, keyword) {
+				return HeuristicGuess{Category: rule.category, Reasons: []string{"名称包含关键词 '" + keyword + "'"}}
+			}
+		}
+	}
+
+	for _, name := range names {
+		if release, ok := parser.ParseAnimeRelease(name); ok {
+			return HeuristicGuess{
+				Category: pick(CategoryDmMovie, CategoryDmShow),
+				Reasons:  []string{"字幕组命名 [" + release.Group + "]"},
+			}
+		}
+	}
+
+	switch {
+	case kanaRe.MatchString(joined):
+		return HeuristicGuess{Category: pick(CategoryJpKrMovie, CategoryJpKrShow), Reasons: []string{"名称包含日文假名"}}
+	case hangulRe.MatchString(joined):
+		return HeuristicGuess{Category: pick(CategoryJpKrMovie, CategoryJpKrShow), Reasons: []string{"名称包含韩文"}}
+	case jpKrReleaseRe.MatchString(joined):
+		return HeuristicGuess{Category: pick(CategoryJpKrMovie, CategoryJpKrShow), Reasons: []string{"名称包含日韩发布标记 '" + jpKrReleaseRe.FindString(joined) + "'"}}
+	}
+
+	var reasons []string
+	if hanRe.MatchString(joined) {
+		reasons = append(reasons, "名称包含汉字")
+	}
+	if match := cnReleaseGroupRe.FindStringSubmatch(joined); match != nil {
+		reasons = append(reasons, "国内发布组 "+match[1])
+	}
+	if match := cnAudioRe.FindString(joined); match != "" {
+		reasons = append(reasons, "音轨标记 '"+match+"'")
+	}
+	if len(reasons) > 0 {
+		return HeuristicGuess{Category: pick(CategoryCnMovie, CategoryCnShow), Reasons: reasons}
+	}
+
+	return HeuristicGuess{Category: pick(CategoryEnMovie, CategoryEnShow), Reasons: []string{"名称中没有中日韩文字"}}
+}
+
+// heuristicNames 返回目录名和目录中所有视频文件的文件名
+func heuristicNames(mediaDir string) []string {
+	names := []string{filepath.Base(mediaDir)}
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if !info.IsDir() && isVideoFile(path) {
+			names = append(names, info.Name())
+		}
+		return nil
+	})
+	return names
+}
@@ -0,0 +1,150 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// IntakeRules 处理NFO之前检查的规则，项目还在复制或下载中时跳过，下次运行时重新检查
+var IntakeRules = []Rule{
+	{RuleIncomplete, checkIncomplete},
+	{RuleNotSettled, checkSettled},
+}
+
+// CheckIntake 检查NFO文件所在的项目是否可以开始处理，不能处理时记录跳过原因并返回false
+func CheckIntake(nfoPath string, cfg *config.Config) bool {
+	mediaDir := filepath.Dir(nfoPath)
+	ctx := &RuleContext{Config: cfg, NFOPath: nfoPath, MediaDir: mediaDir}
+	if denial := EvaluateRules(IntakeRules, ctx); denial != nil {
+		recordDenial(denial, mediaDir)
+		return false
+	}
+	return true
+}
+
+// checkIncomplete 目录（电视剧包括所有季目录）中存在下载未完成的标记文件时拒绝，避免合并下载了一半的季
+func checkIncomplete(ctx *RuleContext) Decision {
+	if marker := findMarkerFile(ctx.MediaDir, ctx.Config.IncompleteMarkers); marker != "" {
+		return deny("存在下载未完成的文件 %s，下载完成后再处理", marker)
+	}
+	return allow()
+}
+
+// checkSettled 按项目所在Temp目录的入库规则检查：存在未下载完成的文件、最近修改过文件或文件正被其他进程打开时拒绝
+func checkSettled(ctx *RuleContext) Decision {
+	rule := ctx.Config.IntakeRuleFor(ctx.MediaDir)
+
+	if partial := findMarkerFile(ctx.MediaDir, rule.PartialExtensions); partial != "" {
+		return deny("存在未下载完成的文件 %s", partial)
+	}
+
+	if rule.SettleMinutes > 0 {
+		if name, modTime := latestPayloadChange(ctx.MediaDir); !modTime.IsZero() {
+			if age := time.Since(modTime); age < time.Duration(rule.SettleMinutes)*time.Minute {
+				return deny("文件 %s 在 %v 前修改过，等待 %d 分钟没有变化后再处理", name, age.Round(time.Second), rule.SettleMinutes)
+			}
+		}
+	}
+
+	if rule.CheckOpenFiles {
+		if name, process := findOpenFile(ctx.MediaDir); name != "" {
+			return deny("文件 %s 正被进程 %s 打开", name, process)
+		}
+	}
+	return allow()
+}
+
+// findMarkerFile 返回目录中第一个匹配标记的文件的相对路径，没有时返回空字符串
+// 以.开头的标记按扩展名（文件名后缀）匹配，其他标记按完整文件名匹配，都不区分大小写
+func findMarkerFile(mediaDir string, markers []string) string {
+	if len(markers) == 0 {
+		return ""
+	}
+
+	var found string
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil // 忽略访问错误
+		}
+		name := strings.ToLower(info.Name())
+		for _, marker := range markers {
+			marker = strings.ToLower(marker)
+			if marker == "" {
+				continue
+			}
+			if (strings.HasPrefix(marker, ".") && strings.HasSuffix(name, marker)) || name == marker {
+				found, _ = filepath.Rel(mediaDir, path)
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return found
+}
+
+// latestPayloadChange 返回目录中最近修改的文件及其修改时间
+// 忽略NFO和图片：刮削时会重新生成，不代表下载或复制还在进行
+func latestPayloadChange(mediaDir string) (string, time.Time) {
+	var latestName string
+	var latest time.Time
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil // 忽略访问错误
+		}
+		if fileType := companionFileType(info.Name()); fileType == companionNFO || fileType == companionImage {
+			return nil
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+			latestName, _ = filepath.Rel(mediaDir, path)
+		}
+		return nil
+	})
+	return latestName, latest
+}
+
+// findOpenFile 通过/proc查找正被其他进程打开的目录中的文件，返回文件的相对路径和进程名
+// 没有/proc（非Linux）或没有权限读取其他进程时视为没有打开的文件
+func findOpenFile(mediaDir string) (string, string) {
+	absDir, err := filepath.Abs(mediaDir)
+	if err != nil {
+		return "", ""
+	}
+	prefix := absDir + string(filepath.Separator)
+
+	processes, err := os.ReadDir("/proc")
+	if err != nil {
+		return "", ""
+	}
+	self := fmt.Sprint(os.Getpid())
+	for _, process := range processes {
+		pid := process.Name()
+		if pid == self || strings.Trim(pid, "0123456789") != "" {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", pid, "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, prefix) {
+				continue
+			}
+			name, _ := filepath.Rel(absDir, target)
+			comm, _ := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+			logging.Debug("进程 %s (PID %s) 正在使用 %s", strings.TrimSpace(string(comm)), pid, target)
+			return name, fmt.Sprintf("%s (PID %s)", strings.TrimSpace(string(comm)), pid)
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,37 @@
+package classifier
+
+import (
+	"path/filepath"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// IsLocked 判断媒体库中的目录是否属于由db lock锁定的记录，锁定的目录不被自动处理修改
+// 查询失败时按未锁定处理，只记录警告
+func IsLocked(path string) bool {
+	if path == "" {
+		return false
+	}
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	locked, err := database.IsPathLocked(path)
+	if err != nil {
+		logging.Warning("%v", err)
+		return false
+	}
+	return locked
+}
+
+// checkLocked 影片目录（adopt_in_place时在媒体库中）或目标目录属于锁定的记录时拒绝，不重新分类、替换版本或合并新季
+// 解析NFO后和确定目标目录后各检查一次
+func checkLocked(ctx *RuleContext) Decision {
+	if ctx.Adopt && IsLocked(ctx.MediaDir) {
+		return deny("已锁定（db lock），不重新分类")
+	}
+	if IsLocked(ctx.TargetMediaPath) {
+		return deny("目标目录 '%s' 已锁定（db lock），不替换或合并", ctx.TargetMediaPath)
+	}
+	return allow()
+}
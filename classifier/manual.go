@@ -0,0 +1,179 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+)
+
+// ManualResult classify set的结果
+type ManualResult struct {
+	OldPath     string
+	NewPath     string
+	OldCategory string // 没有数据库记录时为空
+	Records     int    // 更新或写入的媒体记录数
+}
+
+// SetCategory 手动指定影片目录的分类：移动到分类目录，更新或写入数据库记录，并把分类的来源记录为manual
+// 之后重新处理同一影片（adopt_in_place、合并新的季、新版本）时使用手动指定的分类，不再按规则得出的分类移动
+// 目录可以在Temp目录或媒体库中，新分类目录中已有同名目录时不移动
+func SetCategory(cfg *config.Config, dir string, category string) (*ManualResult, error) {
+	mediaDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("获取绝对路径失败: %w", err)
+	}
+	info, err := os.Stat(mediaDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录: %s", mediaDir)
+	}
+	if IsLocked(mediaDir) {
+		return nil, fmt.Errorf("'%s' 已锁定，请先用 db unlock 解锁", mediaDir)
+	}
+	inTemp := IsUnderTempDirs(mediaDir, cfg.TempDirs)
+	if !inTemp && !IsUnderTempDirs(mediaDir, cfg.CloudDirs()) {
+		return nil, fmt.Errorf("'%s' 不在Temp目录或媒体库中", mediaDir)
+	}
+
+	records, err := database.GetMediaRecords(map[string]interface{}{"target_path": mediaDir})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+	result := &ManualResult{OldPath: mediaDir}
+	if len(records) > 0 {
+		result.OldCategory = records[0].Category
+	}
+
+	// 并发处理时同一目标目录的项目依次移动
+	unlockTarget := lockTarget(RecategorizedPath(cfg, mediaDir, category))
+	defer unlockTarget()
+	result.NewPath, err = MoveToCategory(cfg, mediaDir, category)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := "分类: " + category
+	if result.OldCategory != "" {
+		detail = fmt.Sprintf("分类: %s → %s", result.OldCategory, category)
+	}
+	if !samePath(mediaDir, result.NewPath) {
+		detail += "，目录: " + mediaDir + " → " + result.NewPath
+	}
+	if len(records) == 0 {
+		record := manualRecord(cfg, mediaDir, result.NewPath, category)
+		if err := database.InsertOrUpdateMediaRecord(record); err != nil {
+			return result, fmt.Errorf("记录媒体信息到数据库失败: %w", err)
+		}
+		records = []database.MediaRecord{*record}
+	}
+	for _, record := range records {
+		// MoveToCategory已更新目标路径，这里修改分类并记录来源
+		if err := database.UpdateMediaRecordFields(record.ID, map[string]string{"category": category}); err != nil {
+			return result, err
+		}
+		if err := database.RecordEvent(record.ID, mediaDir, database.EventCategorySet, detail); err != nil {
+			logging.Error("%v", err)
+		}
+		result.Records++
+	}
+
+	if inTemp {
+		if err := database.ClearSkip(mediaDir); err != nil {
+			logging.Error("%v", err)
+		}
+	}
+	logging.Info("已手动将 '%s' 分类为 %s: %s", filepath.Base(mediaDir), category, result.NewPath)
+	return result, nil
+}
+
+// manualRecord 为没有数据库记录的目录（通常在Temp目录中）生成媒体记录，有NFO时使用NFO中的信息
+func manualRecord(cfg *config.Config, mediaDir string, targetPath string, category string) *database.MediaRecord {
+	mediaName := filepath.Base(mediaDir)
+	record := &database.MediaRecord{
+		FileName:    mediaName,
+		Title:       mediaName,
+		Category:    category,
+		SourcePath:  mediaDir,
+		TargetPath:  targetPath,
+		ProcessedAt: time.Now(),
+		Edition:     parser.ParseEdition(mediaName),
+	}
+
+	nfoFiles, err := parser.ListNFOFiles(targetPath)
+	if err != nil || len(nfoFiles) == 0 {
+		return record
+	}
+	nfoPath, _ := parser.SelectNFOFile(nfoFiles, cfg.NFOSelection)
+	nfo, err := parser.ParseNFO(nfoPath)
+	if err != nil {
+		logging.Warning("解析NFO文件失败，只记录目录名: %v", err)
+		return record
+	}
+
+	sources := database.FieldSources{}
+	record.FileName = filepath.Base(nfoPath)
+	if nfo.Title != "" {
+		record.Title = nfo.Title
+		sources.Set(database.FieldTitle, database.SourceNFO)
+	}
+	record.OriginalTitle = nfo.OriginalTitle
+	record.Year = nfo.Year
+	record.Country = strings.Join(nfo.Country, ", ")
+	sources.Set(database.FieldCountry, sourceIf(len(nfo.Country) > 0, database.SourceNFO))
+	record.Genres = strings.Join(nfo.Genres, ", ")
+	sources.Set(database.FieldGenres, sourceIf(len(nfo.Genres) > 0, database.SourceNFO))
+	record.Actors = formatActorsOrArtists(nfo)
+	record.Runtime = nfo.Runtime
+	record.Plot = nfo.Plot
+	record.PlotSource = nfo.PlotSource()
+	record.IMDbID = nfo.IMDbID
+	record.TMDbID = nfo.TMDbID
+	record.Season = nfo.SeasonNumber()
+	record.Episode = nfo.EpisodeNumber()
+	record.Director = nfo.Director
+	record.Writer = nfo.Writer
+	record.Rating = nfo.Rating
+	record.FieldSources = sources.String()
+	return record
+}
+
+// manualCategory 返回classify set或db update手动指定的分类，没有指定过时返回空字符串
+func manualCategory(mediaDir string, tmdbID string, isTVShow bool) string {
+	category, err := database.GetManualCategory(mediaDir, tmdbID, isTVShow)
+	if err != nil {
+		logging.Warning("%v", err)
+		return ""
+	}
+	return category
+}
+
+// builtinCategories 内置分类规则使用的分类
+var builtinCategories = []string{
+	CategoryCnMovie, CategoryCnShow, CategoryEnMovie, CategoryEnShow, CategoryJpKrMovie,
+	CategoryJpKrShow, CategoryDmMovie, CategoryDmShow, CategoryJlShow, CategoryXSShow,
+}
+
+// CanonicalCategory 按已知分类（内置分类、音乐分类和category_dirs中的分类）的写法返回分类名
+// 比较时忽略大小写和&，如 jpkrmovie 返回 Jp&KrMovie；不是已知分类时ok为false，原样返回
+func CanonicalCategory(cfg *config.Config, name string) (category string, ok bool) {
+	known := append(append([]string(nil), builtinCategories...), cfg.MusicCategory)
+	for dirCategory := range cfg.CategoryDirs {
+		known = append(known, dirCategory)
+	}
+	key := func(s string) string { return strings.ToLower(strings.ReplaceAll(s, "&", "")) }
+	for _, c := range known {
+		if c != "" && key(c) == key(name) {
+			return c, true
+		}
+	}
+	return name, false
+}
@@ -0,0 +1,231 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// ResolveMissingSeason 重新检查缺失季记录对应的剧集目录，季数已存在时标记为已获取
+// force为true时即使目录中没有找到该季也标记为已获取（手动确认）
+// 返回该季是否在剧集目录中找到
+func ResolveMissingSeason(id int, force bool) (bool, error) {
+	missing, err := database.GetMissingSeasonByID(id)
+	if err != nil {
+		return false, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+	if missing == nil {
+		return false, fmt.Errorf("缺失季记录不存在: %d", id)
+	}
+	if missing.Status == database.MissingStatusFound {
+		logging.Info("'%s' 第 %d 季已经标记为已获取", missing.Title, missing.Season)
+		return true, nil
+	}
+
+	targetPaths, err := findShowTargetPaths(missing.TMDbID, missing.Title)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, targetPath := range targetPaths {
+		existingSeasons, err := GetExistingSeasons(targetPath)
+		if err != nil {
+			logging.Warning("读取剧集目录 %s 失败: %v", targetPath, err)
+			continue
+		}
+		if containsSeason(existingSeasons, missing.Season) {
+			found = true
+			break
+		}
+	}
+
+	if !found && !force {
+		return false, fmt.Errorf("剧集目录 %v 中没有找到 '%s' 第 %d 季，如确认已获取请使用 --force", targetPaths, missing.Title, missing.Season)
+	}
+
+	if err := database.UpdateMissingItemStatus("missing_seasons", missing.ID, database.MissingStatusFound); err != nil {
+		return false, fmt.Errorf("更新缺失季状态失败: %w", err)
+	}
+
+	logging.Info("已将 '%s' 第 %d 季标记为已获取", missing.Title, missing.Season)
+	return found, nil
+}
+
+// ResolveMissingEpisode 重新检查缺失剧集记录对应的剧集目录，找到该集的视频文件时标记为已获取
+// force为true时即使目录中没有找到该集也标记为已获取（手动确认）
+// 返回该集是否在剧集目录中找到
+func ResolveMissingEpisode(id int, force bool) (bool, error) {
+	missing, err := database.GetMissingEpisodeByID(id)
+	if err != nil {
+		return false, fmt.Errorf("获取缺失剧集记录失败: %w", err)
+	}
+	if missing == nil {
+		return false, fmt.Errorf("缺失剧集记录不存在: %d", id)
+	}
+	if missing.Status == database.MissingStatusFound {
+		logging.Info("'%s' 第 %d 季第 %d 集已经标记为已获取", missing.Title, missing.Season, missing.Episode)
+		return true, nil
+	}
+
+	targetPaths, err := findShowTargetPaths(missing.TMDbID, missing.Title)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, targetPath := range targetPaths {
+		if hasEpisodeFile(targetPath, missing.Season, missing.Episode) {
+			found = true
+			break
+		}
+	}
+
+	if !found && !force {
+		return false, fmt.Errorf("剧集目录 %v 中没有找到 '%s' 第 %d 季第 %d 集，如确认已获取请使用 --force", targetPaths, missing.Title, missing.Season, missing.Episode)
+	}
+
+	if err := database.UpdateMissingItemStatus("missing_episodes", missing.ID, database.MissingStatusFound); err != nil {
+		return false, fmt.Errorf("更新缺失剧集状态失败: %w", err)
+	}
+
+	logging.Info("已将 '%s' 第 %d 季第 %d 集标记为已获取", missing.Title, missing.Season, missing.Episode)
+	return found, nil
+}
+
+// RescanMissing 重新检查标题匹配的剧集目录，将已经存在的缺失季和剧集标记为已获取
+// 返回被标记为已获取的季数和集数
+func RescanMissing(title string) (int, int, error) {
+	seasonRows, err := database.GetMissingSeasons(map[string]interface{}{"title": title})
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+
+	episodeRows, err := database.GetMissingEpisodes(map[string]interface{}{"title": title})
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取缺失剧集记录失败: %w", err)
+	}
+
+	// 同一剧集的目录只查找一次
+	pathCache := make(map[string][]string)
+	targetPathsFor := func(tmdbID, title string) []string {
+		key := tmdbID + "|" + title
+		if paths, ok := pathCache[key]; ok {
+			return paths
+		}
+		paths, err := findShowTargetPaths(tmdbID, title)
+		if err != nil {
+			logging.Warning("查找 '%s' 的剧集目录失败: %v", title, err)
+		}
+		pathCache[key] = paths
+		return paths
+	}
+
+	foundSeasons := 0
+	for _, row := range seasonRows {
+		for _, targetPath := range targetPathsFor(row.TMDbID, row.Title) {
+			existingSeasons, err := GetExistingSeasons(targetPath)
+			if err != nil || !containsSeason(existingSeasons, row.Season) {
+				continue
+			}
+			if err := database.UpdateMissingItemStatus("missing_seasons", row.ID, database.MissingStatusFound); err != nil {
+				logging.Error("更新缺失季状态失败: %v", err)
+				break
+			}
+			logging.Info("'%s' 第 %d 季已存在于 %s，标记为已获取", row.Title, row.Season, targetPath)
+			foundSeasons++
+			break
+		}
+	}
+
+	foundEpisodes := 0
+	for _, row := range episodeRows {
+		for _, targetPath := range targetPathsFor(row.TMDbID, row.Title) {
+			if !hasEpisodeFile(targetPath, row.Season, row.Episode) {
+				continue
+			}
+			if err := database.UpdateMissingItemStatus("missing_episodes", row.ID, database.MissingStatusFound); err != nil {
+				logging.Error("更新缺失剧集状态失败: %v", err)
+				break
+			}
+			logging.Info("'%s' 第 %d 季第 %d 集已存在于 %s，标记为已获取", row.Title, row.Season, row.Episode, targetPath)
+			foundEpisodes++
+			break
+		}
+	}
+
+	return foundSeasons, foundEpisodes, nil
+}
+
+// findShowTargetPaths 查找剧集在Cloud目录中的路径，优先按TMDB ID匹配，没有时按标题匹配
+func findShowTargetPaths(tmdbID string, title string) ([]string, error) {
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, record := range records {
+		if !strings.Contains(record.Category, "Show") || record.TargetPath == "" {
+			continue
+		}
+		if tmdbID != "" {
+			if record.TMDbID != tmdbID {
+				continue
+			}
+		} else if record.Title != title {
+			continue
+		}
+		if !seen[record.TargetPath] {
+			seen[record.TargetPath] = true
+			paths = append(paths, record.TargetPath)
+		}
+	}
+
+	return paths, nil
+}
+
+// containsSeason 判断季数列表中是否包含指定季
+func containsSeason(seasons []int, season int) bool {
+	for _, s := range seasons {
+		if s == season {
+			return true
+		}
+	}
+	return false
+}
+
+// episodeFileRe 匹配文件名中的SxxEyy格式
+var episodeFileRe = regexp.MustCompile(`(?i)S(\d{1,2})E(\d{1,3})`)
+
+// hasEpisodeFile 检查剧集目录中是否存在指定季和集的视频文件
+func hasEpisodeFile(targetPath string, season, episode int) bool {
+	found := false
+	utils.Walk(targetPath, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil || found {
+			return nil
+		}
+		if info.IsDir() || !isVideoFile(info.Name()) {
+			return nil
+		}
+		matches := episodeFileRe.FindStringSubmatch(info.Name())
+		if len(matches) < 3 {
+			return nil
+		}
+		s, _ := strconv.Atoi(matches[1])
+		e, _ := strconv.Atoi(matches[2])
+		if s == season && e == episode {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,52 @@
+package classifier
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/internal/testkit"
+)
+
+func TestResolveMissingEpisode(t *testing.T) {
+	env := testkit.New(t)
+	target := env.CloudPath("CnShow", "三体 (2023)")
+	if err := testkit.Touch(filepath.Join(target, "Season 01", "S01E01.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InsertOrUpdateMediaRecord(&database.MediaRecord{Title: "三体", Year: "2023", TMDbID: "108545", Category: "CnShow", TargetPath: target}); err != nil {
+		t.Fatal(err)
+	}
+	for _, episode := range []int{1, 2} {
+		if err := database.InsertMissingEpisode(&database.MissingEpisode{Title: "三体", TMDbID: "108545", Season: 1, Episode: episode}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := database.GetMissingEpisodes(map[string]interface{}{})
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("缺失剧集记录 = %v, %v", rows, err)
+	}
+	ids := make(map[int]int)
+	for _, row := range rows {
+		ids[row.Episode] = row.ID
+	}
+
+	// 目录中有该集时标记为已获取
+	if found, err := ResolveMissingEpisode(ids[1], false); err != nil || !found {
+		t.Errorf("ResolveMissingEpisode(第1集) = %v, %v，应找到", found, err)
+	}
+	// 没有找到该集时不标记，--force时手动标记
+	if _, err := ResolveMissingEpisode(ids[2], false); err == nil {
+		t.Error("目录中没有第2集时应返回错误")
+	}
+	if found, err := ResolveMissingEpisode(ids[2], true); err != nil || found {
+		t.Errorf("ResolveMissingEpisode(第2集, force) = %v, %v，应手动标记", found, err)
+	}
+
+	if rows, err := database.GetMissingEpisodes(map[string]interface{}{}); err != nil || len(rows) != 0 {
+		t.Errorf("剩余的缺失剧集 = %v, %v，应全部标记为已获取", rows, err)
+	}
+	if _, err := ResolveMissingEpisode(ids[2]+100, false); err == nil {
+		t.Error("记录不存在时应返回错误")
+	}
+}
@@ -0,0 +1,85 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/utils"
+)
+
+// CheckMounts 按mount_check配置检查各媒体库目录是否已挂载，第一个没有挂载的目录返回errs.ErrNotMounted
+// NAS共享没有挂载时媒体库目录只是本地的空目录，移动仍会成功并写满本地磁盘
+func CheckMounts(cfg *config.Config) error {
+	if !cfg.MountCheck.Enabled() {
+		return nil
+	}
+	for _, dir := range writableTargets(cfg) {
+		if err := checkMount(cfg.MountCheck, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckMounted 检查path所在的媒体库目录是否已挂载，不在媒体库目录之下的路径不检查
+func CheckMounted(cfg *config.Config, path string) error {
+	if !cfg.MountCheck.Enabled() {
+		return nil
+	}
+	root := ""
+	for _, dir := range writableTargets(cfg) {
+		if isWithin(dir, path) && len(dir) > len(root) {
+			root = dir
+		}
+	}
+	if root == "" {
+		return nil
+	}
+	return checkMount(cfg.MountCheck, root)
+}
+
+// checkMount 检查一个媒体库目录：目录或其上级目录中存在标记文件，且与根目录不在同一文件系统
+func checkMount(check config.MountCheckConfig, dir string) error {
+	if check.Marker != "" && findMarker(dir, check.Marker) == "" {
+		return fmt.Errorf("%w: '%s' 及其上级目录中没有标记文件 %s", errs.ErrNotMounted, dir, check.Marker)
+	}
+	if check.RequireMount && utils.SameFilesystem(dir, string(filepath.Separator)) {
+		return fmt.Errorf("%w: '%s' 与根目录位于同一文件系统", errs.ErrNotMounted, dir)
+	}
+	return nil
+}
+
+// findMarker 从dir开始逐级向上查找标记文件，返回找到的路径，没有时返回空字符串
+// 标记文件放在共享的根目录中即可，其下的各媒体库目录都视为已挂载
+func findMarker(dir string, marker string) string {
+	dir = filepath.Clean(dir)
+	for {
+		path := filepath.Join(dir, marker)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isWithin 判断path是否为dir本身或位于dir之下
+func isWithin(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,208 @@
+package classifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/parser"
+)
+
+// ErrPathTooLong 目标路径超过max_path_bytes，缩短目录名后仍然超过
+var ErrPathTooLong = errs.ErrPathTooLong
+
+// NamingFields 目标目录命名模板中可以使用的字段
+type NamingFields struct {
+	Title         string
+	OriginalTitle string
+	Year          string
+	TMDbID        string
+	Resolution    string
+	HDR           string
+	Source        string
+	Audio         string
+	Edition       string
+}
+
+var (
+	// 模板中的占位符，如 {title}
+	namingPlaceholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+	// 字段为空后留下的空括号
+	emptyBracketsRe = regexp.MustCompile(`\(\s*\)|\[\s*\]|（\s*）|【\s*】|\{\s*\}`)
+	// 连续的空白
+	multiSpaceRe = regexp.MustCompile(`\s{2,}`)
+	// 括号内侧的空白
+	openBracketSpaceRe  = regexp.MustCompile(`([(\[（【])\s+`)
+	closeBracketSpaceRe = regexp.MustCompile(`\s+([)\]）】])`)
+	// 名称末尾的括号部分，如 " (2023) [2160p DV]"
+	trailingBracketsRe = regexp.MustCompile(`(\s*[(\[（【][^()\[\]（）【】]*[)\]）】])+$`)
+)
+
+// RenderMediaName 按模板生成目标目录名，如 "{title} ({year}) [{resolution} {hdr}]"
+// 为空的字段连同留下的空括号一起去掉，路径分隔符等不能用于目录名的字符替换为空格
+// 模板为空或生成的名称为空时返回空字符串，由调用方使用源目录名
+func RenderMediaName(template string, fields NamingFields) string {
+	if template == "" {
+		return ""
+	}
+
+	values := map[string]string{
+		"title":          fields.Title,
+		"original_title": fields.OriginalTitle,
+		"year":           fields.Year,
+		"tmdbid":         fields.TMDbID,
+		"resolution":     fields.Resolution,
+		"hdr":            fields.HDR,
+		"source":         fields.Source,
+		"audio":          fields.Audio,
+		"edition":        fields.Edition,
+	}
+	name := namingPlaceholderRe.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := values[strings.Trim(placeholder, "{}")]
+		if !ok {
+			return placeholder
+		}
+		return sanitizeName(value)
+	})
+
+	name = multiSpaceRe.ReplaceAllString(name, " ")
+	for {
+		cleaned := emptyBracketsRe.ReplaceAllString(name, "")
+		cleaned = multiSpaceRe.ReplaceAllString(cleaned, " ")
+		if cleaned == name {
+			break
+		}
+		name = cleaned
+	}
+	name = openBracketSpaceRe.ReplaceAllString(name, "$1")
+	name = closeBracketSpaceRe.ReplaceAllString(name, "$1")
+	return strings.Trim(name, " .-_")
+}
+
+// withEdition 名称中没有影片的版本标记时（模板中没有{edition}）在末尾加上版本，避免同一部电影的不同版本使用同一个目标目录
+func withEdition(name string, edition string) string {
+	if edition == "" || parser.ParseEdition(name) == edition {
+		return name
+	}
+	return name + " " + sanitizeName(edition)
+}
+
+// sanitizeName 替换不能用于目录名的字符
+func sanitizeName(value string) string {
+	return strings.TrimSpace(strings.NewReplacer(
+		"/", " ", "\\", " ", ":", " ", "*", " ", "?", " ",
+		"\"", " ", "<", " ", ">", " ", "|", " ",
+	).Replace(value))
+}
+
+// RenderMediaNameWithin 按模板生成不超过maxBytes字节的目标目录名，maxBytes为0时不限制
+// 超过时依次从末尾缩短原始标题和标题字段并加上完整名称的短哈希，保留年份、画质等其他字段；
+// 缩短的结果只取决于模板和字段，同一部剧的各季得到相同的目录名，前缀相同的不同标题不会重名。标题缩短到一个字仍然超过时返回最短的结果，由调用方继续截断
+func RenderMediaNameWithin(template string, fields NamingFields, maxBytes int) string {
+	name := RenderMediaName(template, fields)
+	if maxBytes <= 0 || len(name) <= maxBytes {
+		return name
+	}
+	tag := " " + nameHash(name)
+	for _, field := range []*string{&fields.OriginalTitle, &fields.Title} {
+		runes := []rune(*field)
+		for len(runes) > 1 {
+			runes = []rune(strings.TrimSpace(string(runes[:len(runes)-1])))
+			*field = string(runes) + tag
+			if name = RenderMediaName(template, fields); len(name) <= maxBytes {
+				return name
+			}
+		}
+		*field = string(runes)
+	}
+	return name
+}
+
+// TruncateName 将目录名缩短到不超过maxBytes字节，在UTF-8字符边界截断并加上完整名称的短哈希，避免前缀相同的长标题缩短后重名
+// 保留末尾的括号部分（如年份、画质），从前面的标题末尾截断；标题部分放不下时直接截断整个名称
+func TruncateName(name string, maxBytes int) string {
+	if maxBytes <= 0 || len(name) <= maxBytes {
+		return name
+	}
+	tag := " " + nameHash(name)
+	if suffix := trailingBracketsRe.FindString(name); suffix != "" && suffix != name {
+		head := strings.TrimRight(truncateBytes(strings.TrimSuffix(name, suffix), maxBytes-len(suffix)-len(tag)), " .-_")
+		if head != "" {
+			return head + tag + suffix
+		}
+	}
+	if head := strings.TrimRight(truncateBytes(name, maxBytes-len(tag)), " .-_"); head != "" {
+		return head + tag
+	}
+	return strings.TrimRight(truncateBytes(name, maxBytes), " .-_")
+}
+
+// nameHash 返回名称的短哈希（SHA-256的前6个十六进制字符），同一名称的结果总是相同
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:3])
+}
+
+// truncateBytes 在UTF-8字符边界截断字符串，结果不超过maxBytes字节
+func truncateBytes(s string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(s) <= maxBytes {
+		return s
+	}
+	end := 0
+	for i, r := range s {
+		if i+utf8.RuneLen(r) > maxBytes {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+	return s[:end]
+}
+
+// longestRelativePath 返回目录中最长的相对路径（如 "Season 01/S01E01.mkv"）的字节数
+func longestRelativePath(dir string) int {
+	longest := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != "." && len(rel) > longest {
+			longest = len(rel)
+		}
+		return nil
+	})
+	return longest
+}
+
+// nameReserveBytes 限制路径长度时为目标目录中的文件（如 "Season 01/S01E01.mkv"）预留的字节数
+// 目录名的缩短只取决于分类目录和名称，不取决于本次移动的文件，同一部剧的各季缩短为相同的目录名
+const nameReserveBytes = 100
+
+// nameBudget 返回targetDir下的目标目录名最多可用的字节数，为目录中的文件预留nameReserveBytes字节
+// 实际的文件路径在缩短后由checkPathLength检查
+func nameBudget(targetDir string, maxBytes int) int {
+	return maxBytes - len(targetDir) - len(string(filepath.Separator)) - nameReserveBytes - len(string(filepath.Separator))
+}
+
+// checkPathLength 检查把mediaDir中的内容移动到targetMediaPath后最长的路径是否超过maxBytes，maxBytes为0时不检查
+func checkPathLength(targetMediaPath string, mediaDir string, maxBytes int) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+	length := len(targetMediaPath)
+	if longestRel := longestRelativePath(mediaDir); longestRel > 0 {
+		length += len(string(filepath.Separator)) + longestRel
+	}
+	if length > maxBytes {
+		return fmt.Errorf("%w: 移动到 '%s' 后最长的路径为 %d 字节，超过max_path_bytes（%d）", ErrPathTooLong, targetMediaPath, length, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package classifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateName(t *testing.T) {
+	long := strings.Repeat("很长的标题", 10)
+	tests := []struct {
+		name     string
+		input    string
+		maxBytes int
+		suffix   string
+	}{
+		{name: "保留年份", input: long + "之一 (2019)", maxBytes: 60, suffix: " (2019)"},
+		{name: "没有括号部分", input: long + "之一", maxBytes: 60},
+		{name: "不需要缩短", input: "流浪地球 (2019)", maxBytes: 60, suffix: " (2019)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateName(tt.input, tt.maxBytes)
+			if len(got) > tt.maxBytes {
+				t.Errorf("TruncateName() = %q（%d字节），超过 %d 字节", got, len(got), tt.maxBytes)
+			}
+			if !strings.HasSuffix(got, tt.suffix) {
+				t.Errorf("TruncateName() = %q，应保留末尾的 %q", got, tt.suffix)
+			}
+			if again := TruncateName(tt.input, tt.maxBytes); again != got {
+				t.Errorf("同一名称的结果不同: %q、%q", got, again)
+			}
+		})
+	}
+
+	// 前缀相同的不同标题缩短后不重名
+	first := TruncateName(long+"之一 (2019)", 60)
+	second := TruncateName(long+"之二 (2019)", 60)
+	if first == second {
+		t.Errorf("不同的标题缩短后重名: %q", first)
+	}
+}
+
+func TestRenderMediaNameWithin(t *testing.T) {
+	const template = "{title} ({year})"
+	long := strings.Repeat("很长的标题", 10)
+	first := RenderMediaNameWithin(template, NamingFields{Title: long + "之一", Year: "2019"}, 60)
+	second := RenderMediaNameWithin(template, NamingFields{Title: long + "之二", Year: "2019"}, 60)
+
+	for _, name := range []string{first, second} {
+		if len(name) > 60 || !strings.HasSuffix(name, " (2019)") {
+			t.Errorf("RenderMediaNameWithin() = %q，应不超过60字节并保留年份", name)
+		}
+	}
+	if first == second {
+		t.Errorf("不同的标题缩短后重名: %q", first)
+	}
+	if name := RenderMediaNameWithin(template, NamingFields{Title: "流浪地球", Year: "2019"}, 60); name != "流浪地球 (2019)" {
+		t.Errorf("RenderMediaNameWithin() = %q，不需要缩短时应保持不变", name)
+	}
+}
@@ -0,0 +1,74 @@
+package classifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/parser"
+)
+
+// Prediction 不访问网络、不移动文件时预测的分类结果
+type Prediction struct {
+	Category string `json:"category,omitempty"`
+	Rule     string `json:"rule,omitempty"`    // 会拒绝移动的规则
+	Reason   string `json:"reason,omitempty"`  // 拒绝原因，或无法预测的说明
+	Guessed  bool   `json:"guessed,omitempty"` // 没有NFO，按文件名推测
+}
+
+// String 返回预测结果的简短说明
+func (p Prediction) String() string {
+	switch {
+	case p.Rule != "":
+		return "跳过: " + p.Rule
+	case p.Category == "":
+		return "未知"
+	case p.Guessed:
+		return p.Category + "（推测）"
+	default:
+		return p.Category
+	}
+}
+
+// PredictCategory 只根据NFO文件预测分类：检查解析NFO后的规则，按NFO中的国家、类型确定分类
+// 实际处理时TMDB和元数据插件返回的国家、语言可能改变分类；NFO中没有国家时需要查询TMDB，无法预测
+func PredictCategory(cfg *config.Config, nfoPath string) Prediction {
+	nfo, err := parser.ParseNFO(nfoPath)
+	if err != nil {
+		return Prediction{Reason: fmt.Sprintf("解析NFO失败: %v", err)}
+	}
+	ctx := &RuleContext{Config: cfg, NFOPath: nfoPath, NFO: nfo, MediaDir: filepath.Dir(nfoPath)}
+	for _, rule := range SourceRules {
+		if decision := rule.Check(ctx); !decision.Allow {
+			return Prediction{Rule: rule.Name, Reason: decision.Reason}
+		}
+	}
+
+	category, ok := predictFromNFO(cfg, nfo)
+	if !ok {
+		return Prediction{Reason: "NFO中没有国家，需要查询TMDB"}
+	}
+	return Prediction{Category: category}
+}
+
+// predictFromNFO 按NFO中的国家和类型确定分类，NFO中没有国家、无法确定时ok为false
+func predictFromNFO(cfg *config.Config, nfo *parser.NFO) (category string, ok bool) {
+	if nfo.IsMusicVideo() {
+		return cfg.MusicCategory, true
+	}
+	if len(nfo.Country) == 0 {
+		return "", false
+	}
+	category, err := DetermineCategory(nfo.Country, nfo.IsTVShow(), nfo.Genres, "")
+	return category, err == nil
+}
+
+// PredictUnscraped 按目录名和视频文件名推测未刮削目录的分类
+func PredictUnscraped(mediaDir string, isTVShow bool) Prediction {
+	guess := GuessCategory(mediaDir, isTVShow)
+	if guess.Category == "" {
+		return Prediction{Reason: "无法按文件名推测"}
+	}
+	return Prediction{Category: guess.Category, Reason: strings.Join(guess.Reasons, "；"), Guessed: true}
+}
@@ -0,0 +1,342 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// VideoQuality 影片版本的画质信息，用于比较同一影片的不同版本
+type VideoQuality struct {
+	Height     int      // 分辨率高度，如 2160、1080，未知时为0
+	HDRFormats []string // HDR格式，为空表示SDR
+	Source     string   // 片源：REMUX、BluRay、WEB-DL、WEBRip、HDTV，以及枪版CAM、TS、TC、SCR，未知时为空
+}
+
+// 片源的优先级，数值越大越好
+var sourceRanks = map[string]int{
+	"REMUX":  4,
+	"BluRay": 3,
+	"WEB-DL": 2,
+	"WEBRip": 1,
+	"HDTV":   1,
+}
+
+// HDR格式的优先级，数值越大越好
+var hdrRanks = map[string]int{
+	parser.HDRDolbyVision: 4,
+	parser.HDR10Plus:      3,
+	parser.HDR10:          2,
+	parser.HDRGeneric:     2,
+	parser.HDRHLG:         1,
+}
+
+var (
+	// 文件名中的分辨率
+	heightRe = regexp.MustCompile(`(?i)\b(4320|2160|1440|1080|720|576|480)[pi]\b`)
+	// 文件名中的4K、8K
+	kResolutionRe = regexp.MustCompile(`(?i)\b([48])K\b`)
+	// 文件名中的片源，按优先级排列
+	sourcePatterns = []struct {
+		re     *regexp.Regexp
+		source string
+	}{
+		{regexp.MustCompile(`(?i)\bREMUX\b`), "REMUX"},
+		{regexp.MustCompile(`(?i)\b(Blu-?Ray|BDRip|BD)\b`), "BluRay"},
+		{regexp.MustCompile(`(?i)\bWEB-?DL\b`), "WEB-DL"},
+		{regexp.MustCompile(`(?i)\bWEB-?Rip\b`), "WEBRip"},
+		{regexp.MustCompile(`(?i)\bHDTV\b`), "HDTV"},
+		{regexp.MustCompile(`(?i)\b(HD-?CAM|CAM-?Rip|CAM)\b|枪版|槍版`), "CAM"},
+		{regexp.MustCompile(`(?i)\b(HD-?TS|TELESYNC|TS)\b`), "TS"},
+		{regexp.MustCompile(`(?i)\b(HD-?TC|TELECINE|TC)\b`), "TC"},
+		{regexp.MustCompile(`(?i)\b(DVD-?SCR|SCREENER|SCR)\b`), "SCR"},
+	}
+)
+
+// ffprobeVideoOutput ffprobe输出的视频流信息
+type ffprobeVideoOutput struct {
+	Streams []struct {
+		Width         int    `json:"width"`
+		Height        int    `json:"height"`
+		ColorTransfer string `json:"color_transfer"`
+		SideDataList  []struct {
+			SideDataType string `json:"side_data_type"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+}
+
+// DetectVideoQuality 检测目录中影片的分辨率、HDR格式和片源
+// 分辨率和HDR格式优先通过ffprobe读取最大的视频文件，读取不到时使用目录名和文件名中的标注
+func DetectVideoQuality(mediaDir string) VideoQuality {
+	var quality VideoQuality
+	names := []string{filepath.Base(mediaDir)}
+	var largestVideo string
+	var largestSize int64
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if !info.IsDir() && isVideoFile(path) && !isSampleVideo(mediaDir, path) {
+			// 去掉扩展名，避免.ts被识别为TS片源
+			names = append(names, strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())))
+			if info.Size() >= largestSize {
+				largestVideo, largestSize = path, info.Size()
+			}
+		}
+		return nil
+	})
+
+	if ffprobe := ffprobePath(); ffprobe != "" && largestVideo != "" {
+		height, hdrFormats, err := probeVideoStream(ffprobe, largestVideo)
+		if err != nil {
+			logging.Warning("读取视频信息失败: %s: %v", largestVideo, err)
+		} else {
+			quality.Height = height
+			quality.HDRFormats = hdrFormats
+		}
+	}
+
+	for _, name := range names {
+		named := QualityFromName(name)
+		if quality.Height == 0 {
+			quality.Height = named.Height
+		}
+		if quality.Source == "" {
+			quality.Source = named.Source
+		}
+		if len(quality.HDRFormats) == 0 {
+			quality.HDRFormats = named.HDRFormats
+		}
+	}
+	return quality
+}
+
+// sampleNameRe 样片、预告片的文件名或目录名
+var sampleNameRe = regexp.MustCompile(`(?i)\b(sample|trailer)s?\b|样片|预告片`)
+
+// isSampleVideo 判断视频文件是否为样片或预告片（文件名或影片目录下的子目录名中标注），这些文件不参与画质判断
+func isSampleVideo(mediaDir string, path string) bool {
+	rel, err := filepath.Rel(mediaDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if sampleNameRe.MatchString(strings.NewReplacer(".", " ", "_", " ").Replace(part)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolutionOutlier 分辨率与多数视频文件不同的文件
+type ResolutionOutlier struct {
+	File       string // 相对于影片目录的路径
+	Resolution string
+}
+
+// ResolutionVote 按影片目录中各视频文件名标注的分辨率投票的结果
+type ResolutionVote struct {
+	Resolution string              // 多数文件的分辨率，如 1080p，票数相同时取较低的分辨率；没有文件标注时为空
+	Files      int                 // 标注了分辨率的视频文件数
+	Outliers   []ResolutionOutlier // 分辨率与结果不同的文件
+}
+
+// voteResolution 读取影片目录中所有视频文件（忽略样片和预告片）名称中标注的分辨率，按多数确定影片的分辨率
+// 电视剧各集的分辨率不一致时取多数，票数相同时取较低的分辨率，其余文件记录为不一致的文件
+func voteResolution(mediaDir string) ResolutionVote {
+	type namedFile struct {
+		rel    string
+		height int
+	}
+	var files []namedFile
+	votes := make(map[int]int)
+	utils.Walk(mediaDir, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if info.IsDir() || !isVideoFile(path) || isSampleVideo(mediaDir, path) {
+			return nil
+		}
+		name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		height := heightFromName(strings.NewReplacer(".", " ", "_", " ").Replace(name))
+		if height == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(mediaDir, path)
+		if err != nil {
+			rel = info.Name()
+		}
+		files = append(files, namedFile{rel: rel, height: height})
+		votes[height]++
+		return nil
+	})
+
+	var vote ResolutionVote
+	best := 0
+	for height, count := range votes {
+		if count > votes[best] || (count == votes[best] && height < best) {
+			best = height
+		}
+	}
+	if best == 0 {
+		return vote
+	}
+	vote.Resolution = VideoQuality{Height: best}.Resolution()
+	vote.Files = len(files)
+	for _, file := range files {
+		if file.height != best {
+			vote.Outliers = append(vote.Outliers, ResolutionOutlier{File: file.rel, Resolution: VideoQuality{Height: file.height}.Resolution()})
+		}
+	}
+	return vote
+}
+
+// OutlierSummary 返回不一致文件的说明，如 Season 01/S01E03.mkv: 720p、Season 01/S01E07.mkv: 2160p
+func (v ResolutionVote) OutlierSummary() string {
+	parts := make([]string, len(v.Outliers))
+	for i, outlier := range v.Outliers {
+		parts[i] = filepath.ToSlash(outlier.File) + ": " + outlier.Resolution
+	}
+	return strings.Join(parts, "、")
+}
+
+// QualityFromName 从发布名称（目录名、文件名或索引器中的标题）的标注中解析分辨率、HDR格式和片源
+func QualityFromName(name string) VideoQuality {
+	normalized := strings.NewReplacer(".", " ", "_", " ").Replace(name)
+	quality := VideoQuality{
+		Height:     heightFromName(normalized),
+		HDRFormats: parser.ParseReleaseTags(name).HDRFormats,
+	}
+	for _, pattern := range sourcePatterns {
+		if pattern.re.MatchString(normalized) {
+			quality.Source = pattern.source
+			break
+		}
+	}
+	return quality
+}
+
+// heightFromName 从名称中解析分辨率高度，没有标注时返回0
+func heightFromName(name string) int {
+	if match := heightRe.FindStringSubmatch(name); match != nil {
+		height, _ := strconv.Atoi(match[1])
+		return height
+	}
+	if match := kResolutionRe.FindStringSubmatch(name); match != nil {
+		if match[1] == "8" {
+			return 4320
+		}
+		return 2160
+	}
+	return 0
+}
+
+// probeVideoStream 通过ffprobe读取第一个视频流的分辨率高度和HDR格式
+func probeVideoStream(ffprobe string, videoPath string) (int, []string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height,color_transfer:stream_side_data=side_data_type",
+		"-of", "json",
+		videoPath,
+	).Output()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var result ffprobeVideoOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return 0, nil, err
+	}
+	if len(result.Streams) == 0 {
+		return 0, nil, fmt.Errorf("没有视频流")
+	}
+
+	stream := result.Streams[0]
+	var formats []string
+	for _, sideData := range stream.SideDataList {
+		switch {
+		case strings.Contains(sideData.SideDataType, "DOVI"):
+			formats = append(formats, parser.HDRDolbyVision)
+		case strings.Contains(sideData.SideDataType, "SMPTE2094-40"):
+			formats = append(formats, parser.HDR10Plus)
+		}
+	}
+	switch stream.ColorTransfer {
+	case "smpte2084":
+		if len(formats) == 0 || formats[len(formats)-1] != parser.HDR10Plus {
+			formats = append(formats, parser.HDR10)
+		}
+	case "arib-std-b67":
+		formats = append(formats, parser.HDRHLG)
+	}
+	return stream.Height, formats, nil
+}
+
+// Score 返回版本的画质评分，分辨率优先，其次是HDR格式和片源
+// 例如杜比视界的REMUX高于SDR的1080p
+func (q VideoQuality) Score() int {
+	score := 0
+	switch {
+	case q.Height >= 2160:
+		score = 400
+	case q.Height >= 1440:
+		score = 300
+	case q.Height >= 1080:
+		score = 200
+	case q.Height >= 720:
+		score = 100
+	}
+	bestHDR := 0
+	for _, format := range q.HDRFormats {
+		if rank := hdrRanks[format]; rank > bestHDR {
+			bestHDR = rank
+		}
+	}
+	return score + bestHDR*10 + sourceRanks[q.Source]
+}
+
+// Resolution 返回分辨率标签，如 2160p，未知时为空
+func (q VideoQuality) Resolution() string {
+	if q.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dp", q.Height)
+}
+
+// HDR 返回HDR格式标签，如 DV HDR10，SDR时为空
+func (q VideoQuality) HDR() string {
+	return strings.Join(q.HDRFormats, " ")
+}
+
+// String 返回便于记录日志的画质描述，如 2160p DV REMUX
+func (q VideoQuality) String() string {
+	parts := []string{}
+	if resolution := q.Resolution(); resolution != "" {
+		parts = append(parts, resolution)
+	}
+	if hdr := q.HDR(); hdr != "" {
+		parts = append(parts, hdr)
+	} else {
+		parts = append(parts, "SDR")
+	}
+	if q.Source != "" {
+		parts = append(parts, q.Source)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,48 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/permissions"
+)
+
+// RecategorizedPath 返回媒体库中的影片目录改为新分类后的目录，目录中有tvshow.nfo时按电视剧处理
+func RecategorizedPath(cfg *config.Config, targetPath string, category string) string {
+	_, err := os.Stat(filepath.Join(targetPath, "tvshow.nfo"))
+	return filepath.Join(cfg.CategoryDir(category, err == nil), filepath.Base(targetPath))
+}
+
+// MoveToCategory 把媒体库中的影片目录移动到新分类目录，并更新目标路径相同的所有记录（电视剧的各季），返回新的目录
+// 新分类目录中已有同名目录时不移动
+func MoveToCategory(cfg *config.Config, targetPath string, category string) (string, error) {
+	newPath := RecategorizedPath(cfg, targetPath, category)
+	if samePath(targetPath, newPath) {
+		return targetPath, nil
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return "", fmt.Errorf("目标目录已存在: %s", newPath)
+	}
+	if err := mkdirAll(filepath.Dir(newPath)); err != nil {
+		return "", fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	releaseMoveSlot := acquireMoveSlot(cfg.Workers(config.ConcurrencyMove), targetPath)
+	err := MoveDirectory(targetPath, newPath)
+	releaseMoveSlot()
+	if err != nil {
+		return "", fmt.Errorf("移动影片失败: %w", err)
+	}
+	if err := permissions.Apply(cfg.Permissions, newPath); err != nil {
+		logging.Warning("设置 '%s' 的所有者和权限失败: %v", newPath, err)
+	}
+
+	if _, err := database.UpdateMediaTargetPath(targetPath, newPath); err != nil {
+		return newPath, err
+	}
+	return newPath, nil
+}
@@ -0,0 +1,100 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/safety"
+)
+
+// RecentDirName 媒体库根目录下汇总最近入库项目的目录名，其中只有指向各分类目录中项目的符号链接
+const RecentDirName = "_Recent"
+
+// AddRecentLink 在最近入库目录中为刚移动的项目创建符号链接，并清理过期的链接
+// 链接使用相对路径，媒体库挂载到其他位置（如容器中）时仍然有效
+func AddRecentLink(cfg *config.Config, targetPath string) error {
+	if cfg.RecentDays <= 0 {
+		return nil
+	}
+
+	recentDir := filepath.Join(cfg.CloudDir, RecentDirName)
+	if err := os.MkdirAll(recentDir, 0755); err != nil {
+		return fmt.Errorf("创建最近入库目录失败: %w", err)
+	}
+
+	relTarget, err := filepath.Rel(recentDir, targetPath)
+	if err != nil {
+		relTarget = targetPath
+	}
+
+	linkPath := filepath.Join(recentDir, filepath.Base(targetPath))
+	if err := safety.Check(linkPath); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(linkPath); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("最近入库目录中已存在同名的非链接文件: %s", linkPath)
+		}
+		// 重新入库（合并新季、替换版本）时重建链接，重新计算保留时间
+		if err := os.Remove(linkPath); err != nil {
+			return fmt.Errorf("删除旧的最近入库链接失败: %w", err)
+		}
+	}
+	if err := os.Symlink(relTarget, linkPath); err != nil {
+		return fmt.Errorf("创建最近入库链接失败: %w", err)
+	}
+	logging.Debug("已创建最近入库链接: %s -> %s", linkPath, relTarget)
+
+	return PruneRecentLinks(cfg)
+}
+
+// PruneRecentLinks 删除最近入库目录中创建时间超过recent_days天或目标已不存在的链接
+// 目录中的普通文件和目录不会被删除
+func PruneRecentLinks(cfg *config.Config) error {
+	if cfg.RecentDays <= 0 {
+		return nil
+	}
+
+	recentDir := filepath.Join(cfg.CloudDir, RecentDirName)
+	entries, err := os.ReadDir(recentDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取最近入库目录失败: %w", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -cfg.RecentDays)
+	for _, entry := range entries {
+		linkPath := filepath.Join(recentDir, entry.Name())
+		info, err := os.Lstat(linkPath)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		reason := ""
+		if info.ModTime().Before(cutoff) {
+			reason = fmt.Sprintf("入库超过 %d 天", cfg.RecentDays)
+		} else if _, err := os.Stat(linkPath); err != nil {
+			reason = "目标已不存在"
+		}
+		if reason == "" {
+			continue
+		}
+		if err := safety.Check(linkPath); err != nil {
+			logging.Warning("删除最近入库链接失败: %v", err)
+			continue
+		}
+
+		if err := os.Remove(linkPath); err != nil {
+			logging.Warning("删除最近入库链接失败: %v", err)
+			continue
+		}
+		logging.Debug("已删除最近入库链接 %s（%s）", entry.Name(), reason)
+	}
+	return nil
+}
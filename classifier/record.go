@@ -0,0 +1,246 @@
+package classifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/plugins"
+	"github.com/user/media-manager/tmdb"
+)
+
+// Recording 分类一个影片目录时使用的输入和得到的结果，保存到数据库后可以用Replay离线重现分类决策
+// 只记录NFO内容和各数据源的响应，不包含影片文件
+type Recording struct {
+	NFOPath        string            `json:"nfo_path"`
+	NFO            string            `json:"nfo"`                    // 分类前的NFO内容（UTF-8）
+	EnrichedNFO    string            `json:"enriched_nfo,omitempty"` // Bangumi补充后的NFO内容
+	TMDB           *RecordedTMDB     `json:"tmdb,omitempty"`         // 没有请求TMDB时为空
+	Metadata       *RecordedMetadata `json:"metadata,omitempty"`     // 没有请求元数据插件时为空
+	PluginCategory string            `json:"plugin_category,omitempty"`
+	PluginName     string            `json:"plugin_name,omitempty"`
+	ManualCategory string            `json:"manual_category,omitempty"` // classify set或db update手动指定的分类
+	Quality        *VideoQuality     `json:"quality,omitempty"`         // 确定分类后检测，之前结束时为空
+	AudioLanguages []string          `json:"audio_languages,omitempty"`
+	Outcome        Outcome           `json:"outcome"`
+}
+
+// RecordedTMDB TMDB详情请求的结果
+type RecordedTMDB struct {
+	Countries        []string `json:"countries,omitempty"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	SpokenLanguages  []string `json:"spoken_languages,omitempty"`
+	Error            string   `json:"error,omitempty"` // 请求失败或TMDB中没有该条目时的错误
+}
+
+// RecordedMetadata 元数据插件返回的国家和语言
+type RecordedMetadata struct {
+	Countries        []string `json:"countries,omitempty"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+}
+
+// Outcome 分类决策的结果：移动到的分类，或拒绝移动的规则，或出错
+type Outcome struct {
+	Category   string `json:"category,omitempty"`
+	TargetPath string `json:"target_path,omitempty"`
+	Rule       string `json:"rule,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Note       string `json:"note,omitempty"` // 重现时无法继续检查的说明
+}
+
+// String 返回结果的简短说明
+func (o Outcome) String() string {
+	switch {
+	case o.Error != "":
+		return "错误: " + o.Error
+	case o.Rule != "":
+		return "跳过: " + o.Rule
+	case o.Note != "":
+		return o.Note
+	default:
+		return o.Category
+	}
+}
+
+// Same 判断两个结果的分类和拒绝规则是否相同，移动时的错误无法离线重现，不比较
+func (o Outcome) Same(other Outcome) bool {
+	return o.Category == other.Category && o.Rule == other.Rule
+}
+
+// replayableRules 只读取NFO、元数据和画质，可以离线重新检查的规则；其他规则依赖文件系统，重现时沿用记录的结果
+var replayableRules = map[string]bool{
+	RuleUnresolvedNFO:   true,
+	RuleMissingCountry:  true,
+	RuleNonChineseTitle: true,
+	RuleNonChineseGenre: true,
+	RuleBelowMinQuality: true,
+}
+
+// startRecording 开始记录分类决策，replay_runs为-1或无法读取NFO时返回nil
+func startRecording(cfg *config.Config, nfoPath string) *Recording {
+	if cfg.ReplayRuns < 0 {
+		return nil
+	}
+	content, _, err := parser.ReadNFOFile(nfoPath)
+	if err != nil {
+		return nil
+	}
+	return &Recording{NFOPath: nfoPath, NFO: string(content)}
+}
+
+// deny 记录拒绝移动的规则
+func (r *Recording) deny(denial *Denial) {
+	if r != nil {
+		r.Outcome.Rule = denial.Rule
+		r.Outcome.Reason = denial.Reason
+	}
+}
+
+// recordTMDB 记录TMDB详情请求的结果
+func (r *Recording) recordTMDB(details *tmdb.Details, err error) {
+	if r == nil {
+		return
+	}
+	if err != nil {
+		r.TMDB = &RecordedTMDB{Error: err.Error()}
+		return
+	}
+	r.TMDB = &RecordedTMDB{
+		Countries:        details.Countries,
+		OriginalLanguage: details.OriginalLanguage,
+		SpokenLanguages:  details.SpokenLanguages,
+	}
+}
+
+// recordMetadata 记录元数据插件返回的国家和语言
+func (r *Recording) recordMetadata(metadata *plugins.Metadata) {
+	if r != nil {
+		r.Metadata = &RecordedMetadata{Countries: metadata.Countries, OriginalLanguage: metadata.OriginalLanguage}
+	}
+}
+
+// recordEnrichedNFO 记录Bangumi补充后的NFO内容，没有修改时不记录
+func (r *Recording) recordEnrichedNFO(nfoPath string) {
+	if r == nil {
+		return
+	}
+	if content, _, err := parser.ReadNFOFile(nfoPath); err == nil && string(content) != r.NFO {
+		r.EnrichedNFO = string(content)
+	}
+}
+
+// save 记录结束时的错误并保存到数据库
+func (r *Recording) save(err error) {
+	if r == nil {
+		return
+	}
+	if err != nil {
+		r.Outcome.Error = err.Error()
+	}
+	data, jsonErr := json.Marshal(r)
+	if jsonErr != nil {
+		logging.Error("序列化分类决策记录失败: %v", jsonErr)
+		return
+	}
+	if err := database.SaveDecisionRecord(filepath.Dir(r.NFOPath), string(data)); err != nil {
+		logging.Error("%v", err)
+	}
+}
+
+// Replay 使用记录的NFO内容和数据源响应，按当前配置重新执行分类决策，不访问网络和影片文件
+// 依赖文件系统的规则沿用记录的结果；记录在某个阶段结束、而重现时通过了该阶段时，结果中说明无法继续检查
+func Replay(cfg *config.Config, rec *Recording) Outcome {
+	nfo, err := parser.ParseNFOContent([]byte(rec.NFO))
+	if err != nil {
+		return Outcome{Error: err.Error()}
+	}
+	ctx := &RuleContext{Config: cfg, NFOPath: rec.NFOPath, NFO: nfo, MediaDir: filepath.Dir(rec.NFOPath)}
+	if denial := replayRules(SourceRules, ctx, rec.Outcome); denial != nil {
+		return Outcome{Rule: denial.Rule, Reason: denial.Reason}
+	}
+
+	// 与ClassifyAndMove相同的顺序合并NFO、TMDB和元数据插件的国家和语言
+	countries := nfo.Country
+	originalLanguage := ""
+	if rec.TMDB != nil && rec.TMDB.Error == "" {
+		countries = rec.TMDB.Countries
+		originalLanguage = rec.TMDB.OriginalLanguage
+	}
+	if rec.Metadata != nil {
+		if len(countries) == 0 {
+			countries = rec.Metadata.Countries
+		}
+		if originalLanguage == "" {
+			originalLanguage = rec.Metadata.OriginalLanguage
+		}
+	}
+	ctx.Countries = countries
+	if len(countries) == 0 && !nfo.IsMusicVideo() {
+		ctx.CountryFallback = resolveMissingCountry(cfg, originalLanguage, nfo.IsTVShow())
+	}
+	if denial := replayRules(MetadataRules, ctx, rec.Outcome); denial != nil {
+		return Outcome{Rule: denial.Rule, Reason: denial.Reason}
+	}
+	fallback := ctx.CountryFallback
+	if fallback != nil && fallback.Quarantine {
+		return Outcome{Category: fallback.Category}
+	}
+
+	var category string
+	switch {
+	case nfo.IsMusicVideo():
+		category = cfg.MusicCategory
+	case fallback != nil && fallback.Category != "":
+		category = fallback.Category
+	case fallback != nil:
+		countries = fallback.Countries
+		fallthrough
+	default:
+		if category, err = DetermineCategory(countries, nfo.IsTVShow(), nfo.Genres, originalLanguage); err != nil {
+			return Outcome{Error: fmt.Sprintf("确定分类失败: %v", err)}
+		}
+	}
+	if rec.PluginCategory != "" {
+		category = rec.PluginCategory
+	}
+	if rec.ManualCategory != "" {
+		category = rec.ManualCategory
+	}
+	if rec.Quality == nil {
+		return Outcome{Category: category, Note: fmt.Sprintf("%s（记录在确定分类之前结束，无法重现之后的检查）", category)}
+	}
+	if rec.EnrichedNFO != "" && usesBangumi(cfg, category) {
+		if enriched, err := parser.ParseNFOContent([]byte(rec.EnrichedNFO)); err == nil {
+			ctx.NFO = enriched
+		}
+	}
+
+	ctx.Category = category
+	ctx.Quality = *rec.Quality
+	ctx.AudioLanguages = rec.AudioLanguages
+	if denial := replayRules(MoveRules, ctx, rec.Outcome); denial != nil {
+		return Outcome{Category: category, Rule: denial.Rule, Reason: denial.Reason}
+	}
+	return Outcome{Category: category}
+}
+
+// replayRules 按顺序重新检查可以离线检查的规则，其他规则只在记录中被其拒绝时拒绝
+func replayRules(rules []Rule, ctx *RuleContext, recorded Outcome) *Denial {
+	for _, rule := range rules {
+		if !replayableRules[rule.Name] {
+			if rule.Name == recorded.Rule {
+				return &Denial{Rule: rule.Name, Reason: recorded.Reason}
+			}
+			continue
+		}
+		if decision := rule.Check(ctx); !decision.Allow {
+			return &Denial{Rule: rule.Name, Reason: decision.Reason, Warning: decision.Warning}
+		}
+	}
+	return nil
+}
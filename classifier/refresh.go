@@ -0,0 +1,96 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// RefreshMetadata 重新查询TMDB，更新媒体库中项目的NFO简介和数据库记录，返回有变化的字段
+// 电视剧同时重新检查季数完整性；标题、年份和国家决定了目录名和分类，不在这里修改
+// 没有变化时也会更新记录的更新时间，避免下次刷新时重复查询
+func RefreshMetadata(record *database.MediaRecord) ([]string, error) {
+	if record.TMDbID == "" {
+		return nil, fmt.Errorf("'%s' 没有TMDB ID", record.Title)
+	}
+	isTVShow := strings.Contains(record.Category, "Show")
+
+	details, err := tmdb.GetDetails(record.TMDbID, isTVShow)
+	if err != nil {
+		return nil, fmt.Errorf("获取TMDB详情失败: %w", err)
+	}
+
+	var changed []string
+	if details.Overview != "" && details.Overview != record.Plot {
+		if err := updateNFOPlot(record, isTVShow, details.Overview, details.OverviewSource()); err != nil {
+			logging.Warning("更新 '%s' 的NFO简介失败: %v", record.Title, err)
+		}
+		record.Plot = details.Overview
+		record.PlotSource = details.OverviewSource()
+		changed = append(changed, "plot")
+	}
+	if details.OriginalLanguage != "" && details.OriginalLanguage != record.OriginalLanguage {
+		record.OriginalLanguage = details.OriginalLanguage
+		changed = append(changed, "original_language")
+	}
+	if spoken := strings.Join(details.SpokenLanguages, ","); spoken != "" && spoken != record.SpokenLanguages {
+		record.SpokenLanguages = spoken
+		changed = append(changed, "spoken_languages")
+	}
+
+	if isTVShow {
+		// 已播出新季时重新评估完整性并记录缺失的季，同时保存记录
+		wasComplete := record.IsComplete
+		if err := DetectMissingSeasonsAndEpisodes(record); err != nil {
+			return changed, err
+		}
+		if record.IsComplete != wasComplete {
+			changed = append(changed, "is_complete")
+		}
+		return changed, nil
+	}
+
+	if err := database.InsertOrUpdateMediaRecord(record); err != nil {
+		return changed, fmt.Errorf("更新媒体记录失败: %w", err)
+	}
+	return changed, nil
+}
+
+// updateNFOPlot 更新媒体库中项目NFO文件的简介，source不为空时（简介来自TMDB的备用语言）用注释记录来源
+func updateNFOPlot(record *database.MediaRecord, isTVShow bool, plot string, source string) error {
+	nfoPath := filepath.Join(record.TargetPath, record.FileName)
+	if isTVShow {
+		nfoPath = filepath.Join(record.TargetPath, "tvshow.nfo")
+	}
+	if _, err := os.Stat(nfoPath); err != nil {
+		return fmt.Errorf("NFO文件不存在: %s", nfoPath)
+	}
+
+	doc, err := parser.LoadDocument(nfoPath)
+	if err != nil {
+		return err
+	}
+	if err := doc.SetElements("plot", []string{plot}, "outline", "title"); err != nil {
+		return err
+	}
+	// 简介改为TMDB的内容，不再是百科或之前的备用语言补充的
+	if err := doc.RemovePlotSourceComment(); err != nil {
+		return err
+	}
+	if source != "" {
+		if err := doc.InsertBeforeRootEnd(parser.PlotSourceComment(source, "")); err != nil {
+			return err
+		}
+	}
+	if _, err := doc.Save(); err != nil {
+		return err
+	}
+	logging.Info("已更新 '%s' 的NFO简介: %s", record.Title, nfoPath)
+	return nil
+}
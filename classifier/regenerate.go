@@ -0,0 +1,160 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// RegeneratedNFO 重新生成的NFO文件
+type RegeneratedNFO struct {
+	Path     string // NFO文件路径
+	Content  string // 写入的内容
+	UsedTMDB bool   // 是否使用了TMDB的最新详情
+}
+
+// RegenerateNFO 根据数据库记录和TMDB的最新详情重新生成媒体库中项目的Kodi格式NFO文件
+// 标题、原始标题、类型、演员等沿用记录中的内容（目录名和分类由它们决定），简介、国家、语言和首映日期优先使用TMDB的最新数据
+// TMDB不可用时只使用记录中的内容；write为false时只生成内容不写入
+func RegenerateNFO(cfg *config.Config, record *database.MediaRecord, write bool) (*RegeneratedNFO, error) {
+	isTVShow := strings.Contains(record.Category, "Show")
+	rootTag := "movie"
+	if isTVShow {
+		rootTag = "tvshow"
+	} else if record.Category == cfg.MusicCategory {
+		rootTag = "musicvideo"
+	}
+
+	var details *tmdb.Details
+	if record.TMDbID != "" && cfg.TMDBApiKey != "" {
+		var err error
+		details, err = tmdb.GetDetails(record.TMDbID, isTVShow)
+		if errors.Is(err, tmdb.ErrCachedNotFound) {
+			logging.Debug("%v，NFO只使用数据库记录中的信息", err)
+		} else if err != nil {
+			logging.Warning("从TMDB获取详情失败: %v，NFO只使用数据库记录中的信息", err)
+		}
+	}
+
+	result := &RegeneratedNFO{
+		Path:     filepath.Join(record.TargetPath, regeneratedNFOName(record, rootTag)),
+		Content:  recordNFOContent(record, details, rootTag),
+		UsedTMDB: details != nil,
+	}
+	if !write {
+		return result, nil
+	}
+	if _, err := os.Stat(record.TargetPath); err != nil {
+		return nil, fmt.Errorf("目标目录不存在: %s", record.TargetPath)
+	}
+	if err := CheckMounted(cfg, record.TargetPath); err != nil {
+		return nil, err
+	}
+	if err := parser.WriteNFOFile(result.Path, []byte(result.Content)); err != nil {
+		return nil, fmt.Errorf("写入NFO文件失败: %w", err)
+	}
+	if err := database.RecordEvent(record.ID, record.TargetPath, database.EventNFORegenerated, result.Path); err != nil {
+		logging.Error("%v", err)
+	}
+	logging.Info("已重新生成 '%s' 的NFO文件: %s", record.Title, result.Path)
+	return result, nil
+}
+
+// regeneratedNFOName 返回重新生成的NFO文件名：电视剧为tvshow.nfo，电影和音乐视频沿用记录中的NFO文件名，没有时为 movie.nfo、musicvideo.nfo
+func regeneratedNFOName(record *database.MediaRecord, rootTag string) string {
+	if rootTag == "tvshow" {
+		return showNFOName
+	}
+	if strings.EqualFold(filepath.Ext(record.FileName), ".nfo") {
+		return record.FileName
+	}
+	return rootTag + ".nfo"
+}
+
+// recordNFOContent 由数据库记录和TMDB详情生成NFO的内容，details为nil时只使用记录中的信息
+func recordNFOContent(record *database.MediaRecord, details *tmdb.Details, rootTag string) string {
+	year, plot, plotSource := record.Year, record.Plot, record.PlotSource
+	countries := splitList(record.Country)
+	languages := record.SpokenLanguages
+	premiered := ""
+	if details != nil {
+		if year == "" && details.ReleaseYear() > 0 {
+			year = fmt.Sprint(details.ReleaseYear())
+		}
+		if strings.TrimSpace(details.Overview) != "" {
+			plot, plotSource = details.Overview, details.OverviewSource()
+		}
+		if len(details.Countries) > 0 {
+			countries = details.Countries
+		}
+		if len(details.SpokenLanguages) > 0 {
+			languages = strings.Join(details.SpokenLanguages, ",")
+		}
+		premiered = details.ReleaseDate
+	}
+
+	var content strings.Builder
+	content.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\" ?>\n")
+	content.WriteString("<!-- 由media-manager根据数据库记录重新生成 -->\n")
+	fmt.Fprintf(&content, "<%s>\n", rootTag)
+	writeElement := func(name, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&content, "  <%s>%s</%s>\n", name, escapeXMLText(value), name)
+		}
+	}
+	writeElement("title", record.Title)
+	writeElement("originaltitle", record.OriginalTitle)
+	writeElement("year", year)
+	writeElement("premiered", premiered)
+	writeElement("plot", plot)
+	writeElement("runtime", record.Runtime)
+	writeElement("rating", record.Rating)
+	for _, genre := range splitList(record.Genres) {
+		writeElement("genre", genre)
+	}
+	for _, country := range countries {
+		writeElement("country", country)
+	}
+	writeElement("director", record.Director)
+	writeElement("writer", record.Writer)
+	writeElement("languages", languages)
+	writeElement("id", record.IMDbID)
+	writeElement("tmdbid", record.TMDbID)
+	if record.TMDbID != "" {
+		fmt.Fprintf(&content, "  <uniqueid type=\"tmdb\" default=\"true\">%s</uniqueid>\n", escapeXMLText(record.TMDbID))
+	}
+	if record.IMDbID != "" {
+		fmt.Fprintf(&content, "  <uniqueid type=\"imdb\">%s</uniqueid>\n", escapeXMLText(record.IMDbID))
+	}
+	for _, name := range splitList(record.Actors) {
+		if rootTag == "musicvideo" {
+			writeElement("artist", name)
+			continue
+		}
+		fmt.Fprintf(&content, "  <actor>\n    <name>%s</name>\n  </actor>\n", escapeXMLText(name))
+	}
+	if plotSource != "" {
+		content.WriteString("  " + parser.PlotSourceComment(plotSource, "") + "\n")
+	}
+	fmt.Fprintf(&content, "</%s>\n", rootTag)
+	return content.String()
+}
+
+// splitList 拆分记录中以逗号分隔的列表（国家、类型、演员），去掉空白和空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
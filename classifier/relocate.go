@@ -0,0 +1,119 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+)
+
+// Relocation 目标路径已不存在、根据tvshow.nfo中的TMDB ID找到新位置的剧集记录
+type Relocation struct {
+	Title   string
+	OldPath string
+	NewPath string
+	Records int // 更新的记录数（每季一条）
+}
+
+// VerifyTargetPaths 检查数据库记录的目标路径是否存在
+// 剧集目录被手动改名或移动时，按tvshow.nfo中的TMDB ID在媒体库中找到新目录并更新记录；返回已更新的剧集和仍找不到的记录
+func VerifyTargetPaths(cfg *config.Config) ([]Relocation, []database.MediaRecord, error) {
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	var showDirs map[string]string
+	var relocations []Relocation
+	var missing []database.MediaRecord
+	relocated := make(map[string]bool)
+	for _, record := range records {
+		if record.TargetPath == "" || relocated[record.TargetPath] {
+			continue
+		}
+		if _, err := os.Stat(record.TargetPath); err == nil {
+			continue
+		}
+
+		if strings.Contains(record.Category, "Show") && record.TMDbID != "" {
+			if showDirs == nil {
+				showDirs = indexShowDirs(cfg.CloudDirs(), 1)
+			}
+			if newPath, ok := showDirs[record.TMDbID]; ok {
+				count, err := database.UpdateMediaTargetPath(record.TargetPath, newPath)
+				if err != nil {
+					return relocations, missing, err
+				}
+				logging.Info("剧集 '%s' 的目录已从 %s 改为 %s，已更新 %d 条记录", record.Title, record.TargetPath, newPath, count)
+				for _, r := range records {
+					if r.TargetPath == record.TargetPath {
+						if err := database.RecordEvent(r.ID, r.TargetPath, database.EventVerified, fmt.Sprintf("目录已改为 %s", newPath)); err != nil {
+							logging.Error("%v", err)
+						}
+					}
+				}
+				relocations = append(relocations, Relocation{Title: record.Title, OldPath: record.TargetPath, NewPath: newPath, Records: count})
+				relocated[record.TargetPath] = true
+				continue
+			}
+		}
+		missing = append(missing, record)
+	}
+	return relocations, missing, nil
+}
+
+// relocatedShowPath 计算出的剧集目标目录不存在时，在分类目录中查找tvshow.nfo的TMDB ID相同的目录
+// 剧集目录被手动改名后，新的季合并到改名后的目录，而不是再创建一个同名目录
+func relocatedShowPath(targetDir, targetMediaPath, tmdbID string) string {
+	if tmdbID == "" {
+		return targetMediaPath
+	}
+	if _, err := os.Stat(targetMediaPath); err == nil {
+		return targetMediaPath
+	}
+	if dir, ok := indexShowDirs([]string{targetDir}, 0)[tmdbID]; ok {
+		logging.Info("分类目录中TMDB ID为 %s 的剧集目录已改名为 '%s'，合并到该目录", tmdbID, filepath.Base(dir))
+		return dir
+	}
+	return targetMediaPath
+}
+
+// indexShowDirs 读取目录中各剧集目录的tvshow.nfo，返回TMDB ID到剧集目录的映射
+// depth为1时同时检查下一层：媒体库根目录下是分类目录，category_dirs中的目录下直接是剧集目录
+func indexShowDirs(roots []string, depth int) map[string]string {
+	index := make(map[string]string)
+	var scan func(dir string, depth int)
+	scan = func(dir string, depth int) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if !isDirEntry(dir, entry) || entry.Name() == RecentDirName {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			nfoPath := filepath.Join(path, "tvshow.nfo")
+			if _, err := os.Stat(nfoPath); err == nil {
+				if nfo, err := parser.ParseNFO(nfoPath); err == nil && nfo.TMDbID != "" {
+					if _, exists := index[nfo.TMDbID]; !exists {
+						index[nfo.TMDbID] = path
+					}
+				}
+				continue
+			}
+			if depth > 0 {
+				scan(path, depth-1)
+			}
+		}
+	}
+	for _, root := range roots {
+		scan(root, depth)
+	}
+	return index
+}
@@ -0,0 +1,338 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// 门禁规则名称，跳过移动时记录在日志中，与errs中跳过原因的代码相同
+const (
+	RuleIgnored         = "ignored"           // 目录包含忽略标记文件
+	RuleIncomplete      = "incomplete"        // 目录中存在下载未完成的标记文件
+	RuleNotSettled      = "not_settled"       // 项目还在复制或下载中
+	RuleOutsideTemp     = "outside_temp"      // 目录不在配置的Temp目录中（开启adopt_in_place时媒体库中的目录除外）
+	RuleMultipleNFO     = "multiple_nfo"      // 目录下有多个NFO文件且没有选中当前文件
+	RuleUnresolvedNFO   = "unresolved_nfo"    // NFO信息不完整（未正确刮削）
+	RuleMissingCountry  = "missing_country"   // 没有获取到国家信息
+	RuleProjectFiles    = "project_files"     // 目录中存在项目文件
+	RuleNonChineseTitle = "non_chinese_title" // 标题不是简体中文
+	RuleNonChineseGenre = "non_chinese_genre" // 类型不是简体中文
+	RuleBelowMinQuality = "below_min_quality" // 画质低于配置的最低要求
+	RuleTargetExists    = "target_exists"     // 目标目录已存在且没有可合并的新季
+	RuleLowFreeSpace    = "low_free_space"    // 移动后目标文件系统的剩余空间会低于min_free_space_gb
+	RuleLocked          = "locked"            // 媒体库中的目录或目标目录属于由db lock锁定的记录
+)
+
+// RuleContext 门禁规则检查时使用的影片信息
+// 各阶段的规则只读取该阶段之前已确定的字段
+type RuleContext struct {
+	Config   *config.Config
+	NFOPath  string
+	NFO      *parser.NFO
+	MediaDir string
+	Adopt    bool // 由outside_temp规则填写：目录已在媒体库中，按adopt_in_place在媒体库内更正分类
+
+	// 获取元数据后确定
+	Countries       []string
+	CountryFallback *countryFallback // 没有国家信息时按missing_country采用的处理，为nil时留在Temp目录
+
+	// 确定分类后确定
+	Category        string
+	TargetMediaPath string
+	AudioLanguages  []string
+	Quality         VideoQuality
+
+	// 由target_exists规则填写：目标目录是否已存在，可以合并的新季，以及是否用偏好音轨的版本替换目标目录
+	TargetExists  bool
+	NewSeasons    []int
+	ReplaceTarget bool
+}
+
+// Decision 单条规则的检查结果
+type Decision struct {
+	Allow   bool   // 是否允许继续
+	Reason  string // 拒绝原因，或允许时需要记录的说明
+	Warning bool   // 以警告级别记录
+}
+
+// Rule 一条命名的门禁规则
+type Rule struct {
+	Name  string
+	Check func(ctx *RuleContext) Decision
+}
+
+// Denial 规则拒绝的结果
+type Denial struct {
+	Rule    string
+	Reason  string
+	Warning bool
+}
+
+// Err 返回跳过移动的类型化错误，可以用errors.Is与errs中对应规则的错误值比较
+func (d *Denial) Err(mediaDir string) error {
+	return errs.Skip(errs.Lookup(d.Rule), mediaDir, d.Reason)
+}
+
+// allow 允许继续
+func allow() Decision {
+	return Decision{Allow: true}
+}
+
+// deny 拒绝并说明原因
+func deny(format string, args ...interface{}) Decision {
+	return Decision{Reason: fmt.Sprintf(format, args...)}
+}
+
+// denyWarning 拒绝并以警告级别记录
+func denyWarning(format string, args ...interface{}) Decision {
+	return Decision{Reason: fmt.Sprintf(format, args...), Warning: true}
+}
+
+// 移动前按顺序检查的规则，分为三个阶段
+var (
+	// SourceRules 解析NFO后、请求元数据之前检查
+	SourceRules = []Rule{
+		{RuleIgnored, checkIgnored},
+		{RuleOutsideTemp, checkOutsideTemp},
+		{RuleLocked, checkLocked},
+		{RuleIncomplete, checkIncomplete},
+		{RuleNotSettled, checkSettled},
+		{RuleMultipleNFO, checkMultipleNFO},
+		{RuleUnresolvedNFO, checkUnresolvedNFO},
+	}
+
+	// MetadataRules 获取国家和语言信息后、确定分类之前检查
+	MetadataRules = []Rule{
+		{RuleMissingCountry, checkMissingCountry},
+	}
+
+	// MoveRules 确定分类后、移动之前检查
+	MoveRules = []Rule{
+		{RuleProjectFiles, checkProjectFiles},
+		{RuleNonChineseTitle, checkNonChineseTitle},
+		{RuleNonChineseGenre, checkNonChineseGenre},
+		{RuleBelowMinQuality, checkMinQuality},
+		{RuleLocked, checkLocked},
+		{RuleTargetExists, checkTargetExists},
+		{RuleLowFreeSpace, checkFreeSpace},
+	}
+)
+
+// EvaluateRules 按顺序检查规则，返回第一条拒绝的规则，全部通过时返回nil
+// 允许但带有说明的结果会记录到日志
+func EvaluateRules(rules []Rule, ctx *RuleContext) *Denial {
+	for _, rule := range rules {
+		decision := rule.Check(ctx)
+		if !decision.Allow {
+			return &Denial{Rule: rule.Name, Reason: decision.Reason, Warning: decision.Warning}
+		}
+		if decision.Reason != "" {
+			if decision.Warning {
+				logging.Warning("%s", decision.Reason)
+			} else {
+				logging.Info("%s", decision.Reason)
+			}
+		}
+	}
+	return nil
+}
+
+// recordDenial 记录被规则拒绝而跳过移动的影片，并计入跳过统计
+func recordDenial(denial *Denial, mediaDir string) {
+	if denial.Warning {
+		logging.Warning("[%s] %s，跳过移动: %s", denial.Rule, denial.Reason, mediaDir)
+	} else {
+		logging.Info("[%s] %s，跳过移动: %s", denial.Rule, denial.Reason, mediaDir)
+	}
+
+	if err := database.RecordSkip(mediaDir, denial.Rule, denial.Reason); err != nil {
+		logging.Error("%v", err)
+	}
+}
+
+// checkIgnored 目录被忽略标记文件排除时拒绝
+func checkIgnored(ctx *RuleContext) Decision {
+	if utils.IsIgnoredPath(ctx.MediaDir) {
+		return deny("目录被忽略标记文件（%s）排除", strings.Join(utils.IgnoreMarkerFiles, "、"))
+	}
+	return allow()
+}
+
+// checkOutsideTemp 只移动配置的Temp目录中的影片
+func checkOutsideTemp(ctx *RuleContext) Decision {
+	if IsUnderTempDirs(ctx.MediaDir, ctx.Config.TempDirs) {
+		return allow()
+	}
+	if IsUnderTempDirs(ctx.MediaDir, ctx.Config.CloudDirs()) {
+		if !ctx.Config.AdoptInPlace {
+			return denyWarning("目录已在媒体库中，开启adopt_in_place后可更正分类")
+		}
+		ctx.Adopt = true
+		return Decision{Allow: true, Reason: fmt.Sprintf("目录 %s 已在媒体库中，将在媒体库内更正分类", ctx.MediaDir)}
+	}
+	return denyWarning("目录不在配置的Temp目录 %v 中", ctx.Config.TempDirs)
+}
+
+// checkMultipleNFO 目录下有多个NFO文件时，只处理按策略选中的文件
+func checkMultipleNFO(ctx *RuleContext) Decision {
+	nfoFiles, err := parser.ListNFOFiles(ctx.MediaDir)
+	if err != nil {
+		return denyWarning("读取目录失败: %v", err)
+	}
+
+	nfoCount := len(nfoFiles)
+	if nfoCount <= 1 {
+		return allow()
+	}
+	if ctx.Config.NFOSelection == parser.NFOSelectDefault {
+		return denyWarning("目录下存在 %d 个NFO文件，请手动选择正确的NFO文件后再处理", nfoCount)
+	}
+
+	selected, reason := parser.SelectNFOFile(nfoFiles, ctx.Config.NFOSelection)
+	if filepath.Clean(selected) != filepath.Clean(ctx.NFOPath) {
+		return denyWarning("目录下存在 %d 个NFO文件，按策略 %s 应处理 %s（%s）", nfoCount, ctx.Config.NFOSelection, selected, reason)
+	}
+	return Decision{Allow: true, Reason: fmt.Sprintf("目录 %s 下存在 %d 个NFO文件，按策略 %s 使用: %s（%s）", ctx.MediaDir, nfoCount, ctx.Config.NFOSelection, ctx.NFOPath, reason)}
+}
+
+// checkUnresolvedNFO NFO文件信息不完整时拒绝
+func checkUnresolvedNFO(ctx *RuleContext) Decision {
+	if !isNFOResolved(ctx.NFO) {
+		return deny("NFO文件信息不完整（可能未正确刮削）")
+	}
+	return allow()
+}
+
+// checkMissingCountry 没有国家信息时无法分类，音乐视频不按国家分类
+func checkMissingCountry(ctx *RuleContext) Decision {
+	if ctx.NFO.IsMusicVideo() || len(ctx.Countries) > 0 {
+		return allow()
+	}
+	if ctx.CountryFallback != nil {
+		return Decision{Allow: true, Reason: fmt.Sprintf("没有获取到有效的国家信息，%s: %s", ctx.CountryFallback, ctx.MediaDir), Warning: true}
+	}
+	return denyWarning("没有获取到有效的国家信息")
+}
+
+// checkProjectFiles 目录中存在项目文件时按配置警告或拒绝
+func checkProjectFiles(ctx *RuleContext) Decision {
+	if ctx.Config.ProjectCheck == config.ProjectCheckOff {
+		return allow()
+	}
+	marker := ProjectMarker(ctx.MediaDir)
+	if marker == "" {
+		return allow()
+	}
+	if ctx.Config.ProjectCheck == config.ProjectCheckSkip {
+		return deny("目录中存在项目文件 %s", marker)
+	}
+	return Decision{Allow: true, Reason: fmt.Sprintf("目录中存在项目文件 %s，仍继续移动: %s", marker, ctx.MediaDir), Warning: true}
+}
+
+// checkNonChineseTitle 标题不是简体中文时拒绝
+func checkNonChineseTitle(ctx *RuleContext) Decision {
+	if !utils.IsSimplifiedChinese(ctx.NFO.Title) {
+		return deny("标题 '%s' 不是简体中文", ctx.NFO.Title)
+	}
+	return allow()
+}
+
+// checkNonChineseGenre 任一类型不是简体中文时拒绝
+func checkNonChineseGenre(ctx *RuleContext) Decision {
+	for _, genre := range ctx.NFO.Genres {
+		if !utils.IsSimplifiedChinese(genre) {
+			return deny("类型 '%s' 不是简体中文", genre)
+		}
+	}
+	return allow()
+}
+
+// checkMinQuality 分辨率低于最低要求或片源在拒绝列表中时拒绝，豁免的分类不检查
+func checkMinQuality(ctx *RuleContext) Decision {
+	minQuality := ctx.Config.MinQuality
+	for _, category := range minQuality.ExemptCategories {
+		if category == ctx.Category {
+			return allow()
+		}
+	}
+
+	for _, source := range minQuality.RejectSources {
+		if strings.EqualFold(source, ctx.Quality.Source) {
+			return denyWarning("片源 %s 在拒绝列表中（画质 %s）", ctx.Quality.Source, ctx.Quality)
+		}
+	}
+	if minQuality.MinHeight > 0 && ctx.Quality.Height > 0 && ctx.Quality.Height < minQuality.MinHeight {
+		return denyWarning("分辨率 %s 低于最低要求 %dp", ctx.Quality.Resolution(), minQuality.MinHeight)
+	}
+	return allow()
+}
+
+// checkTargetExists 目标目录已存在时，电影只有新版本包含偏好音轨而旧版本不包含、或开启画质升级且新版本画质更高时才允许替换；
+// 电视剧只有存在新的季时才允许合并
+func checkTargetExists(ctx *RuleContext) Decision {
+	if _, err := os.Stat(ctx.TargetMediaPath); err != nil {
+		ctx.TargetExists = false
+		return allow()
+	}
+	ctx.TargetExists = true
+
+	// 已在媒体库中的项目：已在正确的位置时只更新记录，不替换媒体库中的其他版本
+	if ctx.Adopt {
+		if samePath(ctx.TargetMediaPath, ctx.MediaDir) {
+			return allow()
+		}
+		if !ctx.NFO.IsTVShow() {
+			return denyWarning("正确的分类目录中已存在同名文件夹 '%s'", ctx.TargetMediaPath)
+		}
+	}
+
+	if !ctx.NFO.IsTVShow() {
+		if reason, replace := replacementReason(ctx); replace {
+			ctx.ReplaceTarget = true
+			return Decision{Allow: true, Reason: reason}
+		}
+		return denyWarning("目标目录已存在同名文件夹 '%s'", ctx.TargetMediaPath)
+	}
+
+	hasNew, seasonsToAdd, err := HasNewSeasons(ctx.MediaDir, ctx.TargetMediaPath, ctx.NFO.TMDbID)
+	if err != nil {
+		return denyWarning("检查新季数失败: %v", err)
+	}
+	if !hasNew {
+		return denyWarning("目标目录已存在同名文件夹 '%s'，且没有检测到新的季数", ctx.TargetMediaPath)
+	}
+	ctx.NewSeasons = seasonsToAdd
+	return Decision{Allow: true, Reason: fmt.Sprintf("目标目录已存在，但检测到新的季数 %v，将合并到目标目录", seasonsToAdd)}
+}
+
+// replacementReason 判断电影的新版本是否应该替换目标目录中已有的版本，返回替换原因
+// 偏好音轨优先：新版本包含偏好音轨而旧版本不包含时替换，旧版本包含而新版本不包含时不因画质替换
+func replacementReason(ctx *RuleContext) (string, bool) {
+	if preferred := ctx.Config.PreferredAudio; preferred != "" {
+		existing := AudioLanguages(ctx.TargetMediaPath)
+		newHas, oldHas := hasAudioLanguage(ctx.AudioLanguages, preferred), hasAudioLanguage(existing, preferred)
+		if newHas && !oldHas {
+			return fmt.Sprintf("目标目录 '%s' 的音轨 %v 不包含偏好的%s，将用音轨为 %v 的新版本替换", ctx.TargetMediaPath, existing, parser.NormalizeAudioLanguage(preferred), ctx.AudioLanguages), true
+		}
+		if oldHas && !newHas {
+			return "", false
+		}
+	}
+
+	if ctx.Config.UpgradeQuality {
+		existing := DetectVideoQuality(ctx.TargetMediaPath)
+		if ctx.Quality.Score() > existing.Score() {
+			return fmt.Sprintf("新版本画质 %s 高于目标目录 '%s' 的 %s，将替换", ctx.Quality, ctx.TargetMediaPath, existing), true
+		}
+	}
+	return "", false
+}
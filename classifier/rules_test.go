@@ -0,0 +1,474 @@
+package classifier
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/internal/testkit"
+	"github.com/user/media-manager/parser"
+)
+
+// ruleEnv 规则测试使用的testkit环境
+type ruleEnv struct {
+	*testkit.Env
+	t   *testing.T
+	cfg *config.Config
+}
+
+func newRuleEnv(t *testing.T) *ruleEnv {
+	t.Helper()
+	env := testkit.New(t)
+	env.Config.IntakeRules = nil
+	return &ruleEnv{Env: env, t: t, cfg: env.Config}
+}
+
+// movieDir 在Temp/Movie下创建影片目录和其中的文件，返回目录路径
+func (e *ruleEnv) movieDir(name string, files ...string) string {
+	e.t.Helper()
+	dir := e.TempPath("Movie", name)
+	e.touch(dir, files...)
+	return dir
+}
+
+// touch 在目录中创建空文件，必要时创建目录
+func (e *ruleEnv) touch(dir string, files ...string) {
+	e.t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		e.t.Fatal(err)
+	}
+	for _, file := range files {
+		if err := testkit.Touch(filepath.Join(dir, file)); err != nil {
+			e.t.Fatal(err)
+		}
+	}
+}
+
+// context 返回影片目录的规则上下文，NFO为已刮削的中文电影
+func (e *ruleEnv) context(dir string) *RuleContext {
+	return &RuleContext{
+		Config:   e.cfg,
+		NFOPath:  filepath.Join(dir, "movie.nfo"),
+		NFO:      resolvedMovie(),
+		MediaDir: dir,
+	}
+}
+
+func resolvedMovie() *parser.NFO {
+	return &parser.NFO{
+		XMLName: xml.Name{Local: "movie"},
+		Title:   "流浪地球",
+		Year:    "2019",
+		TMDbID:  "535167",
+		Country: []string{"中国大陆"},
+		Genres:  []string{"科幻"},
+	}
+}
+
+// deniedBy 返回拒绝的规则名称，全部通过时返回空字符串
+func deniedBy(rules []Rule, ctx *RuleContext) string {
+	if denial := EvaluateRules(rules, ctx); denial != nil {
+		return denial.Rule
+	}
+	return ""
+}
+
+func TestEvaluateRulesOrder(t *testing.T) {
+	// 带说明的结果写入日志，日志输出到标准输出
+	testkit.New(t)
+	var called []string
+	rule := func(name string, decision Decision) Rule {
+		return Rule{name, func(*RuleContext) Decision {
+			called = append(called, name)
+			return decision
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		rules      []Rule
+		wantDenied string
+		wantCalled []string
+	}{
+		{
+			name:       "全部通过",
+			rules:      []Rule{rule("a", allow()), rule("b", allow())},
+			wantCalled: []string{"a", "b"},
+		},
+		{
+			name:       "第一条拒绝的规则优先，之后的规则不再检查",
+			rules:      []Rule{rule("a", allow()), rule("b", deny("b")), rule("c", deny("c"))},
+			wantDenied: "b",
+			wantCalled: []string{"a", "b"},
+		},
+		{
+			name:       "带说明的允许结果继续检查",
+			rules:      []Rule{rule("a", Decision{Allow: true, Reason: "说明", Warning: true}), rule("b", denyWarning("b"))},
+			wantDenied: "b",
+			wantCalled: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = nil
+			denial := EvaluateRules(tt.rules, &RuleContext{})
+			got := ""
+			if denial != nil {
+				got = denial.Rule
+				if denial.Reason != got {
+					t.Errorf("Reason = %q，应为 %q", denial.Reason, got)
+				}
+			}
+			if got != tt.wantDenied {
+				t.Errorf("拒绝的规则 = %q，应为 %q", got, tt.wantDenied)
+			}
+			if len(called) != len(tt.wantCalled) {
+				t.Fatalf("检查的规则 = %v，应为 %v", called, tt.wantCalled)
+			}
+			for i := range called {
+				if called[i] != tt.wantCalled[i] {
+					t.Fatalf("检查的规则 = %v，应为 %v", called, tt.wantCalled)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(e *ruleEnv) *RuleContext
+		want  string
+	}{
+		{
+			name: "全部通过",
+			setup: func(e *ruleEnv) *RuleContext {
+				return e.context(e.movieDir("流浪地球 (2019)", "流浪地球.mkv", "movie.nfo"))
+			},
+		},
+		{
+			name: "忽略标记文件",
+			setup: func(e *ruleEnv) *RuleContext {
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", ".mmignore"))
+			},
+			want: RuleIgnored,
+		},
+		{
+			name: "忽略标记优先于下载未完成",
+			setup: func(e *ruleEnv) *RuleContext {
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", ".nomedia", "流浪地球.mkv.part"))
+			},
+			want: RuleIgnored,
+		},
+		{
+			name: "不在Temp目录中",
+			setup: func(e *ruleEnv) *RuleContext {
+				dir := filepath.Join(filepath.Dir(e.cfg.TempDirs[0]), "Other", "流浪地球 (2019)")
+				e.touch(dir, "movie.nfo")
+				return e.context(dir)
+			},
+			want: RuleOutsideTemp,
+		},
+		{
+			name: "Temp目录本身",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.touch(e.cfg.TempDirs[0], "movie.nfo")
+				return e.context(e.cfg.TempDirs[0])
+			},
+			want: RuleOutsideTemp,
+		},
+		{
+			name: "媒体库中的目录没有开启adopt_in_place",
+			setup: func(e *ruleEnv) *RuleContext {
+				dir := filepath.Join(e.cfg.CloudDir, "CnMovie", "流浪地球 (2019)")
+				e.touch(dir, "movie.nfo")
+				return e.context(dir)
+			},
+			want: RuleOutsideTemp,
+		},
+		{
+			name: "下载未完成的标记文件",
+			setup: func(e *ruleEnv) *RuleContext {
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.mkv.aria2"))
+			},
+			want: RuleIncomplete,
+		},
+		{
+			name: "入库规则：没有匹配路径时使用路径为空的规则",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.cfg.IncompleteMarkers = nil
+				e.cfg.IntakeRules = []config.IntakeRule{
+					{PartialExtensions: []string{".tmp"}},
+					{Path: filepath.Join(e.cfg.TempDirs[0], "TvShow"), PartialExtensions: []string{".part"}},
+				}
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.mkv.tmp"))
+			},
+			want: RuleNotSettled,
+		},
+		{
+			name: "入库规则：路径最长的规则优先",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.cfg.IncompleteMarkers = nil
+				e.cfg.IntakeRules = []config.IntakeRule{
+					{Path: e.cfg.TempDirs[0], PartialExtensions: []string{".tmp"}},
+					{Path: filepath.Join(e.cfg.TempDirs[0], "Movie"), PartialExtensions: []string{".part"}},
+				}
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.mkv.tmp"))
+			},
+		},
+		{
+			name: "入库规则：最近修改过的文件",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.cfg.IntakeRules = []config.IntakeRule{{SettleMinutes: 10}}
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.mkv"))
+			},
+			want: RuleNotSettled,
+		},
+		{
+			name: "多个NFO文件且没有选择策略",
+			setup: func(e *ruleEnv) *RuleContext {
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.nfo", "流浪地球.mkv"))
+			},
+			want: RuleMultipleNFO,
+		},
+		{
+			name: "多个NFO文件时处理按策略选中的文件",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.cfg.NFOSelection = parser.NFOSelectStandard
+				return e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.nfo", "流浪地球.mkv"))
+			},
+		},
+		{
+			name: "多个NFO文件时跳过没有选中的文件",
+			setup: func(e *ruleEnv) *RuleContext {
+				e.cfg.NFOSelection = parser.NFOSelectStandard
+				ctx := e.context(e.movieDir("流浪地球 (2019)", "movie.nfo", "流浪地球.nfo", "流浪地球.mkv"))
+				ctx.NFOPath = filepath.Join(ctx.MediaDir, "流浪地球.nfo")
+				return ctx
+			},
+			want: RuleMultipleNFO,
+		},
+		{
+			name: "NFO信息不完整",
+			setup: func(e *ruleEnv) *RuleContext {
+				ctx := e.context(e.movieDir("Unknown", "movie.nfo"))
+				ctx.NFO = &parser.NFO{XMLName: xml.Name{Local: "movie"}, Title: "Unknown"}
+				return ctx
+			},
+			want: RuleUnresolvedNFO,
+		},
+		{
+			name: "只有中文标题和年份时视为已刮削",
+			setup: func(e *ruleEnv) *RuleContext {
+				ctx := e.context(e.movieDir("笑傲江湖 (1990)", "movie.nfo"))
+				ctx.NFO = &parser.NFO{XMLName: xml.Name{Local: "movie"}, Title: "笑傲江湖", Year: "1990"}
+				return ctx
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.setup(newRuleEnv(t))
+			if got := deniedBy(SourceRules, ctx); got != tt.want {
+				t.Errorf("拒绝的规则 = %q，应为 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutsideTempAdopt(t *testing.T) {
+	e := newRuleEnv(t)
+	e.cfg.AdoptInPlace = true
+	dir := filepath.Join(e.cfg.CloudDir, "CnMovie", "流浪地球 (2019)")
+	e.touch(dir, "movie.nfo")
+	ctx := e.context(dir)
+
+	decision := checkOutsideTemp(ctx)
+	if !decision.Allow || decision.Reason == "" {
+		t.Fatalf("开启adopt_in_place时应允许并说明，得到 %+v", decision)
+	}
+	if !ctx.Adopt {
+		t.Error("应标记为在媒体库内更正分类")
+	}
+}
+
+func TestMetadataRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		nfo      string
+		country  []string
+		fallback *countryFallback
+		want     string
+		warning  bool
+	}{
+		{name: "有国家信息", nfo: "movie", country: []string{"中国大陆"}},
+		{name: "音乐视频不按国家分类", nfo: "musicvideo"},
+		{name: "没有国家信息时按missing_country处理", nfo: "movie", fallback: &countryFallback{Category: "EnMovie", Note: "category:EnMovie"}, warning: true},
+		{name: "没有国家信息也没有处理方式", nfo: "tvshow", want: RuleMissingCountry, warning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &RuleContext{
+				Config:          testkit.New(t).Config,
+				NFO:             &parser.NFO{XMLName: xml.Name{Local: tt.nfo}, Title: "标题"},
+				Countries:       tt.country,
+				CountryFallback: tt.fallback,
+			}
+			if got := deniedBy(MetadataRules, ctx); got != tt.want {
+				t.Errorf("拒绝的规则 = %q，应为 %q", got, tt.want)
+			}
+			if decision := checkMissingCountry(ctx); decision.Warning != tt.warning {
+				t.Errorf("Warning = %v，应为 %v", decision.Warning, tt.warning)
+			}
+		})
+	}
+}
+
+func TestMoveRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(e *ruleEnv, ctx *RuleContext)
+		files []string
+		want  string
+	}{
+		{name: "全部通过"},
+		{
+			name:  "项目文件：默认只警告",
+			files: []string{"go.mod"},
+		},
+		{
+			name:  "项目文件：配置为skip时拒绝",
+			files: []string{"package.json"},
+			setup: func(e *ruleEnv, ctx *RuleContext) { e.cfg.ProjectCheck = config.ProjectCheckSkip },
+			want:  RuleProjectFiles,
+		},
+		{
+			name:  "项目文件：配置为off时不检查",
+			files: []string{"Makefile"},
+			setup: func(e *ruleEnv, ctx *RuleContext) { e.cfg.ProjectCheck = config.ProjectCheckOff },
+		},
+		{
+			name:  "项目文件优先于非中文标题",
+			files: []string{"main.go"},
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.ProjectCheck = config.ProjectCheckSkip
+				ctx.NFO.Title = "The Wandering Earth"
+			},
+			want: RuleProjectFiles,
+		},
+		{
+			name:  "标题不是简体中文",
+			setup: func(e *ruleEnv, ctx *RuleContext) { ctx.NFO.Title = "The Wandering Earth" },
+			want:  RuleNonChineseTitle,
+		},
+		{
+			name:  "类型不是简体中文",
+			setup: func(e *ruleEnv, ctx *RuleContext) { ctx.NFO.Genres = []string{"科幻", "Adventure"} },
+			want:  RuleNonChineseGenre,
+		},
+		{
+			name: "片源在拒绝列表中",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.MinQuality.RejectSources = []string{"CAM"}
+				ctx.Quality = VideoQuality{Height: 1080, Source: "cam"}
+			},
+			want: RuleBelowMinQuality,
+		},
+		{
+			name: "分辨率低于最低要求",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.MinQuality.MinHeight = 720
+				ctx.Quality = VideoQuality{Height: 480}
+			},
+			want: RuleBelowMinQuality,
+		},
+		{
+			name: "无法识别分辨率时不受最低要求限制",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.MinQuality.MinHeight = 720
+			},
+		},
+		{
+			name: "豁免的分类不检查画质",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.MinQuality.MinHeight = 720
+				e.cfg.MinQuality.ExemptCategories = []string{"CnMovie"}
+				ctx.Quality = VideoQuality{Height: 480, Source: "CAM"}
+			},
+		},
+		{
+			name: "目标目录已存在",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.touch(ctx.TargetMediaPath, "流浪地球.mkv")
+			},
+			want: RuleTargetExists,
+		},
+		{
+			name: "目标目录已存在且新版本画质更高",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				e.cfg.UpgradeQuality = true
+				e.touch(ctx.TargetMediaPath, "流浪地球.720p.mkv")
+				ctx.Quality = VideoQuality{Height: 2160, Source: "BluRay"}
+			},
+		},
+		{
+			name: "目标目录已锁定",
+			setup: func(e *ruleEnv, ctx *RuleContext) {
+				record := &database.MediaRecord{Title: "流浪地球", Year: "2019", Category: "CnMovie", TargetPath: ctx.TargetMediaPath}
+				if err := database.InsertOrUpdateMediaRecord(record); err != nil {
+					e.t.Fatal(err)
+				}
+				if err := database.SetLocked(record.ID, true); err != nil {
+					e.t.Fatal(err)
+				}
+			},
+			want: RuleLocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRuleEnv(t)
+			ctx := e.context(e.movieDir("流浪地球 (2019)", append([]string{"movie.nfo", "流浪地球.mkv"}, tt.files...)...))
+			ctx.Category = "CnMovie"
+			ctx.TargetMediaPath = filepath.Join(e.cfg.CategoryDir("CnMovie", false), "流浪地球 (2019)")
+			if tt.setup != nil {
+				tt.setup(e, ctx)
+			}
+			if got := deniedBy(MoveRules, ctx); got != tt.want {
+				t.Errorf("拒绝的规则 = %q，应为 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetExistsNewSeasons(t *testing.T) {
+	e := newRuleEnv(t)
+	dir := filepath.Join(e.cfg.TempDirs[0], "TvShow", "三体 (2023)")
+	e.touch(dir, "tvshow.nfo", "Season 02/S02E01.mkv")
+	target := filepath.Join(e.cfg.CloudDir, "CnTvShow", "三体 (2023)")
+	e.touch(target, "tvshow.nfo", "Season 01/S01E01.mkv")
+
+	ctx := &RuleContext{
+		Config:          e.cfg,
+		NFO:             &parser.NFO{XMLName: xml.Name{Local: "tvshow"}, Title: "三体"},
+		MediaDir:        dir,
+		TargetMediaPath: target,
+	}
+	decision := checkTargetExists(ctx)
+	if !decision.Allow {
+		t.Fatalf("有新的季时应允许合并，得到 %+v", decision)
+	}
+	if !ctx.TargetExists || len(ctx.NewSeasons) != 1 || ctx.NewSeasons[0] != 2 {
+		t.Errorf("TargetExists = %v，NewSeasons = %v，应为 true、[2]", ctx.TargetExists, ctx.NewSeasons)
+	}
+
+	// 已有的季不再合并
+	e.touch(target, "Season 02/S02E01.mkv")
+	ctx.NewSeasons = nil
+	if decision := checkTargetExists(ctx); decision.Allow {
+		t.Errorf("没有新的季时应拒绝，得到 %+v", decision)
+	}
+}
@@ -0,0 +1,132 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/safety"
+)
+
+// looseEpisodeSeason 返回按SxxEyy命名的视频文件的季号，不是视频文件或名称中没有SxxEyy时ok为false
+func looseEpisodeSeason(name string) (season int, ok bool) {
+	if !isVideoFile(name) {
+		return 0, false
+	}
+	matches := episodeFileRe.FindStringSubmatch(name)
+	if len(matches) < 3 {
+		return 0, false
+	}
+	season, err := strconv.Atoi(matches[1])
+	return season, err == nil
+}
+
+// looseEpisodeSeasons 返回剧集根目录中平铺的剧集文件包含的季号，不包括特别篇（第0季）
+func looseEpisodeSeasons(showDir string) []int {
+	entries, err := os.ReadDir(showDir)
+	if err != nil {
+		return nil
+	}
+	var seasons []int
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if season, ok := looseEpisodeSeason(entry.Name()); ok && season > 0 && !containsSeason(seasons, season) {
+			seasons = append(seasons, season)
+		}
+	}
+	return seasons
+}
+
+// seasonDirName 返回第season季的目录名：依次在dirs中查找已有的同一季目录，都没有时为 Season 01 格式
+func seasonDirName(season int, dirs ...string) string {
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if season > 0 && isDirEntry(dir, entry) && GetSeasonNumberFromDirName(entry.Name()) == season {
+				return entry.Name()
+			}
+		}
+	}
+	return fmt.Sprintf("Season %02d", season)
+}
+
+// sortSeasonPack 把剧集根目录中按SxxEyy命名的视频文件和同名的字幕、NFO、缩略图等文件移动到对应的季目录
+// 季目录名优先使用showDir和nameDirs（如合并的目标剧集目录）中已有的同一季目录，返回移动的视频文件数
+func sortSeasonPack(showDir string, nameDirs ...string) (int, error) {
+	entries, err := os.ReadDir(showDir)
+	if err != nil {
+		return 0, fmt.Errorf("读取剧集目录失败: %w", err)
+	}
+
+	// 视频文件名（不含扩展名）到季号的映射，同名的其他文件随视频文件移动
+	episodes := make(map[string]int)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if season, ok := looseEpisodeSeason(entry.Name()); ok {
+			episodes[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = season
+		}
+	}
+	if len(episodes) == 0 {
+		return 0, nil
+	}
+
+	dirNames := make(map[int]string)
+	moved := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		season, ok := episodeOfFile(entry.Name(), episodes)
+		if !ok {
+			continue
+		}
+		dirName, ok := dirNames[season]
+		if !ok {
+			dirName = seasonDirName(season, append([]string{showDir}, nameDirs...)...)
+			dirNames[season] = dirName
+		}
+
+		srcPath := filepath.Join(showDir, entry.Name())
+		dstPath := filepath.Join(showDir, dirName, entry.Name())
+		if _, err := os.Lstat(dstPath); err == nil {
+			logging.Warning("季目录中已存在 '%s'，不整理该文件", filepath.Join(dirName, entry.Name()))
+			continue
+		}
+		if err := safety.CheckAll(srcPath, dstPath); err != nil {
+			return moved, fmt.Errorf("整理剧集文件失败: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return moved, fmt.Errorf("创建季目录失败: %w", err)
+		}
+		if err := os.Rename(srcPath, dstPath); err != nil {
+			return moved, fmt.Errorf("整理剧集文件失败: %w", err)
+		}
+		if isVideoFile(entry.Name()) {
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// episodeOfFile 返回文件所属剧集的季号：视频文件本身，或以视频文件名加 . 或 - 开头的文件（如 S01E01.zh.srt、S01E01-thumb.jpg）
+func episodeOfFile(name string, episodes map[string]int) (int, bool) {
+	if season, ok := episodes[strings.TrimSuffix(name, filepath.Ext(name))]; ok {
+		return season, true
+	}
+	for base, season := range episodes {
+		if strings.HasPrefix(name, base+".") || strings.HasPrefix(name, base+"-") {
+			return season, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// showNFOName 剧集根目录中媒体服务器识别整部剧使用的NFO文件名
+const showNFOName = "tvshow.nfo"
+
+// ensureShowNFO 剧集根目录中没有tvshow.nfo时生成一个
+// 单独的季目录合并到已有剧集时通常不带tvshow.nfo，目标目录也可能没有，Jellyfin会因此无法识别合并后的剧集
+// 有TMDB ID和API Key时使用TMDB详情中的标题、首播年份和简介，其余信息和无法查询TMDB时使用本次处理的NFO
+func ensureShowNFO(cfg *config.Config, showDir string, nfo *parser.NFO) error {
+	nfoPath := filepath.Join(showDir, existingName(showDir, showNFOName))
+	if _, err := os.Lstat(nfoPath); err == nil || !os.IsNotExist(err) {
+		return nil
+	}
+
+	var details *tmdb.Details
+	if nfo.TMDbID != "" && cfg.TMDBApiKey != "" {
+		var err error
+		details, err = tmdb.GetDetails(nfo.TMDbID, true)
+		if errors.Is(err, tmdb.ErrCachedNotFound) {
+			logging.Debug("%v，tvshow.nfo只使用NFO中的信息", err)
+		} else if err != nil {
+			logging.Warning("从TMDB获取剧集详情失败: %v，tvshow.nfo只使用NFO中的信息", err)
+		}
+	}
+
+	if err := parser.WriteNFOFile(nfoPath, []byte(showNFOContent(nfo, details))); err != nil {
+		return fmt.Errorf("生成tvshow.nfo失败: %w", err)
+	}
+	logging.Info("剧集目录中没有tvshow.nfo，已生成: %s", nfoPath)
+	return nil
+}
+
+// showNFOContent 生成tvshow.nfo的内容，details为nil时只使用nfo中的信息
+func showNFOContent(nfo *parser.NFO, details *tmdb.Details) string {
+	title, year, plot, plotSource := nfo.Title, nfo.Year, nfo.Plot, ""
+	if details != nil {
+		if strings.TrimSpace(details.Title) != "" {
+			title = details.Title
+		}
+		if releaseYear := details.ReleaseYear(); releaseYear > 0 {
+			year = fmt.Sprint(releaseYear)
+		}
+		if strings.TrimSpace(details.Overview) != "" {
+			plot, plotSource = details.Overview, details.OverviewSource()
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\" ?>\n")
+	content.WriteString("<!-- 由media-manager为剧集目录自动生成 -->\n")
+	content.WriteString("<tvshow>\n")
+	writeElement := func(name, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&content, "  <%s>%s</%s>\n", name, escapeXMLText(value), name)
+		}
+	}
+	writeElement("title", title)
+	writeElement("originaltitle", nfo.OriginalTitle)
+	writeElement("year", year)
+	writeElement("plot", plot)
+	for _, genre := range nfo.Genres {
+		writeElement("genre", genre)
+	}
+	for _, country := range nfo.Country {
+		writeElement("country", country)
+	}
+	writeElement("tmdbid", nfo.TMDbID)
+	if nfo.TMDbID != "" {
+		fmt.Fprintf(&content, "  <uniqueid type=\"tmdb\" default=\"true\">%s</uniqueid>\n", escapeXMLText(nfo.TMDbID))
+	}
+	if nfo.IMDbID != "" {
+		fmt.Fprintf(&content, "  <uniqueid type=\"imdb\">%s</uniqueid>\n", escapeXMLText(nfo.IMDbID))
+	}
+	if plotSource != "" {
+		content.WriteString("  " + parser.PlotSourceComment(plotSource, "") + "\n")
+	}
+	content.WriteString("</tvshow>\n")
+	return content.String()
+}
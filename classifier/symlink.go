@@ -0,0 +1,110 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/safety"
+	"github.com/user/media-manager/utils"
+)
+
+// MoveSymlink 按symlinks.move配置移动影片目录中的符号链接：
+// preserve移动链接本身（跨设备时重建相同的链接），follow复制链接指向的文件或目录后删除链接，skip保留在源目录中
+func MoveSymlink(src, dst string) error {
+	if err := safety.CheckAll(src, dst); err != nil {
+		return err
+	}
+	if DryRun {
+		logging.Info("[模拟] 将移动符号链接 '%s' → '%s'", src, dst)
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	// preserve时先尝试直接重命名链接，跨设备时再重建
+	if cfg.SymlinkMode(config.SymlinkOpMove) == utils.SymlinkPreserve {
+		if err := os.Rename(src, dst); err == nil {
+			return nil
+		}
+	}
+	copied, err := copySymlink(src, dst)
+	if err != nil || !copied {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copySymlink 按symlinks.move配置复制符号链接，不删除源链接：preserve创建相同的链接，follow复制链接指向的内容
+// skip或链接目标不存在时不复制，返回false
+func copySymlink(src, dst string) (bool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+	switch cfg.SymlinkMode(config.SymlinkOpMove) {
+	case utils.SymlinkSkip:
+		logging.Warning("跳过符号链接: %s", src)
+		return false, nil
+	case utils.SymlinkFollow:
+		info, err := os.Stat(src)
+		if err != nil {
+			logging.Warning("符号链接 %s 的目标不存在，跳过", src)
+			return false, nil
+		}
+		if info.IsDir() {
+			err = copyTree(src, dst)
+		} else {
+			err = copyFile(src, dst)
+		}
+		if err != nil {
+			return false, fmt.Errorf("复制符号链接 %s 指向的内容失败: %w", src, err)
+		}
+		return true, nil
+	default:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return false, fmt.Errorf("读取符号链接失败: %w", err)
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return false, fmt.Errorf("创建符号链接失败: %w", err)
+		}
+		return true, nil
+	}
+}
+
+// copyTree 复制目录（跟随其中的符号链接），不删除源目录；循环链接只复制一次
+func copyTree(src, dst string) error {
+	return utils.Walk(src, utils.SymlinkFollow, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			logging.Warning("复制时跳过 %s: %v", path, err)
+			return nil
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, 0755)
+		}
+		return copyFile(path, dstPath)
+	})
+}
+
+// isDirEntry 判断目录项是否为目录，symlinks.scan为follow时指向目录的符号链接也视为目录
+func isDirEntry(dirPath string, entry os.DirEntry) bool {
+	if entry.Type()&os.ModeSymlink == 0 {
+		return entry.IsDir()
+	}
+	if config.CurrentSymlinkMode(config.SymlinkOpScan) != utils.SymlinkFollow {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dirPath, entry.Name()))
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,251 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// TrackUnresolvedItem 将无法正常处理的项目记录到问题项目表
+// 项目超过配置的天数仍未解决时，开启启发式分类且没有NFO文件的项目按文件名推测分类后移动，
+// 其余项目在开启未分类目录时生成最简NFO并移动到未分类目录
+func TrackUnresolvedItem(mediaDir string, isTVShow bool, reason string) error {
+	item, err := database.RecordProblemItem(problemItemPath(mediaDir), reason)
+	if err != nil {
+		return fmt.Errorf("记录问题项目失败: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.UnsortedCategory == "" && !cfg.HeuristicClassify {
+		return nil
+	}
+
+	deadline := item.FirstSeenAt.Add(time.Duration(cfg.UnsortedAfterDays) * 24 * time.Hour)
+	if time.Now().Before(deadline) {
+		target := cfg.UnsortedCategory
+		if cfg.HeuristicClassify {
+			target = "按文件名推测的分类"
+		}
+		logging.Info("项目 %s 未解决（%s），将在 %s 后移动到 %s", mediaDir, reason, deadline.Format("2006-01-02"), target)
+		return nil
+	}
+
+	if cfg.HeuristicClassify {
+		if _, hasNFO := inspectMediaDir(mediaDir); !hasNFO {
+			guess := GuessCategory(mediaDir, isTVShow)
+			if guess.Category != "" {
+				return moveWithGuess(mediaDir, isTVShow, guess, cfg)
+			}
+			logging.Info("无法按文件名推测 %s 的分类", mediaDir)
+		}
+	}
+
+	if cfg.UnsortedCategory == "" {
+		return nil
+	}
+	return moveToUnsorted(mediaDir, isTVShow, cfg)
+}
+
+// RouteUnscrapedItems 检查扫描目录下没有NFO文件的媒体目录，并作为问题项目跟踪
+func RouteUnscrapedItems(scanDir string, isTVShow bool) error {
+	dirs, err := UnscrapedDirs(scanDir)
+	if err != nil {
+		return err
+	}
+
+	for _, mediaDir := range dirs {
+		if err := TrackUnresolvedItem(mediaDir, isTVShow, "没有NFO文件（未刮削）"); err != nil {
+			logging.Error("处理未刮削项目 %s 失败: %v", mediaDir, err)
+		}
+	}
+
+	return nil
+}
+
+// UnscrapedDirs 返回扫描目录下包含视频文件但没有NFO文件的媒体目录，扫描目录不存在时返回空
+func UnscrapedDirs(scanDir string) ([]string, error) {
+	entries, err := os.ReadDir(scanDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		mediaDir := filepath.Join(scanDir, entry.Name())
+		if utils.HasIgnoreMarker(mediaDir) {
+			continue
+		}
+
+		hasVideo, hasNFO := inspectMediaDir(mediaDir)
+		if hasVideo && !hasNFO {
+			dirs = append(dirs, mediaDir)
+		}
+	}
+	return dirs, nil
+}
+
+// inspectMediaDir 递归检查目录是否包含视频文件和NFO文件
+func inspectMediaDir(dirPath string) (hasVideo bool, hasNFO bool) {
+	utils.Walk(dirPath, config.CurrentSymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if info.IsDir() {
+			if utils.HasIgnoreMarker(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.ToLower(filepath.Ext(path)) == ".nfo" {
+			hasNFO = true
+		} else if isVideoFile(path) {
+			hasVideo = true
+		}
+		return nil
+	})
+	return hasVideo, hasNFO
+}
+
+// moveToUnsorted 为项目生成最简NFO（已有NFO时保留），移动到未分类目录并记录到数据库
+func moveToUnsorted(mediaDir string, isTVShow bool, cfg *config.Config) error {
+	record, err := moveUnresolvedItem(mediaDir, isTVShow, cfg.UnsortedCategory, nil, cfg)
+	if err != nil || record == nil {
+		return err
+	}
+	logging.Info("项目长期未解决，已将 '%s' 移动到 '%s'", filepath.Base(mediaDir), cfg.UnsortedCategory)
+	return database.UpdateProblemItemStatus(problemItemPath(mediaDir), database.ProblemStatusMoved)
+}
+
+// quarantineItem 将低于最低画质要求的项目移动到隔离目录并记录到数据库
+func quarantineItem(mediaDir string, isTVShow bool, cfg *config.Config) error {
+	record, err := moveUnresolvedItem(mediaDir, isTVShow, cfg.MinQuality.QuarantineCategory, nil, cfg)
+	if err != nil || record == nil {
+		return err
+	}
+	logging.Info("已将低于最低画质要求的 '%s' 移动到隔离目录 '%s'", filepath.Base(mediaDir), cfg.MinQuality.QuarantineCategory)
+	return database.ClearSkip(mediaDir)
+}
+
+// moveWithGuess 为没有NFO文件的项目生成带低置信度标记的最简NFO，移动到推测的分类目录并记录到数据库
+func moveWithGuess(mediaDir string, isTVShow bool, guess HeuristicGuess, cfg *config.Config) error {
+	record, err := moveUnresolvedItem(mediaDir, isTVShow, guess.Category, []string{HeuristicTag}, cfg)
+	if err != nil || record == nil {
+		return err
+	}
+	logging.Warning("项目长期没有元数据，按文件名推测分类（低置信度，%s），已将 '%s' 移动到 '%s'，请人工确认",
+		strings.Join(guess.Reasons, "、"), filepath.Base(mediaDir), guess.Category)
+	return database.UpdateProblemItemStatus(problemItemPath(mediaDir), database.ProblemStatusGuessed)
+}
+
+// moveUnresolvedItem 为项目生成最简NFO（已有NFO时保留），移动到指定分类目录并记录到数据库，返回写入的记录
+// 目标目录已存在同名文件夹时不移动，返回nil
+func moveUnresolvedItem(mediaDir string, isTVShow bool, category string, tags []string, cfg *config.Config) (*database.MediaRecord, error) {
+	mediaName := filepath.Base(mediaDir)
+	targetDir := cfg.CategoryDir(category, isTVShow)
+	targetName := mediaName
+	if cfg.MaxPathBytes > 0 {
+		targetName = TruncateName(mediaName, nameBudget(targetDir, cfg.MaxPathBytes))
+	}
+	targetMediaPath := filepath.Join(targetDir, targetName)
+	if err := checkPathLength(targetMediaPath, mediaDir, cfg.MaxPathBytes); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(targetMediaPath); err == nil {
+		logging.Warning("%s 目录已存在同名文件夹 '%s'，跳过移动", category, targetMediaPath)
+		return nil, nil
+	}
+
+	_, hasNFO := inspectMediaDir(mediaDir)
+	if !hasNFO {
+		if err := writeMinimalNFO(mediaDir, mediaName, isTVShow, tags...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mkdirAll(targetDir); err != nil {
+		return nil, fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	releaseMoveSlot := acquireMoveSlot(cfg.Workers(config.ConcurrencyMove), mediaDir)
+	err := MoveDirectory(mediaDir, targetMediaPath)
+	releaseMoveSlot()
+	if err != nil {
+		return nil, fmt.Errorf("移动到 %s 目录失败: %w", category, err)
+	}
+
+	record := &database.MediaRecord{
+		FileName:    mediaName,
+		Title:       mediaName,
+		Category:    category,
+		SourcePath:  mediaDir,
+		TargetPath:  targetMediaPath,
+		ProcessedAt: time.Now(),
+	}
+	if err := database.InsertOrUpdateMediaRecord(record); err != nil {
+		logging.Error("记录媒体信息到数据库失败: %v", err)
+	}
+	return record, nil
+}
+
+// problemItemPath 返回问题项目表中使用的路径（绝对路径），保证不同调用方式下一致
+func problemItemPath(mediaDir string) string {
+	if absPath, err := filepath.Abs(mediaDir); err == nil {
+		return absPath
+	}
+	return mediaDir
+}
+
+// writeMinimalNFO 生成只包含标题（和指定标签）的最简NFO文件
+func writeMinimalNFO(mediaDir string, title string, isTVShow bool, tags ...string) error {
+	rootTag := "movie"
+	fileName := "movie.nfo"
+	if isTVShow {
+		rootTag = "tvshow"
+		fileName = "tvshow.nfo"
+	}
+
+	var content strings.Builder
+	content.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\" ?>\n")
+	content.WriteString("<!-- 由media-manager为未刮削内容自动生成 -->\n")
+	fmt.Fprintf(&content, "<%s>\n", rootTag)
+	fmt.Fprintf(&content, "  <title>%s</title>\n", escapeXMLText(title))
+	for _, tag := range tags {
+		fmt.Fprintf(&content, "  <tag>%s</tag>\n", escapeXMLText(tag))
+	}
+	fmt.Fprintf(&content, "</%s>\n", rootTag)
+
+	nfoPath := filepath.Join(mediaDir, fileName)
+	if err := parser.WriteNFOFile(nfoPath, []byte(content.String())); err != nil {
+		return fmt.Errorf("生成NFO文件失败: %w", err)
+	}
+
+	logging.Info("已生成最简NFO文件: %s", nfoPath)
+	return nil
+}
+
+// escapeXMLText转义XML文本中的特殊字符
+func escapeXMLText(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
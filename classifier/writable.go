@@ -0,0 +1,141 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/hooks"
+	"github.com/user/media-manager/logging"
+)
+
+// readOnlyStateKey 记录媒体库目录已发送过不可写入通知的运行状态键，恢复后删除，避免每次运行重复通知
+const readOnlyStateKey = "cloud:read_only:"
+
+// writeProbePattern 写入测试使用的临时文件名
+const writeProbePattern = ".media-manager-write-test-*"
+
+// writableTargets 返回需要写入的媒体库目录：各媒体库根目录和单独配置的分类目录
+func writableTargets(cfg *config.Config) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			targets = append(targets, dir)
+		}
+	}
+
+	for _, root := range cfg.CloudRoots() {
+		add(root)
+	}
+	var categories []string
+	for category := range cfg.CategoryDirs {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		add(cfg.CategoryDirs[category])
+	}
+	return targets
+}
+
+// probeWritable 在目录中创建并删除一个临时文件，检查目录是否可以写入；目录不存在时不检查（移动时会创建）
+// 模拟运行时不在媒体库中创建文件，按可以写入处理
+func probeWritable(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) || DryRun {
+		return nil
+	}
+	file, err := os.CreateTemp(dir, writeProbePattern)
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// CheckTargetsWritable 检查媒体库目录是否都已挂载并可以写入，没有挂载时返回errs.ErrNotMounted，第一个不可写入的目录返回errs.ErrReadOnlyTarget
+// rclone等挂载在出错后常会变为只读，这时每个项目的移动都会失败，复制到一半的目录还会留在媒体库中
+func CheckTargetsWritable(cfg *config.Config) error {
+	if err := CheckMounts(cfg); err != nil {
+		return err
+	}
+	for _, dir := range writableTargets(cfg) {
+		if err := probeWritable(dir); err != nil {
+			return readOnlyError(dir, err)
+		}
+	}
+	return nil
+}
+
+// readOnlyError 返回目录不可写入的错误
+func readOnlyError(dir string, err error) error {
+	return fmt.Errorf("%w: '%s': %v", errs.ErrReadOnlyTarget, dir, probeReason(err))
+}
+
+// probeReason 返回写入失败的简短原因
+func probeReason(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// IsUnavailable 判断错误是否由媒体库目录没有挂载或文件系统只读引起，这时所有项目的移动都会失败
+func IsUnavailable(err error) bool {
+	return errors.Is(err, errs.ErrNotMounted) || errors.Is(err, errs.ErrReadOnlyTarget) || errors.Is(err, syscall.EROFS)
+}
+
+// MonitorWritable 检查媒体库目录是否已挂载并可以写入，返回第一个没有挂载或不可写入的目录的错误
+// 第一次发现时记录错误并执行read_only钩子，恢复之前不重复通知；恢复后记录日志并清除通知状态
+func MonitorWritable(cfg *config.Config) error {
+	if err := CheckMounts(cfg); err != nil {
+		logging.Error("%v，暂停处理，项目留在Temp目录和队列中", err)
+		return err
+	}
+
+	var firstErr error
+	for _, dir := range writableTargets(cfg) {
+		key := readOnlyStateKey + dir
+		notified, err := database.GetRunState(key)
+		if err != nil {
+			logging.Error("%v", err)
+		}
+
+		probeErr := probeWritable(dir)
+		if probeErr == nil {
+			if notified != "" {
+				logging.Info("媒体库目录 '%s' 已恢复可写入，继续处理", dir)
+				if err := database.DeleteRunState(key); err != nil {
+					logging.Error("%v", err)
+				}
+			}
+			continue
+		}
+
+		if firstErr == nil {
+			firstErr = readOnlyError(dir, probeErr)
+		}
+		logging.Error("媒体库目录 '%s' 不可写入（%v），暂停处理，项目留在Temp目录和队列中", dir, probeReason(probeErr))
+		if notified != "" {
+			continue
+		}
+		if err := hooks.RunReadOnly(cfg.Hooks, &hooks.Mount{Path: dir, Error: probeReason(probeErr).Error()}); err != nil {
+			logging.Error("%v", err)
+		}
+		if err := database.SetRunState(key, time.Now().Format(time.RFC3339)); err != nil {
+			logging.Error("%v", err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/cache"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// runCacheCommand 处理cache子命令
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		args = []string{"info"}
+	}
+
+	switch args[0] {
+	case "info":
+		return runCacheInfo()
+	case "clear":
+		if len(args) > 2 {
+			return fmt.Errorf("用法: cache clear [tmdb|tmm|nfo_backups]")
+		}
+		kind := ""
+		if len(args) == 2 {
+			kind = args[1]
+		}
+		return runCacheClear(kind)
+	default:
+		return fmt.Errorf("未知的cache子命令: %s（可用: info、clear）", args[0])
+	}
+}
+
+// runCacheInfo 列出缓存目录和各分类占用的空间
+func runCacheInfo() error {
+	dir, err := cache.Dir()
+	if err != nil {
+		return err
+	}
+	counts, sizes, err := cache.Usage()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("缓存目录: %s\n", dir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "分类\t文件数\t大小")
+	var total int64
+	for _, kind := range cache.Kinds {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", kind, counts[kind], utils.FormatBytes(sizes[kind]))
+		total += sizes[kind]
+	}
+	w.Flush()
+
+	limit := "不限制"
+	if maxMB := appConfig().Cache.MaxMB; maxMB > 0 {
+		limit = utils.FormatBytes(int64(maxMB) << 20)
+	}
+	fmt.Printf("共 %s，上限 %s\n", utils.FormatBytes(total), limit)
+	return nil
+}
+
+// runCacheClear 删除一个分类或所有分类的缓存，模拟运行时只统计将要删除的文件
+func runCacheClear(kind string) error {
+	if database.IsDryRun() {
+		return planCacheClear(kind)
+	}
+	count, size, err := cache.Clear(kind)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已删除 %d 个缓存文件，释放 %s\n", count, utils.FormatBytes(size))
+	return nil
+}
+
+// planCacheClear 输出清除缓存时将要删除的文件数和大小
+func planCacheClear(kind string) error {
+	kinds := cache.Kinds
+	if kind != "" {
+		if !cache.ValidKind(kind) {
+			return fmt.Errorf("未知的缓存分类: %s（可用: %s）", kind, strings.Join(cache.Kinds, ", "))
+		}
+		kinds = []string{kind}
+	}
+	counts, sizes, err := cache.Usage()
+	if err != nil {
+		return err
+	}
+	count, size := 0, int64(0)
+	for _, k := range kinds {
+		count += counts[k]
+		size += sizes[k]
+	}
+	fmt.Printf("[模拟] 将删除 %d 个缓存文件，释放 %s\n", count, utils.FormatBytes(size))
+	return nil
+}
+
+// pruneCache 缓存目录超过cache.max_mb时删除最早的文件
+func pruneCache(cfg *config.Config) {
+	if cfg.Cache.MaxMB <= 0 {
+		return
+	}
+	count, size, err := cache.Prune(int64(cfg.Cache.MaxMB) << 20)
+	if err != nil {
+		logging.Warning("清理缓存目录失败: %v", err)
+		return
+	}
+	if count > 0 {
+		logging.Info("缓存目录超过上限，已删除最早的 %d 个文件（%s）", count, utils.FormatBytes(size))
+	}
+}
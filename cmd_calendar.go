@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/feed"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/paths"
+)
+
+// runCalendarCommand 处理calendar子命令，生成媒体库中剧集的播出日历（.ics）
+func runCalendarCommand(args []string) error {
+	fs := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	days := fs.Int("days", 30, "包含未来多少天内播出的剧集")
+	output := fs.String("output", "", "日历文件的保存路径，默认为reports目录下的upcoming.ics")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("--days 必须大于0")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	c, err := feed.UpcomingEpisodes(*days)
+	if err != nil {
+		return err
+	}
+	if database.IsDryRun() {
+		logging.Info("[模拟] 将生成剧集播出日历（%d 集），不写入日历文件", len(c.Events))
+		return nil
+	}
+
+	if *output == "" {
+		if *output, err = paths.File(paths.Reports, "upcoming.ics"); err != nil {
+			return fmt.Errorf("无法创建reports目录: %w", err)
+		}
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("创建日历文件失败: %w", err)
+	}
+	if err := feed.WriteICS(file, c); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("保存日历文件失败: %w", err)
+	}
+	logging.Info("已生成剧集播出日历: %s（%d 集）", *output, len(c.Events))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+)
+
+// runClassifyCommand 处理classify子命令
+func runClassifyCommand(args []string) error {
+	if len(args) == 0 || args[0] != "set" {
+		return fmt.Errorf("用法: classify set <目录> --category 分类")
+	}
+	return runClassifySet(args[1:])
+}
+
+// runClassifySet 手动指定一个目录的分类：移动到分类目录，更新数据库记录，之后重新处理时不再改变分类
+func runClassifySet(args []string) error {
+	fs := flag.NewFlagSet("classify set", flag.ContinueOnError)
+	category := fs.String("category", "", "分类，如 Jp&KrMovie（可省略&、不区分大小写）")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *category == "" {
+		return fmt.Errorf("用法: classify set <目录> --category 分类")
+	}
+
+	cfg := appConfig()
+	name, known := classifier.CanonicalCategory(cfg, *category)
+	if !known {
+		fmt.Fprintf(os.Stderr, "警告: %s 不是内置分类或category_dirs中配置的分类，将创建新的分类目录\n", name)
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	result, err := classifier.SetCategory(cfg, positional[0], name)
+	if err != nil {
+		return err
+	}
+	if result.OldPath == result.NewPath {
+		fmt.Printf("目录已在分类 %s 中: %s\n", name, result.NewPath)
+	} else {
+		fmt.Printf("已移动: %s → %s\n", result.OldPath, result.NewPath)
+	}
+	fmt.Printf("已将 %d 条记录的分类设为 %s（手动指定，之后重新处理时不再改变）\n", result.Records, name)
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/user/media-manager/acquire"
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/digest"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/scraper"
+	"github.com/user/media-manager/seerr"
+)
+
+// 触发请求和响应
+const (
+	triggerRequest  = "trigger"
+	triggerQueued   = "queued"
+	triggerDialTime = 3 * time.Second
+
+	tmmRetrySource = "TMM超时重试"
+	tmmRetryDelay  = 5 * time.Minute // tinyMediaManager超时后等待多久重试一次
+
+	writableSource       = "媒体库恢复可用"
+	writablePollInterval = time.Minute // 媒体库目录没有挂载或只读时每隔多久检查一次是否已恢复
+)
+
+// runDaemonCommand 以守护进程模式运行，定时或收到触发请求时执行一次完整处理
+func runDaemonCommand(args []string) error {
+	cfg := appConfig()
+
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	interval := fs.Int("interval", cfg.DaemonInterval, "定时处理的间隔（分钟）")
+	socketPath := fs.String("socket", cfg.DaemonSocket, "接收触发请求的unix socket路径")
+	addRunFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	applyRunFlags()
+	if *dryRun {
+		// 模拟运行不移动项目，每次处理都会重复相同的操作
+		return fmt.Errorf("daemon不支持-dry-run，使用 process --dry-run <目录> 预览处理结果")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("无效的处理间隔: %d", *interval)
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	// 容量为1：处理期间收到的多次触发合并为一次
+	triggers := make(chan string, 1)
+	requestPass := func(source string) {
+		select {
+		case triggers <- source:
+		default:
+			logging.Info("已有待执行的处理，忽略来自%s的触发", source)
+		}
+	}
+
+	listener, err := listenTriggerSocket(*socketPath)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	go serveTriggerSocket(listener, requestPass)
+
+	signals := make(chan os.Signal, 1)
+	notifyTriggerSignal(signals)
+	go func() {
+		for range signals {
+			requestPass("信号")
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(time.Duration(*interval) * time.Minute)
+	defer ticker.Stop()
+
+	logging.Info("守护进程已启动，处理间隔 %d 分钟，触发socket: %s", *interval, *socketPath)
+	requestPass("启动")
+
+	var watchingWritable atomic.Bool
+	for {
+		select {
+		case source := <-triggers:
+			err := runDaemonPass(source)
+			// tinyMediaManager超时时稍后重试一次，重试仍然超时则等下次定时处理
+			if errors.Is(err, scraper.ErrTimeout) && source != tmmRetrySource {
+				logging.Info("%v 后重试", tmmRetryDelay)
+				time.AfterFunc(tmmRetryDelay, func() { requestPass(tmmRetrySource) })
+			}
+			// 媒体库目录没有挂载或只读时暂停处理，恢复后立即继续，不等下次定时处理
+			if classifier.IsUnavailable(err) && watchingWritable.CompareAndSwap(false, true) {
+				go func() {
+					defer watchingWritable.Store(false)
+					waitWritable()
+					requestPass(writableSource)
+				}()
+			}
+		case <-ticker.C:
+			requestPass("定时器")
+		case sig := <-stop:
+			logging.Info("收到信号 %v，守护进程退出", sig)
+			return nil
+		}
+	}
+}
+
+// runDaemonPass 执行一次完整的刮削和处理，返回刮削失败的错误
+func runDaemonPass(source string) error {
+	logging.Info("开始处理（触发来源: %s）", source)
+	// 每次处理前重新读取配置文件，修改配置后不需要重启守护进程
+	if cfg, err := config.Reload(); err != nil {
+		logging.Error("重新加载配置失败，继续使用原来的配置: %v", err)
+	} else {
+		applyConfig(cfg)
+	}
+	startedAt := time.Now()
+	database.StartRun()
+	if err := handleScrape("all"); err != nil {
+		logging.Error("本次处理失败: %v", err)
+		return err
+	}
+	cfg := appConfig()
+	if err := runPostRunHooks("daemon", cfg.TempDirs, startedAt); err != nil {
+		logging.Error("%v", err)
+	}
+	if err := digest.SendIfDue(cfg); err != nil {
+		logging.Error("%v", err)
+	}
+	if cfg.Seerr.Enabled() && cfg.Seerr.AutoRequest {
+		requestMissing(cfg)
+	}
+	if cfg.Acquire.Enabled() && cfg.Acquire.AutoAcquire {
+		acquireMissing(cfg)
+	}
+	maintainDatabaseIfDue(cfg)
+	logging.Info("处理完成，耗时: %v", time.Since(startedAt).Round(time.Second))
+	return nil
+}
+
+// waitWritable 每隔writablePollInterval检查一次媒体库目录，直到都已挂载并可以写入
+func waitWritable() {
+	logging.Info("每 %v 检查一次媒体库目录，恢复后继续处理", writablePollInterval)
+	for {
+		time.Sleep(writablePollInterval)
+		if err := classifier.CheckTargetsWritable(appConfig()); err == nil {
+			return
+		}
+	}
+}
+
+// requestMissing 在Overseerr/Jellyseerr中为新的缺失季和系列电影创建请求
+func requestMissing(cfg *config.Config) {
+	requests, err := seerr.Wanted()
+	if err != nil {
+		logging.Error("%v", err)
+		return
+	}
+	result, err := seerr.Submit(cfg.Seerr, requests)
+	if err != nil {
+		logging.Error("创建Overseerr/Jellyseerr请求失败: %v", err)
+		return
+	}
+	if result.Submitted > 0 || result.Failed > 0 {
+		logging.Info("已在Overseerr/Jellyseerr中创建 %d 个请求，失败 %d 个", result.Submitted, result.Failed)
+	}
+}
+
+// acquireMissing 为新的缺失季搜索发布并提交到下载器，最近已搜索过但没有找到发布的季跳过
+func acquireMissing(cfg *config.Config) {
+	result, err := acquire.Run(cfg, true)
+	if err != nil {
+		logging.Error("为缺失季提交下载失败: %v", err)
+		return
+	}
+	if result.Submitted > 0 || result.Failed > 0 {
+		logging.Info("已为缺失季提交 %d 个下载，失败 %d 个", result.Submitted, result.Failed)
+	}
+}
+
+// maintainDatabaseIfDue 距离上次维护超过db_maintenance_days天时检查数据库完整性并执行VACUUM和ANALYZE
+func maintainDatabaseIfDue(cfg *config.Config) {
+	due, err := database.MaintenanceDue(cfg.DBMaintenanceDays)
+	if err != nil {
+		logging.Error("%v", err)
+		return
+	}
+	if !due {
+		return
+	}
+
+	logging.Info("开始定期维护数据库")
+	result, err := database.Maintain()
+	if err != nil {
+		logging.Error("维护数据库失败: %v", err)
+		return
+	}
+	if len(result.Problems) > 0 {
+		logging.Error("数据库完整性检查发现 %d 个问题，未执行VACUUM，请从备份恢复数据库: %s",
+			len(result.Problems), strings.Join(result.Problems, "; "))
+		return
+	}
+	logging.Info("数据库维护完成: %s → %s，耗时 %v", formatDBStats(result.Before), formatDBStats(result.After),
+		result.Duration.Round(time.Millisecond))
+}
+
+// listenTriggerSocket 监听触发socket，清理上次异常退出残留的socket文件
+func listenTriggerSocket(socketPath string) (net.Listener, error) {
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.DialTimeout("unix", socketPath, triggerDialTime); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("守护进程已在运行: %s", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("删除残留的socket文件失败: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("监听触发socket失败: %w", err)
+	}
+	return listener, nil
+}
+
+// serveTriggerSocket 处理触发socket上的连接，每个连接发送一行请求
+func serveTriggerSocket(listener net.Listener, requestPass func(source string)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logging.Error("接受触发连接失败: %v", err)
+			}
+			return
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(triggerDialTime))
+
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil && line == "" {
+				return
+			}
+			if strings.TrimSpace(line) != triggerRequest {
+				fmt.Fprintf(conn, "未知的请求: %s\n", strings.TrimSpace(line))
+				return
+			}
+			requestPass("socket")
+			fmt.Fprintln(conn, triggerQueued)
+		}(conn)
+	}
+}
+
+// runTriggerCommand 通知正在运行的守护进程立即执行一次处理
+func runTriggerCommand(args []string) error {
+	cfg := appConfig()
+
+	fs := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	socketPath := fs.String("socket", cfg.DaemonSocket, "守护进程的unix socket路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("unix", *socketPath, triggerDialTime)
+	if err != nil {
+		return fmt.Errorf("连接守护进程失败（守护进程是否在运行？）: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(triggerDialTime))
+
+	if _, err := fmt.Fprintln(conn, triggerRequest); err != nil {
+		return fmt.Errorf("发送触发请求失败: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("读取守护进程响应失败: %w", err)
+	}
+	if strings.TrimSpace(reply) != triggerQueued {
+		return fmt.Errorf("守护进程拒绝了触发请求: %s", strings.TrimSpace(reply))
+	}
+
+	fmt.Println("已通知守护进程执行处理")
+	return nil
+}
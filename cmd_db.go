@@ -0,0 +1,632 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// runDBCommand 处理db子命令
+func runDBCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: db list [参数] | db search <关键字> | db corrections | db verify | db update --filter 字段=值 --set 字段=值 | db maintenance [--check] | db history <id> | db show <id> | db lock <id> | db unlock <id>")
+	}
+
+	switch args[0] {
+	case "list":
+		return runDBList(args[1:])
+	case "search":
+		return runDBSearch(args[1:])
+	case "corrections":
+		return runDBCorrections()
+	case "verify":
+		return runDBVerify()
+	case "update":
+		return runDBUpdate(args[1:])
+	case "maintenance":
+		return runDBMaintenance(args[1:])
+	case "history":
+		return runDBHistory(args[1:])
+	case "show":
+		return runDBShow(args[1:])
+	case "lock":
+		return runDBLock(args[1:], true)
+	case "unlock":
+		return runDBLock(args[1:], false)
+	default:
+		return fmt.Errorf("未知的db子命令: %s", args[0])
+	}
+}
+
+// runDBList 按条件列出媒体记录
+func runDBList(args []string) error {
+	fs := flag.NewFlagSet("db list", flag.ContinueOnError)
+	title := fs.String("title", "", "按标题过滤（模糊匹配）")
+	category := fs.String("category", "", "按分类过滤（模糊匹配）")
+	language := fs.String("language", "", "按语言过滤（ISO 639-1代码，如 ja、zh）")
+	audio := fs.String("audio", "", "按音轨语言过滤（如 粤语、yue、国语、cmn）")
+	tag := fs.String("tag", "", "按文件名标注的来源平台或画质标签过滤（如 央视频、60帧）")
+	hdr := fs.String("hdr", "", "按HDR格式过滤（DV、HDR10+、HDR10、HLG）")
+	plotSource := fs.String("plot-source", "", "按简介来源过滤（wikipedia、baidu、tmdb:en-US）")
+	countryFallback := fs.Bool("country-fallback", false, "只列出没有国家信息、按missing_country处理的记录")
+	locked := fs.Bool("locked", false, "只列出由db lock锁定的记录")
+	perSeason := fs.Bool("per-season", false, "电视剧的每一季单独列出，不按剧集合并")
+	sortBy := fs.String("sort", "id", "排序方式：id（写入顺序）、pinyin（按标题拼音）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sortBy != "id" && *sortBy != "pinyin" {
+		return fmt.Errorf("无效的排序方式: %s（可用: id、pinyin）", *sortBy)
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	records, err := database.GetMediaRecords(map[string]interface{}{
+		"title":            *title,
+		"category":         *category,
+		"language":         *language,
+		"audio_language":   audioFilter(*audio),
+		"release_tag":      *tag,
+		"hdr":              *hdr,
+		"plot_source":      *plotSource,
+		"country_fallback": *countryFallback,
+		"locked":           *locked,
+		"order":            *sortBy,
+	})
+	if err != nil {
+		return fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+	printMediaList(records, *perSeason)
+	return nil
+}
+
+// runDBSearch 按标题、原标题、标题拼音或拼音首字母搜索媒体记录，结果按拼音排序
+func runDBSearch(args []string) error {
+	fs := flag.NewFlagSet("db search", flag.ContinueOnError)
+	perSeason := fs.Bool("per-season", false, "电视剧的每一季单独列出，不按剧集合并")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("用法: db search <关键字>（标题、原标题、拼音如 langya，或拼音首字母如 lyb）")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	records, err := database.GetMediaRecords(map[string]interface{}{
+		"search": strings.Join(positional, " "),
+		"order":  "pinyin",
+	})
+	if err != nil {
+		return fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+	printMediaList(records, *perSeason)
+	return nil
+}
+
+// printMediaList 以表格输出媒体记录，perSeason为false时电视剧按剧集合并为一行（季列为已有的季号，ID为最近处理的一季）
+func printMediaList(records []database.MediaRecord, perSeason bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t标题\t年份\t版本\t分类\t季\t原始语言\t对白语言\t音轨\tHDR\t发布标签\t简介来源\t无国家处理")
+	if perSeason {
+		for _, record := range records {
+			printMediaRow(w, &record, database.FormatNumber(record.Season))
+		}
+		w.Flush()
+		fmt.Printf("共 %d 条记录\n", len(records))
+		return
+	}
+
+	entries := database.AggregateShows(records)
+	for _, entry := range entries {
+		season := database.FormatNumber(entry.Record.Season)
+		if entry.IsShow() {
+			season = entry.SeasonList()
+		}
+		printMediaRow(w, &entry.Record, season)
+	}
+	w.Flush()
+	fmt.Printf("共 %d 个条目，%d 条记录（电视剧的各季已合并，使用 --per-season 逐季列出）\n", len(entries), len(records))
+}
+
+// printMediaRow 输出媒体记录表格的一行
+func printMediaRow(w io.Writer, record *database.MediaRecord, season string) {
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		record.ID, record.Title, record.Year, record.Edition, record.Category, season,
+		record.OriginalLanguage, record.SpokenLanguages, record.AudioLanguages, record.HDRFormat, record.ReleaseTags, record.PlotSource,
+		record.CountryFallback)
+}
+
+// runDBCorrections 列出adopt_in_place在媒体库内更正分类的记录
+func runDBCorrections() error {
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	corrections, err := database.GetCorrections()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t标题\t原分类\t新分类\t原路径\t新路径")
+	for _, correction := range corrections {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			correction.CorrectedAt.Format("2006-01-02 15:04"), correction.Title, correction.FromCategory, correction.ToCategory,
+			correction.FromPath, correction.ToPath)
+	}
+	w.Flush()
+
+	fmt.Printf("共 %d 条更正记录\n", len(corrections))
+	return nil
+}
+
+// audioFilter 将音轨语言代码转为数据库中记录的名称，为空时不过滤
+func audioFilter(language string) string {
+	if language == "" {
+		return ""
+	}
+	return parser.NormalizeAudioLanguage(language)
+}
+
+// runDBVerify 检查媒体记录的目标路径，自动更新被改名或移动的剧集目录，列出仍找不到的记录
+func runDBVerify() error {
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	relocations, missing, err := classifier.VerifyTargetPaths(appConfig())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "状态\t标题\t原路径\t新路径")
+	for _, r := range relocations {
+		fmt.Fprintf(w, "已更新\t%s\t%s\t%s\n", r.Title, r.OldPath, r.NewPath)
+	}
+	for _, record := range missing {
+		fmt.Fprintf(w, "不存在\t%s\t%s\t\n", record.Title, record.TargetPath)
+	}
+	w.Flush()
+
+	fmt.Printf("已更新 %d 个剧集目录，%d 条记录的目录不存在\n", len(relocations), len(missing))
+	return nil
+}
+
+// keyValueFlags 可重复指定的 字段=值 参数
+type keyValueFlags map[string]string
+
+func (f keyValueFlags) String() string {
+	var pairs []string
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlags) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("格式应为 字段=值: %s", pair)
+	}
+	f[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	return nil
+}
+
+// dbUpdateFilters db update的过滤字段，title和tags为包含匹配，其他字段完全匹配
+var dbUpdateFilters = map[string]func(record *database.MediaRecord) string{
+	"id":       func(r *database.MediaRecord) string { return fmt.Sprint(r.ID) },
+	"title":    func(r *database.MediaRecord) string { return r.Title },
+	"year":     func(r *database.MediaRecord) string { return r.Year },
+	"category": func(r *database.MediaRecord) string { return r.Category },
+	"tmdb_id":  func(r *database.MediaRecord) string { return r.TMDbID },
+	"tags":     func(r *database.MediaRecord) string { return r.ReleaseTags },
+}
+
+// matchesFilters 判断媒体记录是否满足所有过滤条件
+func matchesFilters(record *database.MediaRecord, filters keyValueFlags) bool {
+	for key, want := range filters {
+		got := dbUpdateFilters[key](record)
+		switch key {
+		case "title":
+			if !strings.Contains(got, want) {
+				return false
+			}
+		case "tags":
+			found := false
+			for _, tag := range strings.Split(got, ",") {
+				if tag == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			if got != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// recordFieldValue 返回媒体记录中可修改字段的当前值
+func recordFieldValue(record *database.MediaRecord, field string) string {
+	switch field {
+	case "category":
+		return record.Category
+	case "year":
+		return record.Year
+	case "tags":
+		return record.ReleaseTags
+	case "country":
+		return record.Country
+	case "genres":
+		return record.Genres
+	}
+	return ""
+}
+
+// runDBUpdate 批量修改满足过滤条件的媒体记录，--move时同时把影片目录移动到新分类目录
+func runDBUpdate(args []string) error {
+	fs := flag.NewFlagSet("db update", flag.ContinueOnError)
+	filters := keyValueFlags{}
+	sets := keyValueFlags{}
+	fs.Var(filters, "filter", "过滤条件 字段=值，可重复指定（id、title、year、category、tmdb_id、tags）")
+	fs.Var(sets, "set", "修改的字段 字段=值，可重复指定（category、year、tags、country、genres），分类、国家和类型的来源记录为manual")
+	move := fs.Bool("move", false, "修改分类时同时把影片目录移动到新分类目录")
+	dryRun := fs.Bool("dry-run", false, "只预览将要修改的记录，不修改")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(filters) == 0 {
+		return fmt.Errorf("至少需要一个 --filter 条件")
+	}
+	if len(sets) == 0 {
+		return fmt.Errorf("至少需要一个 --set 字段")
+	}
+	for key := range filters {
+		if _, ok := dbUpdateFilters[key]; !ok {
+			return fmt.Errorf("不支持的过滤字段: %s", key)
+		}
+	}
+	for key := range sets {
+		if _, ok := database.EditableMediaFields[key]; !ok {
+			return fmt.Errorf("不能修改的字段: %s", key)
+		}
+	}
+	if *move && sets["category"] == "" {
+		return fmt.Errorf("--move 需要同时修改分类（--set category=分类）")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	cfg := appConfig()
+	fields := make([]string, 0, len(sets))
+	for field := range sets {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t标题\t修改\t目录")
+	var matched, failed int
+	moved := make(map[string]string) // 原目录到新目录（移动失败时为空），电视剧的各季只移动一次
+	for i := range records {
+		record := &records[i]
+		if !matchesFilters(record, filters) {
+			continue
+		}
+		if record.Locked || classifier.IsLocked(record.TargetPath) {
+			fmt.Fprintf(w, "%d\t%s\t已锁定，跳过\t\n", record.ID, record.Title)
+			continue
+		}
+
+		changes := make(map[string]string)
+		var descriptions []string
+		for _, field := range fields {
+			if old := recordFieldValue(record, field); old != sets[field] {
+				changes[field] = sets[field]
+				descriptions = append(descriptions, fmt.Sprintf("%s: %s → %s", field, old, sets[field]))
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		matched++
+
+		target := ""
+		moveDir := *move && changes["category"] != "" && record.TargetPath != ""
+		if moveDir {
+			target = record.TargetPath + " → " + classifier.RecategorizedPath(cfg, record.TargetPath, sets["category"])
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", record.ID, record.Title, strings.Join(descriptions, "，"), target)
+		if *dryRun {
+			continue
+		}
+
+		if moveDir {
+			newPath, done := moved[record.TargetPath]
+			if !done {
+				newPath, err = classifier.MoveToCategory(cfg, record.TargetPath, sets["category"])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "移动 '%s' 失败，不修改该记录: %v\n", record.TargetPath, err)
+				}
+				moved[record.TargetPath] = newPath
+			}
+			if newPath == "" {
+				failed++
+				continue
+			}
+		}
+		if err := database.UpdateMediaRecordFields(record.ID, changes); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed++
+			continue
+		}
+		detail := strings.Join(descriptions, "，")
+		if moveDir {
+			detail += "，目录: " + record.TargetPath + " → " + moved[record.TargetPath]
+		}
+		if err := database.RecordEvent(record.ID, record.TargetPath, database.EventEdited, detail); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	w.Flush()
+
+	if *dryRun {
+		fmt.Printf("预览：将修改 %d 条记录，使用时去掉 --dry-run\n", matched)
+		return nil
+	}
+	movedDirs := 0
+	for _, newPath := range moved {
+		if newPath != "" {
+			movedDirs++
+		}
+	}
+	fmt.Printf("已修改 %d 条记录，移动 %d 个目录，失败 %d 条\n", matched-failed, movedDirs, failed)
+	return nil
+}
+
+// runDBMaintenance 检查数据库完整性，执行VACUUM和ANALYZE，报告数据库大小和碎片情况
+func runDBMaintenance(args []string) error {
+	fs := flag.NewFlagSet("db maintenance", flag.ContinueOnError)
+	checkOnly := fs.Bool("check", false, "只检查完整性并报告大小和碎片，不执行VACUUM和ANALYZE")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	var stats *database.Stats
+	var problems []string
+	if *checkOnly {
+		var err error
+		if stats, err = database.GetStats(); err != nil {
+			return err
+		}
+		if problems, err = database.IntegrityCheck(); err != nil {
+			return err
+		}
+	} else {
+		result, err := database.Maintain()
+		if err != nil {
+			return err
+		}
+		stats, problems = result.After, result.Problems
+		if result.Vacuumed {
+			fmt.Printf("已执行VACUUM和ANALYZE，耗时 %v\n", result.Duration.Round(time.Millisecond))
+			fmt.Printf("维护前: %s\n", formatDBStats(result.Before))
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "表\t记录数")
+	tables := make([]string, 0, len(stats.TableRows))
+	for table := range stats.TableRows {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool { return stats.TableRows[tables[i]] > stats.TableRows[tables[j]] })
+	for _, table := range tables {
+		fmt.Fprintf(w, "%s\t%d\n", table, stats.TableRows[table])
+	}
+	w.Flush()
+	fmt.Printf("当前: %s\n", formatDBStats(stats))
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("数据库完整性检查发现 %d 个问题，未执行VACUUM，请从备份恢复数据库", len(problems))
+	}
+	fmt.Println("完整性检查: 正常")
+	return nil
+}
+
+// formatDBStats 格式化数据库大小和碎片情况，如 "512.3 MB，131150 页，空闲 20480 页（碎片 15.6%）"
+func formatDBStats(stats *database.Stats) string {
+	return fmt.Sprintf("%s，%d 页，空闲 %d 页（碎片 %.1f%%）",
+		utils.FormatBytes(stats.Size), stats.PageCount, stats.FreePages, stats.Fragmentation())
+}
+
+// getMediaRecordByID 按命令行参数中的ID获取媒体记录，调用前需要初始化数据库
+func getMediaRecordByID(arg string) (*database.MediaRecord, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("无效的记录ID: %s", arg)
+	}
+	records, err := database.GetMediaRecords(map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("没有ID为 %d 的媒体记录", id)
+	}
+	return &records[0], nil
+}
+
+// runDBShow 显示一条媒体记录的详细信息，以及标题、国家、类型、原始语言和分类的来源
+func runDBShow(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: db show <id>")
+	}
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	record, err := getMediaRecordByID(args[0])
+	if err != nil {
+		return err
+	}
+	sources := database.ParseFieldSources(record.FieldSources)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "字段\t值\t来源")
+	rows := []struct {
+		name, value, field string
+	}{
+		{"ID", strconv.Itoa(record.ID), ""},
+		{"标题", record.Title, database.FieldTitle},
+		{"原始标题", record.OriginalTitle, ""},
+		{"年份", record.Year, ""},
+		{"版本", record.Edition, ""},
+		{"国家", record.Country, database.FieldCountry},
+		{"类型", record.Genres, database.FieldGenres},
+		{"原始语言", record.OriginalLanguage, database.FieldOriginalLanguage},
+		{"分类", record.Category, database.FieldCategory},
+		{"无国家处理", record.CountryFallback, ""},
+		{"锁定", lockedText(record.Locked), ""},
+		{"季", database.FormatNumber(record.Season), ""},
+		{"TMDB ID", record.TMDbID, ""},
+		{"IMDb ID", record.IMDbID, ""},
+		{"分辨率", record.Resolution, ""},
+		{"简介来源", record.PlotSource, ""},
+		{"源路径", record.SourcePath, ""},
+		{"目标路径", record.TargetPath, ""},
+		{"处理时间", record.ProcessedAt.Format("2006-01-02 15:04:05"), ""},
+		{"更新时间", record.UpdatedAt.Format("2006-01-02 15:04:05"), ""},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", row.name, row.value, sources[row.field])
+	}
+	w.Flush()
+
+	if record.FieldSources == "" {
+		fmt.Println("该记录没有记录字段来源（在记录来源之前处理）")
+	}
+	return nil
+}
+
+// lockedText 返回锁定状态的说明
+func lockedText(locked bool) string {
+	if locked {
+		return "是"
+	}
+	return "否"
+}
+
+// runDBLock 锁定或解锁媒体记录，锁定的影片不再被重新分类、替换版本、合并新季、刷新元数据或改写NFO
+func runDBLock(args []string, locked bool) error {
+	name := "lock"
+	if !locked {
+		name = "unlock"
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("用法: db %s <id>", name)
+	}
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	record, err := getMediaRecordByID(args[0])
+	if err != nil {
+		return err
+	}
+	if record.Locked == locked {
+		fmt.Printf("'%s' (%s) 的锁定状态没有变化（锁定: %s）\n", record.Title, record.Year, lockedText(locked))
+		return nil
+	}
+	if err := database.SetLocked(record.ID, locked); err != nil {
+		return err
+	}
+	event := database.EventLocked
+	if !locked {
+		event = database.EventUnlocked
+	}
+	if err := database.RecordEvent(record.ID, record.TargetPath, event, record.TargetPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	fmt.Printf("已%s '%s' (%s): %s\n", database.EventName(event), record.Title, record.Year, record.TargetPath)
+	return nil
+}
+
+// runDBHistory 按时间顺序列出媒体记录的历史事件
+func runDBHistory(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: db history <id>")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	record, err := getMediaRecordByID(args[0])
+	if err != nil {
+		return err
+	}
+	id := record.ID
+	events, err := database.GetEvents(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) %s %s\n", record.Title, record.Year, record.Category, record.TargetPath)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t事件\t详情")
+	for _, event := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", event.CreatedAt.Format("2006-01-02 15:04:05"), database.EventName(event.Event), event.Detail)
+	}
+	w.Flush()
+
+	fmt.Printf("共 %d 条事件\n", len(events))
+	return nil
+}
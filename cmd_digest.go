@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/digest"
+)
+
+// runDigestCommand 处理digest子命令，默认输出最近几天的摘要，--send时发送邮件
+func runDigestCommand(args []string) error {
+	cfg := appConfig()
+
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	days := fs.Int("days", cfg.Email.DigestIntervalDays, "汇总最近多少天的变化")
+	html := fs.Bool("html", false, "输出HTML格式的摘要")
+	send := fs.Bool("send", false, "立即发送摘要邮件，并记录存储用量快照")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("--days 必须大于0")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	since := time.Now().AddDate(0, 0, -*days)
+	if *send {
+		if !cfg.Email.Enabled() {
+			return fmt.Errorf("没有配置摘要邮件的smtp_host和to")
+		}
+		return digest.SendNow(cfg, since)
+	}
+
+	d, err := digest.Build(cfg, since)
+	if err != nil {
+		return err
+	}
+	render := digest.RenderText
+	if *html {
+		render = digest.RenderHTML
+	}
+	content, err := render(d)
+	if err != nil {
+		return err
+	}
+	fmt.Print(content)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/permissions"
+)
+
+// runDoctorCommand 处理doctor子命令，检查媒体库中文件和目录的所有者和权限，--fix-permissions时修正
+func runDoctorCommand(args []string) error {
+	cfg := appConfig()
+
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix-permissions", false, "按permissions配置修正所有者和权限")
+	workers := fs.Int("workers", 4, "并发处理的任务数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !cfg.Permissions.Enabled() {
+		return fmt.Errorf("没有配置permissions（puid、pgid、file_mode、dir_mode）")
+	}
+	if *fix && database.IsDryRun() {
+		logging.Info("[模拟] 只检查所有者和权限，不修正")
+		*fix = false
+	}
+
+	var total permissions.Result
+	for _, root := range cfg.CloudDirs() {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		fmt.Printf("检查 %s\n", root)
+		bar := newProgressBar()
+		result, err := permissions.Fix(cfg.Permissions, root, *workers, *fix, bar.update)
+		bar.finish()
+		if err != nil {
+			return err
+		}
+		total.Checked += result.Checked
+		total.Drifted += result.Drifted
+		total.Fixed += result.Fixed
+		total.Failed += result.Failed
+	}
+
+	if *fix {
+		fmt.Printf("检查 %d 项，%d 项与配置不一致，已修正 %d 项，失败 %d 项\n", total.Checked, total.Drifted, total.Fixed, total.Failed)
+	} else {
+		fmt.Printf("检查 %d 项，%d 项与配置不一致，使用 --fix-permissions 修正\n", total.Checked, total.Drifted)
+	}
+	return nil
+}
+
+// progressBar 在标准错误输出上显示进度条，最多每100毫秒刷新一次
+type progressBar struct {
+	mu      sync.Mutex
+	drawnAt time.Time
+}
+
+func newProgressBar() *progressBar {
+	return &progressBar{}
+}
+
+// update 更新进度
+func (b *progressBar) update(done, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if done < total && time.Since(b.drawnAt) < 100*time.Millisecond {
+		return
+	}
+	b.drawnAt = time.Now()
+
+	const width = 30
+	filled := width
+	if total > 0 {
+		filled = int(done * width / total)
+	}
+	percent := int64(100)
+	if total > 0 {
+		percent = done * 100 / total
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3d%% %d/%d", strings.Repeat("#", filled), strings.Repeat("-", width-filled), percent, done, total)
+}
+
+// finish 结束进度条的输出行
+func (b *progressBar) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.drawnAt.IsZero() {
+		fmt.Fprintln(os.Stderr)
+	}
+}
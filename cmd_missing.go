@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/acquire"
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/seerr"
+	"github.com/user/media-manager/utils"
+)
+
+// runMissingCommand 处理missing子命令
+func runMissingCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: missing detect | missing resolve [--episode] <id> [--force] | missing rescan <标题> | missing export | missing request [--dry-run] | missing acquire [--dry-run]")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "detect":
+		return batchDetectMissing()
+	case "resolve":
+		return runMissingResolve(args[1:])
+	case "rescan":
+		return runMissingRescan(args[1:])
+	case "export":
+		return runMissingExport()
+	case "request":
+		return runMissingRequest(args[1:])
+	case "acquire":
+		return runMissingAcquire(args[1:])
+	default:
+		return fmt.Errorf("未知的missing子命令: %s", args[0])
+	}
+}
+
+// runMissingResolve 重新检查并将指定的缺失季（--episode时为缺失剧集）标记为已获取
+func runMissingResolve(args []string) error {
+	fs := flag.NewFlagSet("missing resolve", flag.ContinueOnError)
+	force := fs.Bool("force", false, "即使剧集目录中没有找到该季或该集也标记为已获取")
+	episode := fs.Bool("episode", false, "ID为缺失剧集记录的ID（report missing中按集列出的行）")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("用法: missing resolve [--episode] <id> [--force]")
+	}
+
+	kind, resolve := "缺失季", classifier.ResolveMissingSeason
+	if *episode {
+		kind, resolve = "缺失剧集", classifier.ResolveMissingEpisode
+	}
+
+	id, err := strconv.Atoi(positional[0])
+	if err != nil {
+		return fmt.Errorf("无效的%sID: %s", kind, positional[0])
+	}
+
+	found, err := resolve(id, *force)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		fmt.Printf("%s %d 已在剧集目录中找到，已标记为已获取\n", kind, id)
+	} else {
+		fmt.Printf("%s %d 已手动标记为已获取\n", kind, id)
+	}
+	return nil
+}
+
+// runMissingRescan 重新检查标题匹配的剧集目录
+func runMissingRescan(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: missing rescan <标题>")
+	}
+
+	seasons, episodes, err := classifier.RescanMissing(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("重新检查完成: %d 个缺失季、%d 个缺失剧集标记为已获取\n", seasons, episodes)
+	return nil
+}
+
+// runMissingExport 以Overseerr/Jellyseerr创建请求的JSON格式输出缺失的季和系列电影
+func runMissingExport() error {
+	requests, err := seerr.Wanted()
+	if err != nil {
+		return err
+	}
+	return seerr.WriteJSON(os.Stdout, requests)
+}
+
+// runMissingRequest 在Overseerr/Jellyseerr中为之前没有请求过的缺失内容创建请求
+func runMissingRequest(args []string) error {
+	fs := flag.NewFlagSet("missing request", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "只列出将要创建的请求")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	requests, err := seerr.Wanted()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		pending, skipped, err := seerr.Pending(requests)
+		if err != nil {
+			return err
+		}
+		for _, request := range pending {
+			if request.MediaType == seerr.MediaTypeTV {
+				fmt.Printf("电视剧\t%s\tTMDB %d\t第 %v 季\n", request.Title, request.MediaID, request.Seasons)
+			} else {
+				fmt.Printf("电影\t%s\tTMDB %d\n", request.Title, request.MediaID)
+			}
+		}
+		fmt.Printf("将创建 %d 个请求，%d 项之前已请求过\n", len(pending), skipped)
+		return nil
+	}
+
+	result, err := seerr.Submit(appConfig().Seerr, requests)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已创建 %d 个请求，%d 项之前已请求过，失败 %d 个\n", result.Submitted, result.Skipped, result.Failed)
+	return nil
+}
+
+// runMissingAcquire 为缺失的季在索引器中搜索发布，把画质最好的提交到aria2或qBittorrent
+func runMissingAcquire(args []string) error {
+	fs := flag.NewFlagSet("missing acquire", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "只搜索并列出每一季将要提交的发布")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := appConfig()
+	if !*dryRun {
+		result, err := acquire.Run(cfg, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已提交 %d 个下载，%d 季没有找到符合要求的发布，%d 季之前已提交过，失败 %d 个\n",
+			result.Submitted, result.NotFound, result.Skipped, result.Failed)
+		return nil
+	}
+
+	plans, skipped, err := acquire.SearchMissing(cfg, false)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "剧集\t季\t发布\t画质\t大小\t候选数")
+	for _, plan := range plans {
+		switch {
+		case plan.Err != nil:
+			fmt.Fprintf(w, "%s\t%d\t搜索失败: %v\t\t\t\n", plan.Season.Title, plan.Season.Season, plan.Err)
+		case plan.Best == nil:
+			fmt.Fprintf(w, "%s\t%d\t（没有符合要求的发布）\t\t\t0\n", plan.Season.Title, plan.Season.Season)
+		default:
+			size := "-"
+			if plan.Best.Size > 0 {
+				size = utils.FormatBytes(plan.Best.Size)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\n", plan.Season.Title, plan.Season.Season, plan.Best.Title, plan.Best.Quality, size, plan.Candidates)
+		}
+	}
+	w.Flush()
+	fmt.Printf("共搜索 %d 季，%d 季之前已提交过\n", len(plans), skipped)
+	return nil
+}
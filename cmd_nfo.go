@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/tmdb"
+)
+
+// runNFOCommand 处理nfo子命令
+func runNFOCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: nfo regenerate <id|路径> [--print] [--force]")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "regenerate":
+		return runNFORegenerate(args[1:])
+	default:
+		return fmt.Errorf("未知的nfo子命令: %s", args[0])
+	}
+}
+
+// runNFORegenerate 根据数据库记录和TMDB的最新详情重新生成媒体库中项目的NFO文件，用于NFO损坏或被误删后恢复
+func runNFORegenerate(args []string) error {
+	fs := flag.NewFlagSet("nfo regenerate", flag.ContinueOnError)
+	printOnly := fs.Bool("print", false, "只输出生成的NFO内容，不写入文件")
+	force := fs.Bool("force", false, "记录已锁定（db lock）时也重新生成")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("用法: nfo regenerate <id|路径> [--print] [--force]")
+	}
+
+	record, err := findRecordForNFO(positional[0])
+	if err != nil {
+		return err
+	}
+	if !*printOnly && !*force && (record.Locked || classifier.IsLocked(record.TargetPath)) {
+		return fmt.Errorf("'%s' 已锁定（db lock），使用 --force 重新生成NFO", record.Title)
+	}
+
+	// 使用TMDB的最新详情，不使用缓存
+	tmdb.RefreshCache = true
+	result, err := classifier.RegenerateNFO(appConfig(), record, !*printOnly)
+	if err != nil {
+		return err
+	}
+
+	if *printOnly {
+		fmt.Print(result.Content)
+		return nil
+	}
+	source := "数据库记录和TMDB的最新详情"
+	if !result.UsedTMDB {
+		source = "数据库记录（没有获取到TMDB详情）"
+	}
+	fmt.Printf("已根据%s重新生成 '%s' (%s) 的NFO文件: %s\n", source, record.Title, record.Year, result.Path)
+	return nil
+}
+
+// findRecordForNFO 按记录ID或媒体库中的路径查找媒体记录
+// 路径可以是影片目录、其中的NFO文件或剧集的季目录
+func findRecordForNFO(arg string) (*database.MediaRecord, error) {
+	if _, err := strconv.Atoi(arg); err == nil {
+		return getMediaRecordByID(arg)
+	}
+
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, fmt.Errorf("无效的路径: %s", arg)
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+	for _, dir := range []string{path, filepath.Dir(path)} {
+		records, err := database.GetMediaRecords(map[string]interface{}{"target_path": dir})
+		if err != nil {
+			return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+		}
+		if len(records) > 0 {
+			return &records[0], nil
+		}
+	}
+	return nil, fmt.Errorf("数据库中没有目标路径为 %s 的媒体记录", path)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/plugins"
+)
+
+// runPluginsCommand 处理plugins子命令，列出插件目录中发现的插件
+func runPluginsCommand(args []string) error {
+	if len(args) > 0 && args[0] != "list" {
+		return fmt.Errorf("未知的plugins子命令: %s", args[0])
+	}
+
+	cfg := appConfig()
+	found, err := plugins.Discover(cfg.PluginsDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("插件目录: %s\n", cfg.PluginsDir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "名称\t版本\t能力\t路径")
+	for _, plugin := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", plugin.Name, plugin.Version, strings.Join(plugin.Capabilities, ","), plugin.Path)
+	}
+	w.Flush()
+
+	fmt.Printf("共 %d 个插件\n", len(found))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// runProcessCommand 处理process子命令：处理指定的NFO文件或影片目录中的NFO文件，不运行tinyMediaManager
+func runProcessCommand(args []string) error {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	fs.BoolVar(dryRun, "dry-run", *dryRun, "只输出将要执行的NFO修改、移动、删除和数据库写入，不修改文件和数据库")
+	addRunFlags(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("用法: process <NFO文件|影片目录> [--dry-run] [--max-items 数量] [--max-duration 时长] [--only-category 分类] [--title 标题]")
+	}
+	applyRunFlags()
+	if *dryRun {
+		if err := enableDryRun(); err != nil {
+			return err
+		}
+	}
+
+	path := positional[0]
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("路径不存在: %s", path)
+	}
+
+	startedAt := time.Now()
+	database.StartRun()
+	mode := "nfo"
+	if info.IsDir() {
+		logging.Info("处理影片目录: %s", path)
+		mode = "dir"
+		err = handleMovieDir(path)
+	} else {
+		logging.Info("处理单个NFO文件: %s", path)
+		err = handleSingleNFO(path)
+	}
+	return errors.Join(err, runPostRunHooks(mode, []string{path}, startedAt))
+}
+
+// runConfigCommand 处理config子命令，显示当前配置
+func runConfigCommand(args []string) error {
+	if len(args) > 0 && args[0] != "show" {
+		return fmt.Errorf("用法: config [show]")
+	}
+	showConfig()
+	return nil
+}
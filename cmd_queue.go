@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/database"
+)
+
+// runQueueCommand 处理queue子命令
+func runQueueCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: queue list [--status 状态] | queue purge [--status 状态]")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "list":
+		return runQueueList(args[1:])
+	case "purge":
+		return runQueuePurge(args[1:])
+	default:
+		return fmt.Errorf("未知的queue子命令: %s", args[0])
+	}
+}
+
+// runQueueList 按处理顺序列出队列项目
+func runQueueList(args []string) error {
+	fs := flag.NewFlagSet("queue list", flag.ContinueOnError)
+	status := fs.String("status", "", "按状态过滤：pending、processing、done、failed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	items, err := database.GetQueueItems(map[string]interface{}{"status": *status})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t状态\t优先级\t次数\t入队时间\tNFO文件\t错误")
+	for _, item := range items {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			item.ID, item.Status, item.Priority, item.Attempts,
+			item.EnqueuedAt.Format("2006-01-02 15:04"), item.NFOPath, item.LastError)
+	}
+	w.Flush()
+
+	fmt.Printf("共 %d 个队列项目\n", len(items))
+	return nil
+}
+
+// runQueuePurge 删除指定状态的队列项目
+func runQueuePurge(args []string) error {
+	fs := flag.NewFlagSet("queue purge", flag.ContinueOnError)
+	status := fs.String("status", database.QueueStatusDone, "要删除的项目状态")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	count, err := database.PurgeQueueItems(*status)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已删除 %d 个状态为 %s 的队列项目\n", count, *status)
+	return nil
+}
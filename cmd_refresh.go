@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/tmdb"
+)
+
+// runRefreshMetadataCommand 处理refresh-metadata子命令，重新查询更新时间早于--older-than的记录
+// 按更新时间从早到晚处理，TMDB请求数达到--budget时停止，剩余的记录下次运行继续
+func runRefreshMetadataCommand(args []string) error {
+	fs := flag.NewFlagSet("refresh-metadata", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "90d", "只刷新超过这个时间没有更新的记录，如 90d、12h")
+	budget := fs.Int("budget", 200, "本次最多发送的TMDB请求数，0表示不限制")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	records, err := database.GetMediaRecords(map[string]interface{}{"updated_before": time.Now().Add(-age)})
+	if err != nil {
+		return fmt.Errorf("查询媒体记录失败: %w", err)
+	}
+	logging.Info("共有 %d 条记录超过 %s 没有更新", len(records), *olderThan)
+
+	// 刷新时不使用缓存的详情
+	tmdb.RefreshCache = true
+	startRequests := tmdb.RequestCount()
+	refreshed, updated, failed := 0, 0, 0
+	for i := range records {
+		if *budget > 0 && tmdb.RequestCount()-startRequests >= int64(*budget) {
+			logging.Info("已发送 %d 个TMDB请求，达到上限，剩余 %d 条记录下次刷新", tmdb.RequestCount()-startRequests, len(records)-i)
+			break
+		}
+
+		record := &records[i]
+		if record.TMDbID == "" {
+			continue
+		}
+		if record.Locked || classifier.IsLocked(record.TargetPath) {
+			logging.Info("'%s' 已锁定（db lock），不刷新", record.Title)
+			continue
+		}
+		changed, err := classifier.RefreshMetadata(record)
+		if err != nil {
+			logging.Error("刷新 '%s' 失败: %v", record.Title, err)
+			failed++
+			continue
+		}
+		refreshed++
+		if len(changed) > 0 {
+			updated++
+			logging.Info("已刷新 '%s'，更新了 %s", record.Title, strings.Join(changed, ", "))
+			if err := database.RecordEvent(record.ID, record.TargetPath, database.EventRefreshed, strings.Join(changed, ", ")); err != nil {
+				logging.Error("%v", err)
+			}
+		}
+	}
+
+	fmt.Printf("刷新完成: 查询 %d 条，%d 条有更新，%d 条失败，TMDB请求 %d 个\n", refreshed, updated, failed, tmdb.RequestCount()-startRequests)
+	return nil
+}
+
+// parseAge 解析时间长度，支持以d结尾的天数（如 90d）和Go的时间格式（如 12h）
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("无效的时间长度: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无效的时间长度: %s", value)
+	}
+	return d, nil
+}
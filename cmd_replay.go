@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// runReplayCommand 处理replay子命令：按当前配置离线重现一次运行的分类决策，与记录的结果比较
+func runReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("file", "", "重现export导出的记录文件，而不是数据库中的记录")
+	export := fs.String("export", "", "把运行的记录导出到JSON文件（只包含NFO内容和数据源响应，不包含影片文件），不重现")
+	item := fs.String("item", "", "只处理目录路径包含该字符串的项目")
+	changed := fs.Bool("changed", false, "只列出重现结果与记录不同的项目")
+	verbose := fs.Bool("v", false, "同时输出拒绝原因和重现时使用的国家、语言")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	var recordings []*classifier.Recording
+	switch {
+	case *file != "":
+		if recordings, err = readRecordingsFile(*file); err != nil {
+			return err
+		}
+	case len(positional) == 0:
+		return listRecordedRuns()
+	default:
+		if recordings, err = loadRecordings(positional[0]); err != nil {
+			return err
+		}
+	}
+
+	if *item != "" {
+		var filtered []*classifier.Recording
+		for _, rec := range recordings {
+			if strings.Contains(rec.NFOPath, *item) {
+				filtered = append(filtered, rec)
+			}
+		}
+		recordings = filtered
+	}
+	if len(recordings) == 0 {
+		fmt.Println("没有分类决策记录")
+		return nil
+	}
+
+	if *export != "" {
+		content, err := json.MarshalIndent(recordings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化分类决策记录失败: %w", err)
+		}
+		if err := os.WriteFile(*export, content, 0644); err != nil {
+			return fmt.Errorf("导出分类决策记录失败: %w", err)
+		}
+		fmt.Printf("已导出 %d 条分类决策记录到 %s\n", len(recordings), *export)
+		return nil
+	}
+	return replayRecordings(recordings, *changed, *verbose)
+}
+
+// listRecordedRuns 列出保存了分类决策记录的运行
+func listRecordedRuns() error {
+	runs, err := database.GetRecordedRuns()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("没有分类决策记录（replay_runs为-1时不记录）")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "运行\t项目数")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%d\n", run.RunID, run.Count)
+	}
+	w.Flush()
+	fmt.Println("\n使用 replay <运行ID> 重现，last表示最近一次运行")
+	return nil
+}
+
+// loadRecordings 从数据库读取一次运行的分类决策记录，runID为last时使用最近一次运行
+func loadRecordings(runID string) ([]*classifier.Recording, error) {
+	if runID == "last" {
+		runs, err := database.GetRecordedRuns()
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) == 0 {
+			return nil, nil
+		}
+		runID = runs[0].RunID
+	}
+
+	records, err := database.GetDecisionRecords(runID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("运行 %s 没有分类决策记录", runID)
+	}
+	var recordings []*classifier.Recording
+	for _, record := range records {
+		var rec classifier.Recording
+		if err := json.Unmarshal([]byte(record.Data), &rec); err != nil {
+			logging.Warning("无法解析 '%s' 的分类决策记录: %v", record.MediaDir, err)
+			continue
+		}
+		recordings = append(recordings, &rec)
+	}
+	return recordings, nil
+}
+
+// readRecordingsFile 读取export导出的记录文件
+func readRecordingsFile(path string) ([]*classifier.Recording, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取记录文件失败: %w", err)
+	}
+	var recordings []*classifier.Recording
+	if err := json.Unmarshal(content, &recordings); err != nil {
+		return nil, fmt.Errorf("解析记录文件失败: %w", err)
+	}
+	return recordings, nil
+}
+
+// replayRecordings 重现每条记录的分类决策，列出记录的结果和重现的结果
+func replayRecordings(recordings []*classifier.Recording, changedOnly bool, verbose bool) error {
+	cfg := appConfig()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\t目录\t记录的结果\t重现的结果")
+	changed := 0
+	for _, rec := range recordings {
+		replayed := classifier.Replay(cfg, rec)
+		mark := ""
+		if !replayed.Same(rec.Outcome) {
+			mark = "*"
+			changed++
+		} else if changedOnly {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mark, filepath.Base(filepath.Dir(rec.NFOPath)), rec.Outcome, replayed)
+		if verbose {
+			if rec.Outcome.Reason != "" {
+				fmt.Fprintf(w, "\t\t  %s\t\n", rec.Outcome.Reason)
+			}
+			if replayed.Reason != "" {
+				fmt.Fprintf(w, "\t\t\t  %s\n", replayed.Reason)
+			}
+			if rec.TMDB != nil {
+				if rec.TMDB.Error != "" {
+					fmt.Fprintf(w, "\t\t  TMDB: %s\t\n", rec.TMDB.Error)
+				} else {
+					fmt.Fprintf(w, "\t\t  TMDB: 国家 %v，原始语言 %s\t\n", rec.TMDB.Countries, rec.TMDB.OriginalLanguage)
+				}
+			}
+		}
+	}
+	w.Flush()
+	fmt.Printf("\n共 %d 个项目，%d 个的重现结果与记录不同（*）\n", len(recordings), changed)
+	return nil
+}
+
+// pruneDecisionRecords 每次运行结束时只保留最近replay_runs次运行的分类决策记录
+func pruneDecisionRecords(cfg *config.Config) {
+	if cfg.ReplayRuns <= 0 {
+		return
+	}
+	if _, err := database.PruneDecisionRecords(cfg.ReplayRuns); err != nil {
+		logging.Warning("%v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/utils"
+)
+
+// runReportCommand 处理report子命令
+func runReportCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: report missing [--movies] | report duplicates")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "missing":
+		return runReportMissing(args[1:])
+	case "duplicates":
+		return runReportDuplicates()
+	default:
+		return fmt.Errorf("未知的report子命令: %s", args[0])
+	}
+}
+
+// runReportMissing 列出缺失的季和剧集，或电影系列中缺失的电影
+func runReportMissing(args []string) error {
+	fs := flag.NewFlagSet("report missing", flag.ContinueOnError)
+	movies := fs.Bool("movies", false, "列出电影系列中缺失的电影")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *movies {
+		return reportMissingMovies()
+	}
+	return reportMissingSeasons()
+}
+
+// reportMissingMovies 按系列列出缺失的电影
+func reportMissingMovies() error {
+	missing, err := database.GetMissingMovies()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "系列\t电影\t上映日期\tTMDb ID")
+	for _, movie := range missing {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", movie.CollectionName, movie.Title, movie.ReleaseDate, movie.TMDbID)
+	}
+	w.Flush()
+
+	fmt.Printf("共缺失 %d 部系列电影\n", len(missing))
+	return nil
+}
+
+// reportMissingSeasons 列出缺失的季和剧集
+func reportMissingSeasons() error {
+	seasons, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	episodes, err := database.GetMissingEpisodes(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t剧集\t缺失\t检测时间")
+	for _, season := range seasons {
+		fmt.Fprintf(w, "%d\t%s\t第 %d 季\t%s\n", season.ID, season.Title, season.Season, season.DetectedAt.Format("2006-01-02 15:04"))
+	}
+	for _, episode := range episodes {
+		fmt.Fprintf(w, "%d\t%s\tS%02dE%02d\t%s\n", episode.ID, episode.Title, episode.Season, episode.Episode, episode.DetectedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+
+	fmt.Printf("共缺失 %d 季、%d 集\n", len(seasons), len(episodes))
+	return nil
+}
+
+// runReportDuplicates 列出规范化标题、年份、版本（电视剧还有季数）相同的媒体记录
+func runReportDuplicates() error {
+	records, err := database.GetMediaRecords(map[string]interface{}{"order": "pinyin"})
+	if err != nil {
+		return fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	groups := findDuplicateRecords(records)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "组\tID\t标题\t年份\t季\t版本\t分类\tTMDb ID\t目录")
+	for i, group := range groups {
+		for _, record := range group {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				i+1, record.ID, record.Title, record.Year, database.FormatNumber(record.Season), record.Edition, record.Category, record.TMDbID, record.TargetPath)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("共 %d 组重复记录\n", len(groups))
+	return nil
+}
+
+// findDuplicateRecords 按规范化标题、年份、季数、版本和电影/电视剧分组，返回包含多条记录的组
+// 同一组中有不同的TMDb ID时是同名的不同作品，不算重复
+func findDuplicateRecords(records []database.MediaRecord) [][]database.MediaRecord {
+	var keys []string
+	byKey := make(map[string][]database.MediaRecord)
+	for _, record := range records {
+		title := utils.NormalizeTitle(record.Title)
+		if title == "" {
+			continue
+		}
+		key := strings.Join([]string{title, record.Year, database.FormatNumber(record.Season), record.Edition, fmt.Sprint(strings.HasSuffix(record.Category, "Show"))}, "\x00")
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], record)
+	}
+
+	var groups [][]database.MediaRecord
+	for _, key := range keys {
+		group := byKey[key]
+		if len(group) < 2 {
+			continue
+		}
+		tmdbIDs := make(map[string]bool)
+		for _, record := range group {
+			if record.TMDbID != "" {
+				tmdbIDs[record.TMDbID] = true
+			}
+		}
+		if len(tmdbIDs) <= 1 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// 媒体目录的NFO状态
+const (
+	scanStatusScraped   = "已刮削"
+	scanStatusUnscraped = "未刮削"
+	scanStatusMultiple  = "多个NFO"
+	scanStatusNoVideo   = "无视频"
+	scanStatusIgnored   = "忽略"
+)
+
+// scanItem scan子命令发现的一个媒体目录
+type scanItem struct {
+	Dir        string                `json:"dir"`
+	Status     string                `json:"status"`
+	NFO        string                `json:"nfo,omitempty"` // 处理时使用的NFO文件
+	NFOCount   int                   `json:"nfo_count"`
+	Size       int64                 `json:"size"`
+	Prediction classifier.Prediction `json:"prediction"`
+}
+
+// scanDirResult scan子命令扫描的一个Temp子目录
+type scanDirResult struct {
+	Dir    string     `json:"dir"`
+	Exists bool       `json:"exists"`
+	IsTV   bool       `json:"is_tv"`
+	Items  []scanItem `json:"items"`
+}
+
+// runScanCommand 处理scan子命令：列出Temp目录中的媒体目录、NFO状态、大小和预测的分类，不刮削、不处理
+func runScanCommand(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "以JSON格式输出")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	scanType := "all"
+	if len(positional) > 0 {
+		scanType = positional[0]
+		if scanType != "movies" && scanType != "tv" && scanType != "all" {
+			return fmt.Errorf("用法: scan [movies|tv|all] [--json]")
+		}
+	}
+
+	cfg := appConfig()
+	var results []scanDirResult
+	for _, tempDir := range cfg.TempDirs {
+		for _, subdir := range scrapeSubdirs(scanType) {
+			result, err := scanTempSubdir(filepath.Join(tempDir, subdir), subdir == "TvShow", cfg)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("生成JSON失败: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printScanResults(results)
+	return nil
+}
+
+// scanTempSubdir 检查Temp子目录下的每个媒体目录
+func scanTempSubdir(dir string, isTV bool, cfg *config.Config) (scanDirResult, error) {
+	result := scanDirResult{Dir: dir, IsTV: isTV, Items: []scanItem{}}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, fmt.Errorf("读取目录失败: %w", err)
+	}
+	result.Exists = true
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		result.Items = append(result.Items, scanMediaDir(filepath.Join(dir, entry.Name()), isTV, cfg))
+	}
+	sort.Slice(result.Items, func(i, j int) bool { return result.Items[i].Dir < result.Items[j].Dir })
+	return result, nil
+}
+
+// scanMediaDir 检查一个媒体目录的NFO状态、大小，并预测分类
+func scanMediaDir(mediaDir string, isTV bool, cfg *config.Config) scanItem {
+	item := scanItem{Dir: mediaDir}
+	if utils.HasIgnoreMarker(mediaDir) {
+		item.Status = scanStatusIgnored
+		return item
+	}
+	item.Size = utils.DirSize(mediaDir, cfg.SymlinkMode(config.SymlinkOpScan))
+
+	nfoFiles, err := parser.ListNFOFiles(mediaDir)
+	if err != nil {
+		item.Status = scanStatusUnscraped
+		item.Prediction = classifier.Prediction{Reason: fmt.Sprintf("读取目录失败: %v", err)}
+		return item
+	}
+	item.NFOCount = len(nfoFiles)
+
+	switch {
+	case len(nfoFiles) == 0 && !hasMediaFiles(mediaDir):
+		item.Status = scanStatusNoVideo
+	case len(nfoFiles) == 0:
+		item.Status = scanStatusUnscraped
+		item.Prediction = classifier.PredictUnscraped(mediaDir, isTV)
+	default:
+		item.Status = scanStatusScraped
+		if len(nfoFiles) > 1 {
+			item.Status = scanStatusMultiple
+		}
+		item.NFO, _ = parser.SelectNFOFile(nfoFiles, cfg.NFOSelection)
+		item.Prediction = classifier.PredictCategory(cfg, item.NFO)
+	}
+	return item
+}
+
+// printScanResults 以表格输出扫描结果和各状态的汇总
+func printScanResults(results []scanDirResult) {
+	counts := make(map[string]int)
+	categories := make(map[string]int)
+	var total int64
+	for _, result := range results {
+		if !result.Exists {
+			fmt.Printf("%s（不存在）\n\n", result.Dir)
+			continue
+		}
+		fmt.Printf("%s（%d 个目录）\n", result.Dir, len(result.Items))
+		if len(result.Items) == 0 {
+			fmt.Println()
+			continue
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  目录\t状态\t大小\t预测分类\t说明")
+		for _, item := range result.Items {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", filepath.Base(item.Dir), item.Status,
+				utils.FormatBytes(item.Size), item.Prediction, item.Prediction.Reason)
+			counts[item.Status]++
+			total += item.Size
+			if item.Prediction.Category != "" && item.Prediction.Rule == "" {
+				categories[item.Prediction.Category]++
+			}
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	fmt.Printf("共 %d 个已刮削，%d 个未刮削，%d 个有多个NFO，%d 个没有视频，%d 个忽略，总大小 %s\n",
+		counts[scanStatusScraped], counts[scanStatusUnscraped], counts[scanStatusMultiple],
+		counts[scanStatusNoVideo], counts[scanStatusIgnored], utils.FormatBytes(total))
+	if len(categories) > 0 {
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Print("预测分类:")
+		for _, name := range names {
+			fmt.Printf(" %s %d", name, categories[name])
+		}
+		fmt.Println()
+	}
+}
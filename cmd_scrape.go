@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// runScrapeCommand 处理scrape子命令：运行tinyMediaManager刮削Temp目录，再处理刮削得到的NFO文件并移动到媒体库
+func runScrapeCommand(args []string) error {
+	fs := flag.NewFlagSet("scrape", flag.ContinueOnError)
+	fs.BoolVar(dryRun, "dry-run", *dryRun, "只列出将要运行的tinyMediaManager命令、扫描的目录和NFO文件，不实际刮削和处理")
+	fs.BoolVar(jsonOutput, "json", *jsonOutput, "与--dry-run一起使用，以JSON格式输出")
+	addRunFlags(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	scrapeType := "all"
+	if len(positional) > 0 {
+		scrapeType = positional[0]
+	}
+	if len(positional) > 1 || (scrapeType != "movies" && scrapeType != "tv" && scrapeType != "all") {
+		return fmt.Errorf("用法: scrape [movies|tv|all] [--dry-run [--json]] [--max-items 数量] [--max-duration 时长] [--only-category 分类] [--title 标题]")
+	}
+	applyRunFlags()
+
+	if *dryRun {
+		if *jsonOutput {
+			// JSON输出到stdout，日志不能混在其中
+			logging.SetConsoleLevel(logging.ErrorLevel)
+		}
+		return planScrape(scrapeType, *jsonOutput)
+	}
+
+	startedAt := time.Now()
+	database.StartRun()
+	if err := handleScrape(scrapeType); err != nil {
+		return err
+	}
+	return runPostRunHooks("scrape", appConfig().TempDirs, startedAt)
+}
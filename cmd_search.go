@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/media-manager/acquire"
+	"github.com/user/media-manager/utils"
+)
+
+// runSearchCommand 在索引器中按标题搜索发布，按画质要求排序后列出
+func runSearchCommand(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	season := fs.Int("season", 0, "只列出标题和季数匹配的整季发布")
+	limit := fs.Int("limit", 20, "最多列出的发布数，0表示不限")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("用法: search <标题> [--season N] [--limit 20]")
+	}
+
+	query := acquire.Query{Title: strings.Join(positional, " "), Season: *season}
+	releases, rejected, err := acquire.Search(appConfig(), query)
+	if err != nil {
+		return err
+	}
+
+	shown := releases
+	if *limit > 0 && len(shown) > *limit {
+		shown = shown[:*limit]
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\t发布\t画质\t大小\t做种")
+	for i, release := range shown {
+		size, seeders := "-", "-"
+		if release.Size > 0 {
+			size = utils.FormatBytes(release.Size)
+		}
+		if release.Seeders >= 0 {
+			seeders = strconv.Itoa(release.Seeders)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, release.Title, release.Quality, size, seeders)
+	}
+	w.Flush()
+	fmt.Printf("共 %d 个符合要求的发布，%d 个因画质要求或没有做种被排除\n", len(releases), rejected)
+	return nil
+}
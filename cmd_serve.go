@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/server"
+)
+
+// runServeCommand 启动HTTP服务
+func runServeCommand(args []string) error {
+	cfg := appConfig()
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", cfg.ServeAddr, "HTTP监听地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	return server.Serve(*addr)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/metrics"
+	"github.com/user/media-manager/stats"
+	"github.com/user/media-manager/utils"
+)
+
+// runStatsCommand 处理stats子命令
+func runStatsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: stats summary [--json] | stats skips [--list] | stats timings [--runs N]")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	switch args[0] {
+	case "summary":
+		return runStatsSummary(args[1:])
+	case "skips":
+		return runStatsSkips(args[1:])
+	case "timings":
+		return runStatsTimings(args[1:])
+	default:
+		return fmt.Errorf("未知的stats子命令: %s", args[0])
+	}
+}
+
+// runStatsSummary 输出媒体库的概要数字，--json时输出与serve的/api/stats.json相同的JSON
+func runStatsSummary(args []string) error {
+	fs := flag.NewFlagSet("stats summary", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "以JSON输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := stats.Collect(appConfig())
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Temp目录待处理\t%d\n", s.TempPending)
+	fmt.Fprintf(w, "队列等待/失败\t%d / %d\n", s.QueuePending, s.QueueFailed)
+	fmt.Fprintf(w, "今天入库\t%d\n", s.MovedToday)
+	fmt.Fprintf(w, "媒体库\t电影 %d 部，剧集 %d 部（%d 季）\n", s.LibraryMovies, s.LibraryShows, s.LibrarySeasons)
+	fmt.Fprintf(w, "缺失季\t%d\n", s.MissingSeasons)
+	for _, storage := range s.Storage {
+		fmt.Fprintf(w, "剩余空间\t%s（%s）\n", utils.FormatBytes(int64(storage.FreeBytes)), storage.Path)
+	}
+	if run := s.LastRun; run != nil {
+		fmt.Fprintf(w, "最近一次运行\t%s：处理 %d 个，移动 %d 个，跳过 %d 个，失败 %d 个，耗时 %v\n",
+			run.FinishedAt.Format("2006-01-02 15:04"), run.Processed, run.Moved, run.Skipped, run.Failed, time.Duration(run.DurationSeconds)*time.Second)
+	}
+	return w.Flush()
+}
+
+// runStatsSkips 按规则统计跳过移动的原因：最近一次运行、当前积压和累计次数
+func runStatsSkips(args []string) error {
+	fs := flag.NewFlagSet("stats skips", flag.ContinueOnError)
+	list := fs.Bool("list", false, "同时列出当前积压的每个目录及跳过原因")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 最近一次运行
+	lastRunID, err := database.LastRunID()
+	if err != nil {
+		return err
+	}
+	if lastRunID != "" {
+		items, err := database.GetSkipItems(lastRunID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("最近一次运行（%s）跳过 %d 个目录:\n", lastRunID, len(items))
+		printSkipCounts(countSkipsByRule(items), len(items))
+		fmt.Println()
+	}
+
+	// 当前积压：仍留在原位置的跳过项目
+	items, err := database.GetSkipItems("")
+	if err != nil {
+		return err
+	}
+	var backlog []database.SkipItem
+	for _, item := range items {
+		if _, err := os.Stat(item.MediaDir); err == nil {
+			backlog = append(backlog, item)
+		}
+	}
+	fmt.Printf("当前积压 %d 个目录（按最近一次跳过的原因）:\n", len(backlog))
+	printSkipCounts(countSkipsByRule(backlog), len(backlog))
+
+	if *list && len(backlog) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "规则\t次数\t最近跳过\t目录\t原因")
+		for _, item := range backlog {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+				item.Rule, item.Count, item.SkippedAt.Format("2006-01-02 15:04"), item.MediaDir, item.Reason)
+		}
+		w.Flush()
+	}
+
+	// 累计次数
+	totals, err := database.GetSkipTotals()
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, count := range totals {
+		total += count.Count
+	}
+	fmt.Printf("\n累计跳过 %d 次:\n", total)
+	printSkipCounts(totals, total)
+	return nil
+}
+
+// countSkipsByRule 按规则统计跳过项目数，按数量从多到少排列
+func countSkipsByRule(items []database.SkipItem) []database.SkipCount {
+	byRule := make(map[string]int)
+	for _, item := range items {
+		byRule[item.Rule]++
+	}
+
+	counts := make([]database.SkipCount, 0, len(byRule))
+	for rule, count := range byRule {
+		counts = append(counts, database.SkipCount{Rule: rule, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Rule < counts[j].Rule
+	})
+	return counts
+}
+
+// printSkipCounts 输出各规则的数量和占比
+func printSkipCounts(counts []database.SkipCount, total int) {
+	if total == 0 {
+		fmt.Println("  无")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, count := range counts {
+		fmt.Fprintf(w, "  %s\t%d\t%.1f%%\n", count.Rule, count.Count, float64(count.Count)*100/float64(total))
+	}
+	w.Flush()
+}
+
+// runStatsTimings 输出最近几次运行各操作的次数、总耗时、平均耗时、最长耗时和超过阈值的次数
+func runStatsTimings(args []string) error {
+	fs := flag.NewFlagSet("stats timings", flag.ContinueOnError)
+	runs := fs.Int("runs", 5, "显示最近几次运行")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	timings, err := database.GetRunTimings(*runs)
+	if err != nil {
+		return err
+	}
+	if len(timings) == 0 {
+		fmt.Println("没有耗时统计记录")
+		return nil
+	}
+
+	// 同一次运行内按处理顺序排列操作
+	order := make(map[string]int)
+	for i, op := range metrics.Operations {
+		order[op] = i
+	}
+	sort.SliceStable(timings, func(i, j int) bool {
+		if timings[i].RunID != timings[j].RunID {
+			return timings[i].RunID > timings[j].RunID
+		}
+		return order[timings[i].Operation] < order[timings[j].Operation]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "运行\t操作\t次数\t总耗时\t平均\t最长\t超过阈值")
+	for _, timing := range timings {
+		average := time.Duration(0)
+		if timing.Count > 0 {
+			average = time.Duration(timing.TotalMS/int64(timing.Count)) * time.Millisecond
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%v\t%v\t%d\n",
+			timing.RunID, metrics.OperationName(timing.Operation), timing.Count,
+			time.Duration(timing.TotalMS)*time.Millisecond, average, time.Duration(timing.MaxMS)*time.Millisecond, timing.SlowCount)
+	}
+	return w.Flush()
+}
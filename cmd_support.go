@@ -0,0 +1,374 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/paths"
+	"github.com/user/media-manager/utils"
+)
+
+// redacted 支持包中替换敏感信息的文字
+const redacted = "***"
+
+// secretKeySuffixes 配置中值为密钥、密码或令牌的字段名后缀
+var secretKeySuffixes = []string{"api_key", "apikey", "password", "token", "secret"}
+
+// supportManifest 支持包中manifest.json的内容
+type supportManifest struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Version     string           `json:"version"`
+	GoVersion   string           `json:"go_version"`
+	Platform    string           `json:"platform"`
+	Schema      string           `json:"schema_version"`
+	SQLite      string           `json:"sqlite_version"`
+	TableRows   map[string]int64 `json:"table_rows"`
+	Item        string           `json:"item,omitempty"`
+	Files       []string         `json:"files"`
+	Problems    []string         `json:"problems,omitempty"` // 收集失败的内容
+}
+
+// runSupportBundleCommand 处理support-bundle子命令：把去除敏感信息的配置、最近的日志、运行汇总、数据库表结构和出问题项目的NFO打包为zip
+func runSupportBundleCommand(args []string) error {
+	fs := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	output := fs.String("output", "", "zip文件路径，默认为当前目录下的 media-manager-support-时间.zip")
+	days := fs.Int("days", 3, "包含最近几天的日志文件")
+	item := fs.String("item", "", "出问题的影片目录或NFO文件，默认为队列中最近处理失败的项目")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	cfg := appConfig()
+	now := time.Now()
+	if *output == "" {
+		*output = fmt.Sprintf("media-manager-support-%s.zip", now.Format("20060102-150405"))
+	}
+
+	// 模拟运行时照常收集内容，但不写入zip文件
+	var out io.Writer = io.Discard
+	if !database.IsDryRun() {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("创建支持包失败: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	bundle := &supportBundle{zip: zip.NewWriter(out), secrets: configSecrets(cfg)}
+	manifest := &supportManifest{
+		GeneratedAt: now,
+		Version:     appVersion,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	bundle.addConfig(cfg)
+	bundle.addLogs(*days)
+	bundle.addRuns()
+	if schema, err := database.GetSchema(); err != nil {
+		bundle.problem("读取数据库表结构: %v", err)
+	} else {
+		manifest.Schema, manifest.SQLite = schema.Version, schema.SQLiteVersion
+		bundle.addText("database/schema.sql", strings.Join(schema.Statements, ";\n\n")+";\n")
+	}
+	if stats, err := database.GetStats(); err != nil {
+		bundle.problem("读取数据库统计: %v", err)
+	} else {
+		manifest.TableRows = stats.TableRows
+	}
+
+	mediaDir := *item
+	if mediaDir == "" {
+		mediaDir = latestFailedItem()
+	}
+	if mediaDir != "" {
+		if info, err := os.Stat(mediaDir); err == nil && !info.IsDir() {
+			mediaDir = filepath.Dir(mediaDir)
+		}
+		manifest.Item = mediaDir
+		bundle.addItem(mediaDir)
+	}
+
+	manifest.Files, manifest.Problems = bundle.files, bundle.problems
+	bundle.addJSON("manifest.json", manifest)
+	if err := bundle.zip.Close(); err != nil {
+		return fmt.Errorf("写入支持包失败: %w", err)
+	}
+
+	if database.IsDryRun() {
+		fmt.Printf("[模拟] 将生成支持包: %s（%d 个文件），未写入文件\n", *output, len(bundle.files))
+	} else {
+		fmt.Printf("已生成支持包: %s（%d 个文件）\n", *output, len(bundle.files))
+	}
+	for _, problem := range bundle.problems {
+		fmt.Printf("  未能收集: %s\n", problem)
+	}
+	fmt.Println("配置中的密钥、密码和令牌已替换为 " + redacted + "，日志和NFO中出现的同样内容也已替换；影片目录的路径和标题仍然保留，附加到问题报告前请确认")
+	return nil
+}
+
+// supportBundle 正在写入的支持包
+type supportBundle struct {
+	zip      *zip.Writer
+	secrets  []string
+	files    []string
+	problems []string
+}
+
+// problem 记录未能收集的内容，支持包仍然生成
+func (b *supportBundle) problem(format string, args ...interface{}) {
+	b.problems = append(b.problems, fmt.Sprintf(format, args...))
+}
+
+// addText 写入一个文本文件，其中出现的配置密钥替换为***
+func (b *supportBundle) addText(name string, content string) {
+	for _, secret := range b.secrets {
+		content = strings.ReplaceAll(content, secret, redacted)
+	}
+	w, err := b.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		b.problem("%s: %v", name, err)
+		return
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		b.problem("%s: %v", name, err)
+		return
+	}
+	if name != "manifest.json" {
+		b.files = append(b.files, name)
+	}
+}
+
+// addJSON 以缩进的JSON写入一个文件
+func (b *supportBundle) addJSON(name string, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		b.problem("%s: %v", name, err)
+		return
+	}
+	b.addText(name, string(data)+"\n")
+}
+
+// addConfig 写入去除敏感信息的配置
+func (b *supportBundle) addConfig(cfg *config.Config) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		b.problem("config.json: %v", err)
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		b.problem("config.json: %v", err)
+		return
+	}
+	b.addJSON("config.json", sanitizeConfigValue("", value))
+}
+
+// addLogs 写入最近days天的日志文件
+func (b *supportBundle) addLogs(days int) {
+	dir, err := paths.Dir(paths.Logs)
+	if err != nil {
+		b.problem("日志目录: %v", err)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		b.problem("日志目录: %v", err)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".log" || strings.TrimSuffix(name, ".log") < since {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			b.problem("日志文件 %s: %v", name, err)
+			continue
+		}
+		b.addText("logs/"+name, string(content))
+	}
+}
+
+// addRuns 写入最近一次运行的汇总、最近几次运行的耗时统计、跳过原因和处理队列中失败的项目
+func (b *supportBundle) addRuns() {
+	runs := make(map[string]interface{})
+	if summary, err := database.GetLastRunSummary(); err != nil {
+		b.problem("运行汇总: %v", err)
+	} else {
+		runs["last_run"] = summary
+	}
+	if timings, err := database.GetRunTimings(10); err != nil {
+		b.problem("耗时统计: %v", err)
+	} else {
+		runs["timings"] = timings
+	}
+	if totals, err := database.GetSkipTotals(); err != nil {
+		b.problem("跳过统计: %v", err)
+	} else {
+		runs["skip_totals"] = totals
+	}
+	if failed, err := database.GetQueueItems(map[string]interface{}{"status": database.QueueStatusFailed}); err != nil {
+		b.problem("队列项目: %v", err)
+	} else {
+		runs["failed_queue_items"] = failed
+	}
+	b.addJSON("runs.json", runs)
+}
+
+// addItem 写入出问题的影片目录中的NFO文件、文件列表，以及该项目在队列、跳过记录和问题项目表中的状态
+func (b *supportBundle) addItem(mediaDir string) {
+	entries, err := os.ReadDir(mediaDir)
+	if err != nil {
+		b.problem("影片目录: %v", err)
+		return
+	}
+
+	var listing strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size := ""
+		if !entry.IsDir() {
+			size = utils.FormatBytes(info.Size())
+		}
+		fmt.Fprintf(&listing, "%s\t%s\t%s\n", info.Mode(), size, entry.Name())
+
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".nfo") {
+			content, err := os.ReadFile(filepath.Join(mediaDir, entry.Name()))
+			if err != nil {
+				b.problem("NFO文件 %s: %v", entry.Name(), err)
+				continue
+			}
+			b.addText("item/"+entry.Name(), string(content))
+		}
+	}
+	b.addText("item/files.txt", listing.String())
+
+	state := make(map[string]interface{})
+	if absDir, err := filepath.Abs(mediaDir); err == nil {
+		if problem, err := database.GetProblemItem(absDir); err == nil && problem != nil {
+			state["problem_item"] = problem
+		}
+	}
+	if items, err := database.GetQueueItems(map[string]interface{}{}); err == nil {
+		for _, queueItem := range items {
+			if filepath.Dir(queueItem.NFOPath) == filepath.Clean(mediaDir) {
+				state["queue_item"] = queueItem
+			}
+		}
+	}
+	if skips, err := database.GetSkipItems(""); err == nil {
+		for _, skip := range skips {
+			if filepath.Clean(skip.MediaDir) == filepath.Clean(mediaDir) {
+				state["skip"] = skip
+			}
+		}
+	}
+	b.addJSON("item/state.json", state)
+}
+
+// latestFailedItem 返回队列中最近处理失败的项目的NFO文件路径，没有时返回空字符串
+func latestFailedItem() string {
+	items, err := database.GetQueueItems(map[string]interface{}{"status": database.QueueStatusFailed})
+	if err != nil || len(items) == 0 {
+		return ""
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].UpdatedAt.After(items[j].UpdatedAt) })
+	return items[0].NFOPath
+}
+
+// sanitizeConfigValue 把配置中密钥、密码和令牌字段的非空值替换为***，URL中的密码替换为xxxxx
+func sanitizeConfigValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = sanitizeConfigValue(k, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = sanitizeConfigValue(key, item)
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		if isSecretKey(key) {
+			return redacted
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil && u.Host != "" {
+			return u.Redacted()
+		}
+		return v
+	}
+	return value
+}
+
+// isSecretKey 判断配置字段是否保存密钥、密码或令牌
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// configSecrets 返回配置中所有密钥、密码和令牌的值，用于替换日志和NFO中出现的相同内容
+func configSecrets(cfg *config.Config) []string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+
+	var secrets []string
+	var collect func(key string, value interface{})
+	collect = func(key string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, item := range v {
+				collect(k, item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				collect(key, item)
+			}
+		case string:
+			// 过短的值替换时会误伤正常内容
+			if isSecretKey(key) && len(v) >= 4 {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	collect("", value)
+	return secrets
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// subcommand 表示一个子命令
+type subcommand struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+// subcommands 所有可用的子命令
+var subcommands = []subcommand{
+	{Name: "cache", Description: "查看或清除缓存目录（TMDB详情、tinyMediaManager输出、NFO备份）", Run: runCacheCommand},
+	{Name: "calendar", Description: "生成媒体库中剧集的播出日历（.ics）", Run: runCalendarCommand},
+	{Name: "classify", Description: "手动指定目录的分类并移动，之后重新处理时不再改变", Run: runClassifyCommand},
+	{Name: "config", Description: "显示当前配置", Run: runConfigCommand},
+	{Name: "daemon", Description: "以守护进程模式定时处理，支持SIGUSR1和trigger子命令立即触发", Run: runDaemonCommand},
+	{Name: "db", Description: "查询数据库中的媒体记录", Run: runDBCommand},
+	{Name: "digest", Description: "预览或立即发送媒体库变化摘要邮件", Run: runDigestCommand},
+	{Name: "doctor", Description: "检查或修正媒体库中文件的所有者和权限", Run: runDoctorCommand},
+	{Name: "missing", Description: "手动确认或重新检查缺失的季和剧集", Run: runMissingCommand},
+	{Name: "nfo", Description: "根据数据库记录和TMDB的最新详情重新生成媒体库中项目的NFO文件", Run: runNFOCommand},
+	{Name: "plugins", Description: "列出插件目录中发现的插件", Run: runPluginsCommand},
+	{Name: "process", Description: "处理指定的NFO文件或影片目录，不运行tinyMediaManager", Run: runProcessCommand},
+	{Name: "queue", Description: "查看或清理处理队列", Run: runQueueCommand},
+	{Name: "refresh-metadata", Description: "重新查询TMDB，更新长时间没有更新的记录和NFO", Run: runRefreshMetadataCommand},
+	{Name: "replay", Description: "按当前配置离线重现一次运行的分类决策，与记录的结果比较", Run: runReplayCommand},
+	{Name: "report", Description: "列出缺失的季、剧集和系列电影", Run: runReportCommand},
+	{Name: "scan", Description: "列出Temp目录中的媒体目录、NFO状态、大小和预测的分类，不刮削、不处理", Run: runScanCommand},
+	{Name: "scrape", Description: "运行tinyMediaManager刮削Temp目录，再处理NFO文件并移动到媒体库（scrape [movies|tv|all]）", Run: runScrapeCommand},
+	{Name: "search", Description: "在Torznab索引器中搜索发布，按画质要求排序列出", Run: runSearchCommand},
+	{Name: "serve", Description: "启动HTTP服务，提供最近入库和缺失季的RSS/Atom订阅源", Run: runServeCommand},
+	{Name: "stats", Description: "输出媒体库概要数字，统计跳过移动的原因和各操作的耗时", Run: runStatsCommand},
+	{Name: "support-bundle", Description: "把去除敏感信息的配置、最近的日志、运行汇总和出问题项目的NFO打包为zip，用于问题报告", Run: runSupportBundleCommand},
+	{Name: "trigger", Description: "通知正在运行的守护进程立即执行一次处理", Run: runTriggerCommand},
+}
+
+// findSubcommand 根据名称查找子命令
+func findSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].Name == name {
+			return &subcommands[i]
+		}
+	}
+	return nil
+}
+
+// parseInterspersed 解析参数，允许参数出现在位置参数之后（如 "resolve 12 --force"）
+// 返回所有位置参数
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// printUsage 显示全局参数和子命令的帮助信息，不显示旧版的操作参数
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法: %s [全局参数] <子命令> [子命令参数]\n\n全局参数:\n", os.Args[0])
+	flag.VisitAll(func(f *flag.Flag) {
+		if !legacyFlags[f.Name] {
+			fmt.Fprintf(out, "  -%-16s %s\n", f.Name, f.Usage)
+		}
+	})
+	fmt.Fprintf(out, "\n子命令:\n")
+	for _, cmd := range subcommands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Fprintf(out, "\n子命令的参数使用 %s <子命令> -h 查看。旧版的 -scrape-all、-nfo、-dir 等参数仍然可用，等同于对应的子命令\n", os.Args[0])
+}
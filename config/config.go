@@ -6,8 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/user/media-manager/paths"
 	"github.com/user/media-manager/utils"
 )
 
@@ -15,13 +19,443 @@ import (
 // 当JSON中是字符串时，TempDirs是单元素数组
 // 当JSON中是数组时，TempDirs是多元素数组
 type Config struct {
-	CloudDir             string   `json:"cloud_dir"`
-	TinyMediaManagerDir  string   `json:"tiny_media_manager_dir"`
-	TempDirs             []string `json:"temp_dir"`
-	TMDBApiKey           string   `json:"tmdb_api_key"`             // TMDB API密钥
-	UseTMDBOrg           bool     `json:"use_tmdb_org"`             // 是否使用tmdb.org访问API
-	WaitTimeAfterScan    int      `json:"wait_time_after_scan"`     // 扫描后等待时间（秒）
-	WaitTimeAfterNFOEdit int      `json:"wait_time_after_nfo_edit"` // NFO文件编辑后等待时间（秒）
+	CloudDir              string                      `json:"cloud_dir"`
+	MovieCloudDir         string                      `json:"movie_cloud_dir"` // 电影（含音乐视频）的媒体库根目录，为空时使用cloud_dir
+	ShowCloudDir          string                      `json:"show_cloud_dir"`  // 电视剧的媒体库根目录，为空时使用cloud_dir
+	CategoryDirs          map[string]string           `json:"category_dirs"`   // 各分类的目标目录，优先于movie_cloud_dir、show_cloud_dir和cloud_dir
+	AdoptInPlace          bool                        `json:"adopt_in_place"`  // 处理已在媒体库中的项目：分类不正确时在媒体库内移动到正确的分类目录并记录更正
+	TinyMediaManagerDir   string                      `json:"tiny_media_manager_dir"`
+	TMMDataDir            string                      `json:"tmm_data_dir"`        // tinyMediaManager的数据目录（包含设置文件），为空时使用tiny_media_manager_dir下的data
+	TMMDatasources        string                      `json:"tmm_datasources"`     // 刮削前检查Temp目录是否为tinyMediaManager的数据源：check（缺少时刮削失败）、add（自动添加）、off（不检查）
+	TMMDocker             TMMDockerConfig             `json:"tmm_docker"`          // 通过docker run运行tinyMediaManager容器的配置，image为空时运行本地的tinyMediaManager
+	TMMTimeoutMinutes     int                         `json:"tmm_timeout_minutes"` // 单次运行tinyMediaManager的超时时间（分钟），超时后结束其整个进程组
+	TempDirs              []string                    `json:"temp_dir"`
+	TMDBApiKey            string                      `json:"tmdb_api_key"`             // TMDB API密钥
+	UseTMDBOrg            bool                        `json:"use_tmdb_org"`             // 是否使用tmdb.org访问API
+	TMDBLanguage          string                      `json:"tmdb_language"`            // TMDB返回数据的语言，如zh-CN、zh-TW、en-US
+	TMDBFallbackLanguages []string                    `json:"tmdb_fallback_languages"`  // 首选语言缺少标题或简介时依次尝试的语言
+	PlotFallback          PlotFallbackConfig          `json:"plot_fallback"`            // NFO和TMDB都没有简介时从百科获取
+	WaitTimeAfterScan     int                         `json:"wait_time_after_scan"`     // 扫描后等待时间（秒）
+	WaitTimeAfterNFOEdit  int                         `json:"wait_time_after_nfo_edit"` // NFO文件编辑后等待时间（秒）
+	AnimeMode             bool                        `json:"anime_mode"`               // 是否解析字幕组命名的动漫文件（绝对集数、合集）
+	SortSeasonPacks       bool                        `json:"sort_season_packs"`        // 电视剧移动时把根目录中按SxxEyy命名的剧集文件整理到对应的季目录
+	MusicCategory         string                      `json:"music_category"`           // 音乐视频和演唱会的分类目录名
+	UnsortedCategory      string                      `json:"unsorted_category"`        // 长期未刮削内容的分类目录名，为空时不移动
+	UnsortedAfterDays     int                         `json:"unsorted_after_days"`      // 项目未解决多少天后移动到未分类目录
+	HeuristicClassify     bool                        `json:"heuristic_classify"`       // 没有NFO文件的项目超过unsorted_after_days天后，按文件名推测分类并移动（低置信度）
+	ServeAddr             string                      `json:"serve_addr"`               // serve模式的HTTP监听地址
+	NFOSelection          string                      `json:"nfo_selection"`            // 多NFO目录的选择策略：videoname、standard、newest、largest，为空时跳过多NFO目录
+	Hooks                 HooksConfig                 `json:"hooks"`                    // 各处理阶段执行的钩子脚本
+	PluginsDir            string                      `json:"plugins_dir"`              // 插件目录，为空时使用配置文件所在目录下的plugins
+	DaemonInterval        int                         `json:"daemon_interval"`          // 守护进程定时处理的间隔（分钟）
+	DaemonSocket          string                      `json:"daemon_socket"`            // 守护进程接收触发请求的unix socket路径，为空时使用配置文件所在目录下的media-manager.sock
+	DBMaintenanceDays     int                         `json:"db_maintenance_days"`      // 守护进程每隔多少天检查数据库完整性并执行VACUUM和ANALYZE，-1表示不自动维护
+	CategoryPolicies      map[string][]CategoryPolicy `json:"category_policies"`        // 各分类移动完成后执行的处理策略
+	ProjectCheck          string                      `json:"project_check"`            // 目录中存在项目文件（README.md、.git等）时的处理：warn、skip、off
+	MergeConflicts        map[string]string           `json:"merge_conflicts"`          // 合并季时非视频文件同名冲突的处理策略，键为文件类型：image、audio、nfo、subtitle、other
+	YearTolerance         int                         `json:"year_tolerance"`           // NFO年份与TMDB上映年份相差超过多少年时校正NFO年份，-1表示不校正
+	RecordYearTolerance   int                         `json:"record_year_tolerance"`    // 按标题查找已有的媒体记录时允许的年份差，-1表示年份必须相同
+	GenreOrder            []string                    `json:"genre_order"`              // 写回NFO时类型的排序优先级，未列出的类型排在后面
+	MaxGenres             int                         `json:"max_genres"`               // 写回NFO时最多保留的类型数，0表示不限制
+	FFprobePath           string                      `json:"ffprobe_path"`             // 读取音轨语言的ffprobe路径，为空时在PATH中查找，找不到时只使用文件名中标注的音轨语言
+	PreferredAudio        string                      `json:"preferred_audio"`          // 偏好的音轨语言（如 国语、cmn），电影目标目录已存在且只有新版本包含该音轨时替换旧版本，为空时不替换
+	UpgradeQuality        bool                        `json:"upgrade_quality"`          // 电影目标目录已存在时，新版本画质（分辨率、HDR格式、片源）更高则替换旧版本
+	MinQuality            MinQualityConfig            `json:"min_quality"`              // 最低画质要求，低于要求的影片不移动到媒体库
+	MissingCountry        MissingCountryConfig        `json:"missing_country"`          // TMDB、NFO和元数据插件都没有国家信息时的处理
+	NamingTemplate        string                      `json:"naming_template"`          // 目标目录的命名模板，如 "{title} ({year}) [{resolution} {hdr}]"，为空时沿用源目录名
+	MaxPathBytes          int                         `json:"max_path_bytes"`           // 媒体库中路径的最大字节数（UTF-8），超过时按规则缩短目标目录名，仍然超过时不移动；0表示不限制
+	MinFreeSpaceGB        map[string]int              `json:"min_free_space_gb"`        // 各分类目标文件系统的最低剩余空间（GB），键为分类名，default用于没有单独配置的分类；低于该值时告警，移动后会低于该值时不移动；0表示不检查
+	MountCheck            MountCheckConfig            `json:"mount_check"`              // 移动到媒体库前确认NAS共享已挂载，避免没有挂载时写满本地磁盘
+	IncompleteMarkers     []string                    `json:"incomplete_markers"`       // 表示下载未完成的文件扩展名（以.开头）或文件名，目录中存在时跳过，下次运行时重新检查
+	IntakeRules           []IntakeRule                `json:"intake_rules"`             // 各Temp目录的入库规则：等待文件稳定、跳过未下载完成和正被打开的文件
+	TagRules              []TagRule                   `json:"tag_rules"`                // 按类型、分类、国家或语言为NFO添加<tag>标签的规则
+	RecentDays            int                         `json:"recent_days"`              // 在cloud_dir/_Recent中保留最近多少天入库项目的符号链接，0表示不创建
+	Email                 EmailConfig                 `json:"email"`                    // 定期发送媒体库变化摘要邮件的SMTP配置
+	MQTT                  MQTTConfig                  `json:"mqtt"`                     // 向MQTT代理发布处理事件和运行后的统计数字
+	Seerr                 SeerrConfig                 `json:"seerr"`                    // Overseerr/Jellyseerr的地址和API密钥，用于为缺失的季和系列电影创建请求
+	Indexer               IndexerConfig               `json:"indexer"`                  // Torznab索引器（Jackett、Prowlarr），用于search命令和为缺失季获取发布
+	Acquire               AcquireConfig               `json:"acquire"`                  // 为缺失的季在索引器中搜索发布并提交到aria2或qBittorrent
+	Bangumi               BangumiConfig               `json:"bangumi"`                  // 从Bangumi（bgm.tv）补充动漫的中文标题、简介、标签和剧集标题
+	LogOutput             string                      `json:"log_output"`               // 日志输出：file（按日期命名的日志文件）、stdout（只输出到标准输出）、syslog（syslog/journald）
+	LogLevel              string                      `json:"log_level"`                // 写入日志文件的级别：debug、info、warning、error，与控制台输出的级别（-quiet、-verbose）无关
+	Timezone              string                      `json:"timezone"`                 // 日志、日志文件名和命令输出使用的时区（IANA名称，如 Asia/Shanghai），为空时使用系统时区（TZ环境变量）；数据库中始终保存UTC时间
+	NFOBackups            int                         `json:"nfo_backups"`              // 修改NFO文件前保留的历史版本数（.bak），0表示不保留
+	ReplayRuns            int                         `json:"replay_runs"`              // 保留最近多少次运行的分类决策记录（NFO内容、TMDB和插件的响应、检测到的画质），用于replay离线重现分类，-1表示不记录
+	Cache                 CacheConfig                 `json:"cache"`                    // 缓存目录（TMDB详情、tinyMediaManager输出、NFO备份）的位置和大小上限
+	SlowThresholds        map[string]int              `json:"slow_thresholds"`          // 各操作（parse、tmdb、nfo_write、move）的慢操作阈值（毫秒），超过时记录警告，0表示不警告
+	Permissions           PermissionsConfig           `json:"permissions"`              // 移动到媒体库的文件和目录的所有者和权限，doctor --fix-permissions按此修正已有的文件
+	Symlinks              map[string]string           `json:"symlinks"`                 // 各操作（scan、move、copy）对符号链接的处理方式：skip（忽略）、follow（跟随）、preserve（保留链接本身）
+	Concurrency           map[string]int              `json:"concurrency"`              // 并发处理的任务数：metadata（同时处理的项目数，读取NFO和请求TMDB）、move（同时移动的影片数）
+	Retry                 RetryConfig                 `json:"retry"`                    // 单个项目处理失败（TMDB请求失败、NAS短暂不可用等）时的重试次数和间隔
+}
+
+// CategoryPolicy 分类的移动后处理策略
+type CategoryPolicy struct {
+	Action      string `json:"action"`                  // 策略类型：strm、copy、jellyfin_refresh
+	Target      string `json:"target,omitempty"`        // strm、copy的目标目录（影片目录会创建在其下）
+	URLPrefix   string `json:"url_prefix,omitempty"`    // strm文件内容的URL前缀，为空时写入视频文件的本地路径
+	RateLimitKB int    `json:"rate_limit_kb,omitempty"` // copy每秒最多复制的KB数，0表示不限制
+	URL         string `json:"url,omitempty"`           // jellyfin_refresh的服务器地址
+	APIKey      string `json:"api_key,omitempty"`       // jellyfin_refresh的API密钥
+	LibraryID   string `json:"library_id,omitempty"`    // jellyfin_refresh要刷新的媒体库ID，为空时刷新所有媒体库
+}
+
+// TagRule 标签规则，所有配置的条件都满足时为影片的NFO添加标签
+// 例如 {"genre": "恐怖", "tag": "adults-only"}、{"category": "DmShow", "tag": "anime"}
+type TagRule struct {
+	Genre    string `json:"genre,omitempty"`    // 类型（翻译后的中文类型，如 恐怖）
+	Category string `json:"category,omitempty"` // 分类目录名，如 DmShow
+	Country  string `json:"country,omitempty"`  // 制作国家（中文名称，如 日本）
+	Language string `json:"language,omitempty"` // 原始语言（ISO 639-1代码，如 ja）
+	Tag      string `json:"tag"`                // 添加的标签
+}
+
+// MinQualityConfig 最低画质要求
+// min_height和reject_sources都为空时不检查
+type MinQualityConfig struct {
+	MinHeight          int      `json:"min_height"`          // 最低分辨率高度，如 720，0表示不限制；无法识别分辨率的影片不受限制
+	RejectSources      []string `json:"reject_sources"`      // 拒绝的片源，如 CAM、TS、TC、SCR
+	Action             string   `json:"action"`              // 低于要求时的处理：skip（留在Temp目录）、quarantine（移动到隔离目录）
+	QuarantineCategory string   `json:"quarantine_category"` // quarantine时的目标目录名（位于cloud_dir下）
+	ExemptCategories   []string `json:"exempt_categories"`   // 不检查画质的分类，如 XSShow、JlShow
+}
+
+// RetryConfig 单个项目处理失败时的重试
+type RetryConfig struct {
+	MaxAttempts  int `json:"max_attempts"`  // 每次运行中单个项目最多处理的次数（包括第一次），1表示不重试
+	DelaySeconds int `json:"delay_seconds"` // 两次处理之间等待的秒数
+}
+
+// Attempts 返回每次运行中单个项目最多处理的次数，至少为1
+func (r RetryConfig) Attempts() int {
+	return max(r.MaxAttempts, 1)
+}
+
+// Delay 返回两次处理之间的等待时间
+func (r RetryConfig) Delay() time.Duration {
+	return time.Duration(max(r.DelaySeconds, 0)) * time.Second
+}
+
+// MountCheckConfig 确认媒体库目录已挂载的方式，两者都配置时都要满足
+type MountCheckConfig struct {
+	Marker       string `json:"marker"`        // 标记文件名，媒体库目录或其上级目录中存在该文件才视为已挂载，如 .media-manager-mount
+	RequireMount bool   `json:"require_mount"` // 媒体库目录必须与根目录（/）位于不同的文件系统
+}
+
+// Enabled 判断是否配置了挂载检查
+func (m MountCheckConfig) Enabled() bool {
+	return m.Marker != "" || m.RequireMount
+}
+
+// MissingCountryConfig TMDB、NFO和元数据插件都没有国家信息时的处理
+type MissingCountryConfig struct {
+	Action             string `json:"action"`              // skip（留在Temp目录）、language（按原始语言推断国家，没有原始语言或无法推断时留在Temp目录）、category（移动到指定分类）、quarantine（移动到隔离目录）
+	MovieCategory      string `json:"movie_category"`      // category时电影的分类
+	ShowCategory       string `json:"show_category"`       // category时电视剧的分类
+	QuarantineCategory string `json:"quarantine_category"` // quarantine时的目标目录名（位于cloud_dir下）
+}
+
+// TMMDockerConfig 通过docker run运行tinyMediaManager容器的配置
+// 容器中的路径与主机不同，volumes需要挂载Temp目录（与主机相同的路径）和TMM的数据目录
+type TMMDockerConfig struct {
+	Image     string   `json:"image"`      // tinyMediaManager镜像，如tinymediamanager/tinymediamanager
+	Tag       string   `json:"tag"`        // 镜像标签
+	Volumes   []string `json:"volumes"`    // 挂载的卷（主机路径:容器路径[:ro]）
+	Command   string   `json:"command"`    // 容器中tinyMediaManager命令行程序的路径
+	Docker    string   `json:"docker"`     // docker可执行文件
+	ExtraArgs []string `json:"extra_args"` // 传给docker run的其他参数，如--user、--network
+}
+
+// Enabled 判断是否通过docker运行tinyMediaManager
+func (d TMMDockerConfig) Enabled() bool {
+	return d.Image != ""
+}
+
+// EmailConfig 摘要邮件配置
+// smtp_host和to都不为空时，守护进程每隔digest_interval_days天发送一次摘要
+type EmailConfig struct {
+	SMTPHost           string   `json:"smtp_host"`            // SMTP服务器地址
+	SMTPPort           int      `json:"smtp_port"`            // SMTP端口，465使用TLS直连，其他端口支持时使用STARTTLS
+	Username           string   `json:"username"`             // SMTP用户名，为空时不认证
+	Password           string   `json:"password"`             // SMTP密码或授权码
+	From               string   `json:"from"`                 // 发件人地址，为空时使用username
+	To                 []string `json:"to"`                   // 收件人地址
+	DigestIntervalDays int      `json:"digest_interval_days"` // 摘要的发送间隔（天）
+}
+
+// Enabled 判断是否配置了摘要邮件
+func (e EmailConfig) Enabled() bool {
+	return e.SMTPHost != "" && len(e.To) > 0
+}
+
+// MQTTConfig 发布处理事件和统计数字的MQTT代理
+type MQTTConfig struct {
+	Broker      string `json:"broker"`       // 代理地址，如 tcp://192.168.1.10:1883，ssl://时使用TLS，为空时不发布
+	Username    string `json:"username"`     // 用户名，为空时不认证
+	Password    string `json:"password"`     // 密码
+	ClientID    string `json:"client_id"`    // 客户端ID，为空时使用 media-manager-进程号
+	TopicPrefix string `json:"topic_prefix"` // 主题前缀，事件发布到 前缀/event/阶段，统计数字发布到 前缀/stats
+	QoS         int    `json:"qos"`          // 服务质量等级：0、1、2
+}
+
+// Enabled 判断是否配置了MQTT代理
+func (m MQTTConfig) Enabled() bool {
+	return strings.TrimSpace(m.Broker) != ""
+}
+
+// QoSLevel 返回有效的服务质量等级，超出0～2时按0处理
+func (m MQTTConfig) QoSLevel() byte {
+	if m.QoS < 0 || m.QoS > 2 {
+		return 0
+	}
+	return byte(m.QoS)
+}
+
+// Topic 返回主题前缀下的主题，如 Topic("event", "post_move") 为 media-manager/event/post_move
+func (m MQTTConfig) Topic(parts ...string) string {
+	prefix := strings.Trim(m.TopicPrefix, "/")
+	if prefix == "" {
+		prefix = DefaultMQTTTopicPrefix
+	}
+	return strings.Join(append([]string{prefix}, parts...), "/")
+}
+
+// SeerrConfig Overseerr或Jellyseerr的配置，两者的请求接口相同
+type SeerrConfig struct {
+	URL         string `json:"url"`          // 服务地址，如 http://localhost:5055
+	APIKey      string `json:"api_key"`      // 设置中的API密钥
+	AutoRequest bool   `json:"auto_request"` // 守护进程每次处理后自动为新的缺失内容创建请求
+}
+
+// Enabled 判断是否配置了Overseerr/Jellyseerr
+func (s SeerrConfig) Enabled() bool {
+	return s.URL != "" && s.APIKey != ""
+}
+
+// AcquireConfig 为缺失的季搜索发布并提交到下载器的配置
+type AcquireConfig struct {
+	SearchURL   string `json:"search_url"`   // 没有配置indexer时使用的索引器搜索RSS的地址模板，可用 {title}、{original_title}、{season}、{season2}（两位季号）、{tmdb_id}，如 https://indexer/rss?q={original_title}+S{season2}
+	Downloader  string `json:"downloader"`   // 下载器：aria2、qbittorrent
+	RPCURL      string `json:"rpc_url"`      // aria2的JSON-RPC地址（如 http://localhost:6800/jsonrpc）或qBittorrent WebUI地址（如 http://localhost:8080）
+	Username    string `json:"username"`     // qBittorrent WebUI用户名
+	Password    string `json:"password"`     // qBittorrent WebUI密码或aria2的rpc-secret
+	SavePath    string `json:"save_path"`    // 下载目录，为空时使用下载器的默认目录
+	Category    string `json:"category"`     // qBittorrent中的分类
+	MaxHeight   int    `json:"max_height"`   // 最高分辨率高度，如 1080，0表示不限制；最低要求使用min_quality
+	AutoAcquire bool   `json:"auto_acquire"` // 守护进程每次处理后自动为新的缺失季搜索并提交下载
+}
+
+// Enabled 判断是否配置了下载器
+func (a AcquireConfig) Enabled() bool {
+	return a.Downloader != "" && a.RPCURL != ""
+}
+
+// PlotFallbackConfig 从百科补充简介的配置
+type PlotFallbackConfig struct {
+	Source string `json:"source"` // wikipedia（中文维基百科）、baidu（百度百科），为空时不补充
+	URL    string `json:"url"`    // 接口地址模板，{title} 替换为标题，为空时使用来源的默认地址，可指向镜像
+}
+
+// BangumiConfig Bangumi（bgm.tv）元数据的配置，TMDB中动漫的中文信息常常不完整
+type BangumiConfig struct {
+	Enabled     bool     `json:"enabled"`      // 开启后动漫分类（DmShow、DmMovie）自动使用Bangumi补充元数据
+	Categories  []string `json:"categories"`   // 同样使用Bangumi的其他分类
+	AccessToken string   `json:"access_token"` // 个人令牌，可选，用于获取需要登录才能看到的条目
+	MaxTags     int      `json:"max_tags"`     // 添加到NFO的标签数（按标记人数取前几个），0表示默认值，-1表示不添加
+}
+
+// IndexerConfig Torznab兼容的索引器配置
+type IndexerConfig struct {
+	URL        string `json:"url"`        // Torznab接口地址，如Jackett的 http://localhost:9117/api/v2.0/indexers/all/results/torznab、Prowlarr的 http://localhost:9696/1/api
+	APIKey     string `json:"api_key"`    // API密钥
+	Categories []int  `json:"categories"` // 搜索的Newznab分类，如 5000（电视剧）、2000（电影），为空时不限
+}
+
+// Enabled 判断是否配置了Torznab索引器
+func (i IndexerConfig) Enabled() bool {
+	return i.URL != ""
+}
+
+// CategoryDir 返回分类的目标目录：category_dirs中单独配置的目录优先，
+// 其次是电视剧或电影的媒体库根目录下的分类目录，都没有配置时使用cloud_dir下的分类目录
+func (c *Config) CategoryDir(category string, isTVShow bool) string {
+	if dir := c.CategoryDirs[category]; dir != "" {
+		return dir
+	}
+	root := c.MovieCloudDir
+	if isTVShow {
+		root = c.ShowCloudDir
+	}
+	if root == "" {
+		root = c.CloudDir
+	}
+	return filepath.Join(root, category)
+}
+
+// CloudRoots 返回所有媒体库根目录（cloud_dir、movie_cloud_dir、show_cloud_dir），去除重复和未配置的目录
+func (c *Config) CloudRoots() []string {
+	var roots []string
+	seen := make(map[string]bool)
+	for _, root := range []string{c.CloudDir, c.MovieCloudDir, c.ShowCloudDir} {
+		if root == "" || seen[filepath.Clean(root)] {
+			continue
+		}
+		seen[filepath.Clean(root)] = true
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// CloudDirs 返回所有媒体库根目录和单独配置的分类目录
+func (c *Config) CloudDirs() []string {
+	dirs := c.CloudRoots()
+	for _, dir := range c.CategoryDirs {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// PermissionsConfig 媒体库中文件和目录的所有者和权限，各项为空（0）时不修改
+// 与容器中常用的PUID/PGID约定一致，媒体服务器以该用户读取媒体库
+type PermissionsConfig struct {
+	PUID     int    `json:"puid"`      // 所有者的用户ID
+	PGID     int    `json:"pgid"`      // 所有者的组ID
+	FileMode string `json:"file_mode"` // 文件权限（八进制），如 0644
+	DirMode  string `json:"dir_mode"`  // 目录权限（八进制），如 0755
+}
+
+// Enabled 判断是否配置了任何所有者或权限
+func (p PermissionsConfig) Enabled() bool {
+	return p.PUID > 0 || p.PGID > 0 || p.FileMode != "" || p.DirMode != ""
+}
+
+// Modes 解析文件和目录权限，未配置的返回0
+func (p PermissionsConfig) Modes() (fileMode os.FileMode, dirMode os.FileMode, err error) {
+	parse := func(name, value string) (os.FileMode, error) {
+		if value == "" {
+			return 0, nil
+		}
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return 0, fmt.Errorf("无效的%s: %s", name, value)
+		}
+		return os.FileMode(mode), nil
+	}
+	if fileMode, err = parse("file_mode", p.FileMode); err != nil {
+		return 0, 0, err
+	}
+	if dirMode, err = parse("dir_mode", p.DirMode); err != nil {
+		return 0, 0, err
+	}
+	return fileMode, dirMode, nil
+}
+
+// IntakeRule Temp目录的入库规则，用于多人共用的Temp目录，避免处理还在复制或下载中的项目
+type IntakeRule struct {
+	Path              string   `json:"path,omitempty"`     // 适用的Temp目录（或其子目录），为空时适用于没有单独配置的所有目录
+	SettleMinutes     int      `json:"settle_minutes"`     // 项目中的文件在最近多少分钟内修改过时暂不处理，0表示不检查
+	PartialExtensions []string `json:"partial_extensions"` // 未下载完成的文件扩展名（如 .part、.!qB），项目中存在这些文件时暂不处理
+	CheckOpenFiles    bool     `json:"check_open_files"`   // 项目中的文件正被其他进程打开时暂不处理（仅Linux）
+}
+
+// IntakeRuleFor 返回适用于指定目录的入库规则：路径最长的匹配规则优先，没有匹配时使用路径为空的规则
+func (c *Config) IntakeRuleFor(dir string) IntakeRule {
+	var fallback, best IntakeRule
+	bestLen := -1
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		absDir = dir
+	}
+	for _, rule := range c.IntakeRules {
+		if rule.Path == "" {
+			fallback = rule
+			continue
+		}
+		absPath, err := filepath.Abs(rule.Path)
+		if err != nil {
+			continue
+		}
+		if absDir == absPath || strings.HasPrefix(absDir, absPath+string(filepath.Separator)) {
+			if len(absPath) > bestLen {
+				best, bestLen = rule, len(absPath)
+			}
+		}
+	}
+	if bestLen >= 0 {
+		return best
+	}
+	return fallback
+}
+
+// SymlinkMode 返回操作对符号链接的处理方式，没有配置或配置的值无效时使用默认值
+func (c *Config) SymlinkMode(op string) utils.SymlinkMode {
+	switch mode := utils.SymlinkMode(c.Symlinks[op]); mode {
+	case utils.SymlinkSkip, utils.SymlinkFollow, utils.SymlinkPreserve:
+		return mode
+	}
+	return utils.SymlinkMode(DefaultSymlinks()[op])
+}
+
+// CurrentSymlinkMode 返回当前配置中op操作的符号链接处理方式，用于不返回错误的遍历函数
+// 程序启动时已用Load检查过配置文件，读取失败时使用默认值
+func CurrentSymlinkMode(op string) utils.SymlinkMode {
+	cfg, err := Load()
+	if err != nil {
+		return utils.SymlinkMode(DefaultSymlinks()[op])
+	}
+	return cfg.SymlinkMode(op)
+}
+
+// Workers 返回配置的并发任务数，未配置或小于1时使用默认值
+func (c *Config) Workers(kind string) int {
+	if n := c.Concurrency[kind]; n > 0 {
+		return n
+	}
+	return DefaultConcurrency()[kind]
+}
+
+// Location 返回timezone配置的时区，为空时返回nil（使用系统时区）
+func (c *Config) Location() (*time.Location, error) {
+	name := strings.TrimSpace(c.Timezone)
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 '%s': %w", name, err)
+	}
+	return loc, nil
+}
+
+// MinFreeSpace 返回分类目标文件系统的最低剩余空间（字节），没有单独配置时使用default，0表示不检查
+func (c *Config) MinFreeSpace(category string) uint64 {
+	gb, ok := c.MinFreeSpaceGB[category]
+	if !ok {
+		gb = c.MinFreeSpaceGB[MinFreeSpaceDefault]
+	}
+	if gb <= 0 {
+		return 0
+	}
+	return uint64(gb) << 30
+}
+
+// CacheConfig 缓存目录配置
+type CacheConfig struct {
+	Dir      string `json:"dir"`       // 缓存目录，为空时按当前目录、程序目录下的cache目录和用户主目录下的.media-manager/cache的顺序查找
+	MaxMB    int    `json:"max_mb"`    // 缓存目录的大小上限（MB），超过时从最早的文件开始删除；0使用默认值，-1表示不限制
+	TMDBDays int    `json:"tmdb_days"` // TMDB详情在缓存中保留的天数，0使用默认值，-1表示不缓存到磁盘
+}
+
+// HooksConfig 钩子脚本配置
+// 每个阶段可配置多条命令，命令通过标准输入接收JSON格式的项目信息
+type HooksConfig struct {
+	PreMove        []string `json:"pre_move"`        // 移动影片前执行的命令
+	PostMove       []string `json:"post_move"`       // 移动影片并写入数据库后执行的命令
+	PostRun        []string `json:"post_run"`        // 一次运行结束后执行的命令
+	LowSpace       []string `json:"low_space"`       // 媒体库文件系统的剩余空间低于min_free_space_gb时执行的命令
+	ReadOnly       []string `json:"read_only"`       // 媒体库目录变为只读或不可写入、暂停处理时执行的命令
+	TimeoutSeconds int      `json:"timeout_seconds"` // 单条命令的超时时间（秒）
+	FailurePolicy  string   `json:"failure_policy"`  // 命令失败时的处理策略：continue（记录后继续）、abort（中止当前项目）
 }
 
 const (
@@ -30,110 +464,369 @@ const (
 	DefaultCloud  = "~/Cloud"
 	DefaultTemp   = "~/Temp"
 	DefaultTMMDir = "/usr/local/bin" // 默认路径，需要根据实际情况调整
+
+	DefaultMusicCategory       = "MusicVideo" // 默认的音乐视频分类目录名
+	DefaultUnsortedAfterDays   = 30           // 默认未解决30天后移动到未分类目录
+	DefaultServeAddr           = ":8090"      // 默认HTTP监听地址
+	DefaultPluginsDir          = "plugins"    // 默认插件目录名（相对配置文件所在目录）
+	DefaultDaemonInterval      = 60           // 默认守护进程每60分钟处理一次
+	DefaultTMMDockerTag        = "latest"
+	DefaultTMMDockerCommand    = "/app/tinyMediaManager" // 官方镜像中命令行程序的路径
+	DefaultDockerExecutable    = "docker"
+	DefaultTMMTimeoutMinutes   = 120 // 默认tinyMediaManager运行2小时仍未结束视为卡住
+	DefaultDaemonSocket        = "media-manager.sock"
+	DefaultTMDBLanguage        = "zh-CN" // 默认获取简体中文数据
+	DefaultLogLevel            = "info"
+	DefaultLogOutput           = "file"
+	DefaultMQTTTopicPrefix     = "media-manager"
+	DefaultYearTolerance       = 1   // 默认允许NFO年份与TMDB上映年份相差1年（制作年份与上映年份常差一年）
+	DefaultRecordYearTolerance = 1   // 默认查找已有的媒体记录时允许年份相差1年
+	DefaultDBMaintenanceDays   = 7   // 默认守护进程每周维护一次数据库
+	DefaultBangumiMaxTags      = 10  // 默认添加Bangumi中标记人数最多的10个标签
+	DefaultCacheMaxMB          = 500 // 默认缓存目录最多占用500MB
+	DefaultCacheTMDBDays       = 7   // 默认TMDB详情缓存7天
+	DefaultReplayRuns          = 10  // 默认保留最近10次运行的分类决策记录
+	DefaultRetryAttempts       = 3   // 默认每次运行中单个项目最多处理3次
+	DefaultRetryDelaySeconds   = 30  // 默认失败30秒后重试
+
+	HookFailureContinue       = "continue" // 钩子失败时记录日志后继续
+	HookFailureAbort          = "abort"    // 钩子失败时中止当前项目
+	DefaultHookTimeoutSeconds = 30         // 默认钩子超时时间30秒
+
+	MinFreeSpaceDefault = "default" // min_free_space_gb中用于没有单独配置的分类的键
+
+	PolicyActionStrm            = "strm"             // 为视频文件生成.strm文件，供第二个媒体库使用
+	PolicyActionCopy            = "copy"             // 复制影片目录，可限制复制速度
+	PolicyActionJellyfinRefresh = "jellyfin_refresh" // 刷新Jellyfin媒体库
+
+	ProjectCheckWarn = "warn" // 只记录警告，继续移动
+	ProjectCheckSkip = "skip" // 跳过移动
+	ProjectCheckOff  = "off"  // 不检查
+
+	MinQualitySkip            = "skip"       // 低于最低画质的影片留在Temp目录
+	MinQualityQuarantine      = "quarantine" // 低于最低画质的影片移动到隔离目录
+	DefaultQuarantineCategory = "Quarantine" // 默认的隔离目录名
+
+	MissingCountrySkip                 = "skip"       // 没有国家信息的影片留在Temp目录
+	MissingCountryLanguage             = "language"   // 按原始语言推断国家
+	MissingCountryCategory             = "category"   // 移动到指定的分类
+	MissingCountryQuarantine           = "quarantine" // 移动到隔离目录
+	DefaultMissingCountryMovieCategory = "EnMovie"    // 默认没有国家信息的电影按其他国家电影处理
+	DefaultMissingCountryShowCategory  = "EnShow"     // 默认没有国家信息的电视剧按其他国家电视剧处理
+
+	DefaultSMTPPort           = 587 // 默认SMTP端口（STARTTLS）
+	DefaultDigestIntervalDays = 7   // 默认每周发送一次摘要
+
+	ConflictKeepExisting = "keep-existing" // 保留目标目录中已有的文件
+	ConflictKeepNewest   = "keep-newest"   // 保留修改时间较新的文件
+	ConflictKeepLargest  = "keep-largest"  // 保留较大的文件
+
+	TMMDatasourcesCheck = "check" // Temp目录不是数据源时刮削失败
+	TMMDatasourcesAdd   = "add"   // 自动把Temp目录添加为数据源
+	TMMDatasourcesOff   = "off"   // 不检查
+
+	SymlinkOpScan = "scan" // 扫描Temp目录和影片目录（查找NFO、视频文件，统计大小）
+	SymlinkOpMove = "move" // 跨设备移动时复制影片目录
+	SymlinkOpCopy = "copy" // 分类处理策略的copy和strm
+
+	ConcurrencyMetadata = "metadata" // 同时处理的项目数（读取NFO、请求TMDB）
+	ConcurrencyMove     = "move"     // 同时移动或合并的影片数
+
+	PlotSourceWikipedia = "wikipedia" // 中文维基百科
+	PlotSourceBaidu     = "baidu"     // 百度百科
+
+	DownloaderAria2       = "aria2"       // 通过JSON-RPC的aria2.addUri提交
+	DownloaderQBittorrent = "qbittorrent" // 通过WebUI API提交
 )
 
-func GetConfigPath() string {
-	// 1. 首先检查用户当前目录下是否存在config目录（只检查不创建）
-	currentDir, err := os.Getwd()
-	if err == nil {
-		configDir := filepath.Join(currentDir, "config")
-		if _, err := os.Stat(configDir); err == nil {
-			return filepath.Join(configDir, ConfigFile)
-		}
+// GetConfigPath 获取配置文件路径，配置目录的查找顺序见paths.Dir
+func GetConfigPath() (string, error) {
+	configPath, err := paths.File(paths.Config, ConfigFile)
+	if err != nil {
+		return "", fmt.Errorf("无法创建配置目录: %w", err)
 	}
+	return configPath, nil
+}
 
-	// 2. 检查程序执行文件所在目录下是否存在config目录（只检查不创建）
-	exeDir, err := utils.GetExecutableDir()
-	if err == nil {
-		configDir := filepath.Join(exeDir, "config")
-		if _, err := os.Stat(configDir); err == nil {
-			return filepath.Join(configDir, ConfigFile)
-		}
+// configWithFlexibleTemp 用于处理灵活的temp_dir字段（字符串或数组）
+// 其余字段直接解析到内嵌的Config中
+type configWithFlexibleTemp struct {
+	Config
+	TempDir json.RawMessage `json:"temp_dir"`
+}
+
+// 缓存的配置，所有调用方共享同一个实例，不应修改
+var (
+	cacheMu sync.RWMutex
+	cached  *Config
+)
+
+// Use 指定Load返回的配置，不读取配置文件，用于测试和嵌入；传入nil时清空缓存，下次调用时重新读取配置文件
+func Use(cfg *Config) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cached = cfg
+}
+
+// Current 返回Use指定或已经读取并缓存的配置，没有时返回nil，不读取配置文件
+func Current() *Config {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return cached
+}
+
+// Default 返回默认配置，不读写配置文件
+func Default() *Config {
+	return createDefaultConfig()
+}
+
+// Load 返回缓存的配置，还没有读取过时读取配置文件并缓存
+func Load() (*Config, error) {
+	cacheMu.RLock()
+	config := cached
+	cacheMu.RUnlock()
+	if config != nil {
+		return config, nil
 	}
 
-	// 3. 如果都不存在，使用用户主目录下的.media-manager目录（不存在则创建）
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("无法获取用户主目录: %v\n", err)
-		os.Exit(1)
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cached != nil {
+		return cached, nil
 	}
-	configDir := filepath.Join(homeDir, ConfigDir)
-	// 确保用户主目录下的配置目录存在
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		fmt.Printf("无法创建配置目录: %v\n", err)
-		os.Exit(1)
+	config, err := readConfig()
+	if err != nil {
+		return nil, err
 	}
-	return filepath.Join(configDir, ConfigFile)
+	cached = config
+	return config, nil
 }
 
-// configWithFlexibleTemp 用于处理灵活的temp_dir字段（字符串或数组）
-type configWithFlexibleTemp struct {
-	CloudDir             string          `json:"cloud_dir"`
-	TinyMediaManagerDir  string          `json:"tiny_media_manager_dir"`
-	TempDir              json.RawMessage `json:"temp_dir"`
-	TMDBApiKey           string          `json:"tmdb_api_key"`
-	UseTMDBOrg           bool            `json:"use_tmdb_org"`
-	WaitTimeAfterScan    int             `json:"wait_time_after_scan"`
-	WaitTimeAfterNFOEdit int             `json:"wait_time_after_nfo_edit"`
+// Reload 重新读取配置文件，用于守护进程热加载配置
+// 读取失败时返回错误，继续使用原来的配置；之前已经取得配置的调用方不受影响
+func Reload() (*Config, error) {
+	config, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cached = config
+	return config, nil
 }
 
-func LoadConfig() *Config {
-	configPath := GetConfigPath()
+// readConfig 读取并解析配置文件，配置文件不存在时创建默认配置文件
+func readConfig() (*Config, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
 
 	// 检查配置文件是否存在
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// 创建默认配置
 		config := createDefaultConfig()
 		// 保存默认配置
-		SaveConfig(config)
+		if err := SaveConfig(config); err != nil {
+			return nil, err
+		}
 		fmt.Printf("已创建默认配置文件: %s\n", configPath)
-		return config
+		return config, nil
 	}
 
 	// 读取配置文件
 	file, err := os.Open(configPath)
 	if err != nil {
-		fmt.Printf("无法打开配置文件: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("无法打开配置文件: %w", err)
 	}
 	defer file.Close()
 
 	// 解析JSON到临时结构体
 	var tempConfig configWithFlexibleTemp
 	if err := json.NewDecoder(file).Decode(&tempConfig); err != nil {
-		fmt.Printf("无法解析配置文件: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("无法解析配置文件: %w", err)
 	}
 
 	// 处理灵活的TempDir字段
-	var config Config
-	config.CloudDir = tempConfig.CloudDir
-	config.TinyMediaManagerDir = tempConfig.TinyMediaManagerDir
-	config.TMDBApiKey = tempConfig.TMDBApiKey
-	config.UseTMDBOrg = tempConfig.UseTMDBOrg
-	config.WaitTimeAfterScan = tempConfig.WaitTimeAfterScan
-	config.WaitTimeAfterNFOEdit = tempConfig.WaitTimeAfterNFOEdit
+	config := tempConfig.Config
 
 	// 解析TempDir字段（可能是字符串或数组）
 	if tempConfig.TempDir[0] == '[' {
 		// 是数组
 		if err := json.Unmarshal(tempConfig.TempDir, &config.TempDirs); err != nil {
-			fmt.Printf("无法解析temp_dir数组: %v\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("无法解析temp_dir数组: %w", err)
 		}
 	} else {
 		// 是字符串
 		var tempDir string
 		if err := json.Unmarshal(tempConfig.TempDir, &tempDir); err != nil {
-			fmt.Printf("无法解析temp_dir字符串: %v\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("无法解析temp_dir字符串: %w", err)
 		}
 		config.TempDirs = []string{tempDir}
 	}
 
 	// 替换路径中的 ~ 为用户主目录
 	config.CloudDir = expandHomePath(config.CloudDir)
+	config.MovieCloudDir = expandHomePath(config.MovieCloudDir)
+	config.ShowCloudDir = expandHomePath(config.ShowCloudDir)
+	for category, dir := range config.CategoryDirs {
+		config.CategoryDirs[category] = expandHomePath(dir)
+	}
 	config.TinyMediaManagerDir = expandHomePath(config.TinyMediaManagerDir)
+	if config.PluginsDir == "" {
+		config.PluginsDir = filepath.Join(filepath.Dir(configPath), DefaultPluginsDir)
+	}
+	config.PluginsDir = expandHomePath(config.PluginsDir)
+	if config.DaemonSocket == "" {
+		config.DaemonSocket = filepath.Join(filepath.Dir(configPath), DefaultDaemonSocket)
+	}
+	config.DaemonSocket = expandHomePath(config.DaemonSocket)
+	for category, policies := range config.CategoryPolicies {
+		for i := range policies {
+			policies[i].Target = expandHomePath(policies[i].Target)
+		}
+		config.CategoryPolicies[category] = policies
+	}
+	if config.ProjectCheck == "" {
+		config.ProjectCheck = ProjectCheckWarn
+	}
+	if config.Email.SMTPPort <= 0 {
+		config.Email.SMTPPort = DefaultSMTPPort
+	}
+	if config.Email.DigestIntervalDays <= 0 {
+		config.Email.DigestIntervalDays = DefaultDigestIntervalDays
+	}
+	if _, _, err := config.Permissions.Modes(); err != nil {
+		return nil, fmt.Errorf("无法解析permissions: %w", err)
+	}
+	if config.MinQuality.Action == "" {
+		config.MinQuality.Action = MinQualitySkip
+	}
+	if config.MinQuality.QuarantineCategory == "" {
+		config.MinQuality.QuarantineCategory = DefaultQuarantineCategory
+	}
+	if config.MissingCountry.Action == "" {
+		config.MissingCountry.Action = MissingCountrySkip
+	}
+	if config.MissingCountry.MovieCategory == "" {
+		config.MissingCountry.MovieCategory = DefaultMissingCountryMovieCategory
+	}
+	if config.MissingCountry.ShowCategory == "" {
+		config.MissingCountry.ShowCategory = DefaultMissingCountryShowCategory
+	}
+	if config.MissingCountry.QuarantineCategory == "" {
+		config.MissingCountry.QuarantineCategory = DefaultQuarantineCategory
+	}
+	if config.YearTolerance == 0 {
+		config.YearTolerance = DefaultYearTolerance
+	}
+	if config.RecordYearTolerance == 0 {
+		config.RecordYearTolerance = DefaultRecordYearTolerance
+	}
+	if config.TMDBLanguage == "" {
+		config.TMDBLanguage = DefaultTMDBLanguage
+	}
+	if config.TMDBFallbackLanguages == nil {
+		config.TMDBFallbackLanguages = DefaultTMDBFallbackLanguages()
+	}
+	if config.DaemonInterval <= 0 {
+		config.DaemonInterval = DefaultDaemonInterval
+	}
+	if config.DBMaintenanceDays == 0 {
+		config.DBMaintenanceDays = DefaultDBMaintenanceDays
+	}
+	if config.Bangumi.MaxTags == 0 {
+		config.Bangumi.MaxTags = DefaultBangumiMaxTags
+	}
+	if config.ReplayRuns == 0 {
+		config.ReplayRuns = DefaultReplayRuns
+	}
+	// 没有配置retry时使用默认的次数和间隔
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry.MaxAttempts = DefaultRetryAttempts
+		if config.Retry.DelaySeconds == 0 {
+			config.Retry.DelaySeconds = DefaultRetryDelaySeconds
+		}
+	}
+	if config.Cache.MaxMB == 0 {
+		config.Cache.MaxMB = DefaultCacheMaxMB
+	}
+	if config.Cache.TMDBDays == 0 {
+		config.Cache.TMDBDays = DefaultCacheTMDBDays
+	}
+	config.Cache.Dir = expandHomePath(config.Cache.Dir)
+	config.TMMDataDir = expandHomePath(config.TMMDataDir)
+	if config.TMMDatasources == "" {
+		config.TMMDatasources = TMMDatasourcesCheck
+	}
+	if config.TMMDocker.Tag == "" {
+		config.TMMDocker.Tag = DefaultTMMDockerTag
+	}
+	if config.TMMDocker.Command == "" {
+		config.TMMDocker.Command = DefaultTMMDockerCommand
+	}
+	if config.TMMDocker.Docker == "" {
+		config.TMMDocker.Docker = DefaultDockerExecutable
+	}
+	for i, volume := range config.TMMDocker.Volumes {
+		config.TMMDocker.Volumes[i] = expandHomePath(volume)
+	}
+	if config.TMMTimeoutMinutes <= 0 {
+		config.TMMTimeoutMinutes = DefaultTMMTimeoutMinutes
+	}
+	if config.LogOutput == "" {
+		config.LogOutput = DefaultLogOutput
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = DefaultLogLevel
+	}
+	if config.IncompleteMarkers == nil {
+		config.IncompleteMarkers = DefaultIncompleteMarkers()
+	}
+	// 没有配置的操作使用默认阈值
+	for op, threshold := range DefaultSlowThresholds() {
+		if _, exists := config.SlowThresholds[op]; !exists {
+			if config.SlowThresholds == nil {
+				config.SlowThresholds = make(map[string]int)
+			}
+			config.SlowThresholds[op] = threshold
+		}
+	}
+
+	for kind, n := range DefaultConcurrency() {
+		if _, exists := config.Concurrency[kind]; !exists {
+			if config.Concurrency == nil {
+				config.Concurrency = make(map[string]int)
+			}
+			config.Concurrency[kind] = n
+		}
+	}
+
+	for op, mode := range DefaultSymlinks() {
+		if _, exists := config.Symlinks[op]; !exists {
+			if config.Symlinks == nil {
+				config.Symlinks = make(map[string]string)
+			}
+			config.Symlinks[op] = mode
+		}
+	}
+
+	// 旧配置文件中没有的字段使用默认值
+	if config.MusicCategory == "" {
+		config.MusicCategory = DefaultMusicCategory
+	}
+	if config.UnsortedAfterDays <= 0 {
+		config.UnsortedAfterDays = DefaultUnsortedAfterDays
+	}
+	if config.ServeAddr == "" {
+		config.ServeAddr = DefaultServeAddr
+	}
+	if config.Hooks.TimeoutSeconds <= 0 {
+		config.Hooks.TimeoutSeconds = DefaultHookTimeoutSeconds
+	}
+	if config.Hooks.FailurePolicy == "" {
+		config.Hooks.FailurePolicy = HookFailureContinue
+	}
 
 	// 处理所有TempDirs
 	validTempDirs := []string{}
@@ -160,24 +853,26 @@ func LoadConfig() *Config {
 
 	config.TempDirs = validTempDirs
 
-	return &config
+	return &config, nil
 }
 
-func SaveConfig(config *Config) {
-	configPath := GetConfigPath()
+// SaveConfig 将配置写入配置文件
+func SaveConfig(config *Config) error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
 	configDir := filepath.Dir(configPath)
 
 	// 确保配置目录存在
 	if err := os.MkdirAll(configDir, 0755); err != nil {
-		fmt.Printf("无法创建配置目录: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("无法创建配置目录: %w", err)
 	}
 
 	// 创建配置文件
 	file, err := os.Create(configPath)
 	if err != nil {
-		fmt.Printf("无法创建配置文件: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("无法创建配置文件: %w", err)
 	}
 	defer file.Close()
 
@@ -185,9 +880,9 @@ func SaveConfig(config *Config) {
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(config); err != nil {
-		fmt.Printf("无法保存配置文件: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("无法保存配置文件: %w", err)
 	}
+	return nil
 }
 
 func createDefaultConfig() *Config {
@@ -203,16 +898,82 @@ func createDefaultConfig() *Config {
 	}
 
 	return &Config{
-		CloudDir:             DefaultCloud,
-		TinyMediaManagerDir:  tmmDir,
-		TempDirs:             []string{DefaultTemp},
-		TMDBApiKey:           "",    // 默认为空，需要用户手动配置
-		UseTMDBOrg:           false, // 默认不使用tmdb.org
-		WaitTimeAfterScan:    30,    // 默认等待时间30秒
-		WaitTimeAfterNFOEdit: 10,    // 默认NFO文件编辑后等待时间10秒
+		CloudDir:              DefaultCloud,
+		TinyMediaManagerDir:   tmmDir,
+		TempDirs:              []string{DefaultTemp},
+		TMDBApiKey:            "",    // 默认为空，需要用户手动配置
+		UseTMDBOrg:            false, // 默认不使用tmdb.org
+		TMDBLanguage:          DefaultTMDBLanguage,
+		TMDBFallbackLanguages: DefaultTMDBFallbackLanguages(),
+		IncompleteMarkers:     DefaultIncompleteMarkers(),
+		SlowThresholds:        DefaultSlowThresholds(),
+		Symlinks:              DefaultSymlinks(),
+		Concurrency:           DefaultConcurrency(),
+		Retry:                 RetryConfig{MaxAttempts: DefaultRetryAttempts, DelaySeconds: DefaultRetryDelaySeconds},
+		LogOutput:             DefaultLogOutput,
+		LogLevel:              DefaultLogLevel,
+		WaitTimeAfterScan:     30,    // 默认等待时间30秒
+		WaitTimeAfterNFOEdit:  10,    // 默认NFO文件编辑后等待时间10秒
+		AnimeMode:             false, // 默认不解析字幕组命名
+		SortSeasonPacks:       false, // 默认保持剧集文件原来的位置
+		MusicCategory:         DefaultMusicCategory,
+		UnsortedCategory:      "", // 默认不移动未刮削内容
+		UnsortedAfterDays:     DefaultUnsortedAfterDays,
+		ServeAddr:             DefaultServeAddr,
+		DaemonInterval:        DefaultDaemonInterval,
+		DBMaintenanceDays:     DefaultDBMaintenanceDays,
+		Bangumi:               BangumiConfig{MaxTags: DefaultBangumiMaxTags},
+		ReplayRuns:            DefaultReplayRuns,
+		Cache:                 CacheConfig{MaxMB: DefaultCacheMaxMB, TMDBDays: DefaultCacheTMDBDays},
+		TMMDatasources:        TMMDatasourcesCheck,
+		TMMDocker: TMMDockerConfig{
+			Tag:     DefaultTMMDockerTag,
+			Command: DefaultTMMDockerCommand,
+			Docker:  DefaultDockerExecutable,
+		},
+		TMMTimeoutMinutes:   DefaultTMMTimeoutMinutes,
+		ProjectCheck:        ProjectCheckWarn,
+		YearTolerance:       DefaultYearTolerance,
+		RecordYearTolerance: DefaultRecordYearTolerance,
+		Hooks: HooksConfig{
+			TimeoutSeconds: DefaultHookTimeoutSeconds,
+			FailurePolicy:  HookFailureContinue,
+		},
 	}
 }
 
+// DefaultIncompleteMarkers 默认的下载未完成标记：qBittorrent、µTorrent、aria2、浏览器和常见下载工具的临时文件
+func DefaultIncompleteMarkers() []string {
+	return []string{".!qB", ".!ut", ".part", ".aria2", ".crdownload", ".downloading"}
+}
+
+// DefaultSlowThresholds 默认的慢操作阈值（毫秒）：解析NFO 2秒、TMDB请求 5秒、写入NFO 2秒、移动 10分钟
+func DefaultSlowThresholds() map[string]int {
+	return map[string]int{"parse": 2000, "tmdb": 5000, "nfo_write": 2000, "move": 600000}
+}
+
+// DefaultSymlinks 默认的符号链接处理方式：扫描和复制时跟随链接，移动时保留链接本身
+func DefaultSymlinks() map[string]string {
+	return map[string]string{
+		SymlinkOpScan: string(utils.SymlinkFollow),
+		SymlinkOpMove: string(utils.SymlinkPreserve),
+		SymlinkOpCopy: string(utils.SymlinkFollow),
+	}
+}
+
+// DefaultConcurrency 默认的并发任务数：依次处理项目，一次只移动一个影片
+func DefaultConcurrency() map[string]int {
+	return map[string]int{
+		ConcurrencyMetadata: 1,
+		ConcurrencyMove:     1,
+	}
+}
+
+// DefaultTMDBFallbackLanguages 默认的TMDB备用语言：繁体中文、英文
+func DefaultTMDBFallbackLanguages() []string {
+	return []string{"zh-TW", "en-US"}
+}
+
 // expandHomePath 替换路径中的 ~ 为用户主目录
 func expandHomePath(path string) string {
 	if strings.HasPrefix(path, "~") {
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+)
+
+// notifyTriggerSignal Windows没有SIGUSR1，只能通过trigger子命令触发
+func notifyTriggerSignal(ch chan<- os.Signal) {
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MissingMovie 表示电影系列中缺失的电影
+type MissingMovie struct {
+	ID             int       `db:"id"`
+	CollectionID   int       `db:"collection_id"`
+	CollectionName string    `db:"collection_name"`
+	TMDbID         string    `db:"tmdb_id"`
+	Title          string    `db:"title"`
+	ReleaseDate    string    `db:"release_date"`
+	DetectedAt     time.Time `db:"detected_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+	Status         string    `db:"status"`
+}
+
+// createMissingMoviesTable 创建电影系列缺失电影表
+func createMissingMoviesTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS missing_movies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		collection_id INTEGER,
+		collection_name TEXT,
+		tmdb_id TEXT,
+		title TEXT,
+		release_date TEXT,
+		detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		status TEXT DEFAULT 'missing',
+		UNIQUE(collection_id, tmdb_id)
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建缺失电影表: %w", err)
+	}
+	return nil
+}
+
+// HasMovieRecord 检查数据库中是否有指定TMDb ID的电影记录
+func HasMovieRecord(tmdbID string) (bool, error) {
+	if err := InitDatabase(); err != nil {
+		return false, err
+	}
+
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM media_records WHERE tmdb_id = ? AND category NOT LIKE '%Show'`, tmdbID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("查询电影记录失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetMissingMovieStatus 记录系列中电影的状态，不存在时插入
+func SetMissingMovieStatus(record *MissingMovie, status string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err := DB.Exec(`
+	INSERT INTO missing_movies (collection_id, collection_name, tmdb_id, title, release_date, detected_at, updated_at, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(collection_id, tmdb_id) DO UPDATE SET collection_name = excluded.collection_name,
+		title = excluded.title, release_date = excluded.release_date, updated_at = excluded.updated_at, status = excluded.status`,
+		record.CollectionID, record.CollectionName, record.TMDbID, record.Title, record.ReleaseDate, now, now, status)
+	if err != nil {
+		return fmt.Errorf("记录系列电影状态失败: %w", err)
+	}
+	return nil
+}
+
+// GetMissingMovies 获取仍然缺失的系列电影，按系列和上映日期排列
+func GetMissingMovies() ([]MissingMovie, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+	SELECT id, collection_id, collection_name, tmdb_id, title, release_date, detected_at, updated_at, status
+	FROM missing_movies WHERE status = ? ORDER BY collection_name, release_date`, MissingStatusMissing)
+	if err != nil {
+		return nil, fmt.Errorf("获取缺失电影失败: %w", err)
+	}
+	defer rows.Close()
+
+	var movies []MissingMovie
+	for rows.Next() {
+		var movie MissingMovie
+		if err := rows.Scan(&movie.ID, &movie.CollectionID, &movie.CollectionName, &movie.TMDbID, &movie.Title,
+			&movie.ReleaseDate, &movie.DetectedAt, &movie.UpdatedAt, &movie.Status); err != nil {
+			return nil, err
+		}
+		movies = append(movies, movie)
+	}
+	return movies, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Correction 媒体库中已入库项目的分类更正记录
+type Correction struct {
+	ID           int       `db:"id"`
+	Title        string    `db:"title"`
+	FromPath     string    `db:"from_path"`
+	ToPath       string    `db:"to_path"`
+	FromCategory string    `db:"from_category"`
+	ToCategory   string    `db:"to_category"`
+	CorrectedAt  time.Time `db:"corrected_at"`
+}
+
+// createCorrectionsTable 创建分类更正记录表
+func createCorrectionsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS corrections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT,
+		from_path TEXT,
+		to_path TEXT,
+		from_category TEXT,
+		to_category TEXT,
+		corrected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建分类更正记录表: %w", err)
+	}
+	return nil
+}
+
+// RecordCorrection 记录一次分类更正
+func RecordCorrection(correction *Correction) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`INSERT INTO corrections (title, from_path, to_path, from_category, to_category, corrected_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		correction.Title, correction.FromPath, correction.ToPath, correction.FromCategory, correction.ToCategory, time.Now())
+	if err != nil {
+		return fmt.Errorf("记录分类更正失败: %w", err)
+	}
+	return nil
+}
+
+// GetCorrections 获取分类更正记录，最新的排在前面
+func GetCorrections() ([]Correction, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT id, title, from_path, to_path, from_category, to_category, corrected_at FROM corrections ORDER BY corrected_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("查询分类更正记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var corrections []Correction
+	for rows.Next() {
+		var correction Correction
+		if err := rows.Scan(&correction.ID, &correction.Title, &correction.FromPath, &correction.ToPath,
+			&correction.FromCategory, &correction.ToCategory, &correction.CorrectedAt); err != nil {
+			return nil, fmt.Errorf("读取分类更正记录失败: %w", err)
+		}
+		corrections = append(corrections, correction)
+	}
+	return corrections, rows.Err()
+}
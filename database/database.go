@@ -3,45 +3,61 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "modernc.org/sqlite"
-
+	"github.com/user/media-manager/paths"
 	"github.com/user/media-manager/utils"
 )
 
 // MediaRecord 表示媒体记录的结构
 type MediaRecord struct {
-	ID            int       `db:"id"`
-	FileName      string    `db:"file_name"`
-	Title         string    `db:"title"`
-	OriginalTitle string    `db:"original_title"`
-	Year          string    `db:"year"`
-	Country       string    `db:"country"`
-	Genres        string    `db:"genres"`
-	Actors        string    `db:"actors"`
-	Category      string    `db:"category"`
-	SourcePath    string    `db:"source_path"`
-	TargetPath    string    `db:"target_path"`
-	ProcessedAt   time.Time `db:"processed_at"`
-	UpdatedAt     time.Time `db:"updated_at"`
-	Runtime       string    `db:"runtime"`
-	Plot          string    `db:"plot"`
-	IMDbID        string    `db:"imdb_id"`
-	TMDbID        string    `db:"tmdb_id"`
-	Season        string    `db:"season"`
-	Episode       string    `db:"episode"`
-	Director      string    `db:"director"`
-	Writer        string    `db:"writer"`
-	Rating        string    `db:"rating"`
-	Resolution    string    `db:"resolution"`
-	Version       int       `db:"version"`
-	IsComplete    bool      `db:"is_complete"`
+	ID               int       `db:"id"`
+	FileName         string    `db:"file_name"`
+	Title            string    `db:"title"`
+	OriginalTitle    string    `db:"original_title"`
+	Year             string    `db:"year"`
+	Country          string    `db:"country"`
+	Genres           string    `db:"genres"`
+	Actors           string    `db:"actors"`
+	Category         string    `db:"category"`
+	SourcePath       string    `db:"source_path"`
+	TargetPath       string    `db:"target_path"`
+	ProcessedAt      time.Time `db:"processed_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+	Runtime          string    `db:"runtime"`
+	Plot             string    `db:"plot"`
+	IMDbID           string    `db:"imdb_id"`
+	TMDbID           string    `db:"tmdb_id"`
+	Season           *int      `db:"season_number"`  // 季号，电影和没有季号的记录为nil，第0季为特别篇
+	Episode          *int      `db:"episode_number"` // 集号，没有时为nil
+	Director         string    `db:"director"`
+	Writer           string    `db:"writer"`
+	Rating           string    `db:"rating"`
+	Resolution       string    `db:"resolution"`
+	Version          int       `db:"version"`
+	IsComplete       bool      `db:"is_complete"`
+	OriginalLanguage string    `db:"original_language"`
+	SpokenLanguages  string    `db:"spoken_languages"`
+	AudioLanguages   string    `db:"audio_languages"`  // 文件名中标注的音轨语言，如 国语,粤语
+	ReleaseTags      string    `db:"release_tags"`     // 文件名中标注的来源平台和画质标签，如 央视频,WEB-DL,4K,60帧
+	HDRFormat        string    `db:"hdr_format"`       // HDR格式，如 DV,HDR10，SDR时为空
+	PlotSource       string    `db:"plot_source"`      // 简介不是来自刮削或首选语言时的来源，如 wikipedia、baidu、tmdb:en-US，为空表示来自刮削
+	TitlePinyin      string    `db:"title_pinyin"`     // 标题的拼音，如 lang ya bang，写入记录时根据标题生成
+	TitleInitials    string    `db:"title_initials"`   // 标题拼音的首字母，如 lyb
+	Edition          string    `db:"edition"`          // 目录名中标注的版本，如 导演剪辑版、IMAX版，不同版本是不同的记录
+	CountryFallback  string    `db:"country_fallback"` // 所有数据源都没有国家信息时按missing_country采用的处理，如 language:ja、category:EnMovie，为空表示有国家信息
+	FieldSources     string    `db:"field_sources"`    // 标题、国家、类型、原始语言和分类的来源，如 title=nfo,country=tmdb,category=rules，见FieldSources
+	Locked           bool      `db:"locked"`           // 由db lock锁定，不再被重新分类、替换版本、合并新季或改写NFO；写入记录时不修改，只能用SetLocked修改
 }
 
+// 缺失季和剧集记录的状态
+const (
+	MissingStatusMissing = "missing" // 仍然缺失
+	MissingStatusFound   = "found"   // 已经获取
+)
+
 // MissingEpisode 表示缺失的剧集记录
 type MissingEpisode struct {
 	ID            int       `db:"id"`
@@ -72,59 +88,52 @@ type MissingSeason struct {
 // DB 是数据库连接的全局变量
 var DB *sql.DB
 
-// GetDatabasePath 获取数据库文件路径
-func GetDatabasePath() string {
-	var dataDir string
-	var err error
+// inMemory 为true时使用内存数据库，不读写数据库文件
+var inMemory bool
 
-	// 1. 首先检查用户当前目录下是否存在Data目录（只检查不创建）
-	currentDir, err := os.Getwd()
-	if err == nil {
-		dataDir = filepath.Join(currentDir, "Data")
-		if _, err := os.Stat(dataDir); err == nil {
-			return filepath.Join(dataDir, "media_manager.db")
-		}
-	}
+// SetInMemory 关闭当前的数据库连接，enabled为true时之后的操作使用空的内存数据库（用于测试），为false时恢复使用数据库文件
+func SetInMemory(enabled bool) {
+	CloseDatabase()
+	inMemory = enabled
+	currentRunID = ""
+}
 
-	// 2. 检查程序执行文件所在目录下是否存在Data目录（只检查不创建）
-	exeDir, err := utils.GetExecutableDir()
-	if err == nil {
-		dataDir = filepath.Join(exeDir, "Data")
-		if _, err := os.Stat(dataDir); err == nil {
-			return filepath.Join(dataDir, "media_manager.db")
-		}
-	}
+// InMemory 返回是否使用内存数据库
+func InMemory() bool {
+	return inMemory
+}
 
-	// 3. 最后使用用户主目录下的.media-manager/Data目录（不存在则创建）
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		dataDir = filepath.Join(homeDir, ".media-manager", "Data")
-		// 确保用户主目录下的Data目录存在
-		if err := os.MkdirAll(dataDir, 0755); err == nil {
-			return filepath.Join(dataDir, "media_manager.db")
-		}
+// GetDatabasePath 获取数据库文件路径，Data目录的查找顺序见paths.Dir
+func GetDatabasePath() (string, error) {
+	dbPath, err := paths.File(paths.Data, "media_manager.db")
+	if err != nil {
+		return "", fmt.Errorf("无法创建Data目录: %w", err)
 	}
-
-	// 如果所有尝试都失败，输出错误并退出
-	fmt.Printf("无法创建Data目录\n")
-	os.Exit(1)
-	return "" // 永远不会执行到这里
+	return dbPath, nil
 }
 
-// InitDatabase 初始化数据库
-func InitDatabase() {
+// InitDatabase 初始化数据库，已初始化时直接返回
+func InitDatabase() error {
 	// 检查DB是否已经初始化，这是关键的幂等性检查
 	if DB != nil {
-		return
+		return nil
 	}
 
-	dbPath := GetDatabasePath()
+	dbPath := ":memory:"
+	if !inMemory {
+		var err error
+		if dbPath, err = GetDatabasePath(); err != nil {
+			return err
+		}
+		if dryRunPath != "" {
+			dbPath = dryRunPath
+		}
+	}
 
 	// 打开数据库连接
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open(utcDriverName, dbPath+timeFormatDSN)
 	if err != nil {
-		fmt.Printf("无法打开数据库: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("无法打开数据库: %w", err)
 	}
 
 	// 设置SQLite连接参数
@@ -132,15 +141,25 @@ func InitDatabase() {
 	db.SetMaxOpenConns(1)    // 只允许一个连接，避免SQLite锁定问题
 	db.SetMaxIdleConns(0)    // 不保留空闲连接
 	db.SetConnMaxLifetime(0) // 连接永不超时
+	if inMemory {
+		db.SetMaxIdleConns(1) // 内存数据库随连接关闭而丢失，保留唯一的连接
+	}
 
 	// 立即设置全局DB变量，避免并发初始化
 	DB = db
 
 	// 验证数据库连接
 	if err := db.Ping(); err != nil {
-		fmt.Printf("无法连接到数据库: %v\n", err)
 		DB = nil // 重置DB，以便下次可以重试
-		os.Exit(1)
+		db.Close()
+		return fmt.Errorf("无法连接到数据库: %w", err)
+	}
+
+	// 建表或迁移失败时关闭连接，下次调用时重试
+	fail := func(err error) error {
+		DB = nil
+		db.Close()
+		return err
 	}
 
 	// 创建媒体记录表，包含所有必要字段
@@ -170,26 +189,32 @@ func InitDatabase() {
 		rating TEXT,
 		resolution TEXT,
 		version INTEGER DEFAULT 1,
-		is_complete BOOLEAN DEFAULT FALSE
+		is_complete BOOLEAN DEFAULT FALSE,
+		original_language TEXT,
+		spoken_languages TEXT,
+		audio_languages TEXT,
+		release_tags TEXT,
+		hdr_format TEXT,
+		plot_source TEXT,
+		title_pinyin TEXT,
+		title_initials TEXT,
+		title_key TEXT,
+		edition TEXT
 	);`
 
 	if _, err := db.Exec(createTableSQL); err != nil {
-		fmt.Printf("无法创建媒体记录表: %v\n", err)
-		// 不退出，继续执行
+		return fail(fmt.Errorf("无法创建媒体记录表: %w", err))
 	}
-	// 无论表是否创建成功，都检查并添加缺少的字段
-	// 这确保了旧表也会被更新为包含所有必要字段
 
 	// 如果表已经存在，检查并添加缺少的字段
 	// 这里我们使用更安全的方式，避免锁定问题
 	// 只检查和添加必要的字段，使用简单的ALTER TABLE语句
-	addMissingField := func(fieldName, fieldType string) {
+	addMissingField := func(fieldName, fieldType string) error {
 		// 使用PRAGMA table_info检查字段是否存在
 		var exists bool
 		rows, err := db.Query(`PRAGMA table_info(media_records)`)
 		if err != nil {
-			fmt.Printf("查询表结构失败: %v\n", err)
-			return
+			return fmt.Errorf("查询表结构失败: %w", err)
 		}
 
 		for rows.Next() {
@@ -200,8 +225,8 @@ func InitDatabase() {
 			var dfltValue interface{}
 			var pk int
 			if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
-				fmt.Printf("扫描表结构失败: %v\n", err)
-				break
+				rows.Close()
+				return fmt.Errorf("扫描表结构失败: %w", err)
 			}
 			if name == fieldName {
 				exists = true
@@ -215,19 +240,49 @@ func InitDatabase() {
 			// 使用简单的ALTER TABLE语句，不使用默认值
 			alterSQL := fmt.Sprintf("ALTER TABLE media_records ADD COLUMN %s %s;", fieldName, fieldType)
 			if _, err := db.Exec(alterSQL); err != nil {
-				// 忽略添加字段的错误，特别是"duplicate column name"错误
+				// 字段已存在（duplicate column name）不算错误
 				if !strings.Contains(err.Error(), "duplicate column name") {
-					fmt.Printf("添加字段 %s 失败: %v\n", fieldName, err)
+					return fmt.Errorf("添加字段 %s 失败: %w", fieldName, err)
 				}
 			}
 		}
+		return nil
 	}
 
 	// 添加可能缺少的字段
-	addMissingField("updated_at", "TIMESTAMP")
-	addMissingField("resolution", "TEXT")
-	addMissingField("version", "INTEGER")
-	addMissingField("is_complete", "BOOLEAN")
+	missingFields := []struct{ name, fieldType string }{
+		{"updated_at", "TIMESTAMP"},
+		{"resolution", "TEXT"},
+		{"version", "INTEGER"},
+		{"is_complete", "BOOLEAN"},
+		{"original_language", "TEXT"},
+		{"spoken_languages", "TEXT"},
+		{"audio_languages", "TEXT"},
+		{"release_tags", "TEXT"},
+		{"hdr_format", "TEXT"},
+		{"plot_source", "TEXT"},
+		{"title_pinyin", "TEXT"},
+		{"title_initials", "TEXT"},
+		{"title_key", "TEXT"},
+		{"edition", "TEXT"},
+		{"country_fallback", "TEXT"},
+		{"field_sources", "TEXT"},
+		{"locked", "BOOLEAN"},
+		{"season_number", "INTEGER"},
+		{"episode_number", "INTEGER"},
+		{"season_number_parsed", "BOOLEAN"},
+	}
+	for _, field := range missingFields {
+		if err := addMissingField(field.name, field.fieldType); err != nil {
+			return fail(err)
+		}
+	}
+	if err := fillTitleKeys(db); err != nil {
+		return fail(err)
+	}
+	if err := fillSeasonNumbers(db); err != nil {
+		return fail(err)
+	}
 
 	// 创建缺失剧集表
 	createMissingEpisodesTableSQL := `
@@ -246,8 +301,7 @@ func InitDatabase() {
 	);`
 
 	if _, err := db.Exec(createMissingEpisodesTableSQL); err != nil {
-		fmt.Printf("无法创建缺失剧集表: %v\n", err)
-		// 不退出，继续执行
+		return fail(fmt.Errorf("无法创建缺失剧集表: %w", err))
 	}
 
 	// 创建缺失季表
@@ -266,38 +320,173 @@ func InitDatabase() {
 	);`
 
 	if _, err := db.Exec(createMissingSeasonsTableSQL); err != nil {
-		fmt.Printf("无法创建缺失季表: %v\n", err)
-		// 不退出，继续执行
+		return fail(fmt.Errorf("无法创建缺失季表: %w", err))
+	}
+
+	// 创建其他功能使用的表
+	for _, create := range []func(*sql.DB) error{
+		createProblemItemsTable,
+		createRunStateTable,
+		createQueueTable,
+		createTMDBNotFoundTable,
+		createSkipItemsTable,
+		createMissingMoviesTable,
+		createStorageUsageTable,
+		createRunTimingsTable,
+		createCorrectionsTable,
+		createEventsTable,
+		createDecisionRecordsTable,
+	} {
+		if err := create(db); err != nil {
+			return fail(err)
+		}
+	}
+	if err := migrateTimestampsToUTC(db); err != nil {
+		return fail(err)
 	}
+	return nil
 }
 
-// InsertOrUpdateMediaRecord 插入或更新媒体记录
-func InsertOrUpdateMediaRecord(record *MediaRecord) error {
-	if DB == nil {
-		InitDatabase()
+// fillTitleKeys 为添加拼音和规范化标题字段之前写入的媒体记录生成标题的拼音、首字母和规范化标题
+func fillTitleKeys(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, title FROM media_records WHERE (title_pinyin IS NULL OR title_key IS NULL) AND title IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("查询缺少拼音的媒体记录失败: %w", err)
+	}
+	titles := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var title string
+		if err := rows.Scan(&id, &title); err == nil {
+			titles[id] = title
+		}
 	}
+	rows.Close()
 
-	// 检查是否已存在相同的媒体记录
+	for id, title := range titles {
+		if _, err := db.Exec(`UPDATE media_records SET title_pinyin = ?, title_initials = ?, title_key = ? WHERE id = ?`,
+			utils.Pinyin(title), utils.PinyinInitials(title), utils.NormalizeTitle(title), id); err != nil {
+			return fmt.Errorf("生成标题拼音失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// fillSeasonNumbers 把旧版本以NFO原文保存的季号、集号（如 "02"、""）转换为整数，写入season_number和episode_number
+// 无法解析的值保留为NULL，转换过的记录标记season_number_parsed，启动时不再重复转换；旧的season、episode列保留原文，不再写入
+func fillSeasonNumbers(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, COALESCE(season, ''), COALESCE(episode, '') FROM media_records
+		WHERE COALESCE(season_number_parsed, 0) = 0
+		AND ((season_number IS NULL AND TRIM(COALESCE(season, '')) != '') OR (episode_number IS NULL AND TRIM(COALESCE(episode, '')) != ''))`)
+	if err != nil {
+		return fmt.Errorf("查询需要转换季号的媒体记录失败: %w", err)
+	}
+	type numbers struct{ season, episode *int }
+	parsed := make(map[int]numbers)
+	for rows.Next() {
+		var id int
+		var season, episode string
+		if err := rows.Scan(&id, &season, &episode); err == nil {
+			parsed[id] = numbers{parseNumber(season), parseNumber(episode)}
+		}
+	}
+	rows.Close()
+
+	for id, n := range parsed {
+		if _, err := db.Exec(`UPDATE media_records SET season_number = COALESCE(season_number, ?), episode_number = COALESCE(episode_number, ?), season_number_parsed = 1 WHERE id = ?`,
+			n.season, n.episode, id); err != nil {
+			return fmt.Errorf("转换季号失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseNumber 解析季号或集号，与parser.ParseNumber相同（database不依赖parser）
+func parseNumber(value string) *int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// FormatNumber 返回季号或集号的显示文字，nil时为空字符串
+func FormatNumber(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// RecordYearTolerance 按标题查找已有的媒体记录时允许的年份差，TMM写入的制作年份与TMDB的上映年份常差一年
+// 由main按配置的record_year_tolerance设置，0表示年份必须相同
+var RecordYearTolerance int
+
+// YearsMatch 判断两个年份是否相同，或都是数字且相差不超过RecordYearTolerance
+func YearsMatch(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	ya, errA := strconv.Atoi(strings.TrimSpace(a))
+	yb, errB := strconv.Atoi(strings.TrimSpace(b))
+	if errA != nil || errB != nil {
+		return false
+	}
+	diff := ya - yb
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= RecordYearTolerance
+}
+
+// findExistingMediaRecord 查找与record对应的已有媒体记录，返回其ID和版本号，不存在时返回sql.ErrNoRows
+// 电影使用标题、年份和版本作为唯一标识，电视剧还要求季数相同；版本（如 导演剪辑版）不同时是不同的记录
+// 有TMDb ID时先按TMDb ID查找；否则按标题查找，标题按规范化后比较，不同来源写成 "哪吒之魔童降世（国语版）"
+// 和 "哪吒之魔童降世" 时视为同一条记录，年份允许相差RecordYearTolerance年，但TMDb ID不同时不视为同一条记录
+func findExistingMediaRecord(record *MediaRecord, titleKey string) (int, *int, error) {
 	var existingID int
 	var existingVersion *int // 使用指针类型，允许NULL值
 
-	// 对于电影，使用标题、年份和分辨率作为唯一标识
-	// 对于电视剧，使用标题、年份、季数和分辨率作为唯一标识
-	var query string
-	var args []interface{}
-
-	if record.Season == "" {
-		// 电影
-		query = `SELECT id, version FROM media_records WHERE title = ? AND year = ? AND category NOT LIKE '%Show'`
-		args = []interface{}{record.Title, record.Year}
-	} else {
-		// 电视剧
-		query = `SELECT id, version FROM media_records WHERE title = ? AND year = ? AND season = ? AND category LIKE '%Show'`
-		args = []interface{}{record.Title, record.Year, record.Season}
+	kindCond := `category NOT LIKE '%Show'`
+	kindArgs := []interface{}{record.Edition}
+	if record.Season != nil {
+		kindCond = `season_number = ? AND category LIKE '%Show'`
+		kindArgs = []interface{}{record.Edition, *record.Season}
+	}
+	kindCond = `COALESCE(edition, '') = ? AND ` + kindCond
+
+	if record.TMDbID != "" {
+		query := `SELECT id, version FROM media_records WHERE tmdb_id = ? AND ` + kindCond + ` ORDER BY year = ? DESC, id`
+		args := append(append([]interface{}{record.TMDbID}, kindArgs...), record.Year)
+		err := DB.QueryRow(query, args...).Scan(&existingID, &existingVersion)
+		if err != sql.ErrNoRows {
+			return existingID, existingVersion, err
+		}
 	}
 
+	yearCond := `year = ?`
+	yearArgs := []interface{}{record.Year}
+	if year, err := strconv.Atoi(strings.TrimSpace(record.Year)); err == nil && RecordYearTolerance > 0 {
+		yearCond = `(year = ? OR (ABS(CAST(year AS INTEGER) - ?) <= ? AND (COALESCE(tmdb_id, '') = '' OR ? = '' OR tmdb_id = ?)))`
+		yearArgs = []interface{}{record.Year, year, RecordYearTolerance, record.TMDbID, record.TMDbID}
+	}
+	query := `SELECT id, version FROM media_records WHERE (title = ? OR title_key = ?) AND ` + yearCond + ` AND ` + kindCond +
+		` ORDER BY year = ? DESC, title = ? DESC`
+	args := append([]interface{}{record.Title, titleKey}, yearArgs...)
+	args = append(append(args, kindArgs...), record.Year, record.Title)
 	err := DB.QueryRow(query, args...).Scan(&existingID, &existingVersion)
+	return existingID, existingVersion, err
+}
+
+// InsertOrUpdateMediaRecord 插入或更新媒体记录，完成后record.ID为记录的ID
+func InsertOrUpdateMediaRecord(record *MediaRecord) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
 
+	// 检查是否已存在相同的媒体记录
+	titleKey := utils.NormalizeTitle(record.Title)
+	existingID, existingVersion, err := findExistingMediaRecord(record, titleKey)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// 记录不存在，执行插入
@@ -313,10 +502,10 @@ func InsertOrUpdateMediaRecord(record *MediaRecord) error {
 			}
 
 			insertSQL := `
-			INSERT INTO media_records (file_name, title, original_title, year, country, genres, actors, category, source_path, target_path, processed_at, updated_at, runtime, plot, imdb_id, tmdb_id, season, episode, director, writer, rating, resolution, version, is_complete) 
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			INSERT INTO media_records (file_name, title, original_title, year, country, genres, actors, category, source_path, target_path, processed_at, updated_at, runtime, plot, imdb_id, tmdb_id, season_number, episode_number, director, writer, rating, resolution, version, is_complete, original_language, spoken_languages, audio_languages, release_tags, hdr_format, plot_source, title_pinyin, title_initials, title_key, edition, country_fallback, field_sources) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-			_, err = DB.Exec(insertSQL,
+			result, err := DB.Exec(insertSQL,
 				record.FileName,
 				record.Title,
 				record.OriginalTitle,
@@ -341,9 +530,26 @@ func InsertOrUpdateMediaRecord(record *MediaRecord) error {
 				record.Resolution,
 				version,
 				isComplete,
+				record.OriginalLanguage,
+				record.SpokenLanguages,
+				record.AudioLanguages,
+				record.ReleaseTags,
+				record.HDRFormat,
+				record.PlotSource,
+				utils.Pinyin(record.Title),
+				utils.PinyinInitials(record.Title),
+				titleKey,
+				record.Edition,
+				record.CountryFallback,
+				record.FieldSources,
 			)
-
-			return err
+			if err != nil {
+				return err
+			}
+			if id, err := result.LastInsertId(); err == nil {
+				record.ID = int(id)
+			}
+			return nil
 		} else {
 			return err
 		}
@@ -370,13 +576,21 @@ func InsertOrUpdateMediaRecord(record *MediaRecord) error {
 			plot = ?, 
 			imdb_id = ?, 
 			tmdb_id = ?, 
-			episode = ?, 
+			episode_number = ?, 
 			director = ?, 
 			writer = ?, 
 			rating = ?, 
 			resolution = ?, 
 			version = ?, 
-			is_complete = ? 
+			is_complete = ?, 
+			original_language = ?, 
+			spoken_languages = ?, 
+			audio_languages = ?, 
+			release_tags = ?, 
+			hdr_format = ?, 
+			plot_source = ?, 
+			country_fallback = ?, 
+			field_sources = ? 
 		WHERE id = ?`
 
 		_, err = DB.Exec(updateSQL,
@@ -400,17 +614,140 @@ func InsertOrUpdateMediaRecord(record *MediaRecord) error {
 			record.Resolution,
 			newVersion, // 使用计算后的版本号
 			record.IsComplete,
+			record.OriginalLanguage,
+			record.SpokenLanguages,
+			record.AudioLanguages,
+			record.ReleaseTags,
+			record.HDRFormat,
+			record.PlotSource,
+			record.CountryFallback,
+			record.FieldSources,
 			existingID,
 		)
+		if err != nil {
+			return err
+		}
+		record.ID = existingID
+		return nil
+	}
+}
+
+// CorrectMediaRecordYear 将指定标题的媒体记录年份从oldYear校正为newYear，返回更新的记录数
+func CorrectMediaRecordYear(title, oldYear, newYear string) (int, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`UPDATE media_records SET year = ?, updated_at = ? WHERE title = ? AND year = ?`,
+		newYear, time.Now(), title, oldYear)
+	if err != nil {
+		return 0, fmt.Errorf("校正媒体记录年份失败: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// UpdateMediaTargetPath 把目标路径为oldPath的媒体记录改为newPath（目录在媒体库中被手动改名或移动），返回更新的记录数
+func UpdateMediaTargetPath(oldPath, newPath string) (int, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`UPDATE media_records SET target_path = ?, updated_at = ? WHERE target_path = ?`,
+		newPath, time.Now(), oldPath)
+	if err != nil {
+		return 0, fmt.Errorf("更新媒体记录目标路径失败: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// SetLocked 锁定或解锁媒体记录
+func SetLocked(id int, locked bool) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(`UPDATE media_records SET locked = ?, updated_at = ? WHERE id = ?`, locked, time.Now(), id); err != nil {
+		return fmt.Errorf("修改记录的锁定状态失败: %w", err)
+	}
+	return nil
+}
+
+// IsPathLocked 判断目标路径为path的媒体记录（电视剧为任一季）是否被锁定
+func IsPathLocked(path string) (bool, error) {
+	if err := InitDatabase(); err != nil {
+		return false, err
+	}
+
+	var count int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM media_records WHERE target_path = ? AND locked = ?`, path, true).Scan(&count); err != nil {
+		return false, fmt.Errorf("查询记录的锁定状态失败: %w", err)
+	}
+	return count > 0, nil
+}
 
+// SetCountryFallback 记录没有国家信息的媒体记录按missing_country采用的处理
+func SetCountryFallback(id int, fallback string) error {
+	if err := InitDatabase(); err != nil {
 		return err
 	}
+
+	if _, err := DB.Exec(`UPDATE media_records SET country_fallback = ?, updated_at = ? WHERE id = ?`, fallback, time.Now(), id); err != nil {
+		return fmt.Errorf("记录国家信息的处理方式失败: %w", err)
+	}
+	return nil
+}
+
+// EditableMediaFields db update可以批量修改的字段及对应的数据库列
+var EditableMediaFields = map[string]string{
+	"category": "category",
+	"year":     "year",
+	"tags":     "release_tags",
+	"country":  "country",
+	"genres":   "genres",
+}
+
+// UpdateMediaRecordFields 修改指定媒体记录的字段，fields的键为EditableMediaFields中的字段名
+// 分类、国家和类型的来源同时记录为manual
+func UpdateMediaRecordFields(id int, fields map[string]string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	var assignments []string
+	var args []interface{}
+	for field, value := range fields {
+		column, ok := EditableMediaFields[field]
+		if !ok {
+			return fmt.Errorf("不能修改的字段: %s", field)
+		}
+		assignments = append(assignments, column+" = ?")
+		args = append(args, value)
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	sources, ok, err := manualSources(id, fields)
+	if err != nil {
+		return err
+	}
+	if ok {
+		assignments = append(assignments, "field_sources = ?")
+		args = append(args, sources)
+	}
+	args = append(args, time.Now(), id)
+
+	if _, err := DB.Exec(`UPDATE media_records SET `+strings.Join(assignments, ", ")+`, updated_at = ? WHERE id = ?`, args...); err != nil {
+		return fmt.Errorf("修改媒体记录失败: %w", err)
+	}
+	return nil
 }
 
 // InsertMissingSeason 插入缺失季记录
 func InsertMissingSeason(record *MissingSeason) error {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return err
 	}
 
 	// 检查是否已存在相同的缺失季记录
@@ -447,8 +784,8 @@ func InsertMissingSeason(record *MissingSeason) error {
 
 // InsertMissingEpisode 插入缺失剧集记录
 func InsertMissingEpisode(record *MissingEpisode) error {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return err
 	}
 
 	// 检查是否已存在相同的缺失剧集记录
@@ -486,8 +823,8 @@ func InsertMissingEpisode(record *MissingEpisode) error {
 
 // UpdateMissingItemStatus 更新缺失项目的状态
 func UpdateMissingItemStatus(table string, id int, status string) error {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return err
 	}
 
 	updateSQL := `
@@ -500,10 +837,48 @@ func UpdateMissingItemStatus(table string, id int, status string) error {
 	return err
 }
 
+// GetMissingSeasonByID 根据ID获取缺失季记录（不限状态），不存在时返回nil
+func GetMissingSeasonByID(id int) (*MissingSeason, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	var season MissingSeason
+	query := `SELECT id, media_id, title, original_title, tmdb_id, season, detected_at, updated_at, status FROM missing_seasons WHERE id = ?`
+	err := DB.QueryRow(query, id).Scan(&season.ID, &season.MediaID, &season.Title, &season.OriginalTitle, &season.TMDbID, &season.Season, &season.DetectedAt, &season.UpdatedAt, &season.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+// GetMissingEpisodeByID 根据ID获取缺失剧集记录（不限状态），不存在时返回nil
+func GetMissingEpisodeByID(id int) (*MissingEpisode, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	var episode MissingEpisode
+	query := `SELECT id, media_id, title, original_title, tmdb_id, season, episode, detected_at, updated_at, status FROM missing_episodes WHERE id = ?`
+	err := DB.QueryRow(query, id).Scan(&episode.ID, &episode.MediaID, &episode.Title, &episode.OriginalTitle, &episode.TMDbID, &episode.Season, &episode.Episode, &episode.DetectedAt, &episode.UpdatedAt, &episode.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &episode, nil
+}
+
 // GetMissingSeasons 获取所有缺失的季记录
 func GetMissingSeasons(filter map[string]interface{}) ([]MissingSeason, error) {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return nil, err
 	}
 
 	var missingSeasons []MissingSeason
@@ -540,8 +915,8 @@ func GetMissingSeasons(filter map[string]interface{}) ([]MissingSeason, error) {
 
 // GetMissingEpisodes 获取所有缺失的剧集记录
 func GetMissingEpisodes(filter map[string]interface{}) ([]MissingEpisode, error) {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return nil, err
 	}
 
 	var missingEpisodes []MissingEpisode
@@ -583,8 +958,8 @@ func GetMissingEpisodes(filter map[string]interface{}) ([]MissingEpisode, error)
 
 // GetMediaRecords 获取媒体记录列表
 func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
-	if DB == nil {
-		InitDatabase()
+	if err := InitDatabase(); err != nil {
+		return nil, err
 	}
 
 	var mediaRecords []MediaRecord
@@ -607,14 +982,26 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 		plot, 
 		imdb_id, 
 		tmdb_id, 
-		season, 
-		episode, 
+		season_number, 
+		episode_number, 
 		director, 
 		writer, 
 		rating, 
 		resolution, 
 		version, 
-		is_complete 
+		is_complete, 
+		original_language, 
+		spoken_languages, 
+		audio_languages, 
+		release_tags, 
+		hdr_format, 
+		plot_source, 
+		title_pinyin, 
+		title_initials, 
+		edition, 
+		country_fallback, 
+		field_sources, 
+		locked 
 	FROM media_records`
 
 	// 添加过滤条件
@@ -624,6 +1011,24 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 		args = append(args, "%"+title+"%")
 	}
 
+	if id, ok := filter["id"].(int); ok && id > 0 {
+		if len(args) > 0 {
+			query += ` AND id = ?`
+		} else {
+			query += ` WHERE id = ?`
+		}
+		args = append(args, id)
+	}
+
+	if targetPath, ok := filter["target_path"].(string); ok && targetPath != "" {
+		if len(args) > 0 {
+			query += ` AND target_path = ?`
+		} else {
+			query += ` WHERE target_path = ?`
+		}
+		args = append(args, targetPath)
+	}
+
 	if isComplete, ok := filter["is_complete"].(bool); ok {
 		if len(args) > 0 {
 			query += ` AND is_complete = ?`
@@ -642,6 +1047,97 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 		args = append(args, "%"+category+"%")
 	}
 
+	if language, ok := filter["language"].(string); ok && language != "" {
+		if len(args) > 0 {
+			query += ` AND (original_language = ? OR spoken_languages LIKE ?)`
+		} else {
+			query += ` WHERE (original_language = ? OR spoken_languages LIKE ?)`
+		}
+		args = append(args, language, "%"+language+"%")
+	}
+
+	if audio, ok := filter["audio_language"].(string); ok && audio != "" {
+		if len(args) > 0 {
+			query += ` AND audio_languages LIKE ?`
+		} else {
+			query += ` WHERE audio_languages LIKE ?`
+		}
+		args = append(args, "%"+audio+"%")
+	}
+
+	if tag, ok := filter["release_tag"].(string); ok && tag != "" {
+		if len(args) > 0 {
+			query += ` AND release_tags LIKE ?`
+		} else {
+			query += ` WHERE release_tags LIKE ?`
+		}
+		args = append(args, "%"+tag+"%")
+	}
+
+	if hdr, ok := filter["hdr"].(string); ok && hdr != "" {
+		if len(args) > 0 {
+			query += ` AND hdr_format LIKE ?`
+		} else {
+			query += ` WHERE hdr_format LIKE ?`
+		}
+		args = append(args, "%"+hdr+"%")
+	}
+
+	if plotSource, ok := filter["plot_source"].(string); ok && plotSource != "" {
+		if len(args) > 0 {
+			query += ` AND plot_source = ?`
+		} else {
+			query += ` WHERE plot_source = ?`
+		}
+		args = append(args, plotSource)
+	}
+
+	// 只列出没有国家信息、按missing_country处理的记录
+	if fallback, ok := filter["country_fallback"].(bool); ok && fallback {
+		if len(args) > 0 {
+			query += ` AND COALESCE(country_fallback, '') != ?`
+		} else {
+			query += ` WHERE COALESCE(country_fallback, '') != ?`
+		}
+		args = append(args, "")
+	}
+
+	// 只列出由db lock锁定的记录
+	if locked, ok := filter["locked"].(bool); ok && locked {
+		if len(args) > 0 {
+			query += ` AND locked = ?`
+		} else {
+			query += ` WHERE locked = ?`
+		}
+		args = append(args, true)
+	}
+
+	// 按标题、原标题、标题拼音（忽略空格）或拼音首字母搜索
+	if keyword, ok := filter["search"].(string); ok && keyword != "" {
+		if len(args) > 0 {
+			query += ` AND`
+		} else {
+			query += ` WHERE`
+		}
+		query += ` (title LIKE ? OR original_title LIKE ? OR REPLACE(title_pinyin, ' ', '') LIKE ? OR title_initials LIKE ?)`
+		compact := "%" + strings.ToLower(strings.ReplaceAll(keyword, " ", "")) + "%"
+		args = append(args, "%"+keyword+"%", "%"+keyword+"%", compact, compact)
+	}
+
+	// 最后更新时间早于指定时间的记录，按更新时间从早到晚排序
+	if before, ok := filter["updated_before"].(time.Time); ok {
+		if len(args) > 0 {
+			query += ` AND COALESCE(updated_at, processed_at) < ?`
+		} else {
+			query += ` WHERE COALESCE(updated_at, processed_at) < ?`
+		}
+		args = append(args, before)
+		query += ` ORDER BY COALESCE(updated_at, processed_at)`
+	} else if order, ok := filter["order"].(string); ok && order == "pinyin" {
+		// 按标题拼音排序，而不是按汉字的Unicode码点
+		query += ` ORDER BY title_pinyin, title, year`
+	}
+
 	rows, err := DB.Query(query, args...)
 	if err != nil {
 		return nil, err
@@ -650,31 +1146,43 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 
 	// 定义临时结构体，用于处理可能为NULL的字段
 	type tempMediaRecord struct {
-		ID            int
-		FileName      *string
-		Title         *string
-		OriginalTitle *string
-		Year          *string
-		Country       *string
-		Genres        *string
-		Actors        *string
-		Category      *string
-		SourcePath    *string
-		TargetPath    *string
-		ProcessedAt   time.Time
-		UpdatedAt     *time.Time
-		Runtime       *string
-		Plot          *string
-		IMDbID        *string
-		TMDbID        *string
-		Season        *string
-		Episode       *string
-		Director      *string
-		Writer        *string
-		Rating        *string
-		Resolution    *string
-		Version       *int
-		IsComplete    *bool
+		ID               int
+		FileName         *string
+		Title            *string
+		OriginalTitle    *string
+		Year             *string
+		Country          *string
+		Genres           *string
+		Actors           *string
+		Category         *string
+		SourcePath       *string
+		TargetPath       *string
+		ProcessedAt      time.Time
+		UpdatedAt        *time.Time
+		Runtime          *string
+		Plot             *string
+		IMDbID           *string
+		TMDbID           *string
+		Season           *int
+		Episode          *int
+		Director         *string
+		Writer           *string
+		Rating           *string
+		Resolution       *string
+		Version          *int
+		IsComplete       *bool
+		OriginalLanguage *string
+		SpokenLanguages  *string
+		AudioLanguages   *string
+		ReleaseTags      *string
+		HDRFormat        *string
+		PlotSource       *string
+		TitlePinyin      *string
+		TitleInitials    *string
+		Edition          *string
+		CountryFallback  *string
+		FieldSources     *string
+		Locked           *bool
 	}
 
 	for rows.Next() {
@@ -706,6 +1214,18 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 			&temp.Resolution,
 			&temp.Version,
 			&temp.IsComplete,
+			&temp.OriginalLanguage,
+			&temp.SpokenLanguages,
+			&temp.AudioLanguages,
+			&temp.ReleaseTags,
+			&temp.HDRFormat,
+			&temp.PlotSource,
+			&temp.TitlePinyin,
+			&temp.TitleInitials,
+			&temp.Edition,
+			&temp.CountryFallback,
+			&temp.FieldSources,
+			&temp.Locked,
 		); err != nil {
 			return nil, err
 		}
@@ -765,12 +1285,8 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 		if temp.TMDbID != nil {
 			record.TMDbID = *temp.TMDbID
 		}
-		if temp.Season != nil {
-			record.Season = *temp.Season
-		}
-		if temp.Episode != nil {
-			record.Episode = *temp.Episode
-		}
+		record.Season = temp.Season
+		record.Episode = temp.Episode
 		if temp.Director != nil {
 			record.Director = *temp.Director
 		}
@@ -795,6 +1311,42 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 			// 如果is_complete为NULL，使用默认值false
 			record.IsComplete = false
 		}
+		if temp.OriginalLanguage != nil {
+			record.OriginalLanguage = *temp.OriginalLanguage
+		}
+		if temp.SpokenLanguages != nil {
+			record.SpokenLanguages = *temp.SpokenLanguages
+		}
+		if temp.AudioLanguages != nil {
+			record.AudioLanguages = *temp.AudioLanguages
+		}
+		if temp.ReleaseTags != nil {
+			record.ReleaseTags = *temp.ReleaseTags
+		}
+		if temp.HDRFormat != nil {
+			record.HDRFormat = *temp.HDRFormat
+		}
+		if temp.PlotSource != nil {
+			record.PlotSource = *temp.PlotSource
+		}
+		if temp.TitlePinyin != nil {
+			record.TitlePinyin = *temp.TitlePinyin
+		}
+		if temp.TitleInitials != nil {
+			record.TitleInitials = *temp.TitleInitials
+		}
+		if temp.Edition != nil {
+			record.Edition = *temp.Edition
+		}
+		if temp.CountryFallback != nil {
+			record.CountryFallback = *temp.CountryFallback
+		}
+		if temp.FieldSources != nil {
+			record.FieldSources = *temp.FieldSources
+		}
+		if temp.Locked != nil {
+			record.Locked = *temp.Locked
+		}
 
 		mediaRecords = append(mediaRecords, record)
 	}
@@ -806,5 +1358,6 @@ func GetMediaRecords(filter map[string]interface{}) ([]MediaRecord, error) {
 func CloseDatabase() {
 	if DB != nil {
 		DB.Close()
+		DB = nil
 	}
 }
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DecisionRecord 一次分类决策的记录，Data为分类时使用的输入和结果（JSON），用于replay离线重现
+type DecisionRecord struct {
+	ID        int       `db:"id"`
+	RunID     string    `db:"run_id"`
+	MediaDir  string    `db:"media_dir"`
+	Data      string    `db:"data"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// RecordedRun 保存了分类决策记录的一次运行
+type RecordedRun struct {
+	RunID string
+	Count int
+}
+
+// createDecisionRecordsTable 创建分类决策记录表
+func createDecisionRecordsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS decision_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id TEXT,
+		media_dir TEXT,
+		data TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_decision_records_run_id ON decision_records (run_id);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建分类决策记录表: %w", err)
+	}
+	return nil
+}
+
+// SaveDecisionRecord 保存当前运行中一个影片目录的分类决策记录
+func SaveDecisionRecord(mediaDir string, data string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+	if currentRunID == "" {
+		StartRun()
+	}
+
+	if _, err := DB.Exec(`INSERT INTO decision_records (run_id, media_dir, data, created_at) VALUES (?, ?, ?, ?)`,
+		currentRunID, mediaDir, data, time.Now()); err != nil {
+		return fmt.Errorf("保存分类决策记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetDecisionRecords 获取一次运行的分类决策记录，按处理顺序排列
+func GetDecisionRecords(runID string) ([]DecisionRecord, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT id, run_id, media_dir, data, created_at FROM decision_records WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询分类决策记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []DecisionRecord
+	for rows.Next() {
+		var record DecisionRecord
+		if err := rows.Scan(&record.ID, &record.RunID, &record.MediaDir, &record.Data, &record.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取分类决策记录失败: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetRecordedRuns 获取保存了分类决策记录的运行，最新的运行排在前面
+func GetRecordedRuns() ([]RecordedRun, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT run_id, COUNT(*) FROM decision_records GROUP BY run_id ORDER BY run_id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("查询分类决策记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []RecordedRun
+	for rows.Next() {
+		var run RecordedRun
+		if err := rows.Scan(&run.RunID, &run.Count); err != nil {
+			return nil, fmt.Errorf("读取分类决策记录失败: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// PruneDecisionRecords 只保留最近keepRuns次运行的分类决策记录，返回删除的记录数
+func PruneDecisionRecords(keepRuns int) (int64, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`
+	DELETE FROM decision_records WHERE run_id NOT IN (
+		SELECT DISTINCT run_id FROM decision_records ORDER BY run_id DESC LIMIT ?
+	)`, keepRuns)
+	if err != nil {
+		return 0, fmt.Errorf("清理分类决策记录失败: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// dryRunSuffix 模拟运行时使用的数据库副本的文件名后缀
+const dryRunSuffix = ".dry-run"
+
+// dryRunPath 模拟运行时使用的数据库副本路径，为空时读写数据库文件
+var dryRunPath string
+
+// SetDryRun 关闭当前的数据库连接，之后的操作使用数据库文件的副本：可以读取已有的记录，写入不会修改数据库文件
+// 副本保存在数据库文件旁边，每次模拟运行时重新复制，由CleanupDryRun删除
+func SetDryRun() error {
+	CloseDatabase()
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return err
+	}
+	copyPath := dbPath + dryRunSuffix
+	removeDatabaseFiles(copyPath)
+	if err := copyDatabaseFile(dbPath, copyPath); err != nil {
+		return fmt.Errorf("复制数据库失败: %w", err)
+	}
+	dryRunPath = copyPath
+	currentRunID = ""
+	return nil
+}
+
+// IsDryRun 判断是否正在使用模拟运行的数据库副本
+func IsDryRun() bool {
+	return dryRunPath != ""
+}
+
+// CleanupDryRun 关闭数据库连接并删除模拟运行使用的数据库副本
+func CleanupDryRun() {
+	if dryRunPath == "" {
+		return
+	}
+	CloseDatabase()
+	removeDatabaseFiles(dryRunPath)
+	dryRunPath = ""
+}
+
+// copyDatabaseFile 复制数据库文件，数据库文件还不存在时不复制，副本为空数据库
+func copyDatabaseFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		os.Remove(dst)
+		return err
+	}
+	return dstFile.Close()
+}
+
+// removeDatabaseFiles 删除数据库文件及SQLite的日志文件
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
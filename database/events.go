@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 媒体记录的历史事件
+const (
+	EventScraped            = "scraped"             // 刮削完成，开始处理NFO
+	EventGenreTranslated    = "genre-translated"    // 翻译或整理了类型字段
+	EventMoved              = "moved"               // 移动到媒体库
+	EventSeasonAdded        = "season-added"        // 新的季合并到已有剧集目录
+	EventUpgraded           = "upgraded"            // 新版本替换了媒体库中的旧版本
+	EventVerified           = "verified"            // 确认或更新了媒体库中的目录
+	EventEdited             = "edited"              // 通过db update修改了记录
+	EventRefreshed          = "refreshed"           // 从TMDB刷新了元数据
+	EventCategorySet        = "category-set"        // 通过classify set手动指定了分类
+	EventLocked             = "locked"              // 通过db lock锁定了记录
+	EventUnlocked           = "unlocked"            // 通过db unlock解锁了记录
+	EventResolutionMismatch = "resolution-mismatch" // 各视频文件名标注的分辨率不一致
+	EventNFORegenerated     = "nfo-regenerated"     // 通过nfo regenerate重新生成了NFO文件
+)
+
+// eventNames 事件的中文名称
+var eventNames = map[string]string{
+	EventScraped:            "刮削",
+	EventGenreTranslated:    "翻译类型",
+	EventMoved:              "移动",
+	EventSeasonAdded:        "新增季",
+	EventUpgraded:           "升级版本",
+	EventVerified:           "确认目录",
+	EventEdited:             "修改记录",
+	EventRefreshed:          "刷新元数据",
+	EventCategorySet:        "手动分类",
+	EventLocked:             "锁定",
+	EventUnlocked:           "解锁",
+	EventResolutionMismatch: "分辨率不一致",
+	EventNFORegenerated:     "重新生成NFO",
+}
+
+// EventName 返回事件的中文名称
+func EventName(event string) string {
+	if name, ok := eventNames[event]; ok {
+		return name
+	}
+	return event
+}
+
+// Event 媒体记录的一条历史事件
+type Event struct {
+	ID         int       `db:"id"`
+	MediaID    int       `db:"media_id"`    // 所属的媒体记录，写入记录之前发生的事件为0
+	SourcePath string    `db:"source_path"` // 事件发生时的影片目录，写入记录时用来关联之前的事件
+	Event      string    `db:"event"`
+	Detail     string    `db:"detail"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// createEventsTable 创建媒体记录历史事件表
+func createEventsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS media_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_id INTEGER,
+		source_path TEXT,
+		event TEXT,
+		detail TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_events_media_id ON media_events (media_id);
+	CREATE INDEX IF NOT EXISTS idx_media_events_source_path ON media_events (source_path) WHERE media_id IS NULL;`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建历史事件表: %w", err)
+	}
+	return nil
+}
+
+// RecordEvent 记录媒体记录的一条历史事件
+// 写入媒体记录之前发生的事件mediaID为0，按sourcePath保存，写入记录后由AttachEvents关联
+func RecordEvent(mediaID int, sourcePath string, event string, detail string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	var id interface{}
+	if mediaID > 0 {
+		id = mediaID
+	}
+	_, err := DB.Exec(`INSERT INTO media_events (media_id, source_path, event, detail, created_at) VALUES (?, ?, ?, ?, ?)`,
+		id, sourcePath, event, detail, time.Now())
+	if err != nil {
+		return fmt.Errorf("记录历史事件失败: %w", err)
+	}
+	return nil
+}
+
+// CountEventsSince 统计since之后发生的指定类型的历史事件数量
+func CountEventsSince(since time.Time, events ...string) (int, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	query := `SELECT COUNT(*) FROM media_events WHERE created_at >= ? AND event IN (?` + strings.Repeat(", ?", len(events)-1) + `)`
+	args := []interface{}{since}
+	for _, event := range events {
+		args = append(args, event)
+	}
+	var count int
+	if err := DB.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计历史事件失败: %w", err)
+	}
+	return count, nil
+}
+
+// AttachEvents 将影片目录在写入媒体记录之前发生的事件关联到媒体记录
+func AttachEvents(sourcePath string, mediaID int) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(`UPDATE media_events SET media_id = ? WHERE media_id IS NULL AND source_path = ?`, mediaID, sourcePath); err != nil {
+		return fmt.Errorf("关联历史事件失败: %w", err)
+	}
+	return nil
+}
+
+// GetEvents 获取媒体记录的历史事件，按发生时间排序
+func GetEvents(mediaID int) ([]Event, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT id, media_id, source_path, event, detail, created_at FROM media_events WHERE media_id = ? ORDER BY created_at, id`, mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("获取历史事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var sourcePath, detail sql.NullString
+		if err := rows.Scan(&e.ID, &e.MediaID, &sourcePath, &e.Event, &detail, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取历史事件失败: %w", err)
+		}
+		e.SourcePath, e.Detail = sourcePath.String, detail.String
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lastMaintenanceKey 保存上次数据库维护时间的运行状态键
+const lastMaintenanceKey = "last_db_maintenance"
+
+// Stats 数据库文件的大小和碎片情况
+type Stats struct {
+	Size      int64            // 数据库文件大小（字节），内存数据库为0
+	PageSize  int64            // 页大小（字节）
+	PageCount int64            // 总页数
+	FreePages int64            // 空闲页数，VACUUM后回收
+	TableRows map[string]int64 // 各表的记录数
+}
+
+// Fragmentation 返回空闲页占总页数的百分比
+func (s *Stats) Fragmentation() float64 {
+	if s.PageCount == 0 {
+		return 0
+	}
+	return float64(s.FreePages) / float64(s.PageCount) * 100
+}
+
+// MaintenanceResult 一次数据库维护的结果
+type MaintenanceResult struct {
+	Before    *Stats
+	After     *Stats
+	Problems  []string // integrity_check发现的问题，为空表示完整
+	Vacuumed  bool     // 是否执行了VACUUM和ANALYZE，完整性检查失败时不执行，避免在损坏的数据库上重写数据
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// GetStats 读取数据库文件大小、页数、空闲页数和各表的记录数
+func GetStats() (*Stats, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{TableRows: make(map[string]int64)}
+	for pragma, value := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := DB.QueryRow(`PRAGMA ` + pragma).Scan(value); err != nil {
+			return nil, fmt.Errorf("读取数据库%s失败: %w", pragma, err)
+		}
+	}
+
+	if !inMemory {
+		if dbPath, err := GetDatabasePath(); err == nil {
+			if info, err := os.Stat(dbPath); err == nil {
+				stats.Size = info.Size()
+			}
+		}
+	}
+
+	rows, err := DB.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据库表失败: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("读取数据库表失败: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		var count int64
+		if err := DB.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&count); err != nil {
+			return nil, fmt.Errorf("统计表 %s 的记录数失败: %w", table, err)
+		}
+		stats.TableRows[table] = count
+	}
+	return stats, nil
+}
+
+// IntegrityCheck 执行PRAGMA integrity_check，返回发现的问题，数据库完整时返回空
+func IntegrityCheck() ([]string, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("检查数据库完整性失败: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("检查数据库完整性失败: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Maintain 检查数据库完整性，完整时执行VACUUM回收空闲页、ANALYZE更新查询优化统计，并记录维护时间
+func Maintain() (*MaintenanceResult, error) {
+	result := &MaintenanceResult{StartedAt: time.Now()}
+	before, err := GetStats()
+	if err != nil {
+		return nil, err
+	}
+	result.Before = before
+
+	if result.Problems, err = IntegrityCheck(); err != nil {
+		return nil, err
+	}
+	if len(result.Problems) == 0 {
+		if _, err := DB.Exec(`VACUUM`); err != nil {
+			return nil, fmt.Errorf("执行VACUUM失败: %w", err)
+		}
+		if _, err := DB.Exec(`ANALYZE`); err != nil {
+			return nil, fmt.Errorf("执行ANALYZE失败: %w", err)
+		}
+		result.Vacuumed = true
+	}
+
+	if result.After, err = GetStats(); err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(result.StartedAt)
+	if err := SetRunState(lastMaintenanceKey, result.StartedAt.Format(time.RFC3339)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// MaintenanceDue 判断距离上次数据库维护是否已超过intervalDays天，intervalDays小于等于0时不自动维护
+func MaintenanceDue(intervalDays int) (bool, error) {
+	if intervalDays <= 0 {
+		return false, nil
+	}
+	value, err := GetRunState(lastMaintenanceKey)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return true, nil
+	}
+	last, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return true, nil
+	}
+	return time.Since(last) >= time.Duration(intervalDays)*24*time.Hour, nil
+}
+
+// Schema 数据库的表结构和SQLite版本，用于问题报告
+type Schema struct {
+	SQLiteVersion string   `json:"sqlite_version"`
+	Version       string   `json:"version"`    // 表结构的指纹（建表语句的SHA-256前12位），表结构相同的数据库指纹相同
+	Statements    []string `json:"statements"` // 表和索引的建表语句
+}
+
+// GetSchema 读取数据库的建表语句并计算表结构的指纹
+// 表结构由每次启动时的建表和补充字段迁移决定，没有单独的版本号，用指纹区分不同版本程序创建或升级的数据库
+func GetSchema() (*Schema, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	schema := &Schema{}
+	if err := DB.QueryRow(`SELECT sqlite_version()`).Scan(&schema.SQLiteVersion); err != nil {
+		return nil, fmt.Errorf("读取SQLite版本失败: %w", err)
+	}
+
+	rows, err := DB.Query(`SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type DESC, name`)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据库表结构失败: %w", err)
+	}
+	defer rows.Close()
+
+	hash := sha256.New()
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return nil, fmt.Errorf("读取数据库表结构失败: %w", err)
+		}
+		schema.Statements = append(schema.Statements, statement)
+		hash.Write([]byte(statement + ";\n"))
+	}
+	schema.Version = hex.EncodeToString(hash.Sum(nil))[:12]
+	return schema, rows.Err()
+}
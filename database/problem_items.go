@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// 问题项目的状态
+const (
+	ProblemStatusOpen     = "open"     // 仍未解决
+	ProblemStatusResolved = "resolved" // 已正常处理
+	ProblemStatusMoved    = "moved"    // 已移动到未分类目录
+	ProblemStatusGuessed  = "guessed"  // 已按文件名推测分类并移动（低置信度）
+)
+
+// ProblemItem 表示Temp目录中无法正常处理的项目（如未刮削、元数据不完整）
+type ProblemItem struct {
+	ID          int       `db:"id"`
+	Path        string    `db:"path"`
+	Reason      string    `db:"reason"`
+	FirstSeenAt time.Time `db:"first_seen_at"`
+	LastSeenAt  time.Time `db:"last_seen_at"`
+	Attempts    int       `db:"attempts"`
+	Status      string    `db:"status"`
+}
+
+// createProblemItemsTable 创建问题项目表
+func createProblemItemsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS problem_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT UNIQUE,
+		reason TEXT,
+		first_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		attempts INTEGER DEFAULT 0,
+		status TEXT DEFAULT 'open'
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建问题项目表: %w", err)
+	}
+	return nil
+}
+
+// RecordProblemItem 记录一次问题项目，已存在时更新原因、最后出现时间和尝试次数
+// 返回最新的记录，FirstSeenAt保持为第一次发现的时间
+func RecordProblemItem(path string, reason string) (*ProblemItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	item, err := GetProblemItem(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		insertSQL := `
+		INSERT INTO problem_items (path, reason, first_seen_at, last_seen_at, attempts, status) 
+		VALUES (?, ?, ?, ?, ?, ?)`
+		if _, err := DB.Exec(insertSQL, path, reason, now, now, 1, ProblemStatusOpen); err != nil {
+			return nil, err
+		}
+	} else {
+		// 已解决的项目再次出现时重新打开，并重新计时
+		firstSeenAt := item.FirstSeenAt
+		if item.Status != ProblemStatusOpen {
+			firstSeenAt = now
+		}
+		updateSQL := `
+		UPDATE problem_items SET 
+			reason = ?, 
+			first_seen_at = ?, 
+			last_seen_at = ?, 
+			attempts = attempts + 1, 
+			status = ? 
+		WHERE id = ?`
+		if _, err := DB.Exec(updateSQL, reason, firstSeenAt, now, ProblemStatusOpen, item.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return GetProblemItem(path)
+}
+
+// GetProblemItem 根据路径获取问题项目，不存在时返回nil
+func GetProblemItem(path string) (*ProblemItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	var item ProblemItem
+	query := `SELECT id, path, reason, first_seen_at, last_seen_at, attempts, status FROM problem_items WHERE path = ?`
+	err := DB.QueryRow(query, path).Scan(&item.ID, &item.Path, &item.Reason, &item.FirstSeenAt, &item.LastSeenAt, &item.Attempts, &item.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// UpdateProblemItemStatus 更新问题项目的状态，项目不存在时不做任何操作
+func UpdateProblemItemStatus(path string, status string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	updateSQL := `UPDATE problem_items SET status = ?, last_seen_at = ? WHERE path = ?`
+	_, err := DB.Exec(updateSQL, status, time.Now(), path)
+	return err
+}
+
+// GetProblemItems 获取问题项目列表
+func GetProblemItems(filter map[string]interface{}) ([]ProblemItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	var items []ProblemItem
+	query := `SELECT id, path, reason, first_seen_at, last_seen_at, attempts, status FROM problem_items`
+
+	// 添加过滤条件
+	var args []interface{}
+	if status, ok := filter["status"].(string); ok && status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item ProblemItem
+		if err := rows.Scan(&item.ID, &item.Path, &item.Reason, &item.FirstSeenAt, &item.LastSeenAt, &item.Attempts, &item.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
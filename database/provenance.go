@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 记录来源的字段
+const (
+	FieldTitle            = "title"
+	FieldCountry          = "country"
+	FieldGenres           = "genres"
+	FieldOriginalLanguage = "original_language"
+	FieldCategory         = "category"
+)
+
+// provenanceFields 按显示顺序排列的记录来源的字段
+var provenanceFields = []string{FieldTitle, FieldCountry, FieldGenres, FieldOriginalLanguage, FieldCategory}
+
+// 字段的来源，元数据插件和分类插件为 plugin:插件名（如 plugin:douban）
+const (
+	SourceNFO            = "nfo"             // 刮削生成的NFO文件
+	SourceTMDB           = "tmdb"            // TMDB详情
+	SourceBangumi        = "bangumi"         // Bangumi补充的中文标题
+	SourceRules          = "rules"           // 按国家、类型和语言的内置分类规则
+	SourceMissingCountry = "missing_country" // 没有国家信息时按missing_country配置确定的分类
+	SourceManual         = "manual"          // db update手动修改
+)
+
+// PluginSource 返回插件提供的字段的来源
+func PluginSource(name string) string {
+	return "plugin:" + name
+}
+
+// FieldSources 媒体记录各字段的来源，键为FieldTitle等字段名
+// 保存到数据库时格式为 title=nfo,country=tmdb,category=rules
+type FieldSources map[string]string
+
+// ParseFieldSources 解析数据库中保存的字段来源，忽略格式不正确的部分
+func ParseFieldSources(value string) FieldSources {
+	sources := FieldSources{}
+	for _, pair := range strings.Split(value, ",") {
+		field, source, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && field != "" && source != "" {
+			sources[field] = source
+		}
+	}
+	return sources
+}
+
+// Set 记录字段的来源，source为空时删除该字段的记录（字段没有值）
+func (s FieldSources) Set(field, source string) {
+	if source == "" {
+		delete(s, field)
+		return
+	}
+	s[field] = source
+}
+
+// String 按固定的字段顺序返回保存到数据库的格式，未知的字段按名称排在后面
+func (s FieldSources) String() string {
+	pairs := make([]string, 0, len(s))
+	for _, field := range provenanceFields {
+		if source, ok := s[field]; ok {
+			pairs = append(pairs, field+"="+source)
+		}
+	}
+	var others []string
+	for field, source := range s {
+		if !isProvenanceField(field) {
+			others = append(others, field+"="+source)
+		}
+	}
+	sort.Strings(others)
+	return strings.Join(append(pairs, others...), ",")
+}
+
+// isProvenanceField 判断字段是否在provenanceFields中
+func isProvenanceField(field string) bool {
+	for _, f := range provenanceFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// manualSources 返回db update修改fields后的字段来源，修改的字段记录为manual；没有修改记录来源的字段时ok为false
+func manualSources(id int, fields map[string]string) (value string, ok bool, err error) {
+	var changed []string
+	for field := range fields {
+		if isProvenanceField(field) {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
+		return "", false, nil
+	}
+
+	var current sql.NullString
+	if err := DB.QueryRow(`SELECT field_sources FROM media_records WHERE id = ?`, id).Scan(&current); err != nil && err != sql.ErrNoRows {
+		return "", false, fmt.Errorf("读取字段来源失败: %w", err)
+	}
+	sources := ParseFieldSources(current.String)
+	for _, field := range changed {
+		sources.Set(field, SourceManual)
+	}
+	return sources.String(), true, nil
+}
+
+// GetManualCategory 返回手动指定过分类（来源为manual）的记录的分类，按目标路径或相同类型（电影、电视剧）的TMDB ID查找
+// 没有手动指定过时返回空字符串
+func GetManualCategory(targetPath string, tmdbID string, isTVShow bool) (string, error) {
+	if err := InitDatabase(); err != nil {
+		return "", err
+	}
+
+	kindCond := `category NOT LIKE '%Show'`
+	if isTVShow {
+		kindCond = `category LIKE '%Show'`
+	}
+	query := `SELECT category FROM media_records
+		WHERE (',' || COALESCE(field_sources, '') || ',') LIKE ?
+		AND (target_path = ? OR (? != '' AND tmdb_id = ? AND ` + kindCond + `))
+		ORDER BY target_path = ? DESC, COALESCE(updated_at, processed_at) DESC LIMIT 1`
+	var category string
+	err := DB.QueryRow(query, "%,"+FieldCategory+"="+SourceManual+",%", targetPath, tmdbID, tmdbID, targetPath).Scan(&category)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询手动指定的分类失败: %w", err)
+	}
+	return category, nil
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 队列项目的状态
+const (
+	QueueStatusPending    = "pending"    // 等待处理
+	QueueStatusProcessing = "processing" // 正在处理
+	QueueStatusDone       = "done"       // 处理完成
+	QueueStatusFailed     = "failed"     // 处理失败
+)
+
+// 队列优先级，数值越大越先处理
+const (
+	QueuePriorityReprocess = 10 // 之前处理过但仍留在Temp目录的项目
+	QueuePriorityMovie     = 20 // 新发现的电影
+	QueuePriorityTVShow    = 30 // 新发现的电视剧（通常是新的季或剧集）
+)
+
+// QueueItem 表示处理队列中的一个NFO文件
+type QueueItem struct {
+	ID         int       `db:"id"`
+	NFOPath    string    `db:"nfo_path"`
+	Priority   int       `db:"priority"`
+	Status     string    `db:"status"`
+	Attempts   int       `db:"attempts"`
+	LastError  string    `db:"last_error"`
+	EnqueuedAt time.Time `db:"enqueued_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// createQueueTable 创建处理队列表
+func createQueueTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS queue_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nfo_path TEXT UNIQUE,
+		priority INTEGER DEFAULT 0,
+		status TEXT DEFAULT 'pending',
+		attempts INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		enqueued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建处理队列表: %w", err)
+	}
+	return nil
+}
+
+// queueColumns 查询队列项目时使用的字段
+const queueColumns = `id, nfo_path, priority, status, attempts, last_error, enqueued_at, updated_at`
+
+// scanQueueItem 扫描一行队列项目
+func scanQueueItem(scanner interface{ Scan(...interface{}) error }) (*QueueItem, error) {
+	var item QueueItem
+	if err := scanner.Scan(&item.ID, &item.NFOPath, &item.Priority, &item.Status, &item.Attempts, &item.LastError, &item.EnqueuedAt, &item.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// EnqueueItem 将NFO文件加入处理队列
+// 已在队列中等待的项目保留较高的优先级；已处理过的项目重新入队时使用重新处理优先级
+func EnqueueItem(nfoPath string, priority int) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	item, err := GetQueueItem(nfoPath)
+	if err != nil {
+		return err
+	}
+
+	if item == nil {
+		insertSQL := `
+		INSERT INTO queue_items (nfo_path, priority, status, attempts, last_error, enqueued_at, updated_at)
+		VALUES (?, ?, ?, 0, '', ?, ?)`
+		if _, err := DB.Exec(insertSQL, nfoPath, priority, QueueStatusPending, now, now); err != nil {
+			return fmt.Errorf("加入处理队列失败: %w", err)
+		}
+		return nil
+	}
+
+	switch item.Status {
+	case QueueStatusPending, QueueStatusProcessing:
+		if priority > item.Priority {
+			if _, err := DB.Exec(`UPDATE queue_items SET priority = ?, updated_at = ? WHERE id = ?`, priority, now, item.ID); err != nil {
+				return fmt.Errorf("更新队列优先级失败: %w", err)
+			}
+		}
+	default:
+		updateSQL := `UPDATE queue_items SET priority = ?, status = ?, enqueued_at = ?, updated_at = ? WHERE id = ?`
+		if _, err := DB.Exec(updateSQL, QueuePriorityReprocess, QueueStatusPending, now, now, item.ID); err != nil {
+			return fmt.Errorf("重新加入处理队列失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetQueueItem 根据NFO路径获取队列项目，不存在时返回nil
+func GetQueueItem(nfoPath string) (*QueueItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	item, err := scanQueueItem(DB.QueryRow(`SELECT `+queueColumns+` FROM queue_items WHERE nfo_path = ?`, nfoPath))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取队列项目失败: %w", err)
+	}
+	return item, nil
+}
+
+// NextQueueItem 取出优先级最高的等待项目并标记为正在处理，队列为空时返回nil
+// 相同优先级按入队时间先后处理；指定目录时只取这些目录下的项目
+func NextQueueItem(dirs ...string) (*QueueItem, error) {
+	return NextMatchingQueueItem(nil, dirs...)
+}
+
+// NextMatchingQueueItem 与NextQueueItem相同，但只取出match返回true的项目，match为nil时不限制
+// 不匹配的项目保持等待状态，留给之后不限制处理范围的运行
+func NextMatchingQueueItem(match func(nfoPath string) bool, dirs ...string) (*QueueItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + queueColumns + ` FROM queue_items WHERE status = ?`
+	args := []interface{}{QueueStatusPending}
+	if len(dirs) > 0 {
+		var conditions []string
+		for _, dir := range dirs {
+			prefix := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+			conditions = append(conditions, `substr(nfo_path, 1, ?) = ?`)
+			args = append(args, utf8.RuneCountInString(prefix), prefix)
+		}
+		query += ` AND (` + strings.Join(conditions, " OR ") + `)`
+	}
+	query += ` ORDER BY priority DESC, enqueued_at, id`
+
+	var item *QueueItem
+	if match == nil {
+		var err error
+		item, err = scanQueueItem(DB.QueryRow(query+` LIMIT 1`, args...))
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("获取下一个队列项目失败: %w", err)
+		}
+	} else {
+		rows, err := DB.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("获取下一个队列项目失败: %w", err)
+		}
+		var pending []*QueueItem
+		for rows.Next() {
+			candidate, err := scanQueueItem(rows)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("获取下一个队列项目失败: %w", err)
+			}
+			pending = append(pending, candidate)
+		}
+		rows.Close()
+		for _, candidate := range pending {
+			if match(candidate.NFOPath) {
+				item = candidate
+				break
+			}
+		}
+		if item == nil {
+			return nil, nil
+		}
+	}
+
+	updateSQL := `UPDATE queue_items SET status = ?, attempts = attempts + 1, updated_at = ? WHERE id = ?`
+	if _, err := DB.Exec(updateSQL, QueueStatusProcessing, time.Now(), item.ID); err != nil {
+		return nil, fmt.Errorf("更新队列项目状态失败: %w", err)
+	}
+	item.Status = QueueStatusProcessing
+	item.Attempts++
+	return item, nil
+}
+
+// CompleteQueueItem 记录队列项目的处理结果，err为nil表示处理完成
+func CompleteQueueItem(id int, processErr error) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	status, lastError := QueueStatusDone, ""
+	if processErr != nil {
+		status, lastError = QueueStatusFailed, processErr.Error()
+	}
+
+	updateSQL := `UPDATE queue_items SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`
+	if _, err := DB.Exec(updateSQL, status, lastError, time.Now(), id); err != nil {
+		return fmt.Errorf("更新队列项目状态失败: %w", err)
+	}
+	return nil
+}
+
+// RetryQueueItem 记录正在处理的项目的一次失败，项目保持处理中状态，处理次数加一
+func RetryQueueItem(id int, processErr error) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	updateSQL := `UPDATE queue_items SET attempts = attempts + 1, last_error = ?, updated_at = ? WHERE id = ?`
+	if _, err := DB.Exec(updateSQL, processErr.Error(), time.Now(), id); err != nil {
+		return fmt.Errorf("更新队列项目重试次数失败: %w", err)
+	}
+	return nil
+}
+
+// ReleaseQueueItem 将正在处理的项目放回等待状态，用于媒体库暂时不可写入等与项目本身无关的失败，下次运行时重新处理
+func ReleaseQueueItem(id int, processErr error) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	updateSQL := `UPDATE queue_items SET status = ?, last_error = ?, updated_at = ? WHERE id = ?`
+	if _, err := DB.Exec(updateSQL, QueueStatusPending, processErr.Error(), time.Now(), id); err != nil {
+		return fmt.Errorf("更新队列项目状态失败: %w", err)
+	}
+	return nil
+}
+
+// ResetStaleQueueItems 将上次异常退出时遗留的正在处理项目恢复为等待状态
+func ResetStaleQueueItems() (int, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`UPDATE queue_items SET status = ?, updated_at = ? WHERE status = ?`,
+		QueueStatusPending, time.Now(), QueueStatusProcessing)
+	if err != nil {
+		return 0, fmt.Errorf("恢复队列项目状态失败: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// GetQueueItems 获取队列项目列表，按处理顺序排列
+func GetQueueItems(filter map[string]interface{}) ([]QueueItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + queueColumns + ` FROM queue_items`
+
+	// 添加过滤条件
+	var args []interface{}
+	if status, ok := filter["status"].(string); ok && status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY priority DESC, enqueued_at, id`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("获取队列项目失败: %w", err)
+	}
+	defer rows.Close()
+
+	var items []QueueItem
+	for rows.Next() {
+		item, err := scanQueueItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// CountQueueItems 按状态统计队列项目的数量
+func CountQueueItems() (map[string]int, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT status, COUNT(*) FROM queue_items GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("统计队列项目失败: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("读取队列统计失败: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// PurgeQueueItems 删除指定状态的队列项目，返回删除数量
+func PurgeQueueItems(status string) (int, error) {
+	if err := InitDatabase(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`DELETE FROM queue_items WHERE status = ?`, status)
+	if err != nil {
+		return 0, fmt.Errorf("清理队列项目失败: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
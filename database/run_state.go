@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// createRunStateTable 创建运行状态表，用于在多次运行之间保存少量键值状态（如处理游标）
+func createRunStateTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS run_state (
+		key TEXT PRIMARY KEY,
+		value TEXT,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建运行状态表: %w", err)
+	}
+	return nil
+}
+
+// GetRunState 获取运行状态，不存在时返回空字符串
+func GetRunState(key string) (string, error) {
+	if err := InitDatabase(); err != nil {
+		return "", err
+	}
+
+	var value string
+	err := DB.QueryRow("SELECT value FROM run_state WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("获取运行状态失败: %w", err)
+	}
+	return value, nil
+}
+
+// SetRunState 保存运行状态
+func SetRunState(key string, value string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`
+	INSERT INTO run_state (key, value, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now())
+	if err != nil {
+		return fmt.Errorf("保存运行状态失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteRunState 删除运行状态
+func DeleteRunState(key string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec("DELETE FROM run_state WHERE key = ?", key); err != nil {
+		return fmt.Errorf("删除运行状态失败: %w", err)
+	}
+	return nil
+}
+
+// runStateLastRunSummary 记录最近一次处理运行汇总的运行状态键
+const runStateLastRunSummary = "last_run_summary"
+
+// RunSummary 一次处理运行的汇总：处理、移动、跳过和失败的项目数
+type RunSummary struct {
+	RunID           string    `json:"run_id"`
+	FinishedAt      time.Time `json:"finished_at"`
+	Processed       int       `json:"processed"`
+	Moved           int       `json:"moved"`
+	Skipped         int       `json:"skipped"`
+	Failed          int       `json:"failed"`
+	DurationSeconds int       `json:"duration_seconds"`
+}
+
+// SaveRunSummary 保存最近一次处理运行的汇总
+func SaveRunSummary(summary RunSummary) error {
+	if summary.RunID == "" {
+		summary.RunID = currentRunID
+	}
+	value, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("保存运行汇总失败: %w", err)
+	}
+	return SetRunState(runStateLastRunSummary, string(value))
+}
+
+// GetLastRunSummary 获取最近一次处理运行的汇总，还没有运行过时返回nil
+func GetLastRunSummary() (*RunSummary, error) {
+	value, err := GetRunState(runStateLastRunSummary)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var summary RunSummary
+	if err := json.Unmarshal([]byte(value), &summary); err != nil {
+		return nil, fmt.Errorf("读取运行汇总失败: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RunTiming 一次运行中某种操作（解析NFO、TMDB请求、写入NFO、移动）的耗时汇总
+type RunTiming struct {
+	RunID     string    `db:"run_id"`
+	Operation string    `db:"operation"`
+	Count     int       `db:"count"`
+	TotalMS   int64     `db:"total_ms"`
+	MaxMS     int64     `db:"max_ms"`
+	SlowCount int       `db:"slow_count"` // 超过慢操作阈值的次数
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// createRunTimingsTable 创建运行耗时统计表
+func createRunTimingsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS run_timings (
+		run_id TEXT,
+		operation TEXT,
+		count INTEGER DEFAULT 0,
+		total_ms INTEGER DEFAULT 0,
+		max_ms INTEGER DEFAULT 0,
+		slow_count INTEGER DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (run_id, operation)
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建运行耗时统计表: %w", err)
+	}
+	return nil
+}
+
+// SaveRunTimings 保存当前运行的耗时汇总，同一次运行多次保存时累加
+func SaveRunTimings(timings []RunTiming) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+	if currentRunID == "" {
+		StartRun()
+	}
+
+	for _, timing := range timings {
+		_, err := DB.Exec(`
+		INSERT INTO run_timings (run_id, operation, count, total_ms, max_ms, slow_count, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, operation) DO UPDATE SET count = count + excluded.count, total_ms = total_ms + excluded.total_ms,
+			max_ms = MAX(max_ms, excluded.max_ms), slow_count = slow_count + excluded.slow_count`,
+			currentRunID, timing.Operation, timing.Count, timing.TotalMS, timing.MaxMS, timing.SlowCount, time.Now())
+		if err != nil {
+			return fmt.Errorf("保存运行耗时统计失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetRunTimings 获取最近limit次运行的耗时汇总，最新的运行排在前面
+func GetRunTimings(limit int) ([]RunTiming, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+	SELECT run_id, operation, count, total_ms, max_ms, slow_count, created_at FROM run_timings
+	WHERE run_id IN (SELECT DISTINCT run_id FROM run_timings ORDER BY run_id DESC LIMIT ?)
+	ORDER BY run_id DESC`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询运行耗时统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var timings []RunTiming
+	for rows.Next() {
+		var timing RunTiming
+		if err := rows.Scan(&timing.RunID, &timing.Operation, &timing.Count, &timing.TotalMS, &timing.MaxMS, &timing.SlowCount, &timing.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取运行耗时统计失败: %w", err)
+		}
+		timings = append(timings, timing)
+	}
+	return timings, rows.Err()
+}
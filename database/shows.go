@@ -0,0 +1,103 @@
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaEntry 按剧集聚合后的一个条目：电影为一条记录，电视剧为同一部剧（相同TMDB ID，没有ID时为相同目录）的所有季
+type MediaEntry struct {
+	Record  MediaRecord   // 代表该条目的记录，电视剧为最近处理的一季
+	Seasons []MediaRecord // 电视剧按季号排序的各季记录，电影为空
+}
+
+// IsShow 判断条目是否为电视剧
+func (e *MediaEntry) IsShow() bool {
+	return len(e.Seasons) > 0
+}
+
+// SeasonNumbers 返回电视剧已有的季号，按从小到大排序，没有季号的记录忽略
+func (e *MediaEntry) SeasonNumbers() []int {
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, season := range e.Seasons {
+		if season.Season == nil || seen[*season.Season] {
+			continue
+		}
+		seen[*season.Season] = true
+		numbers = append(numbers, *season.Season)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// SeasonList 返回季号的简短表示，连续的季合并为范围，如 1-3,5
+func (e *MediaEntry) SeasonList() string {
+	numbers := e.SeasonNumbers()
+	var parts []string
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, strconv.Itoa(numbers[i])+"-"+strconv.Itoa(numbers[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(numbers[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// showKey 返回电视剧记录聚合使用的键，电影返回空字符串
+func showKey(record *MediaRecord) string {
+	if !strings.Contains(record.Category, "Show") {
+		return ""
+	}
+	switch {
+	case record.TMDbID != "":
+		return "tmdb:" + record.TMDbID
+	case record.TargetPath != "":
+		return "path:" + record.TargetPath
+	default:
+		return "title:" + record.Title + "|" + record.Year
+	}
+}
+
+// AggregateShows 把同一部剧的各季记录合并为一个条目，条目保持每部剧第一次出现时的顺序
+func AggregateShows(records []MediaRecord) []MediaEntry {
+	var entries []MediaEntry
+	index := make(map[string]int)
+	for _, record := range records {
+		key := showKey(&record)
+		if key == "" {
+			entries = append(entries, MediaEntry{Record: record})
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(entries)
+			entries = append(entries, MediaEntry{Record: record, Seasons: []MediaRecord{record}})
+			continue
+		}
+		entry := &entries[i]
+		entry.Seasons = append(entry.Seasons, record)
+		if record.ProcessedAt.After(entry.Record.ProcessedAt) {
+			entry.Record = record
+		}
+	}
+
+	for i := range entries {
+		seasons := entries[i].Seasons
+		sort.SliceStable(seasons, func(a, b int) bool {
+			x, y := seasons[a].Season, seasons[b].Season
+			if x == nil || y == nil {
+				return x != nil
+			}
+			return *x < *y
+		})
+	}
+	return entries
+}
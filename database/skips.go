@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// runStateLastRunID 保存最近一次运行ID的运行状态键
+const runStateLastRunID = "last_run_id"
+
+// currentRunID 当前运行的ID，由StartRun设置
+var currentRunID string
+
+// SkipItem 表示一个被门禁规则跳过移动的影片目录，只保留最近一次跳过的原因
+type SkipItem struct {
+	MediaDir  string    `db:"media_dir"`
+	Rule      string    `db:"rule"`
+	Reason    string    `db:"reason"`
+	RunID     string    `db:"run_id"`
+	Count     int       `db:"count"`
+	SkippedAt time.Time `db:"skipped_at"`
+}
+
+// SkipCount 某个规则的跳过次数
+type SkipCount struct {
+	Rule  string
+	Count int
+}
+
+// createSkipItemsTable 创建跳过项目表
+func createSkipItemsTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS skip_items (
+		media_dir TEXT PRIMARY KEY,
+		rule TEXT,
+		reason TEXT,
+		run_id TEXT,
+		count INTEGER DEFAULT 0,
+		skipped_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建跳过项目表: %w", err)
+	}
+
+	// 按规则累计的跳过次数，跳过项目被移动后仍保留
+	createTableSQL = `
+	CREATE TABLE IF NOT EXISTS skip_totals (
+		rule TEXT PRIMARY KEY,
+		count INTEGER DEFAULT 0
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建跳过统计表: %w", err)
+	}
+	return nil
+}
+
+// StartRun 开始一次新的运行，之后记录的跳过原因都归入这次运行
+func StartRun() string {
+	currentRunID = time.Now().Format("20060102-150405")
+	if err := SetRunState(runStateLastRunID, currentRunID); err != nil {
+		fmt.Printf("保存运行ID失败: %v\n", err)
+	}
+	return currentRunID
+}
+
+// LastRunID 返回最近一次运行的ID
+func LastRunID() (string, error) {
+	return GetRunState(runStateLastRunID)
+}
+
+// RecordSkip 记录影片目录被规则跳过
+func RecordSkip(mediaDir, rule, reason string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+	if currentRunID == "" {
+		StartRun()
+	}
+
+	_, err := DB.Exec(`
+	INSERT INTO skip_items (media_dir, rule, reason, run_id, count, skipped_at) VALUES (?, ?, ?, ?, 1, ?)
+	ON CONFLICT(media_dir) DO UPDATE SET rule = excluded.rule, reason = excluded.reason,
+		run_id = excluded.run_id, count = count + 1, skipped_at = excluded.skipped_at`,
+		mediaDir, rule, reason, currentRunID, time.Now())
+	if err != nil {
+		return fmt.Errorf("记录跳过原因失败: %w", err)
+	}
+
+	_, err = DB.Exec(`
+	INSERT INTO skip_totals (rule, count) VALUES (?, 1)
+	ON CONFLICT(rule) DO UPDATE SET count = count + 1`, rule)
+	if err != nil {
+		return fmt.Errorf("更新跳过统计失败: %w", err)
+	}
+	return nil
+}
+
+// ClearSkip 影片目录移动成功后删除其跳过记录
+func ClearSkip(mediaDir string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec("DELETE FROM skip_items WHERE media_dir = ?", mediaDir); err != nil {
+		return fmt.Errorf("删除跳过记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetSkipItems 获取跳过项目列表，runID不为空时只返回该次运行跳过的项目
+func GetSkipItems(runID string) ([]SkipItem, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT media_dir, rule, reason, run_id, count, skipped_at FROM skip_items`
+	var args []interface{}
+	if runID != "" {
+		query += ` WHERE run_id = ?`
+		args = append(args, runID)
+	}
+	query += ` ORDER BY rule, media_dir`
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("获取跳过项目失败: %w", err)
+	}
+	defer rows.Close()
+
+	var items []SkipItem
+	for rows.Next() {
+		var item SkipItem
+		if err := rows.Scan(&item.MediaDir, &item.Rule, &item.Reason, &item.RunID, &item.Count, &item.SkippedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetSkipTotals 获取各规则累计的跳过次数，按次数从多到少排列
+func GetSkipTotals() ([]SkipCount, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT rule, count FROM skip_totals ORDER BY count DESC, rule`)
+	if err != nil {
+		return nil, fmt.Errorf("获取跳过统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []SkipCount
+	for rows.Next() {
+		var count SkipCount
+		if err := rows.Scan(&count.Rule, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
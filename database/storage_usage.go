@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// createStorageUsageTable 创建存储用量表，定期记录各分类目录占用的空间，用于统计用量变化趋势
+func createStorageUsageTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS storage_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		category TEXT,
+		bytes INTEGER,
+		recorded_at TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建存储用量表: %w", err)
+	}
+	return nil
+}
+
+// RecordStorageUsage 记录一次各分类目录的存储用量快照
+func RecordStorageUsage(usage map[string]int64, recordedAt time.Time) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("记录存储用量失败: %w", err)
+	}
+	for category, bytes := range usage {
+		if _, err := tx.Exec("INSERT INTO storage_usage (category, bytes, recorded_at) VALUES (?, ?, ?)", category, bytes, recordedAt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("记录存储用量失败: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("记录存储用量失败: %w", err)
+	}
+	return nil
+}
+
+// GetLatestStorageUsage 获取最近一次存储用量快照及其时间，没有快照时返回nil
+func GetLatestStorageUsage() (map[string]int64, time.Time, error) {
+	if err := InitDatabase(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var recordedAt time.Time
+	err := DB.QueryRow("SELECT recorded_at FROM storage_usage ORDER BY recorded_at DESC LIMIT 1").Scan(&recordedAt)
+	if err == sql.ErrNoRows {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("查询存储用量失败: %w", err)
+	}
+
+	rows, err := DB.Query("SELECT category, bytes FROM storage_usage WHERE recorded_at = ?", recordedAt)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("查询存储用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int64)
+	for rows.Next() {
+		var category string
+		var bytes int64
+		if err := rows.Scan(&category, &bytes); err != nil {
+			return nil, time.Time{}, fmt.Errorf("查询存储用量失败: %w", err)
+		}
+		usage[category] = bytes
+	}
+	return usage, recordedAt, rows.Err()
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// createTMDBNotFoundTable 创建TMDB不存在条目表，记录返回404的接口路径（如已删除的tmdbid）
+func createTMDBNotFoundTable(db *sql.DB) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS tmdb_not_found (
+		path TEXT PRIMARY KEY,
+		first_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("无法创建TMDB不存在条目表: %w", err)
+	}
+	return nil
+}
+
+// IsTMDBNotFound 检查TMDB接口路径是否已记录为不存在
+func IsTMDBNotFound(path string) (bool, error) {
+	if err := InitDatabase(); err != nil {
+		return false, err
+	}
+
+	var found string
+	err := DB.QueryRow("SELECT path FROM tmdb_not_found WHERE path = ?", path).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询TMDB不存在条目失败: %w", err)
+	}
+	return true, nil
+}
+
+// MarkTMDBNotFound 记录TMDB接口路径不存在
+func MarkTMDBNotFound(path string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err := DB.Exec(`
+	INSERT INTO tmdb_not_found (path, first_seen, last_checked) VALUES (?, ?, ?)
+	ON CONFLICT(path) DO UPDATE SET last_checked = excluded.last_checked`,
+		path, now, now)
+	if err != nil {
+		return fmt.Errorf("记录TMDB不存在条目失败: %w", err)
+	}
+	return nil
+}
+
+// ClearTMDBNotFound 删除TMDB接口路径的不存在记录
+func ClearTMDBNotFound(path string) error {
+	if err := InitDatabase(); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec("DELETE FROM tmdb_not_found WHERE path = ?", path); err != nil {
+		return fmt.Errorf("删除TMDB不存在条目失败: %w", err)
+	}
+	return nil
+}
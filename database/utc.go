@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// utcDriverName 包装后的SQLite驱动名：写入的时间统一转换为UTC，读取的时间转换为显示时区（time.Local，由timezone配置决定）
+// 容器的时区常与NAS不同，数据库中的时间不随运行环境变化，按时间比较和排序的查询才能得到一致的结果
+const utcDriverName = "sqlite-utc"
+
+// timeFormatDSN 让驱动以timeFormat格式写入时间，与SQLite的日期函数兼容
+const timeFormatDSN = "?_time_format=sqlite"
+
+// timeFormat _time_format=sqlite时驱动写入时间的格式
+const timeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	sql.Register(utcDriverName, utcDriver{&sqlite.Driver{}})
+}
+
+// utcDriver 打开包装后的连接
+type utcDriver struct {
+	driver.Driver
+}
+
+// Open 打开SQLite连接并包装
+func (d utcDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &utcConn{conn}, nil
+}
+
+// utcConn 转换查询参数和结果中时间的连接
+type utcConn struct {
+	driver.Conn
+}
+
+// CheckNamedValue 把time.Time参数转换为UTC，其他参数使用默认的转换
+func (c *utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if t, ok := nv.Value.(time.Time); ok {
+		nv.Value = t.UTC()
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// Prepare 准备语句，语句的查询结果同样转换时间
+func (c *utcConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext 准备语句，语句的查询结果同样转换时间
+func (c *utcConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &utcStmt{stmt}, nil
+}
+
+// BeginTx 开始事务
+func (c *utcConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// ExecContext 直接执行语句
+func (c *utcConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext 直接执行查询，结果中的时间转换为显示时区
+func (c *utcConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &localRows{rows}, nil
+}
+
+// ResetSession 连接放回连接池前重置会话
+func (c *utcConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid 判断连接是否仍然可用
+func (c *utcConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// utcStmt 查询结果转换时间的语句
+type utcStmt struct {
+	driver.Stmt
+}
+
+// ExecContext 执行语句
+func (s *utcStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return s.Stmt.Exec(values)
+}
+
+// QueryContext 执行查询，结果中的时间转换为显示时区
+func (s *utcStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		values := make([]driver.Value, len(args))
+		for i, arg := range args {
+			values[i] = arg.Value
+		}
+		rows, err = s.Stmt.Query(values)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &localRows{rows}, nil
+}
+
+// localRows 把读取的时间转换为显示时区的结果集
+type localRows struct {
+	driver.Rows
+}
+
+// Next 读取下一行，time.Time列转换为time.Local
+func (r *localRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, value := range dest {
+		if t, ok := value.(time.Time); ok {
+			dest[i] = t.Local()
+		}
+	}
+	return nil
+}
+
+// runStateUTCMigrated 记录已有时间已转换为UTC的运行状态键
+const runStateUTCMigrated = "timestamps_utc_migrated"
+
+// legacyTimeFormat 之前版本的驱动写入时间的格式（time.Time.String），带有写入时的时区偏移
+const legacyTimeFormat = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// migrateTimestampsToUTC 把之前版本以本地时区写入的时间改写为UTC，只执行一次
+// 新旧格式混在一起时按文本比较和排序的查询（如 updated_at < ?）会得到错误的结果
+// CURRENT_TIMESTAMP写入的默认值本来就是UTC，不需要改写
+func migrateTimestampsToUTC(db *sql.DB) error {
+	var done int
+	if err := db.QueryRow("SELECT COUNT(*) FROM run_state WHERE key = ?", runStateUTCMigrated).Scan(&done); err != nil {
+		return fmt.Errorf("读取时间迁移状态失败: %w", err)
+	}
+	if done > 0 {
+		return nil
+	}
+
+	columns, err := timestampColumns(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始时间迁移失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	converted := 0
+	for table, names := range columns {
+		for _, column := range names {
+			n, err := convertColumnToUTC(tx, table, column)
+			if err != nil {
+				return err
+			}
+			converted += n
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO run_state (key, value, updated_at) VALUES (?, ?, ?)", runStateUTCMigrated, fmt.Sprint(converted), time.Now()); err != nil {
+		return fmt.Errorf("保存时间迁移状态失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("保存时间迁移结果失败: %w", err)
+	}
+	return nil
+}
+
+// timestampColumns 返回每个表中声明为TIMESTAMP或DATETIME的列
+func timestampColumns(db *sql.DB) (map[string][]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, fmt.Errorf("读取数据库表失败: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+
+	columns := make(map[string][]string)
+	for _, table := range tables {
+		infoRows, err := db.Query(fmt.Sprintf("SELECT name, type FROM pragma_table_info('%s')", table))
+		if err != nil {
+			return nil, fmt.Errorf("读取表 %s 的字段失败: %w", table, err)
+		}
+		for infoRows.Next() {
+			var name, columnType string
+			if err := infoRows.Scan(&name, &columnType); err != nil {
+				continue
+			}
+			if columnType = strings.ToUpper(columnType); columnType == "TIMESTAMP" || columnType == "DATETIME" {
+				columns[table] = append(columns[table], name)
+			}
+		}
+		infoRows.Close()
+	}
+	return columns, nil
+}
+
+// convertColumnToUTC 把一列中带有非UTC时区偏移的时间改写为UTC，返回改写的行数
+func convertColumnToUTC(tx *sql.Tx, table, column string) (int, error) {
+	// 转换为文本读取，避免驱动按列类型解析时间
+	rows, err := tx.Query(fmt.Sprintf(`SELECT rowid, CAST("%s" AS TEXT) FROM "%s" WHERE typeof("%s") = 'text'`, column, table, column))
+	if err != nil {
+		return 0, fmt.Errorf("读取 %s.%s 失败: %w", table, column, err)
+	}
+	values := make(map[int64]time.Time)
+	for rows.Next() {
+		var rowID int64
+		var text string
+		if err := rows.Scan(&rowID, &text); err != nil {
+			continue
+		}
+		if t, ok := parseStoredTime(text); ok {
+			values[rowID] = t
+		}
+	}
+	rows.Close()
+
+	for rowID, t := range values {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE "%s" SET "%s" = ? WHERE rowid = ?`, table, column), t, rowID); err != nil {
+			return 0, fmt.Errorf("改写 %s.%s 失败: %w", table, column, err)
+		}
+	}
+	return len(values), nil
+}
+
+// parseStoredTime 解析需要改写的时间：之前版本的格式一律改写，timeFormat格式只改写不是UTC的时间
+// 没有时区偏移的时间（CURRENT_TIMESTAMP写入的默认值）本来就是UTC，返回false
+func parseStoredTime(text string) (time.Time, bool) {
+	// time.Time.String在单调时钟读数前加 " m=±秒数"
+	if i := strings.Index(text, " m="); i > 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSpace(text)
+	if t, err := time.Parse(legacyTimeFormat, text); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(timeFormat, text); err == nil {
+		_, offset := t.Zone()
+		return t, offset != 0
+	}
+	return time.Time{}, false
+}
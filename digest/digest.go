@@ -0,0 +1,201 @@
+package digest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// lastDigestKey 运行状态表中记录上次发送摘要时间的键
+const lastDigestKey = "last_digest_at"
+
+// CategoryTitles 一个分类中新入库的标题
+type CategoryTitles struct {
+	Category string
+	Titles   []string
+}
+
+// CategoryUsage 一个分类目录的存储用量及与上次快照相比的变化
+type CategoryUsage struct {
+	Category string
+	Bytes    int64
+	Change   int64 // 与上次快照相比增加的字节数，没有上次快照时为0
+}
+
+// Digest 一段时间内媒体库的变化摘要
+type Digest struct {
+	Since          time.Time
+	Until          time.Time
+	Added          []CategoryTitles // 按分类分组的新入库标题
+	Completed      []string         // 已收集完整的剧集
+	MissingSeasons []string         // 新发现的缺失季
+	Storage        []CategoryUsage  // 各分类目录的存储用量
+	TotalBytes     int64
+	TotalChange    int64
+	PreviousAt     time.Time // 上次存储用量快照的时间，没有快照时为零值
+
+	usage map[string]int64
+}
+
+// Build 汇总since之后的媒体库变化，并统计各分类目录当前的存储用量
+func Build(cfg *config.Config, since time.Time) (*Digest, error) {
+	d := &Digest{Since: since, Until: time.Now()}
+
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	added := make(map[string][]string)
+	for _, record := range records {
+		if record.ProcessedAt.After(since) {
+			added[record.Category] = append(added[record.Category], recordTitle(record))
+		}
+		if record.IsComplete && strings.HasSuffix(record.Category, "Show") && record.UpdatedAt.After(since) {
+			d.Completed = append(d.Completed, recordTitle(record))
+		}
+	}
+	for category, titles := range added {
+		utils.SortByPinyin(titles)
+		d.Added = append(d.Added, CategoryTitles{Category: category, Titles: titles})
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Category < d.Added[j].Category })
+	utils.SortByPinyin(d.Completed)
+
+	missing, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+	for _, season := range missing {
+		if season.DetectedAt.After(since) {
+			d.MissingSeasons = append(d.MissingSeasons, fmt.Sprintf("%s 第%d季", season.Title, season.Season))
+		}
+	}
+	utils.SortByPinyin(d.MissingSeasons)
+
+	previous, previousAt, err := database.GetLatestStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	d.PreviousAt = previousAt
+	d.usage = categoryUsage(cfg)
+	for category, bytes := range d.usage {
+		usage := CategoryUsage{Category: category, Bytes: bytes}
+		if previous != nil {
+			usage.Change = bytes - previous[category]
+		}
+		d.Storage = append(d.Storage, usage)
+		d.TotalBytes += bytes
+		d.TotalChange += usage.Change
+	}
+	sort.Slice(d.Storage, func(i, j int) bool { return d.Storage[i].Category < d.Storage[j].Category })
+
+	return d, nil
+}
+
+// AddedCount 返回新入库的标题总数
+func (d *Digest) AddedCount() int {
+	count := 0
+	for _, category := range d.Added {
+		count += len(category.Titles)
+	}
+	return count
+}
+
+// SendIfDue 配置了摘要邮件且距上次发送已超过间隔天数时，汇总上次发送以来的变化并发送
+func SendIfDue(cfg *config.Config) error {
+	if !cfg.Email.Enabled() {
+		return nil
+	}
+
+	interval := time.Duration(cfg.Email.DigestIntervalDays) * 24 * time.Hour
+	since := time.Now().Add(-interval)
+	if value, err := database.GetRunState(lastDigestKey); err != nil {
+		return err
+	} else if value != "" {
+		lastSent, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			if time.Since(lastSent) < interval {
+				return nil
+			}
+			since = lastSent
+		}
+	}
+
+	return SendNow(cfg, since)
+}
+
+// SendNow 汇总since之后的变化并立即发送摘要邮件，发送成功后记录存储用量快照和发送时间
+func SendNow(cfg *config.Config, since time.Time) error {
+	d, err := Build(cfg, since)
+	if err != nil {
+		return err
+	}
+	if err := Send(cfg.Email, d); err != nil {
+		return err
+	}
+	logging.Info("已发送媒体库摘要邮件: 新入库 %d 部，完整剧集 %d 部，新缺失季 %d 个", d.AddedCount(), len(d.Completed), len(d.MissingSeasons))
+
+	if err := database.RecordStorageUsage(d.usage, d.Until); err != nil {
+		logging.Error("%v", err)
+	}
+	return database.SetRunState(lastDigestKey, d.Until.Format(time.RFC3339))
+}
+
+// recordTitle 返回记录的显示标题，如 "流浪地球2 (2023)"、"狂飙 (2023) 第1季"
+func recordTitle(record database.MediaRecord) string {
+	title := record.Title
+	if record.Year != "" {
+		title = fmt.Sprintf("%s (%s)", title, record.Year)
+	}
+	if record.Season != nil {
+		title = fmt.Sprintf("%s 第%d季", title, *record.Season)
+	}
+	return title
+}
+
+// categoryUsage 统计各媒体库根目录下每个分类目录和单独配置的分类目录占用的字节数
+// 不同根目录下的同名分类目录合并统计
+func categoryUsage(cfg *config.Config) map[string]int64 {
+	usage := make(map[string]int64)
+	counted := make(map[string]bool)
+	for _, root := range cfg.CloudRoots() {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			logging.Warning("读取媒体库目录失败: %v", err)
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || entry.Name() == classifier.RecentDirName {
+				continue
+			}
+			dir := filepath.Join(root, entry.Name())
+			counted[dir] = true
+			usage[entry.Name()] += utils.DirSize(dir, cfg.SymlinkMode(config.SymlinkOpScan))
+		}
+	}
+	for category, dir := range cfg.CategoryDirs {
+		if !counted[filepath.Clean(dir)] {
+			usage[category] += utils.DirSize(dir, cfg.SymlinkMode(config.SymlinkOpScan))
+		}
+	}
+	return usage
+}
+
+// FormatChange 将用量变化格式化为带符号的大小，如 +12.3 GB
+func FormatChange(bytes int64) string {
+	if bytes > 0 {
+		return "+" + utils.FormatBytes(bytes)
+	}
+	return utils.FormatBytes(bytes)
+}
@@ -0,0 +1,246 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/utils"
+)
+
+// templateFuncs 模板中使用的函数
+var templateFuncs = map[string]interface{}{
+	"bytes":  utils.FormatBytes,
+	"change": FormatChange,
+	"date":   func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+// textTemplate 纯文本摘要
+var textTemplate = texttemplate.Must(texttemplate.New("text").Funcs(templateFuncs).Parse(`媒体库摘要（{{date .Since}} 至 {{date .Until}}）
+
+新入库（{{.AddedCount}} 部）
+{{- range .Added}}
+  {{.Category}}（{{len .Titles}}）
+{{- range .Titles}}
+    - {{.}}
+{{- end}}
+{{- else}}
+  无
+{{- end}}
+
+已收集完整的剧集（{{len .Completed}} 部）
+{{- range .Completed}}
+  - {{.}}
+{{- else}}
+  无
+{{- end}}
+
+新发现的缺失季（{{len .MissingSeasons}} 个）
+{{- range .MissingSeasons}}
+  - {{.}}
+{{- else}}
+  无
+{{- end}}
+
+存储用量（共 {{bytes .TotalBytes}}{{if not .PreviousAt.IsZero}}，较 {{date .PreviousAt}} {{change .TotalChange}}{{end}}）
+{{- range .Storage}}
+  {{.Category}}: {{bytes .Bytes}}{{if not $.PreviousAt.IsZero}}（{{change .Change}}）{{end}}
+{{- end}}
+`))
+
+// htmlTemplate HTML摘要
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"><title>媒体库摘要</title></head>
+<body style="font-family: sans-serif; color: #222;">
+<h2>媒体库摘要</h2>
+<p style="color: #666;">{{date .Since}} 至 {{date .Until}}</p>
+
+<h3>新入库（{{.AddedCount}} 部）</h3>
+{{range .Added}}
+<h4>{{.Category}}（{{len .Titles}}）</h4>
+<ul>{{range .Titles}}<li>{{.}}</li>{{end}}</ul>
+{{else}}
+<p>无</p>
+{{end}}
+
+<h3>已收集完整的剧集（{{len .Completed}} 部）</h3>
+{{if .Completed}}<ul>{{range .Completed}}<li>{{.}}</li>{{end}}</ul>{{else}}<p>无</p>{{end}}
+
+<h3>新发现的缺失季（{{len .MissingSeasons}} 个）</h3>
+{{if .MissingSeasons}}<ul>{{range .MissingSeasons}}<li>{{.}}</li>{{end}}</ul>{{else}}<p>无</p>{{end}}
+
+<h3>存储用量</h3>
+<table style="border-collapse: collapse;">
+<tr><th style="text-align: left; padding: 4px 12px;">分类</th><th style="text-align: right; padding: 4px 12px;">用量</th>{{if not .PreviousAt.IsZero}}<th style="text-align: right; padding: 4px 12px;">较 {{date .PreviousAt}}</th>{{end}}</tr>
+{{range .Storage}}<tr><td style="padding: 4px 12px;">{{.Category}}</td><td style="text-align: right; padding: 4px 12px;">{{bytes .Bytes}}</td>{{if not $.PreviousAt.IsZero}}<td style="text-align: right; padding: 4px 12px;">{{change .Change}}</td>{{end}}</tr>
+{{end}}<tr><td style="padding: 4px 12px;"><b>合计</b></td><td style="text-align: right; padding: 4px 12px;"><b>{{bytes .TotalBytes}}</b></td>{{if not .PreviousAt.IsZero}}<td style="text-align: right; padding: 4px 12px;"><b>{{change .TotalChange}}</b></td>{{end}}</tr>
+</table>
+</body>
+</html>
+`))
+
+// RenderText 生成纯文本摘要
+func RenderText(d *Digest) (string, error) {
+	var buf bytes.Buffer
+	if err := textTemplate.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("生成纯文本摘要失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderHTML 生成HTML摘要
+func RenderHTML(d *Digest) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("生成HTML摘要失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Send 以multipart/alternative格式（HTML和纯文本）发送摘要邮件
+func Send(email config.EmailConfig, d *Digest) error {
+	if !email.Enabled() {
+		return fmt.Errorf("没有配置摘要邮件的smtp_host和to")
+	}
+
+	message, err := buildMessage(email, d)
+	if err != nil {
+		return err
+	}
+
+	from := email.From
+	if from == "" {
+		from = email.Username
+	}
+	if err := sendMail(email, from, message); err != nil {
+		return fmt.Errorf("发送摘要邮件失败: %w", err)
+	}
+	return nil
+}
+
+// buildMessage 生成邮件内容，包括邮件头和HTML、纯文本两个部分
+func buildMessage(email config.EmailConfig, d *Digest) ([]byte, error) {
+	text, err := RenderText(d)
+	if err != nil {
+		return nil, err
+	}
+	html, err := RenderHTML(d)
+	if err != nil {
+		return nil, err
+	}
+
+	from := email.From
+	if from == "" {
+		from = email.Username
+	}
+	subject := fmt.Sprintf("媒体库摘要 %s 至 %s：新入库 %d 部", d.Since.Format("01-02"), d.Until.Format("01-02"), d.AddedCount())
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		// 纯文本在前，支持HTML的客户端显示最后一个部分
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(wrapBase64(part.content))); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(email.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// sendMail 连接SMTP服务器发送邮件，465端口使用TLS直连，其他端口由net/smtp在服务器支持时使用STARTTLS
+func sendMail(email config.EmailConfig, from string, message []byte) error {
+	addr := net.JoinHostPort(email.SMTPHost, strconv.Itoa(email.SMTPPort))
+	var auth smtp.Auth
+	if email.Username != "" {
+		auth = smtp.PlainAuth("", email.Username, email.Password, email.SMTPHost)
+	}
+
+	if email.SMTPPort != 465 {
+		return smtp.SendMail(addr, auth, from, email.To, message)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: email.SMTPHost})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, email.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range email.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// wrapBase64 将内容编码为base64，每76个字符换行
+func wrapBase64(content string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded + "\r\n")
+	return wrapped.String()
+}
@@ -0,0 +1,127 @@
+package encyclopedia
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+)
+
+// 各来源的默认接口地址，{title} 替换为转义后的标题
+const (
+	wikipediaURL = "https://zh.wikipedia.org/api/rest_v1/page/summary/{title}"
+	baiduURL     = "https://baike.baidu.com/api/openapi/BaikeLemmaCardApi?scope=103&format=json&appid=379020&bk_length=600&bk_key={title}"
+)
+
+// userAgent 维基百科要求请求带有能识别应用的User-Agent
+const userAgent = "media-manager (https://github.com/user/media-manager)"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Summary 百科中的条目摘要
+type Summary struct {
+	Source string // 来源：wikipedia、baidu
+	URL    string // 条目页面地址
+	Text   string
+}
+
+// Fetch 从配置的来源获取影片的摘要，电影和电视剧分别优先尝试 "标题 (电影)"、"标题 (电视剧)" 这样的消歧义条目
+// 没有找到条目时返回nil
+func Fetch(cfg config.PlotFallbackConfig, title string, isTVShow bool) (*Summary, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, nil
+	}
+	suffix := "电影"
+	if isTVShow {
+		suffix = "电视剧"
+	}
+
+	for _, name := range []string{fmt.Sprintf("%s (%s)", title, suffix), title} {
+		var summary *Summary
+		var err error
+		switch cfg.Source {
+		case config.PlotSourceWikipedia:
+			summary, err = fetchWikipedia(cfg.URL, name)
+		case config.PlotSourceBaidu:
+			summary, err = fetchBaidu(cfg.URL, name)
+		default:
+			return nil, fmt.Errorf("不支持的简介来源: %s", cfg.Source)
+		}
+		if err != nil || summary != nil {
+			return summary, err
+		}
+	}
+	return nil, nil
+}
+
+// fetchWikipedia 通过维基百科REST API获取条目摘要，消歧义页视为没有找到
+func fetchWikipedia(template string, title string) (*Summary, error) {
+	if template == "" {
+		template = wikipediaURL
+	}
+	var resp struct {
+		Type        string `json:"type"`
+		Extract     string `json:"extract"`
+		ContentURLs struct {
+			Desktop struct {
+				Page string `json:"page"`
+			} `json:"desktop"`
+		} `json:"content_urls"`
+	}
+	found, err := getJSON(strings.ReplaceAll(template, "{title}", url.PathEscape(title)), &resp)
+	if err != nil || !found || resp.Type == "disambiguation" || strings.TrimSpace(resp.Extract) == "" {
+		return nil, err
+	}
+	return &Summary{Source: config.PlotSourceWikipedia, URL: resp.ContentURLs.Desktop.Page, Text: strings.TrimSpace(resp.Extract)}, nil
+}
+
+// fetchBaidu 通过百度百科的词条卡片接口获取摘要
+func fetchBaidu(template string, title string) (*Summary, error) {
+	if template == "" {
+		template = baiduURL
+	}
+	var resp struct {
+		Abstract string `json:"abstract"`
+		URL      string `json:"url"`
+	}
+	found, err := getJSON(strings.ReplaceAll(template, "{title}", url.QueryEscape(title)), &resp)
+	if err != nil || !found || strings.TrimSpace(resp.Abstract) == "" {
+		return nil, err
+	}
+	return &Summary{Source: config.PlotSourceBaidu, URL: resp.URL, Text: strings.TrimSpace(resp.Abstract)}, nil
+}
+
+// getJSON 请求接口并解析JSON响应，条目不存在（404）时返回false
+func getJSON(apiURL string, result interface{}) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	// 维基百科按请求的语言变体转换繁简，使用简体中文
+	req.Header.Set("Accept-Language", "zh-cn")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("请求百科失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("百科返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return false, fmt.Errorf("解析百科响应失败: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,108 @@
+// Package errs 定义处理流程中跳过和失败的类型化错误
+// 作为库使用时用errors.Is与这里的错误值比较，解析--json输出或数据库记录时使用稳定的原因代码（Code），不依赖中文日志
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error 带稳定原因代码的错误值，Code只由小写字母和下划线组成，发布后不再修改
+type Error struct {
+	Code    string
+	Message string
+}
+
+// Error 返回错误的中文说明
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// registry 按原因代码索引的全部错误值
+var registry = make(map[string]*Error)
+
+// define 定义一个错误值并登记原因代码
+func define(code string, message string) *Error {
+	err := &Error{Code: code, Message: message}
+	registry[code] = err
+	return err
+}
+
+// 跳过处理的原因：项目留在原位置，下次运行时重新检查。代码与门禁规则名和db skips中记录的规则相同
+var (
+	ErrIgnored            = define("ignored", "目录被忽略标记文件排除")
+	ErrOutsideTemp        = define("outside_temp", "目录不在Temp目录中")
+	ErrLocked             = define("locked", "目录已由db lock锁定")
+	ErrIncomplete         = define("incomplete", "下载未完成")
+	ErrNotSettled         = define("not_settled", "项目还在复制或下载中")
+	ErrMultipleNFO        = define("multiple_nfo", "目录下有多个NFO文件")
+	ErrUnresolvedMetadata = define("unresolved_nfo", "NFO信息不完整")
+	ErrMissingCountry     = define("missing_country", "没有国家信息")
+	ErrProjectFiles       = define("project_files", "目录中存在项目文件")
+	ErrNonChineseTitle    = define("non_chinese_title", "标题不是简体中文")
+	ErrNonChineseGenre    = define("non_chinese_genre", "类型不是简体中文")
+	ErrBelowMinQuality    = define("below_min_quality", "画质低于最低要求")
+	ErrTargetExists       = define("target_exists", "目标目录已存在")
+	ErrLowFreeSpace       = define("low_free_space", "目标文件系统剩余空间不足")
+	ErrOutOfScope         = define("out_of_scope", "分类不在本次处理范围内")
+)
+
+// 处理失败的原因
+var (
+	ErrPathTooLong       = define("path_too_long", "目标路径超过长度限制")
+	ErrOutsideRoots      = define("outside_roots", "拒绝修改配置的Temp目录和媒体库目录以外的路径")
+	ErrTMDBNotFound      = define("tmdb_not_found", "TMDB中不存在该条目")
+	ErrScrapeTimeout     = define("scrape_timeout", "tinyMediaManager运行超时")
+	ErrMissingDatasource = define("missing_datasource", "Temp目录不是tinyMediaManager的数据源")
+	ErrReadOnlyTarget    = define("read_only_target", "媒体库目录只读或不可写入")
+	ErrNotMounted        = define("not_mounted", "媒体库目录没有挂载")
+)
+
+// Lookup 返回原因代码对应的错误值，未定义的代码返回以代码为说明的新错误值
+func Lookup(code string) *Error {
+	if err, ok := registry[code]; ok {
+		return err
+	}
+	return &Error{Code: code, Message: code}
+}
+
+// Code 返回错误链中第一个错误值的原因代码，没有时返回空字符串
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// SkipError 项目被跳过，没有移动也没有失败
+type SkipError struct {
+	Reason *Error // 跳过的原因，为上面定义的错误值之一
+	Path   string // 被跳过的影片目录
+	Detail string // 具体说明，如 标题 'Movie' 不是简体中文
+}
+
+// Error 返回带原因代码的说明，如 [non_chinese_title] 标题 'Movie' 不是简体中文
+func (e *SkipError) Error() string {
+	detail := e.Detail
+	if detail == "" {
+		detail = e.Reason.Message
+	}
+	return fmt.Sprintf("[%s] %s", e.Reason.Code, detail)
+}
+
+// Unwrap 返回跳过的原因，使errors.Is(err, errs.ErrTargetExists)等判断成立
+func (e *SkipError) Unwrap() error {
+	return e.Reason
+}
+
+// Skip 返回项目被跳过的错误
+func Skip(reason *Error, path string, detail string) *SkipError {
+	return &SkipError{Reason: reason, Path: path, Detail: detail}
+}
+
+// IsSkip 判断错误是否表示项目被跳过（而不是处理失败）
+func IsSkip(err error) bool {
+	var skip *SkipError
+	return errors.As(err, &skip)
+}
@@ -0,0 +1,150 @@
+package feed
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/tmdb"
+)
+
+// calendarPastDays 日历中保留已播出剧集的天数，避免订阅端刚播出的剧集立即消失
+const calendarPastDays = 7
+
+// Event 表示日历中的一个全天事件
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time // 事件日期（当天零点）
+}
+
+// Calendar 表示一个日历
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// UpcomingEpisodes 根据数据库中的剧集和TMDB的播出日期生成日历
+// 包含最近calendarPastDays天已播出和未来days天将播出的剧集
+func UpcomingEpisodes(days int) (*Calendar, error) {
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	// 同一部剧的多季只查询一次
+	titles := make(map[string]string)
+	var tmdbIDs []string
+	for _, record := range records {
+		if record.TMDbID == "" || !strings.HasSuffix(record.Category, "Show") {
+			continue
+		}
+		if _, exists := titles[record.TMDbID]; !exists {
+			titles[record.TMDbID] = record.Title
+			tmdbIDs = append(tmdbIDs, record.TMDbID)
+		}
+	}
+
+	// TMDB的播出日期没有时区，按本地日期比较
+	year, month, day := time.Now().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -calendarPastDays)
+	until := today.AddDate(0, 0, days)
+
+	c := &Calendar{Name: "媒体库剧集播出日历"}
+	for _, tmdbID := range tmdbIDs {
+		episodes, err := tmdb.GetAiringEpisodes(tmdbID)
+		if err != nil {
+			logging.Warning("获取剧集播出日期失败 %s (TMDB ID: %s): %v", titles[tmdbID], tmdbID, err)
+			continue
+		}
+		for _, episode := range episodes {
+			airDate, err := time.Parse("2006-01-02", episode.AirDate)
+			if err != nil || airDate.Before(from) || !airDate.Before(until) {
+				continue
+			}
+			summary := fmt.Sprintf("%s S%02dE%02d", titles[tmdbID], episode.Season, episode.Episode)
+			if episode.Name != "" {
+				summary += " " + episode.Name
+			}
+			c.Events = append(c.Events, Event{
+				UID:         fmt.Sprintf("tv-%s-s%d-e%d@media-manager", tmdbID, episode.Season, episode.Episode),
+				Summary:     summary,
+				Description: episode.Overview,
+				Date:        airDate,
+			})
+		}
+	}
+
+	sort.SliceStable(c.Events, func(i, j int) bool {
+		return c.Events[i].Date.Before(c.Events[j].Date)
+	})
+	return c, nil
+}
+
+// icsEscaper 转义iCalendar文本中的特殊字符
+var icsEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\;",
+	",", "\\,",
+	"\r\n", "\\n",
+	"\n", "\\n",
+)
+
+// WriteICS 以iCalendar（RFC 5545）格式输出日历
+func WriteICS(w io.Writer, c *Calendar) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeLine := func(line string) {
+		bw.WriteString(foldICSLine(line))
+	}
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//media-manager//upcoming episodes//ZH")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	writeLine("X-WR-CALNAME:" + icsEscaper.Replace(c.Name))
+	for _, event := range c.Events {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + event.UID)
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART;VALUE=DATE:" + event.Date.Format("20060102"))
+		writeLine("DTEND;VALUE=DATE:" + event.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeLine("SUMMARY:" + icsEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			writeLine("DESCRIPTION:" + icsEscaper.Replace(event.Description))
+		}
+		writeLine("TRANSP:TRANSPARENT")
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("输出日历失败: %w", err)
+	}
+	return nil
+}
+
+// foldICSLine 按RFC 5545将超过75字节的行折行（续行以空格开头），不拆分多字节字符，并以CRLF结尾
+func foldICSLine(line string) string {
+	var folded strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			folded.WriteString("\r\n ")
+			width = 1
+		}
+		folded.WriteRune(r)
+		width += size
+	}
+	folded.WriteString("\r\n")
+	return folded.String()
+}
@@ -0,0 +1,237 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/user/media-manager/database"
+)
+
+// Item 表示订阅源中的一个条目
+type Item struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Category    string
+	Published   time.Time
+}
+
+// Feed 表示一个订阅源
+type Feed struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Updated     time.Time
+	Items       []Item
+}
+
+// rss RSS 2.0文档结构
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// atom Atom文档结构
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Link     *atomLink     `xml:"link,omitempty"`
+	Category *atomCategory `xml:"category,omitempty"`
+	Summary  string        `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteRSS 以RSS 2.0格式输出订阅源
+func WriteRSS(w io.Writer, f *Feed) error {
+	doc := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Category:    item.Category,
+			GUID:        rssGUID{IsPermaLink: false, Value: item.ID},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+// WriteAtom 以Atom格式输出订阅源
+func WriteAtom(w io.Writer, f *Feed) error {
+	doc := atom{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.Format(time.RFC3339),
+		Link:    atomLink{Href: f.Link},
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			ID:      item.ID,
+			Title:   item.Title,
+			Updated: item.Published.Format(time.RFC3339),
+			Summary: item.Description,
+		}
+		if item.Link != "" {
+			entry.Link = &atomLink{Href: item.Link}
+		}
+		if item.Category != "" {
+			entry.Category = &atomCategory{Term: item.Category}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+// writeXML 输出带XML声明的文档
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("生成订阅源失败: %w", err)
+	}
+	return nil
+}
+
+// RecentlyAdded 根据数据库生成最近入库的订阅源
+// days为统计的天数，limit为最多返回的条目数
+func RecentlyAdded(link string, days int, limit int) (*Feed, error) {
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	var recent []database.MediaRecord
+	for _, record := range records {
+		if record.ProcessedAt.After(since) {
+			recent = append(recent, record)
+		}
+	}
+
+	// 最新的排在前面
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].ProcessedAt.After(recent[j].ProcessedAt)
+	})
+	if limit > 0 && len(recent) > limit {
+		recent = recent[:limit]
+	}
+
+	f := &Feed{
+		ID:          "urn:media-manager:recent",
+		Title:       "媒体库最近入库",
+		Link:        link,
+		Description: fmt.Sprintf("最近 %d 天加入媒体库的影片和剧集", days),
+		Updated:     time.Now(),
+	}
+	for _, record := range recent {
+		title := record.Title
+		if record.Year != "" {
+			title = fmt.Sprintf("%s (%s)", record.Title, record.Year)
+		}
+		if record.Season != nil {
+			title = fmt.Sprintf("%s 第%d季", title, *record.Season)
+		}
+		f.Items = append(f.Items, Item{
+			ID:          fmt.Sprintf("urn:media-manager:media:%d:%d", record.ID, record.Version),
+			Title:       title,
+			Description: record.Plot,
+			Category:    record.Category,
+			Published:   record.ProcessedAt,
+		})
+	}
+	if len(recent) > 0 {
+		f.Updated = recent[0].ProcessedAt
+	}
+
+	return f, nil
+}
+
+// MissingSeasons 根据数据库生成缺失季的订阅源
+func MissingSeasons(link string) (*Feed, error) {
+	rows, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].DetectedAt.After(rows[j].DetectedAt)
+	})
+
+	f := &Feed{
+		ID:          "urn:media-manager:missing-seasons",
+		Title:       "媒体库缺失季",
+		Link:        link,
+		Description: "媒体库中剧集尚未收集的季",
+		Updated:     time.Now(),
+	}
+	for _, row := range rows {
+		f.Items = append(f.Items, Item{
+			ID:          fmt.Sprintf("urn:media-manager:missing-season:%d", row.ID),
+			Title:       fmt.Sprintf("%s 缺失第%d季", row.Title, row.Season),
+			Description: fmt.Sprintf("%s（%s）第%d季尚未收集，TMDB ID: %s", row.Title, row.OriginalTitle, row.Season, row.TMDbID),
+			Published:   row.DetectedAt,
+		})
+	}
+	if len(rows) > 0 {
+		f.Updated = rows[0].DetectedAt
+	}
+
+	return f, nil
+}
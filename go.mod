@@ -2,7 +2,12 @@ module github.com/user/media-manager
 
 go 1.25.5
 
-require modernc.org/sqlite v1.43.0
+require (
+	github.com/mozillazg/go-pinyin v0.21.0
+	golang.org/x/sync v0.16.0
+	golang.org/x/text v0.28.0
+	modernc.org/sqlite v1.43.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
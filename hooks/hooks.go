@@ -0,0 +1,192 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/mqtt"
+)
+
+// 钩子阶段
+const (
+	StagePreMove  = "pre_move"
+	StagePostMove = "post_move"
+	StagePostRun  = "post_run"
+	StageLowSpace = "low_space"
+	StageReadOnly = "read_only"
+)
+
+// DryRun 为true时只输出将要执行的钩子，不执行命令也不发布MQTT事件
+var DryRun bool
+
+// Item 钩子收到的单个影片信息
+type Item struct {
+	Title      string `json:"title"`
+	Year       string `json:"year,omitempty"`
+	IsTVShow   bool   `json:"is_tvshow"`
+	Category   string `json:"category"`
+	TMDbID     string `json:"tmdb_id,omitempty"`
+	IMDbID     string `json:"imdb_id,omitempty"`
+	NFOPath    string `json:"nfo_path"`
+	SourcePath string `json:"source_path"`
+	TargetPath string `json:"target_path"`
+	Seasons    []int  `json:"seasons,omitempty"` // 合并到已有目录的新季数
+}
+
+// Run 钩子收到的运行信息
+type Run struct {
+	Mode       string    `json:"mode"` // scrape、nfo、dir
+	Paths      []string  `json:"paths,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Space 剩余空间不足的媒体库文件系统
+type Space struct {
+	Category  string `json:"category,omitempty"` // 为空时表示媒体库根目录
+	Path      string `json:"path"`
+	FreeBytes uint64 `json:"free_bytes"`
+	MinBytes  uint64 `json:"min_bytes"`
+}
+
+// Mount 不可写入的媒体库目录
+type Mount struct {
+	Path  string `json:"path"`
+	Error string `json:"error"` // 写入测试失败的原因，如 read-only file system
+}
+
+// Payload 通过标准输入传给钩子命令的JSON
+type Payload struct {
+	Stage string    `json:"stage"`
+	Time  time.Time `json:"time"`
+	Item  *Item     `json:"item,omitempty"`
+	Run   *Run      `json:"run,omitempty"`
+	Space *Space    `json:"space,omitempty"`
+	Mount *Mount    `json:"mount,omitempty"`
+}
+
+// commandsForStage 返回指定阶段配置的命令
+func commandsForStage(cfg config.HooksConfig, stage string) []string {
+	switch stage {
+	case StagePreMove:
+		return cfg.PreMove
+	case StagePostMove:
+		return cfg.PostMove
+	case StagePostRun:
+		return cfg.PostRun
+	case StageLowSpace:
+		return cfg.LowSpace
+	case StageReadOnly:
+		return cfg.ReadOnly
+	}
+	return nil
+}
+
+// RunItem 执行单个影片相关阶段（pre_move、post_move）的钩子
+func RunItem(cfg config.HooksConfig, stage string, item *Item) error {
+	return execute(cfg, &Payload{Stage: stage, Time: time.Now(), Item: item})
+}
+
+// RunFinished 执行运行结束阶段（post_run）的钩子
+func RunFinished(cfg config.HooksConfig, run *Run) error {
+	return execute(cfg, &Payload{Stage: StagePostRun, Time: time.Now(), Run: run})
+}
+
+// RunLowSpace 执行剩余空间不足阶段（low_space）的钩子
+func RunLowSpace(cfg config.HooksConfig, space *Space) error {
+	return execute(cfg, &Payload{Stage: StageLowSpace, Time: time.Now(), Space: space})
+}
+
+// RunReadOnly 执行媒体库目录不可写入阶段（read_only）的钩子
+func RunReadOnly(cfg config.HooksConfig, mount *Mount) error {
+	return execute(cfg, &Payload{Stage: StageReadOnly, Time: time.Now(), Mount: mount})
+}
+
+// execute 依次执行阶段内的所有命令
+// 失败策略为abort时遇到第一个失败即返回错误，否则只记录日志
+// 配置了MQTT代理时同时把相同的JSON发布到 前缀/event/阶段，发布失败只记录日志
+func execute(cfg config.HooksConfig, payload *Payload) error {
+	commands := commandsForStage(cfg, payload.Stage)
+	appCfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	mqttCfg := appCfg.MQTT
+	if len(commands) == 0 && !mqttCfg.Enabled() {
+		return nil
+	}
+	if DryRun {
+		logging.Info("[模拟] 将执行%s钩子", payload.Stage)
+		return nil
+	}
+
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化钩子数据失败: %w", err)
+	}
+	if mqttCfg.Enabled() {
+		if err := mqtt.Publish(mqttCfg, mqttCfg.Topic("event", payload.Stage), input, false); err != nil {
+			logging.Warning("发布%s事件到MQTT失败: %v", payload.Stage, err)
+		}
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultHookTimeoutSeconds * time.Second
+	}
+
+	for _, command := range commands {
+		if strings.TrimSpace(command) == "" {
+			continue
+		}
+		if err := runCommand(command, payload.Stage, input, timeout); err != nil {
+			if cfg.FailurePolicy == config.HookFailureAbort {
+				return fmt.Errorf("%s钩子执行失败: %w", payload.Stage, err)
+			}
+			logging.Warning("%s钩子执行失败，继续处理: %v", payload.Stage, err)
+		}
+	}
+	return nil
+}
+
+// runCommand 通过系统shell执行一条命令，JSON数据写入标准输入
+func runCommand(command string, stage string, input []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	// 超时后shell的子进程可能仍占用输出管道，不再等待其退出
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(), "MEDIA_MANAGER_HOOK_STAGE="+stage)
+
+	logging.Info("执行%s钩子: %s", stage, command)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	if out := strings.TrimSpace(string(output)); out != "" {
+		logging.Info("%s钩子输出: %s", stage, out)
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("命令 '%s' 超时（%v）", command, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("命令 '%s' 失败: %w", command, err)
+	}
+	logging.Info("%s钩子执行完成，耗时 %v: %s", stage, time.Since(start).Round(time.Millisecond), command)
+	return nil
+}
@@ -0,0 +1,123 @@
+package testkit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/media-manager/parser"
+)
+
+// Movie 电影目录夹具
+type Movie struct {
+	Dir           string // 目录名，为空时使用 "标题 (年份)"
+	Title         string
+	OriginalTitle string
+	Year          string
+	TMDbID        string
+	Countries     []string
+	Genres        []string
+	Files         []string // 目录中的其他文件（如字幕、海报），为空时只创建与目录同名的.mkv视频文件
+}
+
+// Show 电视剧目录夹具
+type Show struct {
+	Dir           string // 目录名，为空时使用 "标题 (年份)"
+	Title         string
+	OriginalTitle string
+	Year          string
+	TMDbID        string
+	Countries     []string
+	Genres        []string
+	Seasons       map[int]int // 季号到集数，每集在 "Season 01" 目录中创建 S01E01.mkv 等视频文件
+}
+
+// AddMovie 在Temp/Movie中创建电影目录、视频文件和NFO文件，返回NFO文件路径
+func (e *Env) AddMovie(m Movie) (string, error) {
+	dir := e.TempPath("Movie", dirName(m.Dir, m.Title, m.Year))
+	files := m.Files
+	if len(files) == 0 {
+		files = []string{filepath.Base(dir) + ".mkv"}
+	}
+	for _, file := range files {
+		if err := Touch(filepath.Join(dir, file)); err != nil {
+			return "", err
+		}
+	}
+
+	nfoPath := filepath.Join(dir, "movie.nfo")
+	nfo := &parser.NFO{
+		XMLName:       xml.Name{Local: "movie"},
+		Title:         m.Title,
+		OriginalTitle: m.OriginalTitle,
+		Year:          m.Year,
+		TMDbID:        m.TMDbID,
+		Country:       m.Countries,
+		Genres:        m.Genres,
+	}
+	return nfoPath, WriteNFO(nfoPath, nfo)
+}
+
+// AddShow 在Temp/TvShow中创建电视剧目录、各季的剧集文件和tvshow.nfo，返回NFO文件路径
+func (e *Env) AddShow(s Show) (string, error) {
+	dir := e.TempPath("TvShow", dirName(s.Dir, s.Title, s.Year))
+	for season, episodes := range s.Seasons {
+		for episode := 1; episode <= episodes; episode++ {
+			file := filepath.Join(dir, fmt.Sprintf("Season %02d", season), fmt.Sprintf("S%02dE%02d.mkv", season, episode))
+			if err := Touch(file); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	nfoPath := filepath.Join(dir, "tvshow.nfo")
+	nfo := &parser.NFO{
+		XMLName:       xml.Name{Local: "tvshow"},
+		Title:         s.Title,
+		OriginalTitle: s.OriginalTitle,
+		Year:          s.Year,
+		TMDbID:        s.TMDbID,
+		Country:       s.Countries,
+		Genres:        s.Genres,
+	}
+	return nfoPath, WriteNFO(nfoPath, nfo)
+}
+
+// WriteNFO 将NFO写入文件，必要时创建所在目录
+func WriteNFO(path string, nfo *parser.NFO) error {
+	content, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("生成NFO内容失败: %w", err)
+	}
+	content = append([]byte(xml.Header), content...)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("写入NFO文件失败: %w", err)
+	}
+	return nil
+}
+
+// Touch 创建空文件，必要时创建所在目录
+func Touch(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	return nil
+}
+
+// dirName 返回夹具的目录名，没有指定时使用 "标题 (年份)"
+func dirName(dir, title, year string) string {
+	if dir != "" {
+		return dir
+	}
+	if year == "" {
+		return title
+	}
+	return fmt.Sprintf("%s (%s)", title, year)
+}
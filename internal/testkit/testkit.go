@@ -0,0 +1,123 @@
+package testkit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/paths"
+	"github.com/user/media-manager/tmdb"
+)
+
+// Env 隔离的测试环境：临时目录中的媒体库和Temp目录、内存数据库和模拟的TMDB服务器
+// 创建后config.Load返回Env.Config，database使用内存数据库，tmdb请求发送到Env.TMDB
+// 这些都通过包的全局状态替换，同一时间只能存在一个测试环境，使用testkit的测试不能并行运行
+type Env struct {
+	Root   string         // 临时根目录，测试结束时删除
+	Config *config.Config // 指向临时目录的配置，可在测试中直接修改
+	TMDB   *FakeTMDB
+
+	saved savedState
+}
+
+// savedState 创建测试环境之前的全局状态，测试结束时恢复
+type savedState struct {
+	config   *config.Config
+	inMemory bool
+	home     string
+	baseURL  string
+	output   string
+}
+
+// New 创建测试环境，测试结束时自动关闭模拟服务器并恢复创建之前的全局状态；创建失败时终止测试
+func New(t testing.TB) *Env {
+	t.Helper()
+	root := t.TempDir()
+
+	cfg := config.Default()
+	cfg.CloudDir = filepath.Join(root, "Cloud")
+	cfg.TempDirs = []string{filepath.Join(root, "Temp")}
+	cfg.TinyMediaManagerDir = filepath.Join(root, "tmm")
+	cfg.Cache.Dir = filepath.Join(root, "cache")
+	cfg.TMDBApiKey = "test"
+	cfg.WaitTimeAfterScan = 0
+	cfg.WaitTimeAfterNFOEdit = 0
+
+	for _, dir := range []string{cfg.CloudDir, filepath.Join(cfg.TempDirs[0], "Movie"), filepath.Join(cfg.TempDirs[0], "TvShow")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+
+	env := &Env{
+		Root:   root,
+		Config: cfg,
+		TMDB:   NewFakeTMDB(),
+		saved: savedState{
+			config:   config.Current(),
+			inMemory: database.InMemory(),
+			home:     paths.Home(),
+			baseURL:  tmdb.BaseURL,
+			output:   logging.Output,
+		},
+	}
+	t.Cleanup(env.close)
+
+	// 程序目录也指向临时目录，测试不读写用户目录中的文件
+	paths.SetHome(filepath.Join(root, "home"))
+	config.Use(cfg)
+	database.SetInMemory(true)
+	tmdb.BaseURL = env.TMDB.URL()
+	tmdb.ResetCache()
+	if err := logging.SetOutput(logging.OutputStdout); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// close 关闭模拟服务器和内存数据库，恢复创建测试环境之前的全局状态
+func (e *Env) close() {
+	e.TMDB.Close()
+	database.SetInMemory(e.saved.inMemory)
+	config.Use(e.saved.config)
+	paths.SetHome(e.saved.home)
+	tmdb.BaseURL = e.saved.baseURL
+	tmdb.ResetCache()
+	logging.SetOutput(e.saved.output)
+}
+
+// TempPath 返回Temp目录下的路径，如 TempPath("Movie", "流浪地球2 (2023)")
+func (e *Env) TempPath(parts ...string) string {
+	return filepath.Join(append([]string{e.Config.TempDirs[0]}, parts...)...)
+}
+
+// CloudPath 返回媒体库目录下的路径，如 CloudPath("CnMovie", "流浪地球2 (2023)")
+func (e *Env) CloudPath(parts ...string) string {
+	return filepath.Join(append([]string{e.Config.CloudDir}, parts...)...)
+}
+
+// Exists 判断路径是否存在
+func Exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// FakeTMM 在tinyMediaManager目录中创建模拟的命令行程序（shell脚本），script为执行的命令，为空时直接成功退出
+// 只适用于类unix系统
+func (e *Env) FakeTMM(script string) error {
+	if script == "" {
+		script = "exit 0"
+	}
+	if err := os.MkdirAll(e.Config.TinyMediaManagerDir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	path := filepath.Join(e.Config.TinyMediaManagerDir, "tinymediamanager")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		return fmt.Errorf("创建模拟的tinyMediaManager失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package testkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TMDBItem 模拟的TMDB电影或电视剧详情
+type TMDBItem struct {
+	ID               int
+	Title            string
+	Overview         string
+	ReleaseDate      string   // 电影上映日期或电视剧首播日期（YYYY-MM-DD）
+	Countries        []string // 制作国家的ISO 3166-1代码，如 CN、US
+	OriginalLanguage string   // ISO 639-1代码，如 zh、en
+	Seasons          map[int]int
+}
+
+// FakeTMDB 基于httptest的模拟TMDB API，按请求路径返回预先设置的JSON，未设置的路径返回404
+type FakeTMDB struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	responses map[string][]byte // 键为不含 /3/ 前缀的路径，如 "movie/123"
+	requests  []string
+}
+
+// NewFakeTMDB 启动模拟的TMDB API服务器
+func NewFakeTMDB() *FakeTMDB {
+	f := &FakeTMDB{responses: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// URL 返回API根地址，可赋值给tmdb.BaseURL
+func (f *FakeTMDB) URL() string {
+	return f.server.URL + "/3/"
+}
+
+// Close 关闭服务器
+func (f *FakeTMDB) Close() {
+	f.server.Close()
+}
+
+// Handle 设置路径（如 "tv/123/episode_groups"）返回的内容，response会被编码为JSON
+func (f *FakeTMDB) Handle(path string, response interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		panic(err) // 测试代码中的夹具错误
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[strings.Trim(path, "/")] = body
+}
+
+// AddMovie 设置电影详情 movie/ID
+func (f *FakeTMDB) AddMovie(item TMDBItem) {
+	response := item.response()
+	response["title"] = item.Title
+	response["release_date"] = item.ReleaseDate
+	f.Handle("movie/"+strconv.Itoa(item.ID), response)
+}
+
+// AddTVShow 设置电视剧详情 tv/ID，季数和各季集数来自Seasons
+func (f *FakeTMDB) AddTVShow(item TMDBItem) {
+	response := item.response()
+	response["name"] = item.Title
+	response["first_air_date"] = item.ReleaseDate
+
+	var seasons []map[string]int
+	for season, episodes := range item.Seasons {
+		seasons = append(seasons, map[string]int{"season_number": season, "episode_count": episodes})
+	}
+	response["seasons"] = seasons
+	response["number_of_seasons"] = len(item.Seasons)
+	f.Handle("tv/"+strconv.Itoa(item.ID), response)
+}
+
+// Requests 返回收到的请求路径（不含 /3/ 前缀和查询参数），按请求顺序排列
+func (f *FakeTMDB) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// response 电影和电视剧详情的公共字段
+func (item TMDBItem) response() map[string]interface{} {
+	var countries []map[string]string
+	for _, code := range item.Countries {
+		countries = append(countries, map[string]string{"iso_3166_1": code, "name": code})
+	}
+	return map[string]interface{}{
+		"id":                   item.ID,
+		"overview":             item.Overview,
+		"original_language":    item.OriginalLanguage,
+		"production_countries": countries,
+	}
+}
+
+// serveHTTP 返回路径对应的JSON，未设置的路径按TMDB的格式返回404
+func (f *FakeTMDB) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/3/"), "/")
+
+	f.mu.Lock()
+	f.requests = append(f.requests, path)
+	body, ok := f.responses[path]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"success":false,"status_code":34,"status_message":"The resource you requested could not be found."}`))
+		return
+	}
+	w.Write(body)
+}
@@ -3,10 +3,10 @@ package logging
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/user/media-manager/utils"
+	"github.com/user/media-manager/paths"
 )
 
 // LogLevel 定义日志级别
@@ -34,75 +34,122 @@ var levelNames = map[LogLevel]string{
 	FatalLevel:   "FATAL",
 }
 
-// CurrentLevel 当前日志级别
+// 日志输出后端
+const (
+	OutputFile   = "file"   // 写入按日期命名的日志文件，同时输出到控制台
+	OutputStdout = "stdout" // 只输出到控制台（标准输出），由systemd等服务管理器收集
+	OutputSyslog = "syslog" // 写入syslog（journald），同时输出到控制台
+)
+
+// syslogTag syslog中的程序标识
+const syslogTag = "media-manager"
+
+// Output 当前的日志输出后端
+var Output = OutputFile
+
+// SetOutput 设置日志输出后端，syslog连接失败时返回错误并保持原后端
+func SetOutput(output string) error {
+	switch output {
+	case OutputFile, OutputStdout:
+	case OutputSyslog:
+		if err := openSyslog(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("未知的日志输出: %s", output)
+	}
+	Output = output
+	return nil
+}
+
+// CurrentLevel 写入日志文件的级别
 var CurrentLevel = InfoLevel
 
-// SetLogLevel 设置日志级别
+// ConsoleLevel 输出到控制台的级别，与日志文件的级别相互独立
+var ConsoleLevel = InfoLevel
+
+// SetLogLevel 设置写入日志文件的级别
 func SetLogLevel(level LogLevel) {
 	CurrentLevel = level
 }
 
-// GetLogFilePath 获取日志文件路径
-func GetLogFilePath() string {
-	var logsDir string
-	var err error
-
-	// 1. 首先检查用户当前目录下是否存在logs目录（只检查不创建）
-	currentDir, err := os.Getwd()
-	if err == nil {
-		logsDir = filepath.Join(currentDir, "logs")
-		if _, err := os.Stat(logsDir); err == nil {
-			logFileName := time.Now().Format("2006-01-02") + ".log"
-			return filepath.Join(logsDir, logFileName)
-		}
-	}
+// SetConsoleLevel 设置输出到控制台的级别
+func SetConsoleLevel(level LogLevel) {
+	ConsoleLevel = level
+}
 
-	// 2. 检查程序执行文件所在目录下是否存在logs目录（只检查不创建）
-	exeDir, err := utils.GetExecutableDir()
-	if err == nil {
-		logsDir = filepath.Join(exeDir, "logs")
-		if _, err := os.Stat(logsDir); err == nil {
-			logFileName := time.Now().Format("2006-01-02") + ".log"
-			return filepath.Join(logsDir, logFileName)
+// ParseLevel 解析日志级别名称（debug、info、warning、error），无法识别时返回false
+func ParseLevel(name string) (LogLevel, bool) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, true
 		}
 	}
-
-	// 3. 最后使用用户主目录下的.media-manager/logs目录（不存在则创建）
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		logsDir = filepath.Join(homeDir, ".media-manager", "logs")
-		// 确保用户主目录下的日志目录存在
-		if err := os.MkdirAll(logsDir, 0755); err == nil {
-			logFileName := time.Now().Format("2006-01-02") + ".log"
-			return filepath.Join(logsDir, logFileName)
-		}
+	if strings.EqualFold(name, "warn") {
+		return WarningLevel, true
 	}
+	return InfoLevel, false
+}
+
+// GetLogFilePath 获取当前的日志文件路径，logs目录的查找顺序见paths.Dir
+func GetLogFilePath() (string, error) {
+	return logFilePath(time.Now())
+}
 
-	// 如果所有尝试都失败，输出错误并退出
-	fmt.Printf("无法创建日志目录\n")
-	os.Exit(1)
-	return "" // 永远不会执行到这里
+// logFilePath 返回时间t所在日期的日志文件路径，文件名与日志内容使用相同的时区（timezone配置）
+func logFilePath(t time.Time) (string, error) {
+	logPath, err := paths.File(paths.Logs, t.Format("2006-01-02")+".log")
+	if err != nil {
+		return "", fmt.Errorf("无法创建日志目录: %w", err)
+	}
+	return logPath, nil
 }
 
 // log 记录日志的通用函数
 func log(level LogLevel, format string, args ...interface{}) {
-	// 如果当前级别低于设置的级别，不记录日志
-	if level < CurrentLevel {
+	write(level, level >= ConsoleLevel, format, args...)
+}
+
+// write 生成日志内容，console为true时输出到控制台，级别不低于CurrentLevel时写入日志文件或syslog
+// 输出后端为stdout时只输出到控制台
+func write(level LogLevel, console bool, format string, args ...interface{}) {
+	toBackend := level >= CurrentLevel && Output != OutputStdout
+	if !console && !toBackend {
 		return
 	}
 
-	// 获取当前时间
-	currentTime := time.Now().Format("2006-01-02 15:04:05")
+	// 获取当前时间，日志文件名使用同一时间，跨零点时日志内容与文件名的日期一致
+	now := time.Now()
+	currentTime := now.Format("2006-01-02 15:04:05")
 
 	// 生成日志内容
-	logContent := fmt.Sprintf("[%s] %s: %s\n", currentTime, levelNames[level], fmt.Sprintf(format, args...))
+	message := fmt.Sprintf(format, args...)
+	logContent := fmt.Sprintf("[%s] %s: %s\n", currentTime, levelNames[level], message)
 
 	// 输出到控制台
-	fmt.Print(logContent)
+	if console {
+		fmt.Print(logContent)
+	}
+
+	if !toBackend {
+		return
+	}
+
+	// 写入syslog，syslog自带时间和优先级
+	if Output == OutputSyslog {
+		if err := writeSyslog(level, message); err != nil {
+			fmt.Printf("写入syslog失败: %v\n", err)
+		}
+		return
+	}
 
 	// 写入日志文件
-	logFilePath := GetLogFilePath()
-	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logPath, err := logFilePath(now)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		fmt.Printf("无法打开日志文件: %v\n", err)
 		return
@@ -111,12 +158,6 @@ func log(level LogLevel, format string, args ...interface{}) {
 
 	if _, err := file.WriteString(logContent); err != nil {
 		fmt.Printf("写入日志文件失败: %v\n", err)
-		return
-	}
-
-	// 如果是致命级别，程序退出
-	if level == FatalLevel {
-		os.Exit(1)
 	}
 }
 
@@ -140,7 +181,12 @@ func Error(format string, args ...interface{}) {
 	log(ErrorLevel, format, args...)
 }
 
-// Fatal 记录致命级别日志并退出程序
+// Summary 记录运行摘要，安静模式下也输出到控制台，日志文件中按信息级别记录
+func Summary(format string, args ...interface{}) {
+	write(InfoLevel, true, format, args...)
+}
+
+// Fatal 记录致命级别日志，不退出程序，由调用方返回错误后在main中退出
 func Fatal(format string, args ...interface{}) {
 	log(FatalLevel, format, args...)
 }
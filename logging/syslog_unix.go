@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogWriter 已连接的syslog，journald运行时写入journald
+var syslogWriter *syslog.Writer
+
+// openSyslog 连接本机的syslog
+func openSyslog() error {
+	if syslogWriter != nil {
+		return nil // 重新加载配置时沿用已有的连接
+	}
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+	if err != nil {
+		return fmt.Errorf("连接syslog失败: %w", err)
+	}
+	syslogWriter = writer
+	return nil
+}
+
+// writeSyslog 按日志级别对应的优先级写入syslog
+func writeSyslog(level LogLevel, message string) error {
+	if syslogWriter == nil {
+		return fmt.Errorf("syslog未连接")
+	}
+	switch level {
+	case DebugLevel:
+		return syslogWriter.Debug(message)
+	case InfoLevel:
+		return syslogWriter.Info(message)
+	case WarningLevel:
+		return syslogWriter.Warning(message)
+	case ErrorLevel:
+		return syslogWriter.Err(message)
+	default:
+		return syslogWriter.Crit(message)
+	}
+}
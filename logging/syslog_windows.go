@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package logging
+
+import "fmt"
+
+// openSyslog Windows不支持syslog
+func openSyslog() error {
+	return fmt.Errorf("Windows不支持syslog输出")
+}
+
+// writeSyslog Windows不支持syslog
+func writeSyslog(level LogLevel, message string) error {
+	return fmt.Errorf("Windows不支持syslog输出")
+}
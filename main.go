@@ -1,93 +1,306 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata" // Docker等精简镜像中可能没有时区数据库，timezone配置需要内置的时区数据
 
+	"github.com/user/media-manager/cache"
 	"github.com/user/media-manager/classifier"
 	"github.com/user/media-manager/config"
 	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/hooks"
 	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/metrics"
+	"github.com/user/media-manager/mqtt"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/paths"
 	"github.com/user/media-manager/processor"
 	"github.com/user/media-manager/scraper"
+	"github.com/user/media-manager/stats"
+	"github.com/user/media-manager/tmdb"
+	"github.com/user/media-manager/utils"
 )
 
-// 定义命令行参数
+// appVersion 程序版本
+const appVersion = "1.0.0"
+
+// 全局参数，适用于所有子命令
+var (
+	refreshTMDB = flag.Bool("refresh-tmdb", false, "重新查询之前TMDB返回404的条目")
+	quiet       = flag.Bool("quiet", false, "控制台只输出错误和运行摘要，适合在cron中使用")
+	verbose     = flag.Bool("verbose", false, "控制台输出调试信息")
+	dryRun      = flag.Bool("dry-run", false, dryRunUsage)
+	jsonOutput  = flag.Bool("json", false, "与-dry-run一起使用，以JSON格式输出")
+	homeDir     = flag.String("home", "", "程序根目录，其中的config、Data、logs、cache、reports目录分别保存配置、数据库、日志、缓存和生成的文件，适合从U盘等位置便携运行；也可以用环境变量MEDIA_MANAGER_HOME指定")
+)
+
+// 旧版的操作参数，等同于对应的子命令，保留以兼容已有的计划任务和脚本，不在帮助信息中显示
 var (
-	nfoFile      = flag.String("nfo", "", "指定NFO文件路径")
-	movieDir     = flag.String("dir", "", "指定影片目录路径")
-	scrapeMovies = flag.Bool("scrape-movies", false, "执行电影刮削")
-	scrapeTV     = flag.Bool("scrape-tv", false, "执行电视剧刮削")
-	scrapeAll    = flag.Bool("scrape-all", false, "执行所有刮削")
-	configCmd    = flag.Bool("config", false, "查看或修改配置")
-	detectCmd    = flag.Bool("detect-missing", false, "检测数据库中所有电视剧的缺失季和剧集")
+	nfoFile      = flag.String("nfo", "", "已弃用，使用 process <NFO文件>")
+	movieDir     = flag.String("dir", "", "已弃用，使用 process <影片目录>")
+	scrapeMovies = flag.Bool("scrape-movies", false, "已弃用，使用 scrape movies")
+	scrapeTV     = flag.Bool("scrape-tv", false, "已弃用，使用 scrape tv")
+	scrapeAll    = flag.Bool("scrape-all", false, "已弃用，使用 scrape all")
+	configCmd    = flag.Bool("config", false, "已弃用，使用 config")
+	detectCmd    = flag.Bool("detect-missing", false, "已弃用，使用 missing detect")
+	maxItems     = flag.Int("max-items", 0, maxItemsUsage)
+	maxDuration  = flag.Duration("max-duration", 0, maxDurationUsage)
+	onlyCategory = flag.String("only-category", "", onlyCategoryUsage)
+	titleFilter  = flag.String("title", "", titleFilterUsage)
 )
 
+// legacyFlags 旧版的操作参数名
+var legacyFlags = map[string]bool{
+	"nfo": true, "dir": true, "scrape-movies": true, "scrape-tv": true, "scrape-all": true, "config": true,
+	"detect-missing": true, "max-items": true, "max-duration": true, "only-category": true, "title": true,
+}
+
 // main是应用程序的入口点
 func main() {
 	// 解析命令行参数
+	flag.Usage = printUsage
 	flag.Parse()
+	tmdb.RefreshNotFound = *refreshTMDB
+	if *homeDir != "" {
+		paths.SetHome(*homeDir)
+	}
+	applyRunFlags()
+
+	// 先检查配置文件，之后各模块读取配置不会失败
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		exit(1)
+	}
+	applyConfig(cfg)
 
 	// 记录程序启动信息
-	logging.Info("程序启动，版本: 1.0.0")
+	logging.Info("程序启动，版本: %s", appVersion)
 
 	// 检查是否为单进程
 	if !ensureSingleProcess() {
 		logging.Error("程序已经在运行中，退出")
-		os.Exit(1)
+		exit(1)
+	}
+
+	if *dryRun {
+		if err := enableDryRun(); err != nil {
+			logging.Error("%v", err)
+			exit(1)
+		}
+	}
+
+	args := flag.Args()
+	if legacy := legacyCommand(); legacy != nil {
+		logging.Warning("参数 -%s 已弃用，请使用子命令: %s", legacy[0], strings.Join(legacy[1:], " "))
+		args = append(legacy[1:], args...)
 	}
 
-	// 处理配置命令
-	if *configCmd {
-		logging.Info("处理配置命令")
-		showConfig()
-		os.Exit(0)
+	// 如果没有提供子命令，显示帮助信息
+	if len(args) == 0 {
+		logging.Info("没有提供命令行参数，显示帮助信息")
+		flag.Usage()
+		exit(0)
 	}
 
-	// 处理批量检测缺失季和剧集命令
-	if *detectCmd {
-		logging.Info("处理批量检测缺失季和剧集命令")
-		batchDetectMissing()
-		os.Exit(0)
+	cmd := findSubcommand(args[0])
+	if cmd == nil {
+		logging.Error("未知的子命令: %s", args[0])
+		flag.Usage()
+		exit(1)
 	}
+	logging.Info("处理子命令: %s", cmd.Name)
+	if err := cmd.Run(args[1:]); err != nil {
+		logging.Error("%v", err)
+		exit(1)
+	}
+	exit(0)
+}
+
+// exit 删除模拟运行使用的数据库副本后退出程序，main包中的退出都经过这里
+func exit(code int) {
+	database.CleanupDryRun()
+	os.Exit(code)
+}
 
-	// 处理刮削命令
-	if *scrapeMovies || *scrapeTV || *scrapeAll {
-		logging.Info("处理刮削命令")
-		handleScrape()
-		os.Exit(0)
+// enableDryRun 启用模拟运行：NFO的修改、移动、删除和钩子只输出到日志，数据库的读写使用数据库文件的副本，已启用时直接返回
+func enableDryRun() error {
+	if database.IsDryRun() {
+		return nil
+	}
+	if err := database.SetDryRun(); err != nil {
+		return err
 	}
+	parser.DryRun = true
+	classifier.DryRun = true
+	hooks.DryRun = true
+	logging.Info("模拟运行：不修改NFO文件、媒体库和数据库，只输出将要执行的操作")
+	return nil
+}
 
-	// 处理NFO文件
-	if *nfoFile != "" {
-		logging.Info("处理单个NFO文件: %s", *nfoFile)
-		handleSingleNFO(*nfoFile)
-		os.Exit(0)
+// appConfig 返回缓存的配置；启动时已用config.Load检查过配置文件，之后只有守护进程热加载成功时才会替换
+// 读取失败时输出错误并退出
+func appConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Error("%v", err)
+		exit(1)
 	}
+	return cfg
+}
 
-	// 处理影片目录
-	if *movieDir != "" {
-		logging.Info("处理影片目录: %s", *movieDir)
-		handleMovieDir(*movieDir)
-		os.Exit(0)
+// legacyCommand 把旧版的操作参数转换为对应的子命令，返回参数名和子命令及其参数，没有使用旧版参数时返回nil
+func legacyCommand() []string {
+	switch {
+	case *configCmd:
+		return []string{"config", "config"}
+	case *detectCmd:
+		return []string{"detect-missing", "missing", "detect"}
+	case *scrapeAll:
+		return []string{"scrape-all", "scrape", "all"}
+	case *scrapeMovies:
+		return []string{"scrape-movies", "scrape", "movies"}
+	case *scrapeTV:
+		return []string{"scrape-tv", "scrape", "tv"}
+	case *nfoFile != "":
+		return []string{"nfo", "process", *nfoFile}
+	case *movieDir != "":
+		return []string{"dir", "process", *movieDir}
 	}
+	return nil
+}
+
+// applyConfig 按配置设置各模块的参数，启动时和守护进程重新加载配置后调用
+func applyConfig(cfg *config.Config) {
+	parser.NFOBackups = cfg.NFOBackups
+	if cfg.NFOBackups > 0 {
+		if dir, err := cache.KindDir(cache.KindNFOBackups); err != nil {
+			logging.Warning("%v，NFO备份保存在NFO文件旁边", err)
+		} else {
+			parser.NFOBackupDir = dir
+		}
+	}
+	metrics.SetThresholds(cfg.SlowThresholds)
+	database.RecordYearTolerance = max(cfg.RecordYearTolerance, 0)
+	applyTimezone(cfg)
+	configureLogging()
+}
 
-	// 如果没有提供任何命令行参数，显示帮助信息
-	logging.Info("没有提供命令行参数，显示帮助信息")
-	flag.Usage()
-	os.Exit(0)
+// systemLocation 程序启动时的系统时区，timezone配置被清空时恢复
+var systemLocation = time.Local
+
+// applyTimezone 按timezone配置设置日志、日志文件名和命令输出使用的时区，配置无效时使用系统时区
+// 数据库中的时间以UTC保存，读取时转换为这里设置的时区
+func applyTimezone(cfg *config.Config) {
+	loc, err := cfg.Location()
+	if err != nil {
+		logging.Warning("%v，使用系统时区", err)
+	}
+	if loc == nil {
+		loc = systemLocation
+	}
+	if time.Local.String() != loc.String() {
+		time.Local = loc
+	}
+}
+
+// configureLogging 按配置设置日志输出后端和级别，按-quiet、-verbose参数设置控制台输出的级别
+// 输出后端为stdout且没有指定-quiet、-verbose时，控制台按log_level输出
+func configureLogging() {
+	cfg := appConfig()
+	if err := logging.SetOutput(cfg.LogOutput); err != nil {
+		logging.Warning("%v，使用 %s", err, logging.Output)
+	}
+	if level, ok := logging.ParseLevel(cfg.LogLevel); ok {
+		logging.SetLogLevel(level)
+	} else {
+		logging.Warning("无法识别的日志级别: %s，使用 %s", cfg.LogLevel, config.DefaultLogLevel)
+	}
+
+	switch {
+	case *quiet, *dryRun && *jsonOutput:
+		// JSON输出到stdout，日志不能混在其中
+		logging.SetConsoleLevel(logging.ErrorLevel)
+	case *verbose:
+		logging.SetConsoleLevel(logging.DebugLevel)
+	case logging.Output == logging.OutputStdout:
+		logging.SetConsoleLevel(logging.CurrentLevel)
+	}
+}
+
+// runPostRunHooks 在一次运行结束后保存耗时统计、清理过期的最近入库链接，发布统计数字到MQTT，并执行post_run钩子
+// 返回post_run钩子的错误，由调用方决定是否退出：单次运行以非零状态退出，守护进程记录后继续运行
+func runPostRunHooks(mode string, paths []string, startedAt time.Time) error {
+	// 模拟运行时不清理链接和缓存，也不发布统计数字
+	if database.IsDryRun() {
+		return nil
+	}
+	cfg := appConfig()
+	if err := metrics.SaveRun(); err != nil {
+		logging.Error("%v", err)
+	}
+	if err := classifier.PruneRecentLinks(cfg); err != nil {
+		logging.Error("%v", err)
+	}
+	pruneCache(cfg)
+	pruneDecisionRecords(cfg)
+	run := &hooks.Run{
+		Mode:       mode,
+		Paths:      paths,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+	publishStats(cfg)
+	return hooks.RunFinished(cfg.Hooks, run)
+}
+
+// publishStats 配置了MQTT代理时把运行后的统计数字作为保留消息发布到 前缀/stats，新订阅者可以立即收到最新的数字
+func publishStats(cfg *config.Config) {
+	if !cfg.MQTT.Enabled() {
+		return
+	}
+	s, err := stats.Collect(cfg)
+	if err != nil {
+		logging.Error("%v", err)
+		return
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		logging.Error("序列化统计数字失败: %v", err)
+		return
+	}
+	if err := mqtt.Publish(cfg.MQTT, cfg.MQTT.Topic("stats"), payload, true); err != nil {
+		logging.Warning("发布统计数字到MQTT失败: %v", err)
+	}
 }
 
 // showConfig显示当前配置
 func showConfig() {
-	cfg := config.LoadConfig()
+	cfg := appConfig()
 	fmt.Println("当前配置:")
 	fmt.Printf("Cloud目录: %s\n", cfg.CloudDir)
+	if cfg.MovieCloudDir != "" {
+		fmt.Printf("电影Cloud目录: %s\n", cfg.MovieCloudDir)
+	}
+	if cfg.ShowCloudDir != "" {
+		fmt.Printf("电视剧Cloud目录: %s\n", cfg.ShowCloudDir)
+	}
+	for category, dir := range cfg.CategoryDirs {
+		fmt.Printf("分类 %s 目录: %s\n", category, dir)
+	}
 	fmt.Printf("TinyMediaManager目录: %s\n", cfg.TinyMediaManagerDir)
+	if cfg.TMMDocker.Enabled() {
+		fmt.Printf("TinyMediaManager容器: %s:%s\n", cfg.TMMDocker.Image, cfg.TMMDocker.Tag)
+	}
 	fmt.Println("临时目录:")
 	for i, tempDir := range cfg.TempDirs {
 		fmt.Printf("  %d. %s\n", i+1, tempDir)
@@ -98,31 +311,33 @@ func showConfig() {
 }
 
 // handleScrape处理刮削命令
-func handleScrape() {
+// scrapeType为刮削类型：all, movies, tv
+func handleScrape(scrapeType string) error {
 	var err error
-	var scrapeType string // 记录刮削类型：all, movies, tv
+	limiter := newRunLimiter()
+	classifier.MonitorFreeSpace(appConfig())
+	if err := classifier.MonitorWritable(appConfig()); err != nil {
+		return err
+	}
 
-	if *scrapeAll {
+	switch scrapeType {
+	case "all":
 		// 执行所有刮削
 		err = scraper.ScrapeAll()
-		scrapeType = "all"
-	} else if *scrapeMovies {
+	case "movies":
 		// 执行电影刮削
 		err = scraper.ScrapeMovies()
-		scrapeType = "movies"
-	} else if *scrapeTV {
+	case "tv":
 		// 执行电视剧刮削
 		err = scraper.ScrapeTVShows()
-		scrapeType = "tv"
 	}
 
 	if err != nil {
-		logging.Error("刮削失败: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("刮削失败: %w", err)
 	}
 
 	// 加载配置获取等待时间
-	cfg := config.LoadConfig()
+	cfg := appConfig()
 	if cfg.WaitTimeAfterScan > 0 {
 		logging.Info("刮削完成，等待 %d 秒后开始处理NFO文件...", cfg.WaitTimeAfterScan)
 		time.Sleep(time.Duration(cfg.WaitTimeAfterScan) * time.Second)
@@ -131,15 +346,7 @@ func handleScrape() {
 	logging.Info("开始处理NFO文件...")
 
 	// 根据刮削类型确定要扫描的子目录
-	targetSubdirs := []string{}
-	switch scrapeType {
-	case "all":
-		targetSubdirs = []string{"Movie", "TvShow"}
-	case "movies":
-		targetSubdirs = []string{"Movie"}
-	case "tv":
-		targetSubdirs = []string{"TvShow"}
-	}
+	targetSubdirs := scrapeSubdirs(scrapeType)
 
 	// 先检查所有相关目录是否有多个NFO文件
 	for _, tempDir := range cfg.TempDirs {
@@ -148,12 +355,17 @@ func handleScrape() {
 			logging.Info("开始检查目录 %s 的结构，确保没有包含多个NFO文件的子目录", scanDir)
 
 			// 检查该目录下的所有子目录
-			err := filepath.Walk(scanDir, func(path string, info os.FileInfo, err error) error {
+			err := utils.Walk(scanDir, appConfig().SymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
 				if err != nil {
 					logging.Debug("访问路径失败: %s, 错误: %v", path, err)
 					return nil // 忽略访问错误
 				}
 
+				if info.IsDir() && utils.HasIgnoreMarker(path) {
+					logging.Info("目录 %s 包含忽略标记文件，跳过", path)
+					return filepath.SkipDir
+				}
+
 				if info.IsDir() && path != scanDir {
 					// 检查该目录是否包含媒体文件
 					if hasMediaFiles(path) {
@@ -172,7 +384,7 @@ func handleScrape() {
 						}
 
 						if nfoCount > 1 {
-							logging.Error("目录 %s 下存在 %d 个NFO文件，将跳过该目录的处理。请手动选择正确的NFO文件后再处理。", path, nfoCount)
+							logMultipleNFO(path, nfoCount, cfg.NFOSelection)
 						}
 					}
 				}
@@ -189,139 +401,395 @@ func handleScrape() {
 
 	logging.Info("所有目录结构检查完成")
 
-	// 查找指定子目录下的NFO文件
-	var nfoFiles []string
+	// 恢复上次异常退出时遗留的正在处理项目
+	if count, err := database.ResetStaleQueueItems(); err != nil {
+		logging.Error("%v", err)
+	} else if count > 0 {
+		logging.Info("已将上次未完成的 %d 个队列项目恢复为等待处理", count)
+	}
+
+	// 查找指定子目录下的NFO文件并加入处理队列
+	// 电视剧优先于电影，之前处理过的项目重新入队时排在最后
+	// 指定了处理范围时只加入和取出范围内的项目，其他项目留在队列中等待之后的运行
+	filter := newFilterMatcher(cfg)
+	var scanDirs []string
+	foundCount := 0
 	for _, tempDir := range cfg.TempDirs {
 		for _, subdir := range targetSubdirs {
 			scanDir := filepath.Join(tempDir, subdir)
+			scanDirs = append(scanDirs, scanDir)
 			logging.Info("开始遍历目录 %s 查找NFO文件", scanDir)
 			files, err := findNFOFiles(scanDir)
 			if err != nil {
 				logging.Error("在目录 %s 中查找NFO文件失败: %v", scanDir, err)
 				continue
 			}
-			nfoFiles = append(nfoFiles, files...)
+
+			priority := database.QueuePriorityMovie
+			if subdir == "TvShow" {
+				priority = database.QueuePriorityTVShow
+			}
+			for _, file := range files {
+				if !filter.match(file) {
+					continue
+				}
+				if err := database.EnqueueItem(file, priority); err != nil {
+					logging.Error("%v", err)
+					continue
+				}
+				foundCount++
+			}
 		}
 	}
 
-	if len(nfoFiles) == 0 {
+	if foundCount == 0 {
 		logging.Info("没有找到NFO文件")
-		os.Exit(0)
 	}
 
-	// 处理每个NFO文件
-	for _, nfoFile := range nfoFiles {
-		logging.Info("------------------------")
-		logging.Info("开始处理NFO文件: %s", nfoFile)
+	// 按优先级依次取出队列中的项目，由metadata个任务并发处理，移动影片的任务数由move限制
+	workers := cfg.Workers(config.ConcurrencyMetadata)
+	if workers > 1 {
+		logging.Info("同时处理 %d 个项目，最多同时移动 %d 个影片", workers, cfg.Workers(config.ConcurrencyMove))
+	}
+	stopped, paused := false, false
+	startedAt := time.Now()
+	var processed, moved, failed int
+	var countMu sync.Mutex
+	items := make(chan *database.QueueItem)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				countMu.Lock()
+				processed++
+				logging.Info("------------------------")
+				logging.Info("[%d/%d] 开始处理NFO文件: %s（优先级 %d，第 %d 次处理）", processed, max(foundCount, processed), item.NFOPath, item.Priority, item.Attempts)
+				countMu.Unlock()
+
+				// 并发处理时各项目的操作交替进行，不单独统计每个项目的耗时
+				if workers == 1 {
+					metrics.StartItem()
+				}
+				processErr := processWithRetry(item, cfg)
+				if workers == 1 {
+					metrics.FinishItem(filepath.Base(filepath.Dir(item.NFOPath)))
+				}
 
-		// 处理类型字段
-		genreModified, err := processor.ProcessGenre(nfoFile)
-		if err != nil {
-			logging.Error("处理类型字段失败: %v", err)
-			continue
+				isMoved := false
+				if processErr != nil {
+					logging.Error("%v", processErr)
+				} else {
+					logging.Info("NFO文件处理完成: %s", item.NFOPath)
+					// NFO文件已不在原位置说明影片已移动到媒体库
+					if _, err := os.Stat(item.NFOPath); os.IsNotExist(err) {
+						isMoved = true
+					}
+				}
+
+				// 媒体库目录没有挂载或只读时项目放回队列，暂停处理，恢复后重新处理
+				if classifier.IsUnavailable(processErr) {
+					if err := database.ReleaseQueueItem(item.ID, processErr); err != nil {
+						logging.Error("%v", err)
+					}
+					countMu.Lock()
+					processed--
+					paused = true
+					countMu.Unlock()
+					continue
+				}
+
+				if err := database.CompleteQueueItem(item.ID, processErr); err != nil {
+					logging.Error("%v", err)
+				}
+
+				countMu.Lock()
+				if processErr != nil {
+					failed++
+				} else if isMoved {
+					moved++
+				}
+				countMu.Unlock()
+			}
+		}()
+	}
+
+	for {
+		countMu.Lock()
+		isPaused := paused
+		countMu.Unlock()
+		if isPaused {
+			logging.Error("媒体库目录没有挂载或不可写入，暂停处理，剩余项目留在队列中，恢复后继续")
+			stopped = true
+			break
+		}
+		if reached, reason := limiter.reached(); reached {
+			logging.Info("%s，停止本次处理，剩余项目留在队列中下次运行时继续", reason)
+			stopped = true
+			break
 		}
 
-		// 处理演员字段
-		report, err := processor.ProcessActor(nfoFile)
+		item, err := database.NextMatchingQueueItem(filter.queueMatch(), scanDirs...)
 		if err != nil {
-			logging.Error("处理演员字段失败: %v", err)
-			continue
+			logging.Error("%v", err)
+			break
+		}
+		if item == nil {
+			break
+		}
+		limiter.done()
+
+		items <- item
+	}
+	close(items)
+	wg.Wait()
+
+	if !stopped {
+		logging.Info("所有NFO文件处理完成")
+	}
+	logging.Summary("本次处理 %d 个NFO文件：移动 %d 个，跳过 %d 个，失败 %d 个，耗时 %v",
+		processed, moved, processed-moved-failed, failed, time.Since(startedAt).Round(time.Second))
+	if err := database.SaveRunSummary(database.RunSummary{
+		FinishedAt:      time.Now(),
+		Processed:       processed,
+		Moved:           moved,
+		Skipped:         processed - moved - failed,
+		Failed:          failed,
+		DurationSeconds: int(time.Since(startedAt).Seconds()),
+	}); err != nil {
+		logging.Error("%v", err)
+	}
+
+	// 跟踪没有NFO文件的未刮削项目
+	for _, tempDir := range cfg.TempDirs {
+		for _, subdir := range targetSubdirs {
+			scanDir := filepath.Join(tempDir, subdir)
+			if err := classifier.RouteUnscrapedItems(scanDir, subdir == "TvShow"); err != nil {
+				logging.Error("检查未刮削项目失败: %v", err)
+			}
 		}
+	}
 
-		if len(report.Actors) > 0 {
-			logging.Info("发现 %d 个非中文演员名称", len(report.Actors))
+	if paused {
+		if err := classifier.MonitorWritable(cfg); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// processNFOFile处理队列中的一个NFO文件：规范化类型和演员字段后分类并移动
+func processNFOFile(nfoFile string, cfg *config.Config) error {
+	if _, err := os.Stat(nfoFile); os.IsNotExist(err) {
+		return fmt.Errorf("NFO文件已不存在: %s", nfoFile)
+	}
+
+	// 项目还在复制或下载中时不修改NFO，下次运行时重新检查
+	if !classifier.CheckIntake(nfoFile, cfg) {
+		return nil
+	}
+	if err := database.RecordEvent(0, filepath.Dir(nfoFile), database.EventScraped, filepath.Base(nfoFile)); err != nil {
+		logging.Error("%v", err)
+	}
+
+	// 规范化NFO字段并一次性写回
+	modified, err := runNFOProcessors(nfoFile)
+	if err != nil {
+		return err
+	}
+
+	// 只有当NFO文件被修改时才等待指定时间
+	if cfg.WaitTimeAfterNFOEdit > 0 && modified && !parser.DryRun {
+		logging.Info("NFO文件编辑完成，等待 %d 秒后开始移动文件...", cfg.WaitTimeAfterNFOEdit)
+		time.Sleep(time.Duration(cfg.WaitTimeAfterNFOEdit) * time.Second)
+	}
+
+	// 分类并移动影片，被规则跳过的项目已记录原因，下次运行时重新检查，不算失败
+	if err := classifier.ClassifyAndMove(nfoFile); err != nil && !errs.IsSkip(err) {
+		return fmt.Errorf("分类和移动影片失败: %w", err)
+	}
+	return nil
+}
 
-		// 只有当NFO文件被修改时才等待指定时间
-		if cfg.WaitTimeAfterNFOEdit > 0 && genreModified {
-			logging.Info("NFO文件编辑完成，等待 %d 秒后开始移动文件...", cfg.WaitTimeAfterNFOEdit)
-			time.Sleep(time.Duration(cfg.WaitTimeAfterNFOEdit) * time.Second)
+// processWithRetry 处理队列中的项目，失败时按retry配置等待后重试，每次重试计入队列项目的处理次数
+// 被规则跳过、NFO文件已不存在和重试也不会成功的错误（路径过长、超出配置的目录、TMDB中不存在）不重试
+// 最终仍然失败的项目记录到问题项目表，多次运行都失败的项目在日志中提示检查
+func processWithRetry(item *database.QueueItem, cfg *config.Config) error {
+	maxAttempts := cfg.Retry.Attempts()
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = processNFOFile(item.NFOPath, cfg)
+		if err != nil && !classifier.IsUnavailable(err) {
+			// 移动失败可能是挂载变为只读引起的，复制错误不一定带有EROFS
+			if writeErr := classifier.CheckTargetsWritable(cfg); writeErr != nil {
+				err = fmt.Errorf("%w（%v）", writeErr, err)
+			}
 		}
+		if err == nil || attempt >= maxAttempts || !isRetryable(item.NFOPath, err) {
+			break
+		}
+		logging.Warning("处理失败（第 %d/%d 次）: %v，%d 秒后重试", attempt, maxAttempts, err, cfg.Retry.DelaySeconds)
+		if dbErr := database.RetryQueueItem(item.ID, err); dbErr != nil {
+			logging.Error("%v", dbErr)
+		}
+		time.Sleep(cfg.Retry.Delay())
+	}
+	if err == nil {
+		return nil
+	}
+	// 媒体库目录没有挂载或只读时项目本身没有问题，不记录为问题项目，由调用方暂停处理
+	if classifier.IsUnavailable(err) {
+		return err
+	}
 
-		// 分类并移动影片
-		if err := classifier.ClassifyAndMove(nfoFile); err != nil {
-			logging.Error("分类和移动影片失败: %v", err)
-			continue
+	mediaDir := filepath.Dir(item.NFOPath)
+	if absDir, absErr := filepath.Abs(mediaDir); absErr == nil {
+		mediaDir = absDir
+	}
+	problem, dbErr := database.RecordProblemItem(mediaDir, "处理失败: "+err.Error())
+	if dbErr != nil {
+		logging.Error("记录问题项目失败: %v", dbErr)
+	} else if problem.Attempts > 1 {
+		logging.Error("项目已在 %d 次运行中处理失败（从 %s 开始），请检查: %s", problem.Attempts, problem.FirstSeenAt.Format("2006-01-02"), mediaDir)
+	}
+	return err
+}
+
+// isRetryable 判断处理失败的项目是否值得重试：可能是暂时性的错误（TMDB请求失败、NAS短暂不可用等）且NFO文件仍在原位置
+func isRetryable(nfoPath string, err error) bool {
+	if classifier.IsUnavailable(err) || errs.IsSkip(err) || errors.Is(err, errs.ErrPathTooLong) || errors.Is(err, errs.ErrOutsideRoots) || errors.Is(err, errs.ErrTMDBNotFound) {
+		return false
+	}
+	_, statErr := os.Stat(nfoPath)
+	return statErr == nil
+}
+
+// runNFOProcessors加载NFO文档，依次规范化类型、演员、TMDb ID和年份字段并补充简介，最后一次性原子写回，返回是否修改了文件
+// 锁定的媒体库目录（db lock）不修改
+func runNFOProcessors(nfoPath string) (bool, error) {
+	if classifier.IsLocked(filepath.Dir(nfoPath)) {
+		logging.Info("目录已锁定（db lock），不修改NFO: %s", nfoPath)
+		return false, nil
+	}
+	doc, err := parser.LoadDocument(nfoPath)
+	if err != nil {
+		return false, fmt.Errorf("加载NFO文件失败: %w", err)
+	}
+
+	// 处理类型字段
+	genres := strings.Join(doc.NFO.Genres, ", ")
+	if translated, err := processor.ProcessGenre(doc); err != nil {
+		return false, fmt.Errorf("处理类型字段失败: %w", err)
+	} else if translated {
+		detail := fmt.Sprintf("%s → %s", genres, strings.Join(doc.NFO.Genres, ", "))
+		if err := database.RecordEvent(0, filepath.Dir(nfoPath), database.EventGenreTranslated, detail); err != nil {
+			logging.Error("%v", err)
 		}
+	}
 
-		logging.Info("NFO文件处理完成: %s", nfoFile)
+	// 处理演员字段
+	report, err := processor.ProcessActor(doc)
+	if err != nil {
+		return false, fmt.Errorf("处理演员字段失败: %w", err)
+	}
+	if len(report.Actors) > 0 {
+		logging.Info("发现 %d 个非中文演员名称", len(report.Actors))
 	}
 
-	logging.Info("所有NFO文件处理完成")
+	// 只有IMDb ID时补充TMDb ID
+	if _, err := processor.ProcessTMDbID(doc); err != nil {
+		logging.Warning("%v", err)
+	}
+
+	// NFO年份与TMDB上映年份不一致时校正
+	if _, err := processor.ProcessYear(doc); err != nil {
+		logging.Warning("%v", err)
+	}
+
+	// NFO和TMDB都没有简介时从百科补充
+	if _, err := processor.ProcessPlot(doc); err != nil {
+		logging.Warning("%v", err)
+	}
+
+	saved, err := doc.Save()
+	if err != nil {
+		return false, fmt.Errorf("保存NFO文件失败: %w", err)
+	}
+	return saved, nil
 }
 
 // handleSingleNFO处理单个NFO文件
-func handleSingleNFO(nfoPath string) {
+func handleSingleNFO(nfoPath string) error {
 	// 检查文件是否存在
 	if _, err := os.Stat(nfoPath); os.IsNotExist(err) {
-		logging.Error("NFO文件不存在: %s", nfoPath)
-		os.Exit(1)
+		return fmt.Errorf("NFO文件不存在: %s", nfoPath)
+	}
+
+	// 检查目录是否被忽略标记文件排除
+	if utils.IsIgnoredPath(filepath.Dir(nfoPath)) {
+		logging.Info("NFO文件所在目录被忽略标记文件排除，跳过处理: %s", nfoPath)
+		return nil
 	}
 
 	// 检查NFO文件所在目录是否有多个NFO文件
-	dirPath := filepath.Dir(nfoPath)
-	if _, err := checkNFOCount(dirPath); err != nil {
-		logging.Error("%v，跳过处理", err)
-		os.Exit(1)
+	if _, err := checkNFOCount(nfoPath, appConfig().NFOSelection); err != nil {
+		return fmt.Errorf("%w，跳过处理", err)
 	}
 
 	// 记录开始时间
 	startTime := time.Now()
 
-	// 处理类型字段
+	// 规范化NFO字段并一次性写回
 	logging.Info("开始处理NFO文件: %s", nfoPath)
-	genreModified, err := processor.ProcessGenre(nfoPath)
-	if err != nil {
-		logging.Error("处理类型字段失败: %v", err)
-		os.Exit(1)
-	}
-
-	// 处理演员字段
-	report, err := processor.ProcessActor(nfoPath)
+	modified, err := runNFOProcessors(nfoPath)
 	if err != nil {
-		logging.Error("处理演员字段失败: %v", err)
-		os.Exit(1)
-	}
-
-	if len(report.Actors) > 0 {
-		logging.Info("发现 %d 个非中文演员名称", len(report.Actors))
+		return err
 	}
 
 	// 加载配置获取等待时间
-	cfg := config.LoadConfig()
+	cfg := appConfig()
 	// 只有当NFO文件被修改时才等待指定时间
-	if cfg.WaitTimeAfterNFOEdit > 0 && genreModified {
+	if cfg.WaitTimeAfterNFOEdit > 0 && modified && !parser.DryRun {
 		logging.Info("NFO文件编辑完成，等待 %d 秒后开始移动文件...", cfg.WaitTimeAfterNFOEdit)
 		time.Sleep(time.Duration(cfg.WaitTimeAfterNFOEdit) * time.Second)
 	}
 
 	// 分类并移动影片
-	if err := classifier.ClassifyAndMove(nfoPath); err != nil {
-		logging.Error("分类和移动影片失败: %v", err)
-		os.Exit(1)
+	if err := classifier.ClassifyAndMove(nfoPath); err != nil && !errs.IsSkip(err) {
+		return fmt.Errorf("分类和移动影片失败: %w", err)
 	}
 
 	// 计算处理时间
 	elapsedTime := time.Since(startTime)
 	logging.Info("NFO文件处理完成，耗时: %v", elapsedTime)
+	return nil
 }
 
-// handleMovieDir处理影片目录
-func handleMovieDir(dirPath string) {
+// handleMovieDir处理影片目录，单个NFO文件处理失败时记录错误并继续处理其余文件
+func handleMovieDir(dirPath string) error {
 	// 检查目录是否存在
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		logging.Error("目录不存在: %s", dirPath)
-		os.Exit(1)
+		return fmt.Errorf("目录不存在: %s", dirPath)
+	}
+	classifier.MonitorFreeSpace(appConfig())
+	if err := classifier.MonitorWritable(appConfig()); err != nil {
+		return err
 	}
 
 	// 先检查目录结构，确保没有任何包含多个NFO文件的子目录
 	logging.Info("开始检查目录结构，确保没有包含多个NFO文件的子目录")
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	err := utils.Walk(dirPath, appConfig().SymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			logging.Debug("访问路径失败: %s, 错误: %v", path, err)
 			return nil // 忽略访问错误
 		}
 
+		if info.IsDir() && utils.HasIgnoreMarker(path) {
+			logging.Info("目录 %s 包含忽略标记文件，跳过", path)
+			return filepath.SkipDir
+		}
+
 		if info.IsDir() {
 			// 检查该目录是否包含媒体文件
 			if hasMediaFiles(path) {
@@ -340,7 +808,7 @@ func handleMovieDir(dirPath string) {
 				}
 
 				if nfoCount > 1 {
-					logging.Error("目录 %s 下存在 %d 个NFO文件，将跳过该目录的处理。请手动选择正确的NFO文件后再处理。", path, nfoCount)
+					logMultipleNFO(path, nfoCount, appConfig().NFOSelection)
 				}
 			}
 		}
@@ -359,52 +827,118 @@ func handleMovieDir(dirPath string) {
 	logging.Info("开始在目录 %s 中查找NFO文件", dirPath)
 	nfoFiles, err := findNFOFiles(dirPath)
 	if err != nil {
-		logging.Error("查找NFO文件失败: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("查找NFO文件失败: %w", err)
 	}
 
 	if len(nfoFiles) == 0 {
-		logging.Info("目录 %s 下没有找到NFO文件", dirPath)
-		os.Exit(1)
+		return fmt.Errorf("目录 %s 下没有找到NFO文件", dirPath)
+	}
+
+	if filter := newFilterMatcher(appConfig()); filter != nil {
+		var matched []string
+		for _, nfoFile := range nfoFiles {
+			if filter.match(nfoFile) {
+				matched = append(matched, nfoFile)
+			}
+		}
+		nfoFiles = matched
 	}
 
 	logging.Info("找到 %d 个NFO文件，开始处理", len(nfoFiles))
 
+	// 从上次中断的位置继续
+	cursorScope := "dir:" + dirPath
+	if absPath, err := filepath.Abs(dirPath); err == nil {
+		cursorScope = "dir:" + absPath
+	}
+	return processNFOFiles(cursorScope, nfoFiles, handleSingleNFO)
+}
+
+// processNFOFiles 从上次中断的位置开始依次处理NFO文件，达到运行上限时保存游标并停止
+func processNFOFiles(cursorScope string, nfoFiles []string, handle func(string) error) error {
+	nfoFiles = resumeFromCursor(cursorScope, nfoFiles)
+	limiter := newRunLimiter()
+
 	// 处理每个NFO文件
+	failed := 0
 	for i, nfoFile := range nfoFiles {
+		if reached, reason := limiter.reached(); reached {
+			logging.Info("%s，停止本次处理，剩余项目将在下次运行时继续", reason)
+			// 还没有处理任何项目时保留原来的游标
+			if i > 0 {
+				saveCursor(cursorScope, nfoFiles[i-1])
+			}
+			return failedErr(failed)
+		}
+		limiter.done()
+
 		logging.Info("处理第 %d/%d 个NFO文件: %s", i+1, len(nfoFiles), nfoFile)
-		handleSingleNFO(nfoFile)
+		if err := handle(nfoFile); err != nil {
+			logging.Error("%v", err)
+			failed++
+		}
 		logging.Info("------------------------")
 	}
 
+	saveCursor(cursorScope, "")
 	logging.Info("所有NFO文件处理完成")
+	return failedErr(failed)
 }
 
-// checkNFOCount检查目录中NFO文件的数量，如果有多个则返回错误
-func checkNFOCount(dirPath string) (int, error) {
-	var nfoCount int
+// failedErr 有NFO文件处理失败时返回汇总的错误
+func failedErr(failed int) error {
+	if failed > 0 {
+		return fmt.Errorf("有 %d 个NFO文件处理失败", failed)
+	}
+	return nil
+}
 
-	// 打开目录
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		return 0, fmt.Errorf("无法打开目录: %w", err)
+// scrapeSubdirs返回刮削类型对应的Temp子目录
+func scrapeSubdirs(scrapeType string) []string {
+	switch scrapeType {
+	case "movies":
+		return []string{"Movie"}
+	case "tv":
+		return []string{"TvShow"}
 	}
+	return []string{"Movie", "TvShow"}
+}
 
-	// 遍历目录中的直接子文件，统计NFO文件数量
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.ToLower(filepath.Ext(entry.Name())) == ".nfo" {
-			nfoCount++
-		}
+// checkNFOCount检查NFO文件所在目录中NFO文件的数量
+// 有多个NFO文件时，未配置选择策略或该文件不是按策略选中的文件则返回ErrMultipleNFO跳过错误
+func checkNFOCount(nfoPath string, strategy string) (int, error) {
+	dirPath := filepath.Dir(nfoPath)
+
+	// 列出目录中的直接子文件中的NFO文件
+	files, err := parser.ListNFOFiles(dirPath)
+	if err != nil {
+		return 0, fmt.Errorf("无法打开目录: %w", err)
 	}
+	nfoCount := len(files)
 
-	// 如果有多个NFO文件，返回错误
+	// 如果有多个NFO文件，按策略判断是否处理
 	if nfoCount > 1 {
-		return nfoCount, fmt.Errorf("目录 %s 下存在 %d 个NFO文件", dirPath, nfoCount)
+		if strategy == parser.NFOSelectDefault {
+			return nfoCount, errs.Skip(errs.ErrMultipleNFO, dirPath, fmt.Sprintf("目录 %s 下存在 %d 个NFO文件", dirPath, nfoCount))
+		}
+		selected, reason := parser.SelectNFOFile(files, strategy)
+		if filepath.Clean(selected) != filepath.Clean(nfoPath) {
+			return nfoCount, errs.Skip(errs.ErrMultipleNFO, dirPath, fmt.Sprintf("目录 %s 下存在 %d 个NFO文件，按策略 %s 应处理 %s（%s）", dirPath, nfoCount, strategy, selected, reason))
+		}
 	}
 
 	return nfoCount, nil
 }
 
+// logMultipleNFO记录多NFO目录的处理方式
+func logMultipleNFO(dirPath string, nfoCount int, strategy string) {
+	if strategy == parser.NFOSelectDefault {
+		logging.Error("目录 %s 下存在 %d 个NFO文件，将跳过该目录的处理。请手动选择正确的NFO文件后再处理。", dirPath, nfoCount)
+		return
+	}
+	logging.Info("目录 %s 下存在 %d 个NFO文件，将按策略 %s 选择其中一个处理", dirPath, nfoCount, strategy)
+}
+
 // hasMediaFiles检查目录是否包含媒体文件
 func hasMediaFiles(dirPath string) bool {
 	// 常见的媒体文件扩展名
@@ -414,11 +948,16 @@ func hasMediaFiles(dirPath string) bool {
 	found := false
 
 	// 递归遍历目录内容
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	err := utils.Walk(dirPath, appConfig().SymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // 忽略访问错误
 		}
 
+		// 跳过包含忽略标记文件的目录
+		if info.IsDir() && utils.HasIgnoreMarker(path) {
+			return filepath.SkipDir
+		}
+
 		// 检查是否为文件且具有媒体文件扩展名
 		if !info.IsDir() {
 			ext := strings.ToLower(filepath.Ext(info.Name()))
@@ -445,24 +984,28 @@ func findNFOFiles(dirPath string) ([]string, error) {
 	var nfoFiles []string
 	// 使用map记录每个目录下的NFO文件，确保唯一性
 	dirNFOMap := make(map[string][]string)
+	projectCheck := appConfig().ProjectCheck
 	logging.Info("开始遍历目录 %s 查找NFO文件", dirPath)
 
 	// 遍历目录
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	err := utils.Walk(dirPath, appConfig().SymlinkMode(config.SymlinkOpScan), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			logging.Debug("访问路径失败: %s, 错误: %v", path, err)
 			return nil // 忽略访问错误
 		}
 
 		if info.IsDir() {
-			// 如果是项目目录，跳过
+			// 跳过包含忽略标记文件的目录及其子目录
+			if utils.HasIgnoreMarker(path) {
+				logging.Info("目录 %s 包含忽略标记文件，跳过", path)
+				return filepath.SkipDir
+			}
+
 			if path != dirPath {
-				// 检查目录是否包含项目文件
-				projectFiles := []string{"go.mod", "main.go", "go.sum", "CMakeLists.txt", "Makefile", "package.json", "requirements.txt"}
-				for _, file := range projectFiles {
-					filePath := filepath.Join(path, file)
-					if _, err := os.Stat(filePath); err == nil {
-						logging.Debug("跳过项目目录: %s", path)
+				// 项目检查配置为skip时跳过包含项目文件的目录
+				if projectCheck == config.ProjectCheckSkip {
+					if marker := classifier.ProjectMarker(path); marker != "" {
+						logging.Debug("目录中存在项目文件 %s，跳过: %s", marker, path)
 						return filepath.SkipDir
 					}
 				}
@@ -491,31 +1034,18 @@ func findNFOFiles(dirPath string) ([]string, error) {
 	}
 
 	// 从每个目录中选择一个最合适的NFO文件
+	strategy := appConfig().NFOSelection
 	for dir, files := range dirNFOMap {
 		if len(files) == 0 {
 			continue
 		}
 
-		// 尝试选择最合适的NFO文件
-		// 优先选择没有"(数字)"后缀的文件
-		var selectedFile string
-		for _, file := range files {
-			fileName := filepath.Base(file)
-			// 检查文件名是否包含"(数字)"后缀
-			if !strings.Contains(fileName, "(") || !strings.Contains(fileName, ")") {
-				selectedFile = file
-				break
-			}
-		}
-
-		// 如果没有找到合适的，选择第一个
-		if selectedFile == "" {
-			selectedFile = files[0]
-		}
+		// 按配置的策略选择最合适的NFO文件
+		selectedFile, reason := parser.SelectNFOFile(files, strategy)
 
 		// 如果有多个NFO文件，记录日志
 		if len(files) > 1 {
-			logging.Info("目录 %s 下有 %d 个NFO文件，选择处理: %s", dir, len(files), selectedFile)
+			logging.Info("目录 %s 下有 %d 个NFO文件，选择处理: %s（依据: %s）", dir, len(files), selectedFile, reason)
 			for _, file := range files {
 				if file != selectedFile {
 					logging.Info("跳过NFO文件: %s", file)
@@ -530,23 +1060,20 @@ func findNFOFiles(dirPath string) ([]string, error) {
 	return nfoFiles, nil
 }
 
-// batchDetectMissing 批量检测数据库中所有电视剧的缺失季和剧集
-func batchDetectMissing() {
-	// 初始化数据库
-	database.InitDatabase()
-	defer database.CloseDatabase()
-
+// batchDetectMissing 批量检测数据库中所有电视剧的缺失季和剧集，数据库需要已经打开
+// 单个项目检测失败时记录日志并继续，无法读取媒体记录时返回错误
+func batchDetectMissing() error {
 	// 获取所有媒体记录
 	mediaRecords, err := database.GetMediaRecords(map[string]interface{}{})
 	if err != nil {
-		logging.Error("获取媒体记录失败: %v", err)
-		return
+		return fmt.Errorf("获取媒体记录失败: %w", err)
 	}
 
 	logging.Info("共找到 %d 条媒体记录，开始检测缺失季和剧集...", len(mediaRecords))
 
 	// 筛选出电视剧记录并检测缺失季和剧集
 	tvShowCount := 0
+	movieCount := 0
 	detectedCount := 0
 	errCount := 0
 
@@ -569,13 +1096,24 @@ func batchDetectMissing() {
 				logging.Warning("跳过 '%s'，没有TMDB ID", record.Title)
 				errCount++
 			}
+		} else if record.TMDbID != "" && record.Category != appConfig().MusicCategory {
+			// 电影检测所属系列中缺失的电影
+			movieCount++
+			if err := classifier.DetectMissingCollectionMovies(&record); err != nil {
+				logging.Error("检测 '%s' 所属系列的缺失电影失败: %v", record.Title, err)
+				errCount++
+			} else {
+				detectedCount++
+			}
 		}
 	}
 
 	logging.Info("批量检测完成！")
 	logging.Info("总媒体记录数: %d", len(mediaRecords))
 	logging.Info("电视剧记录数: %d", tvShowCount)
+	logging.Info("电影记录数: %d", movieCount)
 	logging.Info("成功检测数: %d", detectedCount)
 	logging.Info("失败检测数: %d", errCount)
 	logging.Info("检测结果已保存到数据库中")
+	return nil
 }
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// 计时的操作
+const (
+	OpParse    = "parse"     // 读取和解析NFO文件
+	OpTMDB     = "tmdb"      // TMDB API请求
+	OpNFOWrite = "nfo_write" // 写回NFO文件
+	OpMove     = "move"      // 移动或合并影片目录
+)
+
+// Operations 所有计时的操作，按处理顺序排列
+var Operations = []string{OpParse, OpTMDB, OpNFOWrite, OpMove}
+
+// operationNames 操作的中文名称
+var operationNames = map[string]string{
+	OpParse:    "解析NFO",
+	OpTMDB:     "TMDB请求",
+	OpNFOWrite: "写入NFO",
+	OpMove:     "移动",
+}
+
+// OperationName 返回操作的中文名称
+func OperationName(op string) string {
+	if name, ok := operationNames[op]; ok {
+		return name
+	}
+	return op
+}
+
+// SlowThresholds 各操作的慢操作阈值，单次操作超过阈值时记录警告
+var SlowThresholds map[string]time.Duration
+
+// SetThresholds 按配置的毫秒数设置各操作的慢操作阈值，0表示不警告
+func SetThresholds(thresholds map[string]int) {
+	SlowThresholds = make(map[string]time.Duration, len(thresholds))
+	for op, ms := range thresholds {
+		SlowThresholds[op] = time.Duration(ms) * time.Millisecond
+	}
+}
+
+// Stat 一种操作的耗时统计
+type Stat struct {
+	Count int
+	Total time.Duration
+	Max   time.Duration
+	Slow  int // 超过阈值的次数
+}
+
+// add 累计一次操作的耗时
+func (s *Stat) add(elapsed time.Duration, slow bool) {
+	s.Count++
+	s.Total += elapsed
+	if elapsed > s.Max {
+		s.Max = elapsed
+	}
+	if slow {
+		s.Slow++
+	}
+}
+
+var (
+	mu        sync.Mutex
+	runStats  = make(map[string]*Stat) // 本次运行的统计
+	itemStats = make(map[string]*Stat) // 当前项目的统计
+)
+
+// Start 开始计时，调用返回的函数结束计时，累计到当前项目和本次运行的统计中
+// detail说明操作的对象（如NFO路径、TMDB请求路径），用于慢操作警告
+func Start(op string, detail string) func() {
+	startedAt := time.Now()
+	return func() {
+		elapsed := time.Since(startedAt)
+		threshold := SlowThresholds[op]
+		slow := threshold > 0 && elapsed > threshold
+		if slow {
+			logging.Warning("%s耗时 %v，超过阈值 %v: %s", OperationName(op), roundDuration(elapsed), threshold, detail)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, stats := range []map[string]*Stat{runStats, itemStats} {
+			if stats[op] == nil {
+				stats[op] = &Stat{}
+			}
+			stats[op].add(elapsed, slow)
+		}
+	}
+}
+
+// StartItem 开始统计一个项目的各项操作耗时
+func StartItem() {
+	mu.Lock()
+	defer mu.Unlock()
+	itemStats = make(map[string]*Stat)
+}
+
+// FinishItem 记录当前项目各项操作的耗时，如 "解析NFO 2次 12ms，TMDB请求 3次 340ms，移动 1.2s"
+func FinishItem(name string) {
+	mu.Lock()
+	stats := itemStats
+	itemStats = make(map[string]*Stat)
+	mu.Unlock()
+
+	if summary := formatStats(stats); summary != "" {
+		logging.Info("耗时统计 %s: %s", name, summary)
+	}
+}
+
+// formatStats 按操作顺序格式化耗时统计
+func formatStats(stats map[string]*Stat) string {
+	var parts []string
+	for _, op := range Operations {
+		stat := stats[op]
+		if stat == nil {
+			continue
+		}
+		if stat.Count > 1 {
+			parts = append(parts, fmt.Sprintf("%s %d次 %v", OperationName(op), stat.Count, roundDuration(stat.Total)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %v", OperationName(op), roundDuration(stat.Total)))
+		}
+	}
+	return strings.Join(parts, "，")
+}
+
+// roundDuration 舍入耗时便于阅读，不足1毫秒时保留到微秒
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// SaveRun 将本次运行的耗时统计保存到数据库并清空，没有计时记录时不保存
+func SaveRun() error {
+	mu.Lock()
+	stats := runStats
+	runStats = make(map[string]*Stat)
+	mu.Unlock()
+
+	if len(stats) == 0 {
+		return nil
+	}
+
+	var timings []database.RunTiming
+	for _, op := range Operations {
+		stat := stats[op]
+		if stat == nil {
+			continue
+		}
+		timings = append(timings, database.RunTiming{
+			Operation: op,
+			Count:     stat.Count,
+			TotalMS:   stat.Total.Milliseconds(),
+			MaxMS:     stat.Max.Milliseconds(),
+			SlowCount: stat.Slow,
+		})
+	}
+	return database.SaveRunTimings(timings)
+}
@@ -0,0 +1,266 @@
+// Package mqtt 实现向MQTT代理（如Mosquitto、Home Assistant的MQTT集成）发布消息的最小MQTT 3.1.1客户端
+// 每次发布时连接代理、发布一条消息后断开，不订阅主题，适合处理事件这样频率很低的消息
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+)
+
+// 控制报文类型（固定报头的高4位）
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPuback     = 0x40
+	packetPubrec     = 0x50
+	packetPubrel     = 0x62 // PUBREL的固定报头低4位必须为0010
+	packetPubcomp    = 0x70
+	packetDisconnect = 0xE0
+)
+
+// 默认端口和超时
+const (
+	defaultPort    = "1883"
+	defaultTLSPort = "8883"
+	keepAlive      = 60 // 保持连接的秒数，发布后立即断开，只需要大于一次发布的耗时
+	timeout        = 10 * time.Second
+)
+
+// connackErrors CONNACK返回码对应的说明
+var connackErrors = map[byte]string{
+	1: "不支持的协议版本",
+	2: "客户端ID被拒绝",
+	3: "服务不可用",
+	4: "用户名或密码错误",
+	5: "未授权",
+}
+
+// Publish 连接cfg配置的代理，以cfg的QoS向topic发布一条消息后断开
+// QoS为1、2时等待代理确认后才返回
+func Publish(cfg config.MQTTConfig, topic string, payload []byte, retain bool) error {
+	address, useTLS, err := brokerAddress(cfg.Broker)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: hostOf(address)})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("连接MQTT代理失败: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	r := bufio.NewReader(conn)
+	if err := connect(conn, r, cfg); err != nil {
+		return err
+	}
+	if err := publish(conn, r, topic, payload, cfg.QoSLevel(), retain); err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte{packetDisconnect, 0}); err != nil {
+		return fmt.Errorf("断开MQTT连接失败: %w", err)
+	}
+	return nil
+}
+
+// brokerAddress 解析代理地址，支持 host:port、tcp://、mqtt://，以及使用TLS的 ssl://、tls://、mqtts://，没有端口时使用默认端口
+func brokerAddress(broker string) (address string, useTLS bool, err error) {
+	broker = strings.TrimSpace(broker)
+	if broker == "" {
+		return "", false, errors.New("没有配置MQTT代理地址")
+	}
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	u, err := url.Parse(broker)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("无效的MQTT代理地址: %s", broker)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+	default:
+		return "", false, fmt.Errorf("不支持的MQTT代理协议: %s", u.Scheme)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+		if useTLS {
+			port = defaultTLSPort
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// hostOf 返回 host:port 中的主机名
+func hostOf(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// connect 发送CONNECT报文并等待代理的CONNACK
+func connect(w io.Writer, r *bufio.Reader, cfg config.MQTTConfig) error {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("media-manager-%d", os.Getpid())
+	}
+
+	var flags byte = 0x02 // 清除会话
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // 协议级别：3.1.1
+	if cfg.Username != "" {
+		flags |= 0x80
+		if cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if cfg.Username != "" {
+		body = appendString(body, cfg.Username)
+		if cfg.Password != "" {
+			body = appendString(body, cfg.Password)
+		}
+	}
+	if err := writePacket(w, packetConnect, body); err != nil {
+		return fmt.Errorf("发送MQTT连接请求失败: %w", err)
+	}
+
+	header, ack, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("读取MQTT连接响应失败: %w", err)
+	}
+	if header&0xF0 != packetConnack || len(ack) < 2 {
+		return fmt.Errorf("MQTT代理返回了意外的报文: 0x%02X", header)
+	}
+	if code := ack[1]; code != 0 {
+		reason := connackErrors[code]
+		if reason == "" {
+			reason = fmt.Sprintf("返回码 %d", code)
+		}
+		return fmt.Errorf("MQTT代理拒绝连接: %s", reason)
+	}
+	return nil
+}
+
+// publish 发送PUBLISH报文，QoS 1等待PUBACK，QoS 2依次完成PUBREC、PUBREL、PUBCOMP
+func publish(w io.Writer, r *bufio.Reader, topic string, payload []byte, qos byte, retain bool) error {
+	const packetID = 1 // 每个连接只发布一条消息，报文标识符固定
+
+	header := byte(packetPublish) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+	if err := writePacket(w, header, body); err != nil {
+		return fmt.Errorf("发布MQTT消息失败: %w", err)
+	}
+
+	switch qos {
+	case 1:
+		return expectAck(r, packetPuback, packetID)
+	case 2:
+		if err := expectAck(r, packetPubrec, packetID); err != nil {
+			return err
+		}
+		if err := writePacket(w, packetPubrel, binary.BigEndian.AppendUint16(nil, packetID)); err != nil {
+			return fmt.Errorf("发布MQTT消息失败: %w", err)
+		}
+		return expectAck(r, packetPubcomp, packetID)
+	}
+	return nil
+}
+
+// expectAck 读取代理对报文标识符为packetID的确认报文
+func expectAck(r *bufio.Reader, packetType byte, packetID uint16) error {
+	header, body, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("等待MQTT代理确认失败: %w", err)
+	}
+	if header&0xF0 != packetType&0xF0 || len(body) < 2 || binary.BigEndian.Uint16(body) != packetID {
+		return fmt.Errorf("MQTT代理返回了意外的报文: 0x%02X", header)
+	}
+	return nil
+}
+
+// writePacket 写入固定报头（包括剩余长度）和报文内容
+func writePacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+// readPacket 读取一个报文，返回固定报头的第一个字节和报文内容
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("无效的剩余长度")
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// appendString 追加带两字节长度前缀的UTF-8字符串
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
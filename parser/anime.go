@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnimeRelease表示从字幕组命名的文件或目录名中解析出的信息
+// 例如 "[Lilith-Raws] 葬送的芙莉莲 - 05 [Baha][WebDL 1080p]"
+type AnimeRelease struct {
+	Group        string   // 字幕组，如 Lilith-Raws
+	Title        string   // 作品标题
+	Season       int      // 名称中明确写出的季数（如 第二季、S2），没有时为0
+	EpisodeStart int      // 绝对集数；合集时为起始集数
+	EpisodeEnd   int      // 合集结束集数；单集时与EpisodeStart相同
+	Tags         []string // 其余方括号中的标签，如 Baha、WebDL 1080p
+}
+
+// IsBatch判断是否为合集（包含多集）
+func (r *AnimeRelease) IsBatch() bool {
+	return r.EpisodeEnd > r.EpisodeStart
+}
+
+var (
+	// 开头的字幕组标签
+	animeGroupRe = regexp.MustCompile(`^\s*[\[【]([^\]】]+)[\]】]\s*`)
+	// 方括号标签
+	animeBracketRe = regexp.MustCompile(`[\[【]([^\]】]*)[\]】]`)
+	// "标题 - 05" 或 "标题 - 01-12" 形式
+	animeDashEpisodeRe = regexp.MustCompile(`^(.*?)\s+-\s+(\d{1,4})(?:v\d)?(?:\s*[-~]\s*(\d{1,4})(?:v\d)?)?(?:\s|$|\[|【|\()`)
+	// "第05话" 或 "第01-12集" 形式
+	animeChineseEpisodeRe = regexp.MustCompile(`第\s*(\d{1,4})(?:\s*[-~]\s*(\d{1,4}))?\s*[话話集]`)
+	// 方括号中的集数或合集范围，如 [05]、[05v2]、[01-12]、[01~12 Fin]
+	animeBracketEpisodeRe = regexp.MustCompile(`^(\d{1,4})(?:v\d)?(?:\s*[-~]\s*(\d{1,4})(?:v\d)?)?(?:\s*(?:Fin|END|完))?$`)
+	// 标题中的季数标记
+	animeSeasonPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\s*第\s*(` + ChineseNumberPattern + `)\s*季\s*$`),
+		regexp.MustCompile(`(?i)\s+S(\d{1,2})\s*$`),
+		regexp.MustCompile(`(?i)\s+Season\s*(\d+)\s*$`),
+		regexp.MustCompile(`(?i)\s+(\d+)(?:st|nd|rd|th)\s+Season\s*$`),
+	}
+)
+
+// ParseAnimeRelease解析字幕组风格的文件或目录名
+// 无法识别为字幕组命名时返回false
+func ParseAnimeRelease(name string) (*AnimeRelease, bool) {
+	// 去掉视频文件扩展名
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".mkv", ".mp4", ".avi", ".wmv", ".flv", ".mov", ".rmvb", ".ts", ".nfo":
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	release := &AnimeRelease{}
+
+	// 字幕组命名必须以方括号开头
+	groupMatch := animeGroupRe.FindStringSubmatchIndex(name)
+	if groupMatch == nil {
+		return nil, false
+	}
+	release.Group = strings.TrimSpace(name[groupMatch[2]:groupMatch[3]])
+	rest := name[groupMatch[1]:]
+
+	if matches := animeDashEpisodeRe.FindStringSubmatchIndex(rest); matches != nil {
+		// "[组] 标题 - 05 [标签]" 形式
+		release.Title = strings.TrimSpace(rest[matches[2]:matches[3]])
+		release.EpisodeStart, _ = strconv.Atoi(rest[matches[4]:matches[5]])
+		release.EpisodeEnd = release.EpisodeStart
+		if matches[6] >= 0 {
+			release.EpisodeEnd, _ = strconv.Atoi(rest[matches[6]:matches[7]])
+		}
+		for _, tag := range animeBracketRe.FindAllStringSubmatch(rest[matches[5]:], -1) {
+			release.addTag(tag[1])
+		}
+	} else if matches := animeChineseEpisodeRe.FindStringSubmatchIndex(rest); matches != nil {
+		// "[组] 标题 第05话 [标签]" 形式
+		release.Title = strings.TrimSpace(animeBracketRe.ReplaceAllString(rest[:matches[0]], ""))
+		release.EpisodeStart, _ = strconv.Atoi(rest[matches[2]:matches[3]])
+		release.EpisodeEnd = release.EpisodeStart
+		if matches[4] >= 0 {
+			release.EpisodeEnd, _ = strconv.Atoi(rest[matches[4]:matches[5]])
+		}
+		for _, tag := range animeBracketRe.FindAllStringSubmatch(rest[matches[1]:], -1) {
+			release.addTag(tag[1])
+		}
+	} else {
+		// "[组][标题][05][1080P]" 全方括号形式
+		for _, token := range animeBracketRe.FindAllStringSubmatch(rest, -1) {
+			value := strings.TrimSpace(token[1])
+			if release.EpisodeStart == 0 {
+				if episode := animeBracketEpisodeRe.FindStringSubmatch(value); episode != nil {
+					release.EpisodeStart, _ = strconv.Atoi(episode[1])
+					release.EpisodeEnd = release.EpisodeStart
+					if episode[2] != "" {
+						release.EpisodeEnd, _ = strconv.Atoi(episode[2])
+					}
+					continue
+				}
+			}
+			if release.Title == "" && value != "" {
+				release.Title = value
+				continue
+			}
+			release.addTag(value)
+		}
+
+		// 标题不在方括号中的情况，如 "[组] 标题 [05]"
+		if plain := strings.TrimSpace(animeBracketRe.ReplaceAllString(rest, " ")); plain != "" {
+			if release.Title != "" {
+				release.addTag(release.Title)
+			}
+			release.Title = strings.Join(strings.Fields(plain), " ")
+		}
+	}
+
+	if release.Title == "" || release.EpisodeStart == 0 {
+		return nil, false
+	}
+	if release.EpisodeEnd < release.EpisodeStart {
+		release.EpisodeEnd = release.EpisodeStart
+	}
+
+	// 从标题中剥离季数标记
+	for _, re := range animeSeasonPatterns {
+		if matches := re.FindStringSubmatch(release.Title); matches != nil {
+			release.Season, _ = ParseChineseNumber(matches[1])
+			release.Title = strings.TrimSpace(re.ReplaceAllString(release.Title, ""))
+			break
+		}
+	}
+
+	return release, true
+}
+
+// addTag添加非空标签
+func (r *AnimeRelease) addTag(tag string) {
+	tag = strings.TrimSpace(tag)
+	if tag != "" {
+		r.Tags = append(r.Tags, tag)
+	}
+}
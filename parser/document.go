@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/user/media-manager/metrics"
+)
+
+// Document 在内存中加载的NFO文件
+// 一个项目的各个处理器依次修改同一个Document，全部处理完成后只写入一次文件
+type Document struct {
+	Path     string // NFO文件路径
+	Encoding string // 文件的原始编码
+	NFO      *NFO   // 当前内容解析出的NFO，每次修改后更新
+
+	content  string
+	modified bool
+}
+
+// LoadDocument 读取并解析NFO文件
+func LoadDocument(filePath string) (*Document, error) {
+	defer metrics.Start(metrics.OpParse, filePath)()
+
+	content, encoding, err := ReadNFOFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开NFO文件: %w", err)
+	}
+
+	nfo, err := ParseNFOContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		Path:     filePath,
+		Encoding: encoding,
+		NFO:      nfo,
+		content:  string(content),
+	}, nil
+}
+
+// RootTag 返回根标签（movie、tvshow或musicvideo）
+func (d *Document) RootTag() string {
+	return d.NFO.XMLName.Local
+}
+
+// Content 返回当前的NFO内容
+func (d *Document) Content() string {
+	return d.content
+}
+
+// Modified 返回加载后内容是否被修改过
+func (d *Document) Modified() bool {
+	return d.modified
+}
+
+// HasElement 检查是否存在指定元素
+func (d *Document) HasElement(tag string) bool {
+	return HasElement(d.content, tag)
+}
+
+// SetElements 将所有指定元素替换为新的值，规则同SetElements函数
+func (d *Document) SetElements(tag string, values []string, anchors ...string) error {
+	return d.update(SetElements(d.content, d.RootTag(), tag, values, anchors...))
+}
+
+// InsertBeforeRootEnd 在根元素结束标签之前插入元素
+func (d *Document) InsertBeforeRootEnd(elements string) error {
+	return d.update(InsertBeforeRootEnd(d.content, d.RootTag(), elements))
+}
+
+// RemovePlotSourceComment 删除记录简介来源的注释
+func (d *Document) RemovePlotSourceComment() error {
+	return d.update(RemovePlotSourceComment(d.content))
+}
+
+// update 替换内容并重新解析，修改后的内容无法解析时保持原内容
+func (d *Document) update(content string) error {
+	if content == d.content {
+		return nil
+	}
+
+	nfo, err := ParseNFOContent([]byte(content))
+	if err != nil {
+		return fmt.Errorf("修改后的NFO内容无效: %w", err)
+	}
+
+	d.content = content
+	d.NFO = nfo
+	d.modified = true
+	return nil
+}
+
+// Save 内容被修改过时以UTF-8原子写回文件，返回是否写入了文件
+func (d *Document) Save() (bool, error) {
+	if !d.modified {
+		return false, nil
+	}
+	if err := WriteNFOFile(d.Path, []byte(d.content)); err != nil {
+		return false, err
+	}
+	d.modified = false
+	return true, nil
+}
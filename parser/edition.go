@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 影片版本的统一名称
+const (
+	EditionDirectorsCut = "导演剪辑版"
+	EditionUltimateCut  = "终极剪辑版"
+	EditionTheatrical   = "院线版"
+	EditionExtended     = "加长版"
+	EditionUncut        = "未删减版"
+	EditionSpecial      = "特别版"
+	EditionIMAX         = "IMAX版"
+	EditionRemastered   = "修复版"
+	EditionMandarin     = "国语版"
+	EditionCantonese    = "粤语版"
+)
+
+// editionPatterns 版本标记，按输出顺序排列
+var editionPatterns = []releaseTagPattern{
+	{regexp.MustCompile(`导演剪辑版|導演剪輯版|导演版|導演版|(?i)\bDirector'?s?\s*Cut\b`), EditionDirectorsCut},
+	{regexp.MustCompile(`终极剪辑版|終極剪輯版|终极版|終極版|(?i)\bUltimate\s*(Cut|Edition)\b`), EditionUltimateCut},
+	{regexp.MustCompile(`院线版|院線版|公映版|(?i)\bTheatrical(\s*Cut)?\b`), EditionTheatrical},
+	{regexp.MustCompile(`加长版|加長版|(?i)\bExtended(\s*(Cut|Edition))?\b`), EditionExtended},
+	{regexp.MustCompile(`未删减版|未刪減版|未删减|未刪減|(?i)\b(Uncut|Unrated)\b`), EditionUncut},
+	{regexp.MustCompile(`特别版|特別版|(?i)\bSpecial\s*Edition\b`), EditionSpecial},
+	{regexp.MustCompile(`(?i)\bIMAX\b`), EditionIMAX},
+	{regexp.MustCompile(`修复版|修復版|(?i)\b(Remastered|Restored)\b`), EditionRemastered},
+	{regexp.MustCompile(`国语版|國語版|普通话版|普通話版`), EditionMandarin},
+	{regexp.MustCompile(`粤语版|粵語版`), EditionCantonese},
+}
+
+// ParseEdition 解析目录名中的版本标记（如 "导演剪辑版"、"Extended"、"IMAX"），返回统一的名称，多个版本以空格分隔
+// 没有版本标记时返回空字符串
+func ParseEdition(name string) string {
+	return strings.Join(matchReleaseTags(name, editionPatterns), " ")
+}
@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	xencoding "golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/metrics"
+	"github.com/user/media-manager/safety"
+)
+
+// NFOBackups 覆盖NFO文件前保留的历史版本数，0表示不保留
+// 最近的版本保存为<文件名>.bak，更早的版本依次为.bak.1、.bak.2……
+var NFOBackups int
+
+// NFOBackupDir 保存NFO备份的目录，备份按NFO文件的完整路径存放在其中；为空时备份保存在NFO文件旁边
+var NFOBackupDir string
+
+// DryRun 为true时只模拟写入NFO文件：WriteNFOFile输出将要写入的文件，内容保存在内存中，不修改文件
+// 之后ReadNFOFile读取到模拟写入的内容，后续的处理和分类与实际运行时一致
+var DryRun bool
+
+// simulatedNFOs 模拟运行时写入的NFO内容，按文件路径保存
+var simulatedNFOs sync.Map
+
+// utf8BOM UTF-8字节顺序标记
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// xmlDeclRegex 匹配XML声明
+var xmlDeclRegex = regexp.MustCompile(`^\s*<\?xml[^?]*\?>`)
+
+// xmlEncodingRegex 匹配XML声明中的encoding属性
+var xmlEncodingRegex = regexp.MustCompile(`encoding\s*=\s*["']([^"']*)["']`)
+
+// gbEncodings 按GB18030解码的编码名称（GB18030兼容GBK和GB2312）
+var gbEncodings = map[string]bool{
+	"gbk":     true,
+	"gb2312":  true,
+	"gb18030": true,
+	"cp936":   true,
+	"x-gbk":   true,
+}
+
+// ReadNFOFile 读取NFO文件并转换为UTF-8内容，返回内容和原始编码
+// 老的中文工具生成的NFO常为GBK编码：按声明的编码解码，没有声明且不是有效UTF-8时按GB18030解码
+// 返回内容中的XML声明已改为UTF-8
+func ReadNFOFile(filePath string) ([]byte, string, error) {
+	if content, ok := simulatedNFOs.Load(filePath); ok {
+		return DecodeNFOContent(content.([]byte))
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取NFO文件失败: %w", err)
+	}
+	return DecodeNFOContent(content)
+}
+
+// DecodeNFOContent 将NFO内容转换为UTF-8，返回内容和原始编码
+func DecodeNFOContent(content []byte) ([]byte, string, error) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	encoding := strings.ToLower(declaredEncoding(content))
+	var decoder *xencoding.Decoder
+	switch {
+	case gbEncodings[encoding], encoding == "" && !utf8.Valid(content):
+		if encoding == "" {
+			encoding = "gb18030"
+		}
+		decoder = simplifiedchinese.GB18030.NewDecoder()
+	case encoding == "" || encoding == "utf-8" || encoding == "utf8":
+		return content, "utf-8", nil
+	default:
+		// 其他声明的编码（如big5）
+		enc, err := htmlindex.Get(encoding)
+		if err != nil {
+			return nil, encoding, fmt.Errorf("不支持的NFO文件编码: %s", encoding)
+		}
+		decoder = enc.NewDecoder()
+	}
+
+	decoded, err := decoder.Bytes(content)
+	if err != nil {
+		return nil, encoding, fmt.Errorf("将NFO文件从 %s 转换为UTF-8失败: %w", encoding, err)
+	}
+	return setDeclaredEncoding(decoded), encoding, nil
+}
+
+// WriteNFOFile 以UTF-8写入NFO文件，确保XML声明的编码为UTF-8
+// 先写入同目录的临时文件再重命名覆盖原文件，写入中断时不会留下不完整的NFO文件
+func WriteNFOFile(filePath string, content []byte) error {
+	defer metrics.Start(metrics.OpNFOWrite, filePath)()
+
+	if err := safety.Check(filePath); err != nil {
+		return err
+	}
+
+	content = setDeclaredEncoding(bytes.TrimPrefix(content, utf8BOM))
+	if DryRun {
+		simulatedNFOs.Store(filePath, content)
+		logging.Info("[模拟] 将写入NFO文件: %s", filePath)
+		return nil
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时NFO文件失败: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入NFO文件失败: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入NFO文件失败: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入NFO文件失败: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("设置NFO文件权限失败: %w", err)
+	}
+	if err := backupNFOFile(filePath, NFOBackups); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("替换NFO文件失败: %w", err)
+	}
+	return nil
+}
+
+// backupNFOFile 在覆盖NFO文件前保留原文件的副本，并按保留数轮换已有的备份
+// 原文件始终保留在原位置，备份失败时不覆盖原文件
+func backupNFOFile(filePath string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	original, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取NFO文件失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(backupPath(filePath, 0)), 0755); err != nil {
+		return fmt.Errorf("创建NFO备份目录失败: %w", err)
+	}
+
+	// 删除超出保留数的最早备份，其余备份依次后移
+	os.Remove(backupPath(filePath, keep-1))
+	for i := keep - 2; i >= 0; i-- {
+		if err := os.Rename(backupPath(filePath, i), backupPath(filePath, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("轮换NFO备份失败: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(backupPath(filePath, 0), original, 0644); err != nil {
+		return fmt.Errorf("备份NFO文件失败: %w", err)
+	}
+	return nil
+}
+
+// backupPath 返回第index个备份的路径，0为最近的备份
+func backupPath(filePath string, index int) string {
+	if NFOBackupDir != "" {
+		if abs, err := filepath.Abs(filePath); err == nil {
+			volume := filepath.VolumeName(abs)
+			filePath = filepath.Join(NFOBackupDir, strings.TrimSuffix(volume, ":"), abs[len(volume):])
+		}
+	}
+	if index == 0 {
+		return filePath + ".bak"
+	}
+	return fmt.Sprintf("%s.bak.%d", filePath, index)
+}
+
+// declaredEncoding 返回XML声明中的编码，没有声明时返回空字符串
+func declaredEncoding(content []byte) string {
+	decl := xmlDeclRegex.Find(content)
+	if decl == nil {
+		return ""
+	}
+	if match := xmlEncodingRegex.FindSubmatch(decl); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// setDeclaredEncoding 将XML声明的编码改为UTF-8，没有声明时添加
+func setDeclaredEncoding(content []byte) []byte {
+	decl := xmlDeclRegex.Find(content)
+	if decl == nil {
+		return append([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"), content...)
+	}
+
+	var newDecl []byte
+	if match := xmlEncodingRegex.FindSubmatch(decl); match != nil && strings.EqualFold(string(match[1]), "utf-8") {
+		return content
+	} else if match != nil {
+		newDecl = xmlEncodingRegex.ReplaceAll(decl, []byte(`encoding="UTF-8"`))
+	} else {
+		newDecl = bytes.Replace(decl, []byte("?>"), []byte(` encoding="UTF-8"?>`), 1)
+	}
+	if bytes.Equal(newDecl, decl) {
+		return content
+	}
+	return append(newDecl, content[len(decl):]...)
+}
@@ -1,32 +1,50 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
-	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/metrics"
 )
 
 // NFO表示NFO文件的结构
 type NFO struct {
-	XMLName       xml.Name // 根标签，动态设置为movie或tvshow
-	Title         string   `xml:"title"`
-	OriginalTitle string   `xml:"originaltitle"`
-	Year          string   `xml:"year"`
-	Country       []string `xml:"country"`
-	Genres        []string `xml:"genre"`
-	Actors        []Actor  `xml:"actor"`
-	Runtime       string   `xml:"runtime"`
-	Plot          string   `xml:"plot"`
-	IMDbID        string   `xml:"id" xml:"imdbid"`
-	TMDbID        string   `xml:"tmdbid"`
-	Season        string   `xml:"season"`
-	Episode       string   `xml:"episode"`
-	Director      string   `xml:"director"`
-	Writer        string   `xml:"writer"`
-	Rating        string   `xml:"rating"`
+	XMLName       xml.Name   // 根标签，动态设置为movie、tvshow或musicvideo
+	Title         string     `xml:"title"`
+	OriginalTitle string     `xml:"originaltitle"`
+	Year          string     `xml:"year"`
+	Country       []string   `xml:"country"`
+	Genres        []string   `xml:"genre"`
+	Actors        []Actor    `xml:"actor"`
+	Runtime       string     `xml:"runtime"`
+	Plot          string     `xml:"plot"`
+	IMDbID        string     `xml:"id" xml:"imdbid"`
+	TMDbID        string     `xml:"tmdbid"`
+	Season        string     `xml:"season"`
+	Episode       string     `xml:"episode"`
+	Director      string     `xml:"director"`
+	Writer        string     `xml:"writer"`
+	Rating        string     `xml:"rating"`
+	Languages     string     `xml:"languages"` // 对白语言，逗号分隔
+	Artists       []string   `xml:"artist"`    // 音乐视频的艺术家
+	Album         string     `xml:"album"`     // 音乐视频所属专辑
+	UniqueIDs     []UniqueID `xml:"uniqueid"`  // Kodi格式的外部ID，如 <uniqueid type="tmdb">
+	Tags          []string   `xml:"tag"`       // 标签，Jellyfin等媒体服务器可按标签筛选
+	Comment       string     `xml:",comment"`  // 根元素下的注释，如补充简介时记录的来源
 	// 其他可能需要的字段
 }
 
+// UniqueID表示Kodi格式的外部ID
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:",chardata"`
+}
+
 // Actor表示演员信息
 type Actor struct {
 	Name string `xml:"name"`
@@ -35,15 +53,20 @@ type Actor struct {
 
 // ParseNFO解析指定路径的NFO文件
 func ParseNFO(filePath string) (*NFO, error) {
-	// 打开NFO文件
-	file, err := os.Open(filePath)
+	defer metrics.Start(metrics.OpParse, filePath)()
+
+	// 读取NFO文件并转换为UTF-8
+	content, _, err := ReadNFOFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("无法打开NFO文件: %w", err)
 	}
-	defer file.Close()
+	return ParseNFOContent(content)
+}
 
+// ParseNFOContent解析UTF-8编码的NFO内容
+func ParseNFOContent(content []byte) (*NFO, error) {
 	// 创建XML解码器
-	decoder := xml.NewDecoder(file)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
 
 	// 跳过XML声明
 	for {
@@ -53,7 +76,7 @@ func ParseNFO(filePath string) (*NFO, error) {
 		}
 		if startElement, ok := token.(xml.StartElement); ok {
 			// 检查根标签类型
-			if startElement.Name.Local == "movie" || startElement.Name.Local == "tvshow" {
+			if startElement.Name.Local == "movie" || startElement.Name.Local == "tvshow" || startElement.Name.Local == "musicvideo" {
 				// 创建NFO结构体并设置根标签
 				var nfo NFO
 				nfo.XMLName = startElement.Name
@@ -63,6 +86,14 @@ func ParseNFO(filePath string) (*NFO, error) {
 					return nil, fmt.Errorf("无法解析NFO文件: %w", err)
 				}
 
+				// 没有单独的ID标签时使用uniqueid
+				if nfo.IMDbID == "" {
+					nfo.IMDbID = nfo.UniqueID("imdb")
+				}
+				if nfo.TMDbID == "" {
+					nfo.TMDbID = nfo.UniqueID("tmdb")
+				}
+
 				return &nfo, nil
 			}
 			return nil, fmt.Errorf("不支持的NFO文件类型: %s", startElement.Name.Local)
@@ -70,12 +101,73 @@ func ParseNFO(filePath string) (*NFO, error) {
 	}
 }
 
+// UniqueID返回指定类型（如imdb、tmdb）的uniqueid，不存在时返回空字符串
+func (n *NFO) UniqueID(idType string) string {
+	for _, id := range n.UniqueIDs {
+		if strings.EqualFold(id.Type, idType) {
+			return strings.TrimSpace(id.Value)
+		}
+	}
+	return ""
+}
+
+// ParseNumber 解析NFO中的季号或集号（如 2、02），为空、无法解析或为负数（Kodi用-1表示未知）时返回nil
+// 第0季（特别篇）返回0
+func ParseNumber(value string) *int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// SeasonNumber 返回解析后的季号，没有或无法解析时返回nil
+func (n *NFO) SeasonNumber() *int {
+	return ParseNumber(n.Season)
+}
+
+// EpisodeNumber 返回解析后的集号，没有或无法解析时返回nil
+func (n *NFO) EpisodeNumber() *int {
+	return ParseNumber(n.Episode)
+}
+
+// plotSourceRe 记录简介来源的注释内容，如 "plot_source: wikipedia https://zh.wikipedia.org/wiki/..."
+var plotSourceRe = regexp.MustCompile(`plot_source:\s*(\S+)`)
+
+// PlotSourceComment 返回记录简介来源和条目地址的NFO注释
+func PlotSourceComment(source string, pageURL string) string {
+	// 注释中不能出现 "--"
+	text := strings.ReplaceAll(strings.TrimSpace(source+" "+pageURL), "--", "%2D%2D")
+	return fmt.Sprintf("<!-- plot_source: %s -->", text)
+}
+
+// plotSourceCommentRe 记录简介来源的注释，包括所在行的缩进和换行
+var plotSourceCommentRe = regexp.MustCompile(`(?m)^[ \t]*<!--\s*plot_source:[\s\S]*?-->[ \t]*(?:\r?\n)?|<!--\s*plot_source:[\s\S]*?-->`)
+
+// RemovePlotSourceComment 删除内容中记录简介来源的注释，简介被替换为其他来源时使用
+func RemovePlotSourceComment(content string) string {
+	return plotSourceCommentRe.ReplaceAllString(content, "")
+}
+
+// PlotSource返回注释中记录的简介来源（如 wikipedia、tmdb:en-US），简介来自刮削时返回空字符串
+func (n *NFO) PlotSource() string {
+	if match := plotSourceRe.FindStringSubmatch(n.Comment); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
 // IsTVShow判断是否为电视剧（根据XML根标签）
 func (n *NFO) IsTVShow() bool {
 	// 根据XML根标签判断：如果是tvshow则为电视剧，否则为电影
 	return n.XMLName.Local == "tvshow"
 }
 
+// IsMusicVideo判断是否为音乐视频或演唱会（根据XML根标签）
+func (n *NFO) IsMusicVideo() bool {
+	return n.XMLName.Local == "musicvideo"
+}
+
 // GetFullTitle获取完整的影片标题
 func (n *NFO) GetFullTitle() string {
 	if n.OriginalTitle != "" {
@@ -83,3 +175,15 @@ func (n *NFO) GetFullTitle() string {
 	}
 	return fmt.Sprintf("%s (%s)", n.Title, n.Year)
 }
+
+// GetLanguages获取NFO中记录的对白语言列表
+func (n *NFO) GetLanguages() []string {
+	var languages []string
+	for _, language := range strings.Split(n.Languages, ",") {
+		language = strings.TrimSpace(language)
+		if language != "" {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
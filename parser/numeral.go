@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ChineseNumberPattern 匹配阿拉伯数字或中文数字（如 12、十二、一百零五）的正则表达式片段，配合ParseChineseNumber使用
+const ChineseNumberPattern = `[0-9零〇一二两三四五六七八九十百千]+`
+
+// chineseDigits 中文数字
+var chineseDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// chineseUnits 中文数字的单位
+var chineseUnits = map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+// ParseChineseNumber 解析阿拉伯数字或中文数字，如 "12"、"十二"、"二十"、"一百零五"，无法解析时ok为false
+func ParseChineseNumber(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+
+	total, digit := 0, -1
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			// 两个非零数字连写（如 一二）不是有效的中文数字
+			if digit > 0 {
+				return 0, false
+			}
+			digit = d
+			continue
+		}
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, false
+		}
+		if digit < 0 {
+			digit = 1 // 十二 表示 一十二
+		}
+		total += digit * unit
+		digit = -1
+	}
+	if digit > 0 {
+		total += digit
+	}
+	return total, true
+}
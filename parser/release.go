@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ReleaseTags 表示从国内平台（N_m3u8DL、WEB-DL等）发布的文件或目录名中解析出的标签
+// 例如 "繁花.2023.S01E01.4K.HDR.60帧.国语中字.WEB-DL.央视频"
+type ReleaseTags struct {
+	AudioLanguages []string // 音轨语言，如 国语、粤语
+	Sources        []string // 来源平台，如 央视频、腾讯视频、WEB-DL
+	HDRFormats     []string // HDR格式，如 DV、HDR10+、HDR10、HLG
+	Tags           []string // 其余画质和字幕标签，如 4K、60帧、中字
+}
+
+// HDR格式名称
+const (
+	HDRDolbyVision = "DV"
+	HDR10Plus      = "HDR10+"
+	HDR10          = "HDR10"
+	HDRHLG         = "HLG"
+	HDRGeneric     = "HDR" // 只标注了HDR，没有写明具体格式
+)
+
+// releaseTagPattern 一条标签匹配规则，命中时记录为统一的名称
+type releaseTagPattern struct {
+	re   *regexp.Regexp
+	name string
+}
+
+var (
+	// 音轨语言，"国粤双语"等同时包含多种语言
+	releaseAudioPatterns = []releaseTagPattern{
+		{regexp.MustCompile(`国语|國語|国配|國配|普通话|普通話|国粤|國粵|(?i)\bMandarin\b`), "国语"},
+		{regexp.MustCompile(`粤语|粵語|粤配|粵配|国粤|國粵|(?i)\bCantonese\b`), "粤语"},
+		{regexp.MustCompile(`闽南语|閩南語|台语|台語`), "闽南语"},
+		{regexp.MustCompile(`英语|英語|(?i)\bEnglish\b`), "英语"},
+		{regexp.MustCompile(`日语|日語`), "日语"},
+		{regexp.MustCompile(`韩语|韓語`), "韩语"},
+	}
+
+	// 来源平台
+	releaseSourcePatterns = []releaseTagPattern{
+		{regexp.MustCompile(`央视频|(?i)\bYSP\b`), "央视频"},
+		{regexp.MustCompile(`(?i)\bCCTV\d*\b|央视(?:$|[^频頻])`), "央视"},
+		{regexp.MustCompile(`腾讯视频|騰訊視頻|(?i)\b(TX|Tencent|WeTV)\b`), "腾讯视频"},
+		{regexp.MustCompile(`爱奇艺|愛奇藝|(?i)\biQIYI\b`), "爱奇艺"},
+		{regexp.MustCompile(`优酷|優酷|(?i)\bYouku\b`), "优酷"},
+		{regexp.MustCompile(`芒果TV|芒果台|(?i)\bMGTV\b`), "芒果TV"},
+		{regexp.MustCompile(`哔哩哔哩|嗶哩嗶哩|B站|(?i)\bBilibili\b`), "哔哩哔哩"},
+		{regexp.MustCompile(`咪咕|(?i)\bMIGU\b`), "咪咕视频"},
+		{regexp.MustCompile(`西瓜视频|(?i)\bIXIGUA\b`), "西瓜视频"},
+		{regexp.MustCompile(`(?i)\bWEB-?DL\b`), "WEB-DL"},
+		{regexp.MustCompile(`(?i)\bWEB-?Rip\b`), "WEBRip"},
+	}
+
+	// HDR格式，按优先级排列
+	releaseHDRPatterns = []releaseTagPattern{
+		{regexp.MustCompile(`(?i)\b(DV|DoVi|Dolby[ .]?Vision)\b|杜比视界`), HDRDolbyVision},
+		{regexp.MustCompile(`(?i)\bHDR10(\+|Plus\b)`), HDR10Plus},
+		{regexp.MustCompile(`(?i)\bHDR10(?:$|[^+P\w])`), HDR10},
+		{regexp.MustCompile(`(?i)\bHLG\b`), HDRHLG},
+		{regexp.MustCompile(`(?i)\bHDR(?:$|[^1\w])|高动态`), HDRGeneric},
+	}
+
+	// 画质和字幕
+	releaseQualityPatterns = []releaseTagPattern{
+		{regexp.MustCompile(`(?i)\b4K\b|2160[pP]`), "4K"},
+		{regexp.MustCompile(`60\s*[帧幀]|(?i)\b60\s*fps\b`), "60帧"},
+		{regexp.MustCompile(`高码率|(?i)\bHQ\b`), "高码率"},
+		{regexp.MustCompile(`中字|中文字幕|简中|簡中|繁中|(?i)\bCHS\b|\bCHT\b`), "中字"},
+		{regexp.MustCompile(`双语字幕|雙語字幕|中英字幕|中英双字|中英雙字`), "双语字幕"},
+	}
+)
+
+// ParseReleaseTags 解析文件或目录名中的音轨语言、来源平台和画质标签
+func ParseReleaseTags(name string) ReleaseTags {
+	var tags ReleaseTags
+	tags.AudioLanguages = matchReleaseTags(name, releaseAudioPatterns)
+	tags.Sources = matchReleaseTags(name, releaseSourcePatterns)
+	tags.HDRFormats = matchReleaseTags(name, releaseHDRPatterns)
+	if len(tags.HDRFormats) > 1 {
+		// 写明了具体格式时不再记录笼统的HDR
+		tags.HDRFormats = removeValue(tags.HDRFormats, HDRGeneric)
+	}
+	tags.Tags = matchReleaseTags(name, releaseQualityPatterns)
+	return tags
+}
+
+// Merge 合并另一组标签，保持首次出现的顺序并去重
+func (t *ReleaseTags) Merge(other ReleaseTags) {
+	t.AudioLanguages = appendUnique(t.AudioLanguages, other.AudioLanguages...)
+	t.Sources = appendUnique(t.Sources, other.Sources...)
+	t.HDRFormats = appendUnique(t.HDRFormats, other.HDRFormats...)
+	t.Tags = appendUnique(t.Tags, other.Tags...)
+}
+
+// IsEmpty 判断是否没有解析出任何标签
+func (t ReleaseTags) IsEmpty() bool {
+	return len(t.AudioLanguages) == 0 && len(t.Sources) == 0 && len(t.HDRFormats) == 0 && len(t.Tags) == 0
+}
+
+// matchReleaseTags 返回名称中命中的所有标签
+func matchReleaseTags(name string, patterns []releaseTagPattern) []string {
+	// 点、下划线和方括号都作为分隔符，保证\b能正确匹配
+	normalized := strings.NewReplacer(".", " ", "_", " ", "[", " ", "]", " ", "【", " ", "】", " ").Replace(name)
+
+	var matched []string
+	for _, pattern := range patterns {
+		if pattern.re.MatchString(normalized) {
+			matched = appendUnique(matched, pattern.name)
+		}
+	}
+	return matched
+}
+
+// appendUnique 追加列表中还没有的值
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		exists := false
+		for _, item := range list {
+			if item == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// audioLanguageAliases 音轨语言代码（ISO 639-1、639-2、639-3）和常见写法对应的名称
+var audioLanguageAliases = map[string]string{
+	"国语": "国语", "zh": "国语", "chi": "国语", "zho": "国语", "cmn": "国语", "mandarin": "国语",
+	"粤语": "粤语", "yue": "粤语", "cantonese": "粤语",
+	"闽南语": "闽南语", "nan": "闽南语",
+	"英语": "英语", "en": "英语", "eng": "英语", "english": "英语",
+	"日语": "日语", "ja": "日语", "jpn": "日语", "japanese": "日语",
+	"韩语": "韩语", "ko": "韩语", "kor": "韩语", "korean": "韩语",
+}
+
+// NormalizeAudioLanguage 将音轨语言代码或名称统一为数据库中记录的名称（如 yue → 粤语）
+// 无法识别的值转为小写后原样返回
+func NormalizeAudioLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if name, ok := audioLanguageAliases[language]; ok {
+		return name
+	}
+	return language
+}
+
+// removeValue 返回去掉指定值后的列表
+func removeValue(list []string, value string) []string {
+	var result []string
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+	return result
+}
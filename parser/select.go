@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NFO选择策略，用于同一目录下存在多个NFO文件的情况
+const (
+	NFOSelectDefault   = ""          // 优先选择文件名不带括号的NFO，多NFO目录不参与分类移动
+	NFOSelectVideoName = "videoname" // 优先选择与视频文件同名的NFO
+	NFOSelectStandard  = "standard"  // 优先选择movie.nfo、tvshow.nfo或musicvideo.nfo
+	NFOSelectNewest    = "newest"    // 选择修改时间最新的NFO
+	NFOSelectLargest   = "largest"   // 选择文件最大的NFO
+)
+
+// ListNFOFiles列出目录下（不含子目录）的所有NFO文件
+func ListNFOFiles(dirPath string) ([]string, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.ToLower(filepath.Ext(entry.Name())) == ".nfo" {
+			files = append(files, filepath.Join(dirPath, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// SelectNFOFile按策略从同一目录的多个NFO文件中选择一个，返回选中的文件和选择依据
+// 策略没有命中时退回默认规则
+func SelectNFOFile(files []string, strategy string) (string, string) {
+	if len(files) == 0 {
+		return "", ""
+	}
+	if len(files) == 1 {
+		return files[0], "唯一的NFO文件"
+	}
+
+	switch strategy {
+	case NFOSelectVideoName:
+		if selected := selectByVideoName(files); selected != "" {
+			return selected, "与视频文件同名"
+		}
+	case NFOSelectStandard:
+		for _, standardName := range []string{"movie.nfo", "tvshow.nfo", "musicvideo.nfo"} {
+			for _, file := range files {
+				if strings.EqualFold(filepath.Base(file), standardName) {
+					return file, "标准文件名 " + standardName
+				}
+			}
+		}
+	case NFOSelectNewest:
+		if selected := selectByFileInfo(files, func(a, b os.FileInfo) bool { return a.ModTime().After(b.ModTime()) }); selected != "" {
+			return selected, "修改时间最新"
+		}
+	case NFOSelectLargest:
+		if selected := selectByFileInfo(files, func(a, b os.FileInfo) bool { return a.Size() > b.Size() }); selected != "" {
+			return selected, "文件最大"
+		}
+	}
+
+	// 默认规则：优先选择没有"(数字)"后缀的文件
+	for _, file := range files {
+		fileName := filepath.Base(file)
+		if !strings.Contains(fileName, "(") || !strings.Contains(fileName, ")") {
+			return file, "文件名不带括号"
+		}
+	}
+
+	// 如果没有找到合适的，选择第一个
+	return files[0], "第一个NFO文件"
+}
+
+// selectByVideoName选择与目录中最大的视频文件同名的NFO
+func selectByVideoName(files []string) string {
+	dirPath := filepath.Dir(files[0])
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return ""
+	}
+
+	var selected string
+	var selectedSize int64 = -1
+	for _, entry := range entries {
+		if entry.IsDir() || !isVideoFileName(entry.Name()) {
+			continue
+		}
+		baseName := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		for _, file := range files {
+			nfoBase := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			if nfoBase != baseName {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if info.Size() > selectedSize {
+				selected = file
+				selectedSize = info.Size()
+			}
+		}
+	}
+	return selected
+}
+
+// selectByFileInfo按文件信息比较选择NFO，better(a, b)为true表示a优于b
+func selectByFileInfo(files []string, better func(a, b os.FileInfo) bool) string {
+	var selected string
+	var selectedInfo os.FileInfo
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if selectedInfo == nil || better(info, selectedInfo) {
+			selected = file
+			selectedInfo = info
+		}
+	}
+	return selected
+}
+
+// isVideoFileName根据扩展名判断是否为视频文件
+func isVideoFileName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mkv", ".mp4", ".avi", ".wmv", ".flv", ".mov", ".rmvb", ".ts":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 修改NFO文件时只替换或插入相关元素所在的行，保留XML声明、注释、属性顺序、缩进和换行风格
+
+// xmlEscaper 转义XML文本中的特殊字符
+var xmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\"", "&quot;",
+	"'", "&apos;",
+)
+
+// EscapeXML 转义XML文本中的特殊字符
+func EscapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
+
+// Newline 返回内容使用的换行符，默认为\n
+func Newline(content string) string {
+	if strings.Contains(content, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// ChildIndent 返回根元素子元素的缩进，没有子元素时默认两个空格
+func ChildIndent(content string, rootTag string) string {
+	rootOpen := regexp.MustCompile(`<` + regexp.QuoteMeta(rootTag) + `(\s[^>]*)?>`).FindStringIndex(content)
+	if rootOpen == nil {
+		return "  "
+	}
+	if match := regexp.MustCompile(`(?m)^([ \t]+)<[A-Za-z]`).FindStringSubmatch(content[rootOpen[1]:]); match != nil {
+		return match[1]
+	}
+	return "  "
+}
+
+// elementLineRegex 匹配独占一行（或多行）的元素，包括行首缩进和行尾换行
+func elementLineRegex(tag string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(tag)
+	return regexp.MustCompile(`(?m)^([ \t]*)<` + quoted + `(?:\s[^>]*)?(?:/>|>[\s\S]*?</` + quoted + `>)[ \t]*(?:\r?\n)?`)
+}
+
+// elementInlineRegex 匹配与其他内容在同一行的元素
+func elementInlineRegex(tag string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(tag)
+	return regexp.MustCompile(`<` + quoted + `(?:\s[^>]*)?(?:/>|>[\s\S]*?</` + quoted + `>)`)
+}
+
+// HasElement 检查内容中是否存在指定元素
+func HasElement(content string, tag string) bool {
+	return elementInlineRegex(tag).MatchString(content)
+}
+
+// SetElements 将内容中所有指定元素替换为新的值
+// 新元素写在第一个原有元素的位置并沿用其缩进，其余原有元素连同所在行一起删除
+// 没有原有元素时插入到第一个存在的anchors元素之后，都不存在时插入到根元素结束标签之前
+func SetElements(content string, rootTag string, tag string, values []string, anchors ...string) string {
+	newline := Newline(content)
+
+	buildBlock := func(indent string) string {
+		var block strings.Builder
+		for _, value := range values {
+			fmt.Fprintf(&block, "%s<%s>%s</%s>%s", indent, tag, EscapeXML(value), tag, newline)
+		}
+		return block.String()
+	}
+
+	// 独占一行的原有元素
+	lineRegex := elementLineRegex(tag)
+	if matches := lineRegex.FindAllStringSubmatchIndex(content, -1); len(matches) > 0 {
+		indent := content[matches[0][2]:matches[0][3]]
+		var result strings.Builder
+		last := 0
+		for i, match := range matches {
+			result.WriteString(content[last:match[0]])
+			if i == 0 {
+				result.WriteString(buildBlock(indent))
+			}
+			last = match[1]
+		}
+		result.WriteString(content[last:])
+		return result.String()
+	}
+
+	// 与其他内容在同一行的原有元素，原位替换
+	inlineRegex := elementInlineRegex(tag)
+	if matches := inlineRegex.FindAllStringIndex(content, -1); len(matches) > 0 {
+		var inline strings.Builder
+		for _, value := range values {
+			fmt.Fprintf(&inline, "<%s>%s</%s>", tag, EscapeXML(value), tag)
+		}
+		var result strings.Builder
+		last := 0
+		for i, match := range matches {
+			result.WriteString(content[last:match[0]])
+			if i == 0 {
+				result.WriteString(inline.String())
+			}
+			last = match[1]
+		}
+		result.WriteString(content[last:])
+		return result.String()
+	}
+
+	// 没有原有元素，插入到锚点元素之后
+	for _, anchor := range anchors {
+		matches := elementLineRegex(anchor).FindAllStringSubmatchIndex(content, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		match := matches[len(matches)-1]
+		indent := content[match[2]:match[3]]
+		insertPos := match[1]
+		prefix := content[:insertPos]
+		if !strings.HasSuffix(prefix, "\n") {
+			prefix += newline
+		}
+		return prefix + buildBlock(indent) + content[insertPos:]
+	}
+
+	return InsertBeforeRootEnd(content, rootTag, strings.TrimSuffix(buildBlock(""), newline))
+}
+
+// InsertBeforeRootEnd 在根元素结束标签之前插入元素，elements中的每一行都使用子元素的缩进
+func InsertBeforeRootEnd(content string, rootTag string, elements string) string {
+	closeTag := "</" + rootTag + ">"
+	insertPos := strings.LastIndex(content, closeTag)
+	if insertPos == -1 {
+		return content
+	}
+
+	newline := Newline(content)
+	indent := ChildIndent(content, rootTag)
+
+	// 结束标签所在行的缩进保持不变，新元素插入到该行行首
+	lineStart := strings.LastIndex(content[:insertPos], "\n") + 1
+	if strings.TrimSpace(content[lineStart:insertPos]) != "" {
+		// 结束标签前还有其他内容，另起一行
+		lineStart = insertPos
+		elements = newline + elements
+	}
+
+	var block strings.Builder
+	for i, line := range strings.Split(strings.ReplaceAll(elements, "\r\n", "\n"), "\n") {
+		if line == "" && i == 0 {
+			block.WriteString(newline)
+			continue
+		}
+		block.WriteString(indent + line + newline)
+	}
+	return content[:lineStart] + block.String() + content[lineStart:]
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/media-manager/config"
+)
+
+// update 为true时用当前的输出重新生成testdata中的期望文件：go test ./parser -run Golden -update
+var update = flag.Bool("update", false, "重新生成golden文件")
+
+// writerCases 每个用例读取 testdata/writer/名称.nfo，执行edit后与 名称.golden.nfo 比较
+var writerCases = []struct {
+	name string
+	edit func(d *Document) error
+}{
+	{
+		// 替换多个同名元素，保留XML声明、注释、属性顺序和两个空格的缩进
+		name: "genres",
+		edit: func(d *Document) error {
+			return d.SetElements("genre", []string{"科幻", "剧情"})
+		},
+	},
+	{
+		// CRLF换行和制表符缩进，没有原有元素时插入到锚点元素之后
+		name: "crlf_tabs",
+		edit: func(d *Document) error {
+			return d.SetElements("country", []string{"中国大陆"}, "year")
+		},
+	},
+	{
+		// 与其他元素在同一行的元素原位替换
+		name: "inline",
+		edit: func(d *Document) error {
+			return d.SetElements("title", []string{"流浪地球"})
+		},
+	},
+	{
+		// 锚点元素都不存在时插入到根元素结束标签之前，沿用子元素的四个空格缩进
+		name: "insert_root_end",
+		edit: func(d *Document) error {
+			return d.SetElements("tmdbid", []string{"535167"}, "uniqueid", "imdbid")
+		},
+	},
+	{
+		// 自闭合元素替换为转义后的值，其他元素的属性顺序不变
+		name: "escape",
+		edit: func(d *Document) error {
+			return d.SetElements("plot", []string{`"地球" & <太阳> 'A'`})
+		},
+	},
+	{
+		// 替换简介时删除简介来源注释，其他注释保留
+		name: "plot_source",
+		edit: func(d *Document) error {
+			if err := d.RemovePlotSourceComment(); err != nil {
+				return err
+			}
+			return d.SetElements("plot", []string{"新的简介。"})
+		},
+	},
+}
+
+func writerInput(name string) string {
+	return filepath.Join("testdata", "writer", name+".nfo")
+}
+
+func writerGolden(name string) string {
+	return filepath.Join("testdata", "writer", name+".golden.nfo")
+}
+
+func TestWriterGolden(t *testing.T) {
+	for _, tc := range writerCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := LoadDocument(writerInput(tc.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.edit(doc); err != nil {
+				t.Fatal(err)
+			}
+			if !doc.Modified() {
+				t.Fatal("修改后Modified应为true")
+			}
+
+			golden := writerGolden(tc.name)
+			if *update {
+				if err := os.WriteFile(golden, []byte(doc.Content()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.Content(); got != string(want) {
+				t.Errorf("输出与 %s 不一致\n得到:\n%s\n期望:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.TempDirs = []string{dir}
+	config.Use(cfg)
+	defer config.Use(nil)
+
+	for _, tc := range writerCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input, err := os.ReadFile(writerInput(tc.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(writerGolden(tc.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, tc.name+".nfo")
+			if err := os.WriteFile(path, input, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// 没有修改时不写入文件
+			doc, err := LoadDocument(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved, err := doc.Save(); err != nil || saved {
+				t.Fatalf("没有修改时Save() = %v, %v，应为 false, nil", saved, err)
+			}
+
+			// 修改后写入文件，再读取的内容与golden文件一致
+			if err := tc.edit(doc); err != nil {
+				t.Fatal(err)
+			}
+			if saved, err := doc.Save(); err != nil || !saved {
+				t.Fatalf("修改后Save() = %v, %v，应为 true, nil", saved, err)
+			}
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(written) != string(want) {
+				t.Errorf("写入的内容与golden文件不一致\n得到:\n%s\n期望:\n%s", written, want)
+			}
+
+			// 重新读取后再次执行相同的修改，内容不变
+			reloaded, err := LoadDocument(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.edit(reloaded); err != nil {
+				t.Fatal(err)
+			}
+			if reloaded.Modified() {
+				t.Errorf("再次执行相同的修改后内容发生变化:\n%s", reloaded.Content())
+			}
+		})
+	}
+}
+
+func TestWriterPreservesUnrelatedContent(t *testing.T) {
+	doc, err := LoadDocument(writerInput("genres"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := doc.Content()
+	if err := doc.SetElements("genre", []string{"科幻"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有genre所在的行改变，其余的行原样保留
+	var kept []string
+	for _, line := range strings.Split(original, "\n") {
+		if !strings.Contains(line, "<genre>") {
+			kept = append(kept, line)
+		}
+	}
+	for _, line := range kept {
+		if !strings.Contains(doc.Content(), line) {
+			t.Errorf("修改后缺少原有的行: %q", line)
+		}
+	}
+	if genres := doc.NFO.Genres; len(genres) != 1 || genres[0] != "科幻" {
+		t.Errorf("Genres = %v，应为 [科幻]", genres)
+	}
+}
@@ -0,0 +1,139 @@
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/user/media-manager/utils"
+)
+
+// 程序使用的目录
+const (
+	Config  = "config"  // 配置文件、锁文件、插件和守护进程socket
+	Data    = "Data"    // 数据库
+	Logs    = "logs"    // 日志文件
+	Cache   = "cache"   // TMDB详情、tinyMediaManager输出和NFO备份
+	Reports = "reports" // calendar等命令生成的文件
+)
+
+// HomeEnv 指定程序根目录的环境变量，根目录下的config、Data、logs、cache、reports分别作为对应的目录（不存在则创建）
+const HomeEnv = "MEDIA_MANAGER_HOME"
+
+// appHomeDir 用户主目录下的程序目录名
+const appHomeDir = ".media-manager"
+
+// kinds 各目录在用户主目录下.media-manager中的子目录，配置文件直接放在.media-manager中
+var kinds = map[string]string{
+	Config:  "",
+	Data:    "Data",
+	Logs:    "logs",
+	Cache:   "cache",
+	Reports: "reports",
+}
+
+var (
+	mu        sync.RWMutex
+	home      string            // 命令行参数指定的程序根目录
+	overrides map[string]string // 单独指定的目录
+)
+
+// SetHome 指定程序根目录（-home参数），优先于环境变量MEDIA_MANAGER_HOME
+func SetHome(dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	home = dir
+}
+
+// Home 返回SetHome指定的程序根目录，没有指定时返回空字符串
+func Home() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return home
+}
+
+// Set 单独指定某个目录，优先于环境变量和程序根目录；dir为空时取消
+func Set(kind string, dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+	if dir == "" {
+		delete(overrides, kind)
+		return
+	}
+	overrides[kind] = dir
+}
+
+// EnvName 返回单独指定目录的环境变量名，如 MEDIA_MANAGER_DATA_DIR
+func EnvName(kind string) string {
+	return "MEDIA_MANAGER_" + strings.ToUpper(kind) + "_DIR"
+}
+
+// Dir 返回程序使用的目录，按以下顺序确定：
+// 1. Set指定的目录；2. 环境变量MEDIA_MANAGER_<目录>_DIR；
+// 3. -home参数或环境变量MEDIA_MANAGER_HOME指定的根目录下的同名目录（以上几处不存在时创建）；
+// 4. 当前目录下的同名目录；5. 程序执行文件所在目录下的同名目录（这两处只检查不创建）；
+// 6. 用户主目录下的.media-manager中的对应目录（不存在则创建）
+func Dir(kind string) (string, error) {
+	homeName, ok := kinds[kind]
+	if !ok {
+		return "", fmt.Errorf("未知的程序目录: %s", kind)
+	}
+
+	mu.RLock()
+	override, root := overrides[kind], home
+	mu.RUnlock()
+	if override == "" {
+		override = os.Getenv(EnvName(kind))
+	}
+	if root == "" {
+		root = os.Getenv(HomeEnv)
+	}
+	switch {
+	case override != "":
+		return ensure(override)
+	case root != "":
+		return ensure(filepath.Join(root, kind))
+	}
+
+	if currentDir, err := os.Getwd(); err == nil {
+		dir := filepath.Join(currentDir, kind)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+
+	if exeDir, err := utils.GetExecutableDir(); err == nil {
+		dir := filepath.Join(exeDir, kind)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取用户主目录: %w", err)
+	}
+	return ensure(filepath.Join(homeDir, appHomeDir, homeName))
+}
+
+// File 返回程序目录中的文件路径
+func File(kind string, name string) (string, error) {
+	dir, err := Dir(kind)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// ensure 创建目录（已存在时不处理），返回目录路径
+func ensure(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package permissions
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner 返回文件的所有者用户ID和组ID
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
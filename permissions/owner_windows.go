@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package permissions
+
+import "os"
+
+// fileOwner Windows上没有UID和GID，不检查所有者
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
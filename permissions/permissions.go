@@ -0,0 +1,143 @@
+package permissions
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+)
+
+// Result 检查或修正所有者和权限的统计
+type Result struct {
+	Checked int64 // 检查的文件和目录数
+	Drifted int64 // 所有者或权限与配置不一致的数量
+	Fixed   int64 // 已修正的数量
+	Failed  int64 // 修正失败的数量
+}
+
+// target 解析后的目标所有者和权限，为0的项不检查
+type target struct {
+	uid, gid          int
+	fileMode, dirMode os.FileMode
+}
+
+// Apply 按配置修正路径及其下所有文件和目录的所有者和权限，用于刚移动到媒体库的项目
+func Apply(p config.PermissionsConfig, root string) error {
+	if !p.Enabled() {
+		return nil
+	}
+	result, err := Fix(p, root, 1, true, nil)
+	if err != nil {
+		return err
+	}
+	if result.Fixed > 0 {
+		logging.Debug("已修正 %s 中 %d 个文件和目录的所有者或权限", root, result.Fixed)
+	}
+	return nil
+}
+
+// Fix 使用workers个并发任务检查root下所有文件和目录的所有者和权限，fix为true时修正不一致的项
+// 符号链接不检查；progress在每处理完一项后调用，参数为已处理数和总数
+func Fix(p config.PermissionsConfig, root string, workers int, fix bool, progress func(done, total int64)) (*Result, error) {
+	fileMode, dirMode, err := p.Modes()
+	if err != nil {
+		return nil, err
+	}
+	t := target{uid: p.PUID, gid: p.PGID, fileMode: fileMode, dirMode: dirMode}
+
+	// 先收集所有路径，得到进度的总数
+	var paths []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			logging.Warning("访问路径失败: %s, 错误: %v", path, err)
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	result := &Result{}
+	total := int64(len(paths))
+	var done atomic.Int64
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				fixPath(path, t, fix, result)
+				if progress != nil {
+					progress(done.Add(1), total)
+				}
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+	return result, nil
+}
+
+// fixPath 检查并修正单个文件或目录
+func fixPath(path string, t target, fix bool, result *Result) {
+	atomic.AddInt64(&result.Checked, 1)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+
+	wantMode := t.fileMode
+	if info.IsDir() {
+		wantMode = t.dirMode
+	}
+	modeDrift := wantMode != 0 && info.Mode().Perm() != wantMode.Perm()
+
+	uid, gid, hasOwner := fileOwner(info)
+	wantUID, wantGID := -1, -1
+	if hasOwner && t.uid > 0 && uid != t.uid {
+		wantUID = t.uid
+	}
+	if hasOwner && t.gid > 0 && gid != t.gid {
+		wantGID = t.gid
+	}
+	ownerDrift := wantUID >= 0 || wantGID >= 0
+
+	if !modeDrift && !ownerDrift {
+		return
+	}
+	atomic.AddInt64(&result.Drifted, 1)
+	if !fix {
+		logging.Debug("所有者或权限与配置不一致: %s", path)
+		return
+	}
+
+	if ownerDrift {
+		if err := os.Lchown(path, wantUID, wantGID); err != nil {
+			logging.Warning("修改所有者失败: %v", err)
+			atomic.AddInt64(&result.Failed, 1)
+			return
+		}
+	}
+	if modeDrift {
+		if err := os.Chmod(path, wantMode.Perm()); err != nil {
+			logging.Warning("修改权限失败: %v", err)
+			atomic.AddInt64(&result.Failed, 1)
+			return
+		}
+	}
+	atomic.AddInt64(&result.Fixed, 1)
+}
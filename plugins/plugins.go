@@ -0,0 +1,312 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/hooks"
+	"github.com/user/media-manager/logging"
+)
+
+// 插件能力
+const (
+	CapabilityClassifier = "classifier" // 自定义分类
+	CapabilityMetadata   = "metadata"   // 补充元数据（国家、类型、语言）
+	CapabilityNotifier   = "notifier"   // 接收处理事件通知
+)
+
+// 插件方法名
+const (
+	MethodDescribe = "describe"
+	MethodClassify = "classify"
+	MethodMetadata = "metadata"
+	MethodNotify   = "notify"
+)
+
+// 通知事件
+const (
+	EventMoved = "moved" // 影片已移动到Cloud目录
+)
+
+// DefaultTimeout 单次插件调用的超时时间
+const DefaultTimeout = 30 * time.Second
+
+// Plugin 表示一个已发现的插件
+type Plugin struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+	Path         string   `json:"-"`
+}
+
+// Has 检查插件是否具备指定能力
+func (p *Plugin) Has(capability string) bool {
+	for _, c := range p.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyRequest classify方法的参数
+type ClassifyRequest struct {
+	Title            string   `json:"title"`
+	OriginalTitle    string   `json:"original_title,omitempty"`
+	Year             string   `json:"year,omitempty"`
+	IsTVShow         bool     `json:"is_tvshow"`
+	Countries        []string `json:"countries"`
+	Genres           []string `json:"genres"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	TMDbID           string   `json:"tmdb_id,omitempty"`
+	IMDbID           string   `json:"imdb_id,omitempty"`
+	SourcePath       string   `json:"source_path"`
+	DefaultCategory  string   `json:"default_category,omitempty"` // 内置规则得出的分类，可能为空
+}
+
+// ClassifyResult classify方法的返回值，分类为空表示不干预
+type ClassifyResult struct {
+	Category string `json:"category"`
+}
+
+// MetadataRequest metadata方法的参数
+type MetadataRequest struct {
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title,omitempty"`
+	Year          string `json:"year,omitempty"`
+	IsTVShow      bool   `json:"is_tvshow"`
+	TMDbID        string `json:"tmdb_id,omitempty"`
+	IMDbID        string `json:"imdb_id,omitempty"`
+	Language      string `json:"language,omitempty"` // 配置的元数据语言，如zh-CN
+}
+
+// Metadata metadata方法的返回值，空字段表示不提供
+type Metadata struct {
+	Countries        []string `json:"countries,omitempty"`
+	Genres           []string `json:"genres,omitempty"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+
+	Sources map[string]string `json:"-"` // 合并后各字段来自的插件名，键为countries、genres、original_language
+}
+
+// NotifyRequest notify方法的参数
+type NotifyRequest struct {
+	Event string      `json:"event"`
+	Item  *hooks.Item `json:"item"`
+}
+
+// rpcRequest JSON-RPC 2.0请求
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// rpcResponse JSON-RPC 2.0响应
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+// rpcError JSON-RPC 2.0错误
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	loadOnce sync.Once
+	loaded   []*Plugin
+)
+
+// Loaded 返回配置的插件目录中发现的插件，只在首次调用时扫描
+func Loaded() []*Plugin {
+	loadOnce.Do(func() {
+		cfg, err := config.Load()
+		if err != nil {
+			logging.Warning("加载插件失败: %v", err)
+			return
+		}
+		plugins, err := Discover(cfg.PluginsDir)
+		if err != nil {
+			logging.Warning("加载插件失败: %v", err)
+			return
+		}
+		loaded = plugins
+	})
+	return loaded
+}
+
+// Discover 扫描目录中的可执行文件，通过describe方法获取插件信息
+// 目录不存在时返回空列表
+func Discover(dir string) ([]*Plugin, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取插件目录失败: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var plugins []*Plugin
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if !isExecutable(path) {
+			continue
+		}
+
+		plugin := &Plugin{Path: path}
+		if err := call(path, MethodDescribe, nil, plugin); err != nil {
+			logging.Warning("插件 %s 描述信息获取失败，跳过: %v", entry.Name(), err)
+			continue
+		}
+		plugin.Path = path
+		if plugin.Name == "" {
+			plugin.Name = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		logging.Info("已加载插件 %s（%s），能力: %v", plugin.Name, path, plugin.Capabilities)
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// isExecutable 检查文件是否可以作为插件执行
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode()&0111 != 0
+}
+
+// call 启动插件进程，通过标准输入发送一条JSON-RPC请求并从标准输出读取响应
+func call(path string, method string, params interface{}, result interface{}) error {
+	input, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("序列化插件请求失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("调用 %s 超时（%v）", method, DefaultTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("调用 %s 失败: %w: %s", method, err, msg)
+		}
+		return fmt.Errorf("调用 %s 失败: %w", method, err)
+	}
+
+	var response rpcResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return fmt.Errorf("解析插件响应失败: %w", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("插件返回错误 %d: %s", response.Error.Code, response.Error.Message)
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("解析插件返回值失败: %w", err)
+	}
+	return nil
+}
+
+// Classify 依次询问分类插件，返回第一个给出的分类和插件名
+// 没有插件给出分类时返回空字符串
+func Classify(req *ClassifyRequest) (string, string) {
+	for _, plugin := range Loaded() {
+		if !plugin.Has(CapabilityClassifier) {
+			continue
+		}
+		var result ClassifyResult
+		if err := call(plugin.Path, MethodClassify, req, &result); err != nil {
+			logging.Warning("分类插件 %s 调用失败: %v", plugin.Name, err)
+			continue
+		}
+		if category := strings.TrimSpace(result.Category); category != "" {
+			return category, plugin.Name
+		}
+	}
+	return "", ""
+}
+
+// FetchMetadata 依次询问元数据插件，合并各插件返回的非空字段（先加载的插件优先）
+func FetchMetadata(req *MetadataRequest) *Metadata {
+	merged := &Metadata{Sources: make(map[string]string)}
+	for _, plugin := range Loaded() {
+		if !plugin.Has(CapabilityMetadata) {
+			continue
+		}
+		var result Metadata
+		if err := call(plugin.Path, MethodMetadata, req, &result); err != nil {
+			logging.Warning("元数据插件 %s 调用失败: %v", plugin.Name, err)
+			continue
+		}
+		if len(merged.Countries) == 0 && len(result.Countries) > 0 {
+			merged.Countries = result.Countries
+			merged.Sources["countries"] = plugin.Name
+		}
+		if len(merged.Genres) == 0 && len(result.Genres) > 0 {
+			merged.Genres = result.Genres
+			merged.Sources["genres"] = plugin.Name
+		}
+		if merged.OriginalLanguage == "" && result.OriginalLanguage != "" {
+			merged.OriginalLanguage = result.OriginalLanguage
+			merged.Sources["original_language"] = plugin.Name
+		}
+	}
+	return merged
+}
+
+// Notify 将事件发送给所有通知插件，失败只记录日志
+func Notify(event string, item *hooks.Item) {
+	for _, plugin := range Loaded() {
+		if !plugin.Has(CapabilityNotifier) {
+			continue
+		}
+		if err := call(plugin.Path, MethodNotify, &NotifyRequest{Event: event, Item: item}, nil); err != nil {
+			logging.Warning("通知插件 %s 调用失败: %v", plugin.Name, err)
+		}
+	}
+}
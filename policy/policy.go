@@ -0,0 +1,284 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/safety"
+	"github.com/user/media-manager/utils"
+)
+
+// videoExtensions 生成.strm文件的视频文件扩展名
+var videoExtensions = []string{".mkv", ".mp4", ".avi", ".wmv", ".flv", ".mov", ".rmvb", ".ts"}
+
+// httpClient 刷新媒体库使用的HTTP客户端
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Apply 对移动完成的影片目录依次执行分类配置的处理策略
+// 单个策略失败只记录日志，不影响其他策略
+func Apply(policies []config.CategoryPolicy, category string, mediaPath string) {
+	for i, p := range policies {
+		logging.Info("执行分类 %s 的第 %d 个处理策略: %s", category, i+1, p.Action)
+
+		var err error
+		switch p.Action {
+		case config.PolicyActionStrm:
+			err = generateStrm(p, mediaPath)
+		case config.PolicyActionCopy:
+			err = copyMedia(p, mediaPath)
+		case config.PolicyActionJellyfinRefresh:
+			err = refreshJellyfin(p)
+		default:
+			err = fmt.Errorf("未知的策略类型: %s", p.Action)
+		}
+
+		if err != nil {
+			logging.Error("分类 %s 的处理策略 %s 执行失败: %v", category, p.Action, err)
+		}
+	}
+}
+
+// isVideoFile 检查文件是否为视频文件
+func isVideoFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, videoExt := range videoExtensions {
+		if ext == videoExt {
+			return true
+		}
+	}
+	return false
+}
+
+// generateStrm 在目标目录下按原有目录结构为每个视频文件生成.strm文件
+// 已存在的.strm文件会被覆盖，以便视频路径变化时保持正确
+func generateStrm(p config.CategoryPolicy, mediaPath string) error {
+	if p.Target == "" {
+		return fmt.Errorf("strm策略没有配置target")
+	}
+
+	targetRoot := filepath.Join(p.Target, filepath.Base(mediaPath))
+	if err := safety.Check(targetRoot); err != nil {
+		return err
+	}
+	count := 0
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	err = utils.Walk(mediaPath, cfg.SymlinkMode(config.SymlinkOpCopy), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if info.IsDir() || !isVideoFile(info.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(mediaPath, path)
+		if err != nil {
+			return err
+		}
+
+		content := path
+		if p.URLPrefix != "" {
+			content = strings.TrimSuffix(p.URLPrefix, "/") + "/" + escapeURLPath(filepath.Join(filepath.Base(mediaPath), relPath))
+		}
+
+		strmPath := filepath.Join(targetRoot, strings.TrimSuffix(relPath, filepath.Ext(relPath))+".strm")
+		if err := os.MkdirAll(filepath.Dir(strmPath), 0755); err != nil {
+			return fmt.Errorf("创建strm目录失败: %w", err)
+		}
+		if err := os.WriteFile(strmPath, []byte(content+"\n"), 0644); err != nil {
+			return fmt.Errorf("写入strm文件失败: %w", err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.Info("已在 %s 生成 %d 个strm文件", targetRoot, count)
+	return nil
+}
+
+// escapeURLPath 对相对路径的每一段进行URL编码
+func escapeURLPath(relPath string) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// copyMedia 将影片目录复制到目标目录，目标已存在且大小相同的文件会跳过
+func copyMedia(p config.CategoryPolicy, mediaPath string) error {
+	if p.Target == "" {
+		return fmt.Errorf("copy策略没有配置target")
+	}
+
+	targetRoot := filepath.Join(p.Target, filepath.Base(mediaPath))
+	copied, skipped := 0, 0
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	err = utils.Walk(mediaPath, cfg.SymlinkMode(config.SymlinkOpCopy), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+
+		relPath, err := filepath.Rel(mediaPath, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(targetRoot, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, 0755)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// symlinks.copy为preserve时在目标目录中重建相同的链接
+			return copySymlink(path, dstPath)
+		}
+
+		if dstInfo, err := os.Stat(dstPath); err == nil && dstInfo.Size() == info.Size() {
+			skipped++
+			return nil
+		}
+
+		if err := copyFileThrottled(path, dstPath, p.RateLimitKB); err != nil {
+			return fmt.Errorf("复制文件 %s 失败: %w", relPath, err)
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.Info("已将影片复制到 %s（复制 %d 个文件，跳过 %d 个已存在的文件）", targetRoot, copied, skipped)
+	return nil
+}
+
+// copySymlink 在目标位置创建与源链接指向相同的符号链接，已存在的同名链接会被替换
+func copySymlink(src, dst string) error {
+	if err := safety.Check(dst); err != nil {
+		return err
+	}
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Lstat(dst); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, dst)
+}
+
+// copyFileThrottled 复制单个文件，rateLimitKB大于0时限制每秒复制的KB数
+// 先写入临时文件，完成后再重命名，避免中断时留下不完整的文件
+func copyFileThrottled(src, dst string, rateLimitKB int) error {
+	if err := safety.Check(dst); err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	tmpPath := dst + ".part"
+	dstFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = srcFile
+	if rateLimitKB > 0 {
+		reader = &throttledReader{reader: srcFile, bytesPerSecond: int64(rateLimitKB) * 1024, start: time.Now()}
+	}
+
+	if _, err := io.Copy(dstFile, reader); err != nil {
+		dstFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dst)
+}
+
+// throttledReader 按固定速度读取的Reader
+type throttledReader struct {
+	reader         io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	total          int64
+}
+
+// Read 读取数据，读取速度超过限制时等待
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// 每次最多读取0.1秒的数据量，使等待更平滑
+	if chunk := t.bytesPerSecond / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.reader.Read(p)
+	t.total += int64(n)
+
+	expected := time.Duration(float64(t.total) / float64(t.bytesPerSecond) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// refreshJellyfin 请求Jellyfin刷新媒体库
+func refreshJellyfin(p config.CategoryPolicy) error {
+	if p.URL == "" {
+		return fmt.Errorf("jellyfin_refresh策略没有配置url")
+	}
+
+	endpoint := strings.TrimSuffix(p.URL, "/") + "/Library/Refresh"
+	if p.LibraryID != "" {
+		endpoint = strings.TrimSuffix(p.URL, "/") + "/Items/" + url.PathEscape(p.LibraryID) + "/Refresh?Recursive=true"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("创建刷新请求失败: %w", err)
+	}
+	if p.APIKey != "" {
+		req.Header.Set("X-Emby-Token", p.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求Jellyfin失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Jellyfin返回状态码 %d", resp.StatusCode)
+	}
+
+	if p.LibraryID != "" {
+		logging.Info("已请求Jellyfin刷新媒体库 %s", p.LibraryID)
+	} else {
+		logging.Info("已请求Jellyfin刷新所有媒体库")
+	}
+	return nil
+}
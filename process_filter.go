@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+)
+
+// parseProcessFilter 根据--only-category、--title参数生成本次运行的处理范围
+func parseProcessFilter(categories string, title string) classifier.ProcessFilter {
+	filter := classifier.ProcessFilter{Title: strings.TrimSpace(title)}
+	for _, category := range strings.Split(categories, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			filter.Categories = append(filter.Categories, category)
+		}
+	}
+	return filter
+}
+
+// filterMatcher 按处理范围检查NFO文件，记住每个文件的结果，避免取出队列项目时重复解析
+type filterMatcher struct {
+	cfg     *config.Config
+	filter  classifier.ProcessFilter
+	matched map[string]bool
+}
+
+// newFilterMatcher 没有指定处理范围时返回nil，nil的filterMatcher接受所有文件
+func newFilterMatcher(cfg *config.Config) *filterMatcher {
+	if classifier.ActiveFilter.Empty() {
+		return nil
+	}
+	logging.Info("本次只处理%s的项目", classifier.ActiveFilter)
+	return &filterMatcher{cfg: cfg, filter: classifier.ActiveFilter, matched: make(map[string]bool)}
+}
+
+// match 判断NFO文件是否在处理范围内
+func (m *filterMatcher) match(nfoPath string) bool {
+	if m == nil {
+		return true
+	}
+	matched, ok := m.matched[nfoPath]
+	if !ok {
+		matched = m.filter.MatchNFO(m.cfg, nfoPath)
+		m.matched[nfoPath] = matched
+		if !matched {
+			logging.Debug("不在本次处理范围内，跳过: %s", nfoPath)
+		}
+	}
+	return matched
+}
+
+// queueMatch 返回取出队列项目时使用的检查函数，没有指定处理范围时返回nil
+func (m *filterMatcher) queueMatch() func(string) bool {
+	if m == nil {
+		return nil
+	}
+	return m.match
+}
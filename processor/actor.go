@@ -23,13 +23,9 @@ type ActorIssue struct {
 	Issue string
 }
 
-// ProcessActor检查NFO文件中的演员名称是否为中文
-func ProcessActor(filePath string) (*ActorReport, error) {
-	// 解析NFO文件
-	nfo, err := parser.ParseNFO(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("处理actor时解析NFO文件失败: %w", err)
-	}
+// ProcessActor检查NFO文档中的演员名称是否为中文
+func ProcessActor(doc *parser.Document) (*ActorReport, error) {
+	nfo, filePath := doc.NFO, doc.Path
 
 	// 创建报告
 	report := &ActorReport{
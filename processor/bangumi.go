@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/media-manager/bangumi"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/utils"
+)
+
+// ProcessBangumi 用Bangumi条目补充NFO文档，返回是否修改了文档：
+// 标题不含中文时改为中文名，缺少原标题、简介时补充，并追加标记人数最多的maxTags个标签
+func ProcessBangumi(doc *parser.Document, subject *bangumi.Subject, maxTags int) (bool, error) {
+	nfo := doc.NFO
+	modified := false
+
+	if subject.NameCN != "" && !utils.IsChinese(nfo.Title) {
+		oldTitle := nfo.Title
+		// 原来的标题作为原标题保留
+		if strings.TrimSpace(nfo.OriginalTitle) == "" && oldTitle != "" {
+			if err := doc.SetElements("originaltitle", []string{oldTitle}, "title"); err != nil {
+				return modified, fmt.Errorf("更新originaltitle字段失败: %w", err)
+			}
+		}
+		if err := doc.SetElements("title", []string{subject.NameCN}); err != nil {
+			return modified, fmt.Errorf("更新title字段失败: %w", err)
+		}
+		logging.Info("使用Bangumi中文名: '%s' → '%s': %s", oldTitle, subject.NameCN, doc.Path)
+		modified = true
+	}
+
+	if strings.TrimSpace(doc.NFO.OriginalTitle) == "" && subject.Name != "" {
+		if err := doc.SetElements("originaltitle", []string{subject.Name}, "title"); err != nil {
+			return modified, fmt.Errorf("更新originaltitle字段失败: %w", err)
+		}
+		modified = true
+	}
+
+	if strings.TrimSpace(doc.NFO.Plot) == "" && strings.TrimSpace(subject.Summary) != "" {
+		if err := doc.SetElements("plot", []string{strings.TrimSpace(subject.Summary)}, "originaltitle", "title"); err != nil {
+			return modified, fmt.Errorf("更新plot字段失败: %w", err)
+		}
+		logging.Info("使用Bangumi简介补充plot: %s", doc.Path)
+		modified = true
+	}
+
+	if maxTags > 0 {
+		tags := append([]string(nil), doc.NFO.Tags...)
+		var added []string
+		for _, tag := range subject.TopTags(maxTags) {
+			if !containsFold(tags, tag) {
+				tags = append(tags, tag)
+				added = append(added, tag)
+			}
+		}
+		if len(added) > 0 {
+			if err := doc.SetElements("tag", tags, "genre", "country", "year", "title"); err != nil {
+				return modified, fmt.Errorf("更新tag字段失败: %w", err)
+			}
+			logging.Info("添加Bangumi标签 %v: %s", added, doc.Path)
+			modified = true
+		}
+	}
+	return modified, nil
+}
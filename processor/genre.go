@@ -2,25 +2,23 @@ package processor
 
 import (
 	"fmt"
-	"os"
-	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
+	"github.com/user/media-manager/config"
 	"github.com/user/media-manager/logging"
 	"github.com/user/media-manager/parser"
 	"github.com/user/media-manager/utils"
 )
 
-// ProcessGenre检查并翻译NFO文件中的genre字段，返回是否修改了文件
-func ProcessGenre(filePath string) (bool, error) {
-	// 解析NFO文件
-	nfo, err := parser.ParseNFO(filePath)
-	if err != nil {
-		return false, fmt.Errorf("处理genre时解析NFO文件失败: %w", err)
-	}
+// ProcessGenre检查并翻译NFO文档中的genre字段，返回是否修改了文档
+func ProcessGenre(doc *parser.Document) (bool, error) {
+	nfo, filePath := doc.NFO, doc.Path
+	genres := append([]string(nil), nfo.Genres...)
 
 	// 如果没有类型字段，返回错误
-	if len(nfo.Genres) == 0 {
+	if len(genres) == 0 {
 		logging.Warning("NFO文件中没有找到类型字段: %s", filePath)
 		// 不返回错误，继续处理其他字段
 		return false, nil
@@ -28,20 +26,33 @@ func ProcessGenre(filePath string) (bool, error) {
 
 	// 检查并翻译每个genre
 	hasChanges := false
-	for i, genre := range nfo.Genres {
+	for i, genre := range genres {
 		if !utils.IsSimplifiedChinese(genre) {
 			translated := utils.TranslateGenre(genre)
 			if translated != genre {
 				logging.Info("将genre '%s' 翻译为 '%s'", genre, translated)
-				nfo.Genres[i] = translated
+				genres[i] = translated
 				hasChanges = true
 			}
 		}
 	}
 
-	// 如果有变化，更新NFO文件
+	// 翻译后去重、按配置的优先级排序并限制数量
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+	normalized := normalizeGenres(genres, cfg.GenreOrder, cfg.MaxGenres)
+	if !slices.Equal(normalized, genres) {
+		logging.Info("整理genre: %v -> %v", genres, normalized)
+		genres = normalized
+		hasChanges = true
+	}
+
+	// 如果有变化，更新NFO文档
+	// 新的genre写在原有genre的位置；原来没有genre时写在country、year或title之后
 	if hasChanges {
-		if err := updateGenreInFile(filePath, nfo.Genres); err != nil {
+		if err := doc.SetElements("genre", genres, "country", "year", "title"); err != nil {
 			return false, fmt.Errorf("更新genre字段失败: %w", err)
 		}
 		logging.Info("已更新NFO文件中的genre字段: %s", filePath)
@@ -52,78 +63,41 @@ func ProcessGenre(filePath string) (bool, error) {
 	return hasChanges, nil
 }
 
-// updateGenreInFile更新NFO文件中的genre字段
-func updateGenreInFile(filePath string, genres []string) error {
-	// 读取文件内容
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("读取NFO文件失败: %w", err)
-	}
-
-	// 将内容转换为字符串
-	contentStr := string(content)
-
-	// 移除所有旧的genre标签
-	contentStr = removeGenreTags(contentStr)
-
-	// 在合适的位置插入新的genre标签
-	contentStr = insertGenreTags(contentStr, genres)
-
-	// 写回文件
-	if err := os.WriteFile(filePath, []byte(contentStr), 0644); err != nil {
-		return fmt.Errorf("写入NFO文件失败: %w", err)
+// normalizeGenres去除重复的类型（忽略大小写和首尾空格），按优先级排序，maxGenres大于0时只保留前maxGenres个
+// 在order中的类型按order的顺序排在前面，其余类型保持原有顺序
+func normalizeGenres(genres []string, order []string, maxGenres int) []string {
+	result := make([]string, 0, len(genres))
+	seen := make(map[string]bool)
+	for _, genre := range genres {
+		genre = strings.TrimSpace(genre)
+		key := strings.ToLower(genre)
+		if genre == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, genre)
 	}
 
-	return nil
-}
-
-// removeGenreTags移除所有旧的genre标签
-func removeGenreTags(content string) string {
-	// 简单的字符串替换，移除所有<genre>和</genre>标签及其内容
-	// 注意：这是一个简单的实现，可能需要根据实际的NFO文件结构调整
-	content = regexp.MustCompile(`<genre>.*?</genre>`).ReplaceAllString(content, "")
-	return content
-}
-
-// insertGenreTags在合适的位置插入新的genre标签
-func insertGenreTags(content string, genres []string) string {
-	// 找到插入位置，例如在<country>标签之后
-	// 注意：这是一个简单的实现，可能需要根据实际的NFO文件结构调整
-	insertPos := strings.Index(content, "</country>")
-	if insertPos == -1 {
-		// 如果没有找到country标签，尝试其他位置
-		insertPos = strings.Index(content, "</year>")
-		if insertPos == -1 {
-			// 如果没有找到year标签，尝试其他位置
-			insertPos = strings.Index(content, "</title>")
-			if insertPos == -1 {
-				// 如果都没有找到，返回原内容（不更新）
-				return content
+	if len(order) > 0 {
+		rank := make(map[string]int, len(order))
+		for i, genre := range order {
+			if _, ok := rank[genre]; !ok {
+				rank[genre] = i
+			}
+		}
+		priority := func(genre string) int {
+			if r, ok := rank[genre]; ok {
+				return r
 			}
+			return len(order)
 		}
+		sort.SliceStable(result, func(i, j int) bool {
+			return priority(result[i]) < priority(result[j])
+		})
 	}
 
-	// 找到插入位置的行尾
-	insertPos = strings.Index(content[insertPos:], "\n") + insertPos + 1
-
-	// 构建新的genre标签
-	var genreTags strings.Builder
-	for _, genre := range genres {
-		genreTags.WriteString(fmt.Sprintf("  <genre>%s</genre>\n", escapeXML(genre)))
+	if maxGenres > 0 && len(result) > maxGenres {
+		result = result[:maxGenres]
 	}
-
-	// 插入新的genre标签
-	content = content[:insertPos] + genreTags.String() + content[insertPos:]
-
-	return content
-}
-
-// escapeXML转义XML特殊字符
-func escapeXML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	s = strings.ReplaceAll(s, "\"", "&quot;")
-	s = strings.ReplaceAll(s, "'", "&apos;")
-	return s
+	return result
 }
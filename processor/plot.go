@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/encyclopedia"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// ProcessPlot在NFO没有简介时补充简介，返回是否修改了文档
+// TMDB有简介（包括首选语言没有、按tmdb_fallback_languages从备用语言获取的简介）时写入TMDB简介，
+// 都没有时从配置的百科获取条目摘要；简介不是首选语言的TMDB简介时用注释记录来源
+func ProcessPlot(doc *parser.Document) (bool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+	nfo := doc.NFO
+	if strings.TrimSpace(nfo.Plot) != "" || nfo.IsMusicVideo() {
+		return false, nil
+	}
+
+	// TMDB有简介时不使用百科
+	if nfo.TMDbID != "" && cfg.TMDBApiKey != "" {
+		details, err := tmdb.GetDetails(nfo.TMDbID, nfo.IsTVShow())
+		if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+			return false, fmt.Errorf("获取TMDB简介失败: %w", err)
+		}
+		if err == nil && details.Overview != "" {
+			return setTMDBPlot(doc, details)
+		}
+	}
+	if cfg.PlotFallback.Source == "" {
+		return false, nil
+	}
+
+	summary, err := encyclopedia.Fetch(cfg.PlotFallback, nfo.Title, nfo.IsTVShow())
+	if err != nil {
+		return false, fmt.Errorf("从百科获取 '%s' 的简介失败: %w", nfo.Title, err)
+	}
+	if summary == nil {
+		logging.Info("NFO和TMDB都没有简介，百科（%s）中也没有找到 '%s'", cfg.PlotFallback.Source, nfo.Title)
+		return false, nil
+	}
+
+	if err := doc.SetElements("plot", []string{summary.Text}, "originaltitle", "title"); err != nil {
+		return false, fmt.Errorf("更新plot字段失败: %w", err)
+	}
+	if err := doc.InsertBeforeRootEnd(parser.PlotSourceComment(summary.Source, summary.URL)); err != nil {
+		return false, fmt.Errorf("写入简介来源注释失败: %w", err)
+	}
+	logging.Info("NFO和TMDB都没有简介，已使用 %s 的摘要（%s）: %s", summary.Source, summary.URL, doc.Path)
+	return true, nil
+}
+
+// setTMDBPlot 将TMDB简介写入NFO为空的plot，简介来自备用语言时用注释记录语言
+func setTMDBPlot(doc *parser.Document, details *tmdb.Details) (bool, error) {
+	if err := doc.SetElements("plot", []string{details.Overview}, "originaltitle", "title"); err != nil {
+		return false, fmt.Errorf("更新plot字段失败: %w", err)
+	}
+	if source := details.OverviewSource(); source != "" {
+		if err := doc.InsertBeforeRootEnd(parser.PlotSourceComment(source, "")); err != nil {
+			return false, fmt.Errorf("写入简介来源注释失败: %w", err)
+		}
+	}
+	logging.Info("NFO没有简介，已使用TMDB的%s简介: %s", details.OverviewLanguage, doc.Path)
+	return true, nil
+}
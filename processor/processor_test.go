@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/media-manager/internal/testkit"
+	"github.com/user/media-manager/parser"
+)
+
+// loadDocument 读取夹具的NFO文件
+func loadDocument(t *testing.T, nfoPath string) *parser.Document {
+	t.Helper()
+	doc, err := parser.LoadDocument(nfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestProcessYear(t *testing.T) {
+	tests := []struct {
+		name      string
+		nfoYear   string
+		tolerance int
+		want      string
+	}{
+		{name: "相差超过容差时校正", nfoYear: "2017", tolerance: 1, want: "2019"},
+		{name: "在容差之内不修改", nfoYear: "2018", tolerance: 1, want: "2018"},
+		{name: "容差为负数时不检查", nfoYear: "2010", tolerance: -1, want: "2010"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := testkit.New(t)
+			env.Config.YearTolerance = tt.tolerance
+			env.TMDB.AddMovie(testkit.TMDBItem{ID: 535167, Title: "流浪地球", ReleaseDate: "2019-02-05", Countries: []string{"CN"}})
+			nfoPath, err := env.AddMovie(testkit.Movie{Dir: "流浪地球", Title: "流浪地球", Year: tt.nfoYear, TMDbID: "535167"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			doc := loadDocument(t, nfoPath)
+			modified, err := ProcessYear(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.NFO.Year != tt.want {
+				t.Errorf("年份 = %s，应为 %s", doc.NFO.Year, tt.want)
+			}
+			if modified != (tt.want != tt.nfoYear) {
+				t.Errorf("modified = %v", modified)
+			}
+		})
+	}
+}
+
+func TestProcessYearNotFound(t *testing.T) {
+	env := testkit.New(t)
+	env.Config.YearTolerance = 0
+	nfoPath, err := env.AddMovie(testkit.Movie{Title: "流浪地球", Year: "2017", TMDbID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// TMDB返回404时返回错误，不修改年份
+	doc := loadDocument(t, nfoPath)
+	if modified, err := ProcessYear(doc); err == nil || modified {
+		t.Errorf("ProcessYear() = %v, %v，应返回错误", modified, err)
+	}
+	if doc.NFO.Year != "2017" {
+		t.Errorf("年份 = %s，应保持 2017", doc.NFO.Year)
+	}
+}
+
+func TestProcessTMDbID(t *testing.T) {
+	env := testkit.New(t)
+	env.TMDB.Handle("find/tt7605074", map[string]interface{}{
+		"movie_results": []map[string]int{{"id": 535167}},
+		"tv_results":    []map[string]int{},
+	})
+	nfoPath := filepath.Join(env.TempPath("Movie", "流浪地球 (2019)"), "movie.nfo")
+	nfo := &parser.NFO{XMLName: xml.Name{Local: "movie"}, Title: "流浪地球", Year: "2019", IMDbID: "tt7605074"}
+	if err := testkit.WriteNFO(nfoPath, nfo); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := loadDocument(t, nfoPath)
+	modified, err := ProcessTMDbID(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified || doc.NFO.TMDbID != "535167" {
+		t.Errorf("ProcessTMDbID() = %v，TMDbID = %q，应写入 535167", modified, doc.NFO.TMDbID)
+	}
+	if requests := env.TMDB.Requests(); len(requests) != 1 || requests[0] != "find/tt7605074" {
+		t.Errorf("TMDB请求 = %v", requests)
+	}
+
+	// 已有TMDb ID时不再查询
+	if modified, err := ProcessTMDbID(doc); err != nil || modified {
+		t.Errorf("再次处理 = %v, %v，应不修改", modified, err)
+	}
+	if requests := env.TMDB.Requests(); len(requests) != 1 {
+		t.Errorf("已有TMDb ID时不应请求TMDB，请求 = %v", requests)
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+)
+
+// TagContext 匹配标签规则时使用的影片信息
+type TagContext struct {
+	Category         string   // 确定的分类
+	Countries        []string // 制作国家（中文名称）
+	OriginalLanguage string   // 原始语言（ISO 639-1代码）
+}
+
+// MatchTagRules 返回所有条件都满足的规则的标签，按规则顺序去重
+// 没有配置任何条件的规则不会匹配
+func MatchTagRules(rules []config.TagRule, genres []string, ctx TagContext) []string {
+	var tags []string
+	for _, rule := range rules {
+		if rule.Tag == "" || (rule.Genre == "" && rule.Category == "" && rule.Country == "" && rule.Language == "") {
+			continue
+		}
+		if rule.Genre != "" && !containsFold(genres, rule.Genre) {
+			continue
+		}
+		if rule.Category != "" && !strings.EqualFold(rule.Category, ctx.Category) {
+			continue
+		}
+		if rule.Country != "" && !containsFold(ctx.Countries, rule.Country) {
+			continue
+		}
+		if rule.Language != "" && !strings.EqualFold(rule.Language, ctx.OriginalLanguage) {
+			continue
+		}
+		if !containsFold(tags, rule.Tag) {
+			tags = append(tags, rule.Tag)
+		}
+	}
+	return tags
+}
+
+// ProcessTags 按配置的标签规则为NFO文档添加<tag>元素，返回是否修改了文档
+// 保留NFO中已有的标签，只追加缺少的标签
+func ProcessTags(doc *parser.Document, rules []config.TagRule, ctx TagContext) (bool, error) {
+	matched := MatchTagRules(rules, doc.NFO.Genres, ctx)
+	if len(matched) == 0 {
+		return false, nil
+	}
+
+	tags := append([]string(nil), doc.NFO.Tags...)
+	var added []string
+	for _, tag := range matched {
+		if !containsFold(tags, tag) {
+			tags = append(tags, tag)
+			added = append(added, tag)
+		}
+	}
+	if len(added) == 0 {
+		return false, nil
+	}
+
+	// 新的tag写在原有tag的位置；原来没有tag时写在genre、country或year之后
+	if err := doc.SetElements("tag", tags, "genre", "country", "year", "title"); err != nil {
+		return false, fmt.Errorf("更新tag字段失败: %w", err)
+	}
+	logging.Info("按标签规则添加标签 %v: %s", added, doc.Path)
+	return true, nil
+}
+
+// containsFold 检查列表中是否包含指定值（忽略大小写和首尾空格）
+func containsFold(list []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// ProcessTMDbID在NFO文档只有IMDb ID时，通过TMDB查询对应的TMDb ID并写入文档，返回是否修改了文档
+func ProcessTMDbID(doc *parser.Document) (bool, error) {
+	nfo, filePath := doc.NFO, doc.Path
+
+	// 已有TMDb ID或没有可用的IMDb ID时不需要处理
+	if nfo.TMDbID != "" || !strings.HasPrefix(nfo.IMDbID, "tt") {
+		return false, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+	if cfg.TMDBApiKey == "" {
+		logging.Info("未配置TMDB API密钥，无法通过IMDb ID %s 查询TMDb ID", nfo.IMDbID)
+		return false, nil
+	}
+
+	tmdbID, err := tmdb.FindTMDbIDByIMDbID(nfo.IMDbID, nfo.IsTVShow())
+	if err != nil {
+		return false, fmt.Errorf("通过IMDb ID查询TMDb ID失败: %w", err)
+	}
+	if tmdbID == "" {
+		logging.Warning("TMDB中没有找到IMDb ID %s 对应的条目: %s", nfo.IMDbID, filePath)
+		return false, nil
+	}
+
+	// 在根标签结束前插入tmdbid和uniqueid标签
+	idTags := fmt.Sprintf("<tmdbid>%s</tmdbid>\n<uniqueid type=\"tmdb\">%s</uniqueid>", parser.EscapeXML(tmdbID), parser.EscapeXML(tmdbID))
+	if err := doc.InsertBeforeRootEnd(idTags); err != nil {
+		return false, fmt.Errorf("写入TMDb ID失败: %w", err)
+	}
+	logging.Info("已通过IMDb ID %s 查询到TMDb ID %s 并写入NFO文件: %s", nfo.IMDbID, tmdbID, filePath)
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/parser"
+	"github.com/user/media-manager/tmdb"
+)
+
+// ProcessYear比较NFO文档中的年份与TMDB的上映（首播）年份，相差超过容差时校正NFO和数据库中的年份，返回是否修改了文档
+// 翻拍作品常与原作同名，年份错误会导致合并到错误的目录
+func ProcessYear(doc *parser.Document) (bool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return false, err
+	}
+	if cfg.YearTolerance < 0 || cfg.TMDBApiKey == "" {
+		return false, nil
+	}
+
+	nfo, filePath := doc.NFO, doc.Path
+
+	// 没有TMDb ID或年份时无法比较
+	nfoYear, err := strconv.Atoi(strings.TrimSpace(nfo.Year))
+	if nfo.TMDbID == "" || err != nil {
+		return false, nil
+	}
+
+	details, err := tmdb.GetDetails(nfo.TMDbID, nfo.IsTVShow())
+	if errors.Is(err, tmdb.ErrCachedNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("获取TMDB上映日期失败: %w", err)
+	}
+	tmdbYear := details.ReleaseYear()
+	if tmdbYear == 0 {
+		return false, nil
+	}
+
+	diff := nfoYear - tmdbYear
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff <= cfg.YearTolerance {
+		return false, nil
+	}
+
+	oldYear, newYear := strings.TrimSpace(nfo.Year), strconv.Itoa(tmdbYear)
+	if !doc.HasElement("year") {
+		return false, fmt.Errorf("没有找到year标签: %s", filePath)
+	}
+	if err := doc.SetElements("year", []string{newYear}); err != nil {
+		return false, fmt.Errorf("更新年份失败: %w", err)
+	}
+	logging.Info("年份校正: %s 的NFO年份 %s 与TMDB上映日期 %s 相差 %d 年（容差 %d），已改为 %s: %s",
+		nfo.Title, oldYear, details.ReleaseDate, diff, cfg.YearTolerance, newYear, filePath)
+
+	// 同步校正之前处理时写入数据库的记录
+	count, err := database.CorrectMediaRecordYear(nfo.Title, oldYear, newYear)
+	if err != nil {
+		logging.Warning("%v", err)
+	} else if count > 0 {
+		logging.Info("年份校正: 已更新数据库中 %d 条 %s (%s) 的记录", count, nfo.Title, oldYear)
+	}
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// 运行上限、处理范围和模拟运行参数的说明
+const (
+	maxItemsUsage     = "单次运行最多处理的NFO文件数，0表示不限制"
+	maxDurationUsage  = "单次运行的最长时间（如 2h、90m），0表示不限制"
+	onlyCategoryUsage = "只处理这些分类的项目（逗号分隔，如 CnShow,CnMovie），其他项目留在Temp目录和队列中"
+	titleFilterUsage  = "只处理标题、原始标题或目录名包含该文字的项目，其他项目留在Temp目录和队列中"
+	dryRunUsage       = "模拟运行：NFO的修改、移动、删除和数据库写入只输出不执行；与scrape一起使用时只列出将要运行的tinyMediaManager命令、扫描的目录和NFO文件"
+)
+
+// addRunFlags 为执行处理的子命令（scrape、process、daemon）添加运行上限和处理范围参数，默认值取自旧版的全局参数
+func addRunFlags(fs *flag.FlagSet) {
+	fs.IntVar(maxItems, "max-items", *maxItems, maxItemsUsage)
+	fs.DurationVar(maxDuration, "max-duration", *maxDuration, maxDurationUsage)
+	fs.StringVar(onlyCategory, "only-category", *onlyCategory, onlyCategoryUsage)
+	fs.StringVar(titleFilter, "title", *titleFilter, titleFilterUsage)
+}
+
+// applyRunFlags 按--only-category、--title参数设置本次运行的处理范围，解析子命令的参数后调用
+func applyRunFlags() {
+	classifier.ActiveFilter = parseProcessFilter(*onlyCategory, *titleFilter)
+}
+
+// runLimiter 控制单次运行处理的项目数和时长
+type runLimiter struct {
+	maxItems    int
+	maxDuration time.Duration
+	startedAt   time.Time
+	processed   int
+}
+
+// newRunLimiter 根据命令行参数创建运行限制，从调用时开始计时
+func newRunLimiter() *runLimiter {
+	return &runLimiter{
+		maxItems:    *maxItems,
+		maxDuration: *maxDuration,
+		startedAt:   time.Now(),
+	}
+}
+
+// reached 检查是否已达到处理上限，返回原因
+func (l *runLimiter) reached() (bool, string) {
+	if l.maxItems > 0 && l.processed >= l.maxItems {
+		return true, fmt.Sprintf("已处理 %d 个项目，达到上限 %d", l.processed, l.maxItems)
+	}
+	if l.maxDuration > 0 {
+		if elapsed := time.Since(l.startedAt); elapsed >= l.maxDuration {
+			return true, fmt.Sprintf("已运行 %v，达到上限 %v", elapsed.Round(time.Second), l.maxDuration)
+		}
+	}
+	return false, ""
+}
+
+// done 记录处理完一个项目
+func (l *runLimiter) done() {
+	l.processed++
+}
+
+// cursorKey 返回保存处理游标的运行状态键
+func cursorKey(scope string) string {
+	return "cursor:" + scope
+}
+
+// resumeFromCursor 按路径排序文件，读取上次因达到上限而中断时保存的游标，将游标之后的文件排在前面
+// 游标之前（含游标）的文件上次已处理过，排到最后；没有游标时也排序，保存游标时使用相同的顺序
+func resumeFromCursor(scope string, files []string) []string {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+
+	cursor, err := database.GetRunState(cursorKey(scope))
+	if err != nil {
+		logging.Error("读取处理游标失败: %v", err)
+		return sorted
+	}
+	if cursor == "" {
+		return sorted
+	}
+
+	split := sort.SearchStrings(sorted, cursor)
+	if split < len(sorted) && sorted[split] == cursor {
+		split++
+	}
+
+	logging.Info("从上次中断的位置继续处理: %s", cursor)
+	return append(sorted[split:], sorted[:split]...)
+}
+
+// saveCursor 保存处理游标，游标为空表示本次已全部处理完成，清除游标
+func saveCursor(scope string, cursor string) {
+	var err error
+	if cursor == "" {
+		err = database.DeleteRunState(cursorKey(scope))
+	} else {
+		err = database.SetRunState(cursorKey(scope), cursor)
+		if err == nil {
+			logging.Info("已保存处理游标，下次运行将从 %s 之后继续", cursor)
+		}
+	}
+	if err != nil {
+		logging.Error("%v", err)
+	}
+}
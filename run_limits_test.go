@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/internal/testkit"
+)
+
+func TestProcessNFOFilesResumesFromCursor(t *testing.T) {
+	testkit.New(t)
+	defer func(n int) { *maxItems = n }(*maxItems)
+	*maxItems = 2
+
+	const scope = "dir:/Temp"
+	processed := make(map[string]int)
+	handle := func(nfoFile string) error {
+		processed[nfoFile]++
+		return nil
+	}
+
+	// 没有游标时的文件顺序来自map遍历，每次运行都可能不同
+	runs := [][]string{
+		{"/Temp/d.nfo", "/Temp/b.nfo", "/Temp/a.nfo", "/Temp/c.nfo"},
+		{"/Temp/c.nfo", "/Temp/a.nfo", "/Temp/d.nfo", "/Temp/b.nfo"},
+	}
+	for _, files := range runs {
+		if err := processNFOFiles(scope, files, handle); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range runs[0] {
+		if processed[file] != 1 {
+			t.Errorf("%s 处理了 %d 次，应为 1 次", file, processed[file])
+		}
+	}
+	// 游标指向排序后最后处理的文件
+	if cursor, err := database.GetRunState(cursorKey(scope)); err != nil || cursor != "/Temp/d.nfo" {
+		t.Errorf("游标 = %q, %v，应为 /Temp/d.nfo", cursor, err)
+	}
+}
@@ -0,0 +1,102 @@
+package safety
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/errs"
+)
+
+// ErrOutsideRoots 要修改的路径不在配置的根目录之下
+var ErrOutsideRoots = errs.ErrOutsideRoots
+
+// Roots 返回允许移动、删除和改写文件的根目录：Temp目录、媒体库根目录、单独配置的分类目录和分类处理策略的目标目录
+func Roots(cfg *config.Config) []string {
+	roots := append([]string{}, cfg.TempDirs...)
+	roots = append(roots, cfg.CloudDirs()...)
+	for _, policies := range cfg.CategoryPolicies {
+		for _, policy := range policies {
+			if policy.Target != "" {
+				roots = append(roots, policy.Target)
+			}
+		}
+	}
+	return roots
+}
+
+// Check 检查路径是否位于某个允许的根目录之下（不含根目录本身），否则返回ErrOutsideRoots
+// 路径和根目录都先解析符号链接，路径的最后一级不解析（移动、删除和替换的是目录项本身）
+func Check(path string) error {
+	resolved, err := resolve(path, false)
+	if err != nil {
+		return fmt.Errorf("%w: %s（%v）", ErrOutsideRoots, path, err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for _, root := range Roots(cfg) {
+		if root == "" {
+			continue
+		}
+		resolvedRoot, err := resolve(root, true)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(resolvedRoot, resolved); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOutsideRoots, path)
+}
+
+// CheckAll 依次检查多个路径
+func CheckAll(paths ...string) error {
+	for _, path := range paths {
+		if err := Check(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve 返回解析了符号链接的绝对路径，followLast为false时最后一级保持不变
+// 路径还不存在时解析最近的已存在的上级目录
+func resolve(path string, followLast bool) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if followLast {
+		if resolvedPath, err := filepath.EvalSymlinks(absPath); err == nil {
+			return resolvedPath, nil
+		}
+	}
+	dir, name := filepath.Split(absPath)
+	if name == "" {
+		return absPath, nil // 根目录
+	}
+	dir = filepath.Clean(dir)
+
+	var missing []string
+	for {
+		resolvedDir, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			parts := append([]string{resolvedDir}, missing...)
+			return filepath.Join(append(parts, name)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+		dir = parent
+	}
+}
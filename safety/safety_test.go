@@ -0,0 +1,131 @@
+package safety
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/media-manager/config"
+)
+
+// useRoots 在临时目录中创建Temp和Cloud两个根目录并作为配置使用，返回临时目录
+func useRoots(t *testing.T) string {
+	t.Helper()
+	// 解析临时目录本身的符号链接（如macOS的/var → /private/var），便于比较
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"Temp/Movie/流浪地球 (2019)", "Cloud/CnMovie", "Outside/secret"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.TempDirs = []string{filepath.Join(base, "Temp")}
+	cfg.CloudDir = filepath.Join(base, "Cloud")
+	config.Use(cfg)
+	t.Cleanup(func() { config.Use(nil) })
+	return base
+}
+
+func TestCheck(t *testing.T) {
+	base := useRoots(t)
+	symlink := func(target, link string) string {
+		path := filepath.Join(base, link)
+		if err := os.Symlink(filepath.Join(base, target), path); err != nil {
+			t.Skipf("无法创建符号链接: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    func() string
+		allowed bool
+	}{
+		{name: "Temp目录中的影片目录", path: func() string { return filepath.Join(base, "Temp/Movie/流浪地球 (2019)") }, allowed: true},
+		{name: "Temp目录中还不存在的文件", path: func() string { return filepath.Join(base, "Temp/Movie/新建/movie.nfo") }, allowed: true},
+		{name: "媒体库中的目标目录", path: func() string { return filepath.Join(base, "Cloud/CnMovie/流浪地球 (2019)") }, allowed: true},
+		{name: "相对路径", path: func() string {
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, err := filepath.Rel(wd, filepath.Join(base, "Temp/Movie"))
+			if err != nil {
+				t.Skip("无法转换为相对路径")
+			}
+			return rel
+		}, allowed: true},
+		{name: "根目录本身", path: func() string { return filepath.Join(base, "Temp") }},
+		{name: "媒体库根目录本身", path: func() string { return filepath.Join(base, "Cloud") }},
+		{name: "根目录之外", path: func() string { return filepath.Join(base, "Outside/secret") }},
+		{name: "文件系统根目录", path: func() string { return string(filepath.Separator) }},
+		{name: "前缀相同的兄弟目录", path: func() string { return filepath.Join(base, "TempOther/file") }},
+		{name: "..跳出根目录", path: func() string { return filepath.Join(base, "Temp") + "/Movie/../../Outside/secret" }},
+		{name: "..回到根目录本身", path: func() string { return filepath.Join(base, "Temp") + "/Movie/.." }},
+		{name: "..仍在根目录中", path: func() string { return filepath.Join(base, "Temp") + "/Movie/../Movie/流浪地球 (2019)" }, allowed: true},
+		{name: "通过符号链接目录跳出根目录", path: func() string {
+			return filepath.Join(symlink("Outside", "Temp/escape"), "secret")
+		}},
+		{name: "通过符号链接目录跳出根目录（目标还不存在）", path: func() string {
+			return filepath.Join(symlink("Outside", "Temp/escape2"), "new/file")
+		}},
+		{name: "指向根目录之外的符号链接本身", path: func() string {
+			// 移动和删除的是链接本身，不影响链接指向的目录
+			return symlink("Outside/secret", "Temp/link")
+		}, allowed: true},
+		{name: "根目录之外指向根目录中的符号链接", path: func() string {
+			return filepath.Join(symlink("Temp/Movie", "Outside/movie"), "流浪地球 (2019)")
+		}, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path()
+			err := Check(path)
+			if tt.allowed && err != nil {
+				t.Errorf("Check(%q) = %v，应允许", path, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrOutsideRoots) {
+				t.Errorf("Check(%q) = %v，应返回ErrOutsideRoots", path, err)
+			}
+		})
+	}
+}
+
+func TestCheckSymlinkedRoot(t *testing.T) {
+	base := useRoots(t)
+	// 配置的根目录本身是符号链接（如指向挂载点）时，按解析后的路径比较
+	link := filepath.Join(base, "CloudLink")
+	if err := os.Symlink(filepath.Join(base, "Cloud"), link); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	cfg, _ := config.Load()
+	cfg.CloudDir = link
+
+	if err := Check(filepath.Join(base, "Cloud/CnMovie")); err != nil {
+		t.Errorf("通过解析后的路径访问应允许: %v", err)
+	}
+	if err := Check(filepath.Join(link, "CnMovie")); err != nil {
+		t.Errorf("通过符号链接访问应允许: %v", err)
+	}
+	if err := Check(link + "/../Outside/secret"); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("..跳出符号链接根目录应拒绝，得到 %v", err)
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	base := useRoots(t)
+	inside := filepath.Join(base, "Temp/Movie/流浪地球 (2019)")
+	outside := filepath.Join(base, "Outside/secret")
+
+	if err := CheckAll(inside, filepath.Join(base, "Cloud/CnMovie/流浪地球 (2019)")); err != nil {
+		t.Errorf("都在根目录中时应允许: %v", err)
+	}
+	if err := CheckAll(inside, outside); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("任一路径在根目录之外时应拒绝，得到 %v", err)
+	}
+}
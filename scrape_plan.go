@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user/media-manager/classifier"
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/scraper"
+)
+
+// scrapePlan -dry-run时一次刮削将要执行的操作
+type scrapePlan struct {
+	TMM         []scraper.TMMPlan `json:"tmm"`
+	WaitSeconds int               `json:"wait_seconds"` // 刮削后等待的秒数
+	ScanDirs    []scanDirPlan     `json:"scan_dirs"`
+}
+
+// scanDirPlan 刮削后扫描的一个Temp子目录
+type scanDirPlan struct {
+	Dir       string         `json:"dir"`
+	Exists    bool           `json:"exists"`
+	NFOFiles  []nfoCandidate `json:"nfo_files"`
+	Unscraped []string       `json:"unscraped,omitempty"` // 还没有NFO文件、需要TMM刮削的媒体目录
+}
+
+// nfoCandidate 将要处理的NFO文件，Skip不为空时表示会被跳过的原因
+type nfoCandidate struct {
+	Path string `json:"path"`
+	Skip string `json:"skip,omitempty"`
+}
+
+// planScrape 列出刮削将要运行的tinyMediaManager命令、扫描的目录和目前已有的NFO文件，不运行TMM、不修改任何文件
+// 未刮削的媒体目录在TMM运行后才会有NFO文件
+func planScrape(scrapeType string, asJSON bool) error {
+	cfg := appConfig()
+	plan := scrapePlan{WaitSeconds: cfg.WaitTimeAfterScan}
+
+	subdirs := scrapeSubdirs(scrapeType)
+	for _, subdir := range subdirs {
+		if subdir == "Movie" {
+			plan.TMM = append(plan.TMM, scraper.PlanMovies())
+		} else {
+			plan.TMM = append(plan.TMM, scraper.PlanTVShows())
+		}
+	}
+
+	for _, tempDir := range cfg.TempDirs {
+		for _, subdir := range subdirs {
+			dirPlan, err := planScanDir(filepath.Join(tempDir, subdir), cfg)
+			if err != nil {
+				return err
+			}
+			plan.ScanDirs = append(plan.ScanDirs, dirPlan)
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("生成JSON失败: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printScrapePlan(plan)
+	return nil
+}
+
+// planScanDir 列出扫描目录中的NFO文件和未刮削的媒体目录
+func planScanDir(scanDir string, cfg *config.Config) (scanDirPlan, error) {
+	dirPlan := scanDirPlan{Dir: scanDir, NFOFiles: []nfoCandidate{}}
+	if info, err := os.Stat(scanDir); err != nil || !info.IsDir() {
+		return dirPlan, nil
+	}
+	dirPlan.Exists = true
+
+	files, err := findNFOFiles(scanDir)
+	if err != nil {
+		return dirPlan, fmt.Errorf("查找NFO文件失败: %w", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		candidate := nfoCandidate{Path: file}
+		if _, err := checkNFOCount(file, cfg.NFOSelection); err != nil {
+			candidate.Skip = err.Error()
+		}
+		dirPlan.NFOFiles = append(dirPlan.NFOFiles, candidate)
+	}
+
+	if dirPlan.Unscraped, err = classifier.UnscrapedDirs(scanDir); err != nil {
+		return dirPlan, err
+	}
+	return dirPlan, nil
+}
+
+// printScrapePlan 以文本形式输出刮削计划
+func printScrapePlan(plan scrapePlan) {
+	fmt.Println("tinyMediaManager命令:")
+	for _, tmm := range plan.TMM {
+		fmt.Printf("  [%s] %s\n", tmm.Module, strings.Join(tmm.Command, " "))
+		if tmm.Dir != "" {
+			fmt.Printf("    工作目录: %s\n", tmm.Dir)
+		}
+		if len(tmm.MissingDatasources) > 0 {
+			fmt.Printf("    不在数据源中（%s）: %s\n", tmm.SettingsPath, strings.Join(tmm.MissingDatasources, ", "))
+		}
+		for _, problem := range tmm.Problems {
+			fmt.Printf("    问题: %s\n", problem)
+		}
+	}
+	if plan.WaitSeconds > 0 {
+		fmt.Printf("\n刮削后等待 %d 秒\n", plan.WaitSeconds)
+	}
+
+	fmt.Println("\n扫描目录:")
+	for _, dir := range plan.ScanDirs {
+		if !dir.Exists {
+			fmt.Printf("  %s（不存在）\n", dir.Dir)
+			continue
+		}
+		fmt.Printf("  %s（%d 个NFO文件，%d 个未刮削目录）\n", dir.Dir, len(dir.NFOFiles), len(dir.Unscraped))
+		for _, nfo := range dir.NFOFiles {
+			if nfo.Skip != "" {
+				fmt.Printf("    跳过 %s: %s\n", nfo.Path, nfo.Skip)
+			} else {
+				fmt.Printf("    处理 %s\n", nfo.Path)
+			}
+		}
+		for _, unscraped := range dir.Unscraped {
+			fmt.Printf("    未刮削 %s\n", unscraped)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/logging"
+)
+
+// ErrMissingDatasource Temp目录没有添加为tinyMediaManager的数据源，TMM不会扫描其中的影片
+var ErrMissingDatasource = errs.ErrMissingDatasource
+
+// tmmModule tinyMediaManager各模块的数据源设置
+type tmmModule struct {
+	subdir   string // Temp目录下对应的子目录
+	jsonFile string // 4.x及以上版本的设置文件
+	jsonKey  string // 设置文件中数据源列表的键
+	xmlTag   string // 3.x版本config.xml中数据源的元素名
+	xmlGroup string // 3.x版本config.xml中数据源所在的元素
+}
+
+var tmmModules = map[string]tmmModule{
+	"movie":  {subdir: "Movie", jsonFile: "movies.json", jsonKey: "movieDataSource", xmlTag: "movieDataSource", xmlGroup: "movieSettings"},
+	"tvshow": {subdir: "TvShow", jsonFile: "tvShows.json", jsonKey: "tvShowDataSource", xmlTag: "tvShowDataSource", xmlGroup: "tvShowSettings"},
+}
+
+// TMMDataDir 返回tinyMediaManager的数据目录，未配置tmm_data_dir时使用安装目录下的data
+func TMMDataDir(cfg *config.Config) string {
+	if cfg.TMMDataDir != "" {
+		return cfg.TMMDataDir
+	}
+	return filepath.Join(cfg.TinyMediaManagerDir, "data")
+}
+
+// ReadDatasources 读取tinyMediaManager模块（movie、tvshow）的数据源，返回数据源和设置文件的路径
+// 优先读取4.x及以上版本的JSON设置文件，不存在时读取3.x版本的config.xml
+func ReadDatasources(dataDir string, module string) ([]string, string, error) {
+	m, ok := tmmModules[module]
+	if !ok {
+		return nil, "", fmt.Errorf("未知的tinyMediaManager模块: %s", module)
+	}
+
+	jsonPath := filepath.Join(dataDir, m.jsonFile)
+	if content, err := os.ReadFile(jsonPath); err == nil {
+		var settings map[string]json.RawMessage
+		if err := json.Unmarshal(content, &settings); err != nil {
+			return nil, jsonPath, fmt.Errorf("解析tinyMediaManager设置文件失败: %w", err)
+		}
+		var sources []string
+		if raw, ok := settings[m.jsonKey]; ok {
+			if err := json.Unmarshal(raw, &sources); err != nil {
+				return nil, jsonPath, fmt.Errorf("解析%s失败: %w", m.jsonKey, err)
+			}
+		}
+		return sources, jsonPath, nil
+	}
+
+	xmlPath := filepath.Join(dataDir, "config.xml")
+	content, err := os.ReadFile(xmlPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("在 %s 中没有找到tinyMediaManager的设置文件（%s或config.xml）", dataDir, m.jsonFile)
+	}
+	var settings struct {
+		Movie  []string `xml:"movieSettings>movieDataSource"`
+		TVShow []string `xml:"tvShowSettings>tvShowDataSource"`
+	}
+	if err := xml.Unmarshal(content, &settings); err != nil {
+		return nil, xmlPath, fmt.Errorf("解析tinyMediaManager设置文件失败: %w", err)
+	}
+	if module == "movie" {
+		return settings.Movie, xmlPath, nil
+	}
+	return settings.TVShow, xmlPath, nil
+}
+
+// checkDatasources 检查Temp目录下的模块子目录是否都在tinyMediaManager的数据源中（数据源是其本身或上级目录）
+// tmm_datasources为add时自动添加缺少的数据源，为off时不检查；无法读取设置文件时只记录警告
+func checkDatasources(cfg *config.Config, module string) error {
+	if cfg.TMMDatasources == config.TMMDatasourcesOff {
+		return nil
+	}
+
+	missing, settingsPath, err := missingDatasources(cfg, module)
+	if err != nil {
+		logging.Warning("无法检查tinyMediaManager的数据源: %v", err)
+		return nil
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if cfg.TMMDatasources == config.TMMDatasourcesAdd {
+		if err := addDatasources(settingsPath, module, missing); err != nil {
+			return fmt.Errorf("添加tinyMediaManager数据源失败: %w", err)
+		}
+		logging.Info("已将 %s 添加为tinyMediaManager的%s数据源", strings.Join(missing, ", "), module)
+		return nil
+	}
+	return fmt.Errorf("%w: %s 没有添加到 %s，tinyMediaManager不会扫描其中的影片；请在tinyMediaManager中添加数据源，或将tmm_datasources设置为add自动添加",
+		ErrMissingDatasource, strings.Join(missing, ", "), settingsPath)
+}
+
+// missingDatasources 返回存在但不在tinyMediaManager数据源中的Temp模块子目录，以及设置文件的路径
+func missingDatasources(cfg *config.Config, module string) ([]string, string, error) {
+	sources, settingsPath, err := ReadDatasources(TMMDataDir(cfg), module)
+	if err != nil {
+		return nil, settingsPath, err
+	}
+	logging.Debug("tinyMediaManager %s 数据源（%s）: %s", module, settingsPath, strings.Join(sources, ", "))
+
+	var missing []string
+	for _, tempDir := range cfg.TempDirs {
+		dir := filepath.Join(tempDir, tmmModules[module].subdir)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if !coveredBy(dir, sources) {
+			missing = append(missing, dir)
+		}
+	}
+	return missing, settingsPath, nil
+}
+
+// coveredBy 检查目录是否为某个数据源或位于其下
+func coveredBy(dir string, sources []string) bool {
+	dir = filepath.Clean(dir)
+	for _, source := range sources {
+		source = filepath.Clean(source)
+		if dir == source || strings.HasPrefix(dir, source+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addDatasources 把目录添加到tinyMediaManager设置文件的数据源列表，需要在TMM未运行时修改
+func addDatasources(settingsPath string, module string, dirs []string) error {
+	m := tmmModules[module]
+	content, err := os.ReadFile(settingsPath)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Ext(settingsPath) == ".json" {
+		var settings map[string]any
+		if err := json.Unmarshal(content, &settings); err != nil {
+			return err
+		}
+		sources, _ := settings[m.jsonKey].([]any)
+		for _, dir := range dirs {
+			sources = append(sources, dir)
+		}
+		settings[m.jsonKey] = sources
+		content, err = json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(settingsPath, content, 0644)
+	}
+
+	// 3.x的config.xml：在模块设置元素的结束标签前插入数据源元素
+	closeTag := []byte("</" + m.xmlGroup + ">")
+	i := bytes.Index(content, closeTag)
+	if i < 0 {
+		return fmt.Errorf("%s 中没有%s元素", settingsPath, m.xmlGroup)
+	}
+	var elements bytes.Buffer
+	for _, dir := range dirs {
+		elements.WriteString("  <" + m.xmlTag + ">")
+		xml.EscapeText(&elements, []byte(dir))
+		elements.WriteString("</" + m.xmlTag + ">\n  ")
+	}
+	updated := append(append(append([]byte{}, content[:i]...), elements.Bytes()...), content[i:]...)
+	return os.WriteFile(settingsPath, updated, 0644)
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/user/media-manager/config"
+)
+
+// TMMPlan 刮削一个模块时将要运行的tinyMediaManager命令，用于预览，不会运行命令或修改TMM的设置
+type TMMPlan struct {
+	Module             string   `json:"module"`  // movie、tvshow
+	Command            []string `json:"command"` // 可执行文件和参数
+	Dir                string   `json:"dir"`     // 工作目录
+	SettingsPath       string   `json:"settings_path,omitempty"`
+	MissingDatasources []string `json:"missing_datasources,omitempty"` // 不在TMM数据源中的Temp子目录
+	Problems           []string `json:"problems,omitempty"`            // 实际运行时会导致刮削失败或无效的问题
+}
+
+// PlanMovies 返回刮削电影时将要运行的命令
+func PlanMovies() TMMPlan {
+	return planTMM("movie")
+}
+
+// PlanTVShows 返回刮削电视剧时将要运行的命令
+func PlanTVShows() TMMPlan {
+	return planTMM("tvshow")
+}
+
+// planTMM 按runTMM的方式构建命令并检查数据源，tmm_datasources为add时只列出将要添加的数据源
+func planTMM(module string) TMMPlan {
+	plan := TMMPlan{Module: module}
+	cfg, err := config.Load()
+	if err != nil {
+		plan.Problems = append(plan.Problems, err.Error())
+		return plan
+	}
+
+	if len(cfg.TempDirs) == 0 {
+		plan.Problems = append(plan.Problems, "没有有效的临时目录可用")
+	} else {
+		plan.Dir = cfg.TempDirs[0]
+	}
+
+	if cfg.TMMDocker.Enabled() {
+		_, args := dockerArgs(cfg.TMMDocker, module)
+		plan.Command = append([]string{cfg.TMMDocker.Docker}, args...)
+	} else {
+		tmmPath := getTMMExecutablePath(cfg)
+		plan.Command = []string{tmmPath, module, "-u", "-n", "-r"}
+		if _, err := os.Stat(tmmPath); os.IsNotExist(err) {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("tinyMediaManager可执行文件不存在: %s", tmmPath))
+		}
+	}
+
+	if cfg.TMMDatasources == config.TMMDatasourcesOff {
+		return plan
+	}
+	missing, settingsPath, err := missingDatasources(cfg, module)
+	plan.SettingsPath = settingsPath
+	if err != nil {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("无法检查tinyMediaManager的数据源: %v", err))
+		return plan
+	}
+	plan.MissingDatasources = missing
+	if len(missing) > 0 && cfg.TMMDatasources != config.TMMDatasourcesAdd {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("%v，TMM不会刮削其中的影片", ErrMissingDatasource))
+	}
+	return plan
+}
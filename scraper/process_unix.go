@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package scraper
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup 让命令在新的进程组中运行，超时时结束整个进程组
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package scraper
+
+import "os/exec"
+
+// killProcessGroup Windows上超时时只结束tinyMediaManager进程本身
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -1,24 +1,42 @@
 package scraper
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/user/media-manager/cache"
 	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/errs"
 	"github.com/user/media-manager/logging"
 )
 
+// ErrTimeout tinyMediaManager在tmm_timeout_minutes内没有结束，已被强制结束，可以稍后重试
+var ErrTimeout = errs.ErrScrapeTimeout
+
 // ScrapeMovies执行电影刮削命令
 func ScrapeMovies() error {
-	cfg := config.LoadConfig()
+	return runTMM("movie", "电影")
+}
+
+// ScrapeTVShows执行电视剧刮削命令
+func ScrapeTVShows() error {
+	return runTMM("tvshow", "电视剧")
+}
 
-	// 检查tinyMediaManager可执行文件是否存在
-	tmmPath := getTMMExecutablePath(cfg)
-	if _, err := os.Stat(tmmPath); os.IsNotExist(err) {
-		return fmt.Errorf("tinyMediaManager可执行文件不存在: %s\n请检查配置文件中的TinyMediaManagerDir路径是否正确", tmmPath)
+// runTMM 运行tinyMediaManager的更新和刮削命令，输出逐行写入日志
+// 超过tmm_timeout_minutes仍未结束时结束整个进程组（TMM会启动Java子进程），返回ErrTimeout
+func runTMM(module string, label string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
 	}
 
 	// 使用第一个有效的TempDir作为工作目录
@@ -26,53 +44,126 @@ func ScrapeMovies() error {
 		return fmt.Errorf("没有有效的临时目录可用")
 	}
 
+	// TMM只扫描设置中的数据源，Temp目录不在其中时不会刮削任何影片
+	if err := checkDatasources(cfg, module); err != nil {
+		return fmt.Errorf("刮削%s失败: %w", label, err)
+	}
+
+	timeout := time.Duration(cfg.TMMTimeoutMinutes) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
 	// 构建命令
-	cmd := exec.Command(tmmPath, "movie", "-u", "-n", "-r")
+	var cmd *exec.Cmd
+	if cfg.TMMDocker.Enabled() {
+		cmd = dockerCommand(ctx, cfg.TMMDocker, module)
+	} else {
+		// 检查tinyMediaManager可执行文件是否存在
+		tmmPath := getTMMExecutablePath(cfg)
+		if _, err := os.Stat(tmmPath); os.IsNotExist(err) {
+			return fmt.Errorf("tinyMediaManager可执行文件不存在: %s\n请检查配置文件中的TinyMediaManagerDir路径是否正确", tmmPath)
+		}
+		cmd = exec.CommandContext(ctx, tmmPath, module, "-u", "-n", "-r")
+		killProcessGroup(cmd)
+	}
 	cmd.Dir = cfg.TempDirs[0] // 设置工作目录为第一个临时目录
+	// 被结束的进程的子进程可能仍占用输出管道，不再等待其退出
+	cmd.WaitDelay = 5 * time.Second
+
+	// 输出逐行写入日志，同时完整保存到缓存目录
+	output := &lineLogger{prefix: "TMM: "}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	capture, err := cache.Create(cache.KindTMM, fmt.Sprintf("%s-%s.log", module, time.Now().Format("20060102-150405")))
+	if err != nil {
+		logging.Warning("%v，不保存tinyMediaManager的输出", err)
+	} else {
+		defer capture.Close()
+		cmd.Stdout = io.MultiWriter(output, capture)
+		cmd.Stderr = cmd.Stdout
+		logging.Debug("tinyMediaManager的输出保存到: %s", capture.Name())
+	}
 
-	// 设置输出
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	logging.Info("开始刮削电影...")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("刮削电影失败: %w", err)
+	logging.Info("开始刮削%s...", label)
+	start := time.Now()
+	err = cmd.Run()
+	output.Flush()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("刮削%s失败: %w（超过 %v，已结束进程）", label, ErrTimeout, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("刮削%s失败: %w", label, err)
 	}
 
-	logging.Info("电影刮削完成")
+	logging.Info("%s刮削完成，耗时 %v", label, time.Since(start).Round(time.Second))
 	return nil
 }
 
-// ScrapeTVShows执行电视剧刮削命令
-func ScrapeTVShows() error {
-	cfg := config.LoadConfig()
-
-	// 检查tinyMediaManager可执行文件是否存在
-	tmmPath := getTMMExecutablePath(cfg)
-	if _, err := os.Stat(tmmPath); os.IsNotExist(err) {
-		return fmt.Errorf("tinyMediaManager可执行文件不存在: %s\n请检查配置文件中的TinyMediaManagerDir路径是否正确", tmmPath)
+// dockerCommand 构建通过docker run运行tinyMediaManager容器的命令
+// 容器使用固定的名称，超时时通过docker kill结束容器（只结束docker客户端不会停止容器）
+func dockerCommand(ctx context.Context, d config.TMMDockerConfig, module string) *exec.Cmd {
+	name, args := dockerArgs(d, module)
+	cmd := exec.CommandContext(ctx, d.Docker, args...)
+	cmd.Cancel = func() error {
+		logging.Warning("结束tinyMediaManager容器 %s", name)
+		if err := exec.Command(d.Docker, "kill", name).Run(); err != nil {
+			logging.Warning("结束容器失败: %v", err)
+		}
+		return cmd.Process.Kill()
 	}
+	logging.Debug("通过docker运行tinyMediaManager: %s %s", d.Docker, strings.Join(args, " "))
+	return cmd
+}
 
-	// 使用第一个有效的TempDir作为工作目录
-	if len(cfg.TempDirs) == 0 {
-		return fmt.Errorf("没有有效的临时目录可用")
+// dockerArgs 返回tinyMediaManager容器的名称和docker run的参数
+func dockerArgs(d config.TMMDockerConfig, module string) (string, []string) {
+	name := fmt.Sprintf("media-manager-tmm-%s-%d", module, os.Getpid())
+	args := []string{"run", "--rm", "--name", name}
+	for _, volume := range d.Volumes {
+		args = append(args, "-v", volume)
 	}
+	args = append(args, d.ExtraArgs...)
+	// 镜像的默认入口启动带界面的服务，使用命令行程序作为入口
+	args = append(args, "--entrypoint", d.Command, d.Image+":"+d.Tag, module, "-u", "-n", "-r")
+	return name, args
+}
 
-	// 构建命令
-	cmd := exec.Command(tmmPath, "tvshow", "-u", "-n", "-r")
-	cmd.Dir = cfg.TempDirs[0] // 设置工作目录为第一个临时目录
-
-	// 设置输出
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// lineLogger 把写入的内容按行记录到日志
+type lineLogger struct {
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
 
-	logging.Info("开始刮削电视剧...")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("刮削电视剧失败: %w", err)
+// Write 记录完整的行，不完整的行保留到下次写入
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.log(l.buf[:i])
+		l.buf = l.buf[i+1:]
 	}
+	return len(p), nil
+}
 
-	logging.Info("电视剧刮削完成")
-	return nil
+// Flush 记录剩余的不完整的行
+func (l *lineLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.log(l.buf)
+	l.buf = nil
+}
+
+func (l *lineLogger) log(line []byte) {
+	if text := strings.TrimRight(string(line), "\r "); text != "" {
+		logging.Info("%s%s", l.prefix, text)
+	}
 }
 
 // ScrapeAll执行所有刮削命令
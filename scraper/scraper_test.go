@@ -0,0 +1,79 @@
+//go:build !windows
+
+package scraper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/internal/testkit"
+)
+
+// newEnv 创建测试环境并安装执行script的模拟tinyMediaManager
+func newEnv(t *testing.T, script string) *testkit.Env {
+	t.Helper()
+	env := testkit.New(t)
+	if err := env.FakeTMM(script); err != nil {
+		t.Fatal(err)
+	}
+	env.Config.TMMDatasources = config.TMMDatasourcesOff
+	return env
+}
+
+func TestScrapeRunsTMM(t *testing.T) {
+	// 模拟的tinyMediaManager把参数和工作目录写入Temp目录
+	env := newEnv(t, `echo "$@" >> args.txt; pwd > pwd.txt`)
+
+	if err := ScrapeMovies(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScrapeTVShows(); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := os.ReadFile(env.TempPath("args.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "movie -u -n -r\ntvshow -u -n -r\n"; string(args) != want {
+		t.Errorf("tinyMediaManager的参数 = %q，应为 %q", args, want)
+	}
+	pwd, err := os.ReadFile(env.TempPath("pwd.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDir, _ := filepath.EvalSymlinks(env.Config.TempDirs[0])
+	if gotDir, _ := filepath.EvalSymlinks(strings.TrimSpace(string(pwd))); gotDir != wantDir {
+		t.Errorf("工作目录 = %s，应为 %s", gotDir, wantDir)
+	}
+}
+
+func TestScrapeFailure(t *testing.T) {
+	newEnv(t, "echo 刮削失败; exit 3")
+	if err := ScrapeMovies(); err == nil {
+		t.Error("tinyMediaManager失败时应返回错误")
+	}
+}
+
+func TestScrapeMissingDatasource(t *testing.T) {
+	env := newEnv(t, "")
+	env.Config.TMMDatasources = config.TMMDatasourcesCheck
+	env.Config.TMMDataDir = filepath.Join(env.Root, "tmm-data")
+	for _, dir := range []string{env.Config.TMMDataDir, env.TempPath("Movie")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	settings := `{"movieDataSource":["/other"]}`
+	if err := os.WriteFile(filepath.Join(env.Config.TMMDataDir, "movies.json"), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ScrapeMovies(); !errors.Is(err, ErrMissingDatasource) {
+		t.Errorf("ScrapeMovies() = %v，Temp目录不是数据源时应返回ErrMissingDatasource", err)
+	}
+}
@@ -0,0 +1,248 @@
+package seerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// 请求的媒体类型，与Overseerr/Jellyseerr的mediaType一致
+const (
+	MediaTypeMovie = "movie"
+	MediaTypeTV    = "tv"
+)
+
+// requestedKeyPrefix 已创建请求的运行状态键前缀，同一部电影或同一季只请求一次
+const requestedKeyPrefix = "seerr:"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Request 媒体库缺少的内容，字段与Overseerr/Jellyseerr创建请求接口（POST /api/v1/request）的请求体一致
+type Request struct {
+	MediaType string `json:"mediaType"`         // movie 或 tv
+	MediaID   int    `json:"mediaId"`           // TMDB ID
+	Seasons   []int  `json:"seasons,omitempty"` // 电视剧缺失的季
+	Title     string `json:"title,omitempty"`   // 标题，只用于显示，创建请求时不发送
+}
+
+// Result 一次创建请求的统计
+type Result struct {
+	Submitted int // 新创建的请求数
+	Skipped   int // 之前已请求过的数量
+	Failed    int
+}
+
+// Wanted 返回媒体库缺失的季（按剧集合并）和系列中缺失的电影，电视剧在前，各自按标题排序
+func Wanted() ([]Request, error) {
+	seasons, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+	movies, err := database.GetMissingMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	shows := make(map[int]*Request)
+	for _, season := range seasons {
+		id, err := strconv.Atoi(season.TMDbID)
+		if err != nil || id <= 0 {
+			continue
+		}
+		if shows[id] == nil {
+			shows[id] = &Request{MediaType: MediaTypeTV, MediaID: id, Title: season.Title}
+		}
+		shows[id].Seasons = append(shows[id].Seasons, season.Season)
+	}
+
+	var tvRequests []Request
+	for _, show := range shows {
+		sort.Ints(show.Seasons)
+		tvRequests = append(tvRequests, *show)
+	}
+	sort.Slice(tvRequests, func(i, j int) bool { return tvRequests[i].Title < tvRequests[j].Title })
+
+	var movieRequests []Request
+	seen := make(map[int]bool)
+	for _, movie := range movies {
+		id, err := strconv.Atoi(movie.TMDbID)
+		if err != nil || id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		movieRequests = append(movieRequests, Request{MediaType: MediaTypeMovie, MediaID: id, Title: movie.Title})
+	}
+	sort.Slice(movieRequests, func(i, j int) bool { return movieRequests[i].Title < movieRequests[j].Title })
+
+	return append(tvRequests, movieRequests...), nil
+}
+
+// WriteJSON 以JSON数组输出请求列表，每一项可以直接作为Overseerr/Jellyseerr创建请求的请求体
+func WriteJSON(w io.Writer, requests []Request) error {
+	if requests == nil {
+		requests = []Request{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(requests); err != nil {
+		return fmt.Errorf("输出缺失内容失败: %w", err)
+	}
+	return nil
+}
+
+// Pending 去掉之前已创建过请求的电影和季，返回仍需请求的内容和跳过的数量
+func Pending(requests []Request) ([]Request, int, error) {
+	var pending []Request
+	skipped := 0
+	for _, request := range requests {
+		if request.MediaType == MediaTypeTV {
+			var seasons []int
+			for _, season := range request.Seasons {
+				requested, err := isRequested(seasonKey(request.MediaID, season))
+				if err != nil {
+					return nil, skipped, err
+				}
+				if requested {
+					skipped++
+				} else {
+					seasons = append(seasons, season)
+				}
+			}
+			if len(seasons) > 0 {
+				request.Seasons = seasons
+				pending = append(pending, request)
+			}
+			continue
+		}
+
+		requested, err := isRequested(movieKey(request.MediaID))
+		if err != nil {
+			return nil, skipped, err
+		}
+		if requested {
+			skipped++
+		} else {
+			pending = append(pending, request)
+		}
+	}
+	return pending, skipped, nil
+}
+
+// Submit 为之前没有请求过的缺失内容在Overseerr/Jellyseerr中创建请求
+// 创建成功或服务端已有相同请求（409）时记录下来，之后不再重复请求
+func Submit(cfg config.SeerrConfig, requests []Request) (*Result, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("没有配置seerr的url和api_key")
+	}
+
+	pending, skipped, err := Pending(requests)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Skipped: skipped}
+	for _, request := range pending {
+		if err := post(cfg, request); err != nil {
+			logging.Error("为 '%s' 创建请求失败: %v", request.Title, err)
+			result.Failed++
+			continue
+		}
+		if err := markRequested(request); err != nil {
+			return result, err
+		}
+		if request.MediaType == MediaTypeTV {
+			logging.Info("已为 '%s' 第 %s 季创建请求", request.Title, joinInts(request.Seasons))
+		} else {
+			logging.Info("已为电影 '%s' 创建请求", request.Title)
+		}
+		result.Submitted++
+	}
+	return result, nil
+}
+
+// post 调用创建请求接口
+func post(cfg config.SeerrConfig, request Request) error {
+	payload := map[string]interface{}{
+		"mediaType": request.MediaType,
+		"mediaId":   request.MediaID,
+	}
+	if request.MediaType == MediaTypeTV {
+		payload["seasons"] = request.Seasons
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.URL, "/")+"/api/v1/request", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", cfg.APIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		logging.Info("'%s' 在Overseerr/Jellyseerr中已有请求", request.Title)
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// markRequested 记录已创建请求的电影或各季
+func markRequested(request Request) error {
+	now := time.Now().Format(time.RFC3339)
+	if request.MediaType == MediaTypeMovie {
+		return database.SetRunState(movieKey(request.MediaID), now)
+	}
+	for _, season := range request.Seasons {
+		if err := database.SetRunState(seasonKey(request.MediaID, season), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRequested 判断是否已创建过请求
+func isRequested(key string) (bool, error) {
+	value, err := database.GetRunState(key)
+	return value != "", err
+}
+
+// movieKey 返回电影已请求的运行状态键
+func movieKey(id int) string {
+	return fmt.Sprintf("%smovie:%d", requestedKeyPrefix, id)
+}
+
+// seasonKey 返回电视剧某一季已请求的运行状态键
+func seasonKey(id int, season int) string {
+	return fmt.Sprintf("%stv:%d:%d", requestedKeyPrefix, id, season)
+}
+
+// joinInts 将季号格式化为 "1、2"
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(parts, "、")
+}
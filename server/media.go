@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+)
+
+// mediaItem /api/media.json中的一个条目，电视剧的各季放在seasons中
+type mediaItem struct {
+	ID            int          `json:"id"`
+	Title         string       `json:"title"`
+	OriginalTitle string       `json:"original_title,omitempty"`
+	Year          string       `json:"year,omitempty"`
+	Edition       string       `json:"edition,omitempty"`
+	Category      string       `json:"category"`
+	TMDbID        string       `json:"tmdb_id,omitempty"`
+	IMDbID        string       `json:"imdb_id,omitempty"`
+	TargetPath    string       `json:"target_path"`
+	IsShow        bool         `json:"is_show"`
+	Season        *int         `json:"season,omitempty"` // per_season=1时电视剧条目的季号
+	IsComplete    bool         `json:"is_complete,omitempty"`
+	Locked        bool         `json:"locked,omitempty"`
+	ProcessedAt   time.Time    `json:"processed_at"`
+	Seasons       []seasonItem `json:"seasons,omitempty"`
+}
+
+// seasonItem 电视剧一季的记录
+type seasonItem struct {
+	ID          int       `json:"id"`
+	Season      *int      `json:"season"` // 没有季号时为null
+	Resolution  string    `json:"resolution,omitempty"`
+	TargetPath  string    `json:"target_path"`
+	IsComplete  bool      `json:"is_complete"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// newMediaItem 把按剧集合并后的条目转换为接口输出的格式
+func newMediaItem(entry *database.MediaEntry) mediaItem {
+	record := &entry.Record
+	item := mediaItem{
+		ID:            record.ID,
+		Title:         record.Title,
+		OriginalTitle: record.OriginalTitle,
+		Year:          record.Year,
+		Edition:       record.Edition,
+		Category:      record.Category,
+		TMDbID:        record.TMDbID,
+		IMDbID:        record.IMDbID,
+		TargetPath:    record.TargetPath,
+		IsShow:        entry.IsShow() || strings.Contains(record.Category, "Show"),
+		IsComplete:    record.IsComplete,
+		Locked:        record.Locked,
+		ProcessedAt:   record.ProcessedAt,
+	}
+	if !entry.IsShow() {
+		item.Season = record.Season
+	}
+	for _, season := range entry.Seasons {
+		item.Locked = item.Locked || season.Locked
+		item.Seasons = append(item.Seasons, seasonItem{
+			ID:          season.ID,
+			Season:      season.Season,
+			Resolution:  season.Resolution,
+			TargetPath:  season.TargetPath,
+			IsComplete:  season.IsComplete,
+			ProcessedAt: season.ProcessedAt,
+		})
+	}
+	return item
+}
+
+// handleMedia 以JSON输出媒体记录，电视剧按剧集合并、各季嵌套在seasons中
+// 支持 title、category 查询参数（模糊匹配），per_season=1 时电视剧的每一季单独作为一个条目
+func handleMedia(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	records, err := database.GetMediaRecords(map[string]interface{}{
+		"title":    query.Get("title"),
+		"category": query.Get("category"),
+		"order":    "pinyin",
+	})
+	if err != nil {
+		logging.Error("获取媒体记录失败: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var entries []database.MediaEntry
+	if query.Get("per_season") == "1" {
+		for _, record := range records {
+			entries = append(entries, database.MediaEntry{Record: record})
+		}
+	} else {
+		entries = database.AggregateShows(records)
+	}
+	items := make([]mediaItem, 0, len(entries))
+	for i := range entries {
+		items = append(items, newMediaItem(&entries[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(items); err != nil {
+		logging.Error("输出媒体记录失败: %v", err)
+	}
+}
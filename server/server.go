@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/feed"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/seerr"
+)
+
+// 订阅源的默认参数
+const (
+	defaultRecentDays   = 14
+	defaultRecentLimit  = 50
+	defaultCalendarDays = 30
+	calendarCacheTTL    = time.Hour // 日历订阅端会频繁刷新，缓存生成结果以减少TMDB请求
+)
+
+// feedWriter 订阅源的输出函数（RSS或Atom）
+type feedWriter func(w io.Writer, f *feed.Feed) error
+
+// NewHandler 创建包含所有HTTP接口的处理器
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feeds/recent.rss", handleRecentFeed(feed.WriteRSS, "application/rss+xml"))
+	mux.HandleFunc("/feeds/recent.atom", handleRecentFeed(feed.WriteAtom, "application/atom+xml"))
+	mux.HandleFunc("/feeds/missing.rss", handleMissingFeed(feed.WriteRSS, "application/rss+xml"))
+	mux.HandleFunc("/feeds/missing.atom", handleMissingFeed(feed.WriteAtom, "application/atom+xml"))
+	mux.HandleFunc("/calendar/upcoming.ics", handleUpcomingCalendar())
+	mux.HandleFunc("/seerr/wanted.json", handleWanted)
+	mux.HandleFunc("/api/media.json", handleMedia)
+	mux.HandleFunc("/api/stats.json", handleStats)
+	return mux
+}
+
+// Serve 在指定地址启动HTTP服务，阻塞直到服务退出
+func Serve(addr string) error {
+	logging.Info("HTTP服务已启动，监听地址: %s", addr)
+	if err := http.ListenAndServe(addr, NewHandler()); err != nil {
+		return fmt.Errorf("HTTP服务异常退出: %w", err)
+	}
+	return nil
+}
+
+// handleRecentFeed 处理最近入库订阅源请求，支持 days 和 limit 查询参数
+func handleRecentFeed(write feedWriter, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := queryInt(r, "days", defaultRecentDays)
+		limit := queryInt(r, "limit", defaultRecentLimit)
+
+		f, err := feed.RecentlyAdded(requestURL(r), days, limit)
+		if err != nil {
+			logging.Error("生成最近入库订阅源失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeFeed(w, f, write, contentType)
+	}
+}
+
+// handleMissingFeed 处理缺失季订阅源请求
+func handleMissingFeed(write feedWriter, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := feed.MissingSeasons(requestURL(r))
+		if err != nil {
+			logging.Error("生成缺失季订阅源失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeFeed(w, f, write, contentType)
+	}
+}
+
+// handleWanted 以Overseerr/Jellyseerr创建请求的格式输出缺失的季和系列电影
+func handleWanted(w http.ResponseWriter, r *http.Request) {
+	requests, err := seerr.Wanted()
+	if err != nil {
+		logging.Error("获取缺失内容失败: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := seerr.WriteJSON(w, requests); err != nil {
+		logging.Error("%v", err)
+	}
+}
+
+// handleUpcomingCalendar 处理剧集播出日历请求，支持 days 查询参数
+// 相同参数的日历在calendarCacheTTL内直接返回缓存的结果
+func handleUpcomingCalendar() http.HandlerFunc {
+	type cachedCalendar struct {
+		content     []byte
+		generatedAt time.Time
+	}
+	var (
+		mu    sync.Mutex
+		cache = make(map[int]cachedCalendar)
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := queryInt(r, "days", defaultCalendarDays)
+
+		mu.Lock()
+		defer mu.Unlock()
+		cached, ok := cache[days]
+		if !ok || time.Since(cached.generatedAt) > calendarCacheTTL {
+			c, err := feed.UpcomingEpisodes(days)
+			if err != nil {
+				logging.Error("生成剧集播出日历失败: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			var buf bytes.Buffer
+			if err := feed.WriteICS(&buf, c); err != nil {
+				logging.Error("%v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			cached = cachedCalendar{content: buf.Bytes(), generatedAt: time.Now()}
+			cache[days] = cached
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(cached.content)
+	}
+}
+
+// writeFeed 设置响应头并输出订阅源
+func writeFeed(w http.ResponseWriter, f *feed.Feed, write feedWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	if err := write(w, f); err != nil {
+		logging.Error("输出订阅源失败: %v", err)
+	}
+}
+
+// queryInt 读取整数查询参数，缺失或无效时返回默认值
+func queryInt(r *http.Request, name string, defaultValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// requestURL 根据请求还原订阅源自身的地址
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+}
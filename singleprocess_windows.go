@@ -15,7 +15,11 @@ import (
 // 简化实现，移除平台特定的文件锁
 func ensureSingleProcess() bool {
 	// 创建锁文件路径
-	configPath := config.GetConfigPath()
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return true // 与锁文件创建失败时一致，允许程序继续运行
+	}
 	lockDir := filepath.Dir(configPath)
 	lockFile := filepath.Join(lockDir, "media-manager.lock")
 
@@ -0,0 +1,128 @@
+// Package stats 汇总媒体库的概要数字（Temp目录积压、今天入库数、媒体库总数、剩余空间、最近一次运行），
+// 输出为扁平的JSON，便于Home Assistant等系统作为传感器读取
+package stats
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/utils"
+)
+
+// tempSubdirs Temp目录中存放待处理项目的子目录
+var tempSubdirs = []string{"Movie", "TvShow"}
+
+// Storage 一个媒体库根目录所在文件系统的剩余空间
+type Storage struct {
+	Path      string  `json:"path"`
+	FreeBytes uint64  `json:"free_bytes"`
+	FreeGB    float64 `json:"free_gb"`
+}
+
+// Stats 媒体库的概要数字，字段都是可以直接作为传感器状态的数值
+type Stats struct {
+	GeneratedAt    time.Time            `json:"generated_at"`
+	TempPending    int                  `json:"temp_pending"`    // Temp目录中等待处理的媒体目录数
+	QueuePending   int                  `json:"queue_pending"`   // 处理队列中等待处理的项目数
+	QueueFailed    int                  `json:"queue_failed"`    // 处理队列中处理失败的项目数
+	MovedToday     int                  `json:"moved_today"`     // 今天移动到媒体库（包括合并新的季、替换为新版本）的项目数
+	LibraryMovies  int                  `json:"library_movies"`  // 媒体库中的电影数
+	LibraryShows   int                  `json:"library_shows"`   // 媒体库中的剧集数（各季合并为一部）
+	LibrarySeasons int                  `json:"library_seasons"` // 媒体库中电视剧的季记录数
+	MissingSeasons int                  `json:"missing_seasons"` // 尚未获取的缺失季数
+	StorageFreeGB  float64              `json:"storage_free_gb"` // 各媒体库根目录中最少的剩余空间（GB）
+	Storage        []Storage            `json:"storage"`
+	LastRun        *database.RunSummary `json:"last_run"` // 最近一次处理运行的汇总，还没有运行过时为null
+}
+
+// Collect 汇总当前的概要数字
+func Collect(cfg *config.Config) (*Stats, error) {
+	now := time.Now()
+	s := &Stats{GeneratedAt: now, Storage: []Storage{}}
+
+	s.TempPending = countTempItems(cfg)
+
+	queue, err := database.CountQueueItems()
+	if err != nil {
+		return nil, err
+	}
+	s.QueuePending = queue[database.QueueStatusPending] + queue[database.QueueStatusProcessing]
+	s.QueueFailed = queue[database.QueueStatusFailed]
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if s.MovedToday, err = database.CountEventsSince(today, database.EventMoved, database.EventSeasonAdded, database.EventUpgraded); err != nil {
+		return nil, err
+	}
+
+	records, err := database.GetMediaRecords(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取媒体记录失败: %w", err)
+	}
+	for _, entry := range database.AggregateShows(records) {
+		if entry.IsShow() || strings.Contains(entry.Record.Category, "Show") {
+			s.LibraryShows++
+			s.LibrarySeasons += max(len(entry.Seasons), 1)
+		} else {
+			s.LibraryMovies++
+		}
+	}
+
+	missing, err := database.GetMissingSeasons(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("获取缺失季记录失败: %w", err)
+	}
+	s.MissingSeasons = len(missing)
+
+	for _, root := range cfg.CloudRoots() {
+		free, err := utils.FreeSpace(root)
+		if err != nil {
+			logging.Warning("%v", err)
+			continue
+		}
+		storage := Storage{Path: root, FreeBytes: free, FreeGB: toGB(free)}
+		if len(s.Storage) == 0 || storage.FreeGB < s.StorageFreeGB {
+			s.StorageFreeGB = storage.FreeGB
+		}
+		s.Storage = append(s.Storage, storage)
+	}
+
+	if s.LastRun, err = database.GetLastRunSummary(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// countTempItems 统计各Temp目录Movie、TvShow子目录中的媒体目录数，不包括带忽略标记的目录
+func countTempItems(cfg *config.Config) int {
+	count := 0
+	for _, tempDir := range cfg.TempDirs {
+		for _, subdir := range tempSubdirs {
+			dir := filepath.Join(tempDir, subdir)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					logging.Warning("读取Temp目录失败: %v", err)
+				}
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() && !utils.HasIgnoreMarker(filepath.Join(dir, entry.Name())) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// toGB 将字节数换算为GB，保留一位小数
+func toGB(bytes uint64) float64 {
+	return math.Round(float64(bytes)/(1<<30)*10) / 10
+}
@@ -5,8 +5,21 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/media-manager/cache"
 	"github.com/user/media-manager/config"
+	"github.com/user/media-manager/database"
+	"github.com/user/media-manager/errs"
+	"github.com/user/media-manager/logging"
+	"github.com/user/media-manager/metrics"
+	"golang.org/x/sync/singleflight"
 )
 
 // 国家代码到中文名称的映射表
@@ -124,17 +137,61 @@ var countryCodeToChinese = map[string]string{
 	"ZW": "津巴布韦",
 }
 
-// TMDBResponse 表示TMDB API的响应结构
-type TMDBResponse struct {
+// detailsResponse 表示电影或电视剧详情接口的响应（电视剧才有季信息）
+type detailsResponse struct {
+	Title               string              `json:"title"` // 电影标题
+	Name                string              `json:"name"`  // 电视剧标题
+	Overview            string              `json:"overview"`
+	ReleaseDate         string              `json:"release_date"`   // 电影上映日期
+	FirstAirDate        string              `json:"first_air_date"` // 电视剧首播日期
+	BelongsToCollection *collectionRef      `json:"belongs_to_collection"`
+	NumberOfSeasons     int                 `json:"number_of_seasons"`
 	ProductionCountries []ProductionCountry `json:"production_countries"`
 	OriginalLanguage    string              `json:"original_language"`
+	SpokenLanguages     []SpokenLanguage    `json:"spoken_languages"`
+	Seasons             []Season            `json:"seasons"`
 }
 
-// TVShowResponse 表示TMDB API返回的电视剧信息
-type TVShowResponse struct {
-	NumberOfSeasons     int                 `json:"number_of_seasons"`
-	ProductionCountries []ProductionCountry `json:"production_countries"`
-	OriginalLanguage    string              `json:"original_language"`
+// Details 电影或电视剧详情中分类和季数检测需要的字段
+type Details struct {
+	Title            string   // 标题（按配置的语言顺序取第一个非空值）
+	TitleLanguage    string   // 标题所用的语言，如 zh-CN、en-US，标题为空时为空
+	Overview         string   // 简介（按配置的语言顺序取第一个非空值）
+	OverviewLanguage string   // 简介所用的语言，简介为空时为空
+	ReleaseDate      string   // 电影上映日期或电视剧首播日期（YYYY-MM-DD）
+	CollectionID     int      // 电影所属系列的ID，不属于系列时为0
+	CollectionName   string   // 电影所属系列的名称
+	Countries        []string // 制作国家（中文名称）
+	OriginalLanguage string   // 原始语言（ISO 639-1代码）
+	SpokenLanguages  []string // 对白语言（ISO 639-1代码）
+	NumberOfSeasons  int      // 电视剧总季数
+	Seasons          []Season // 电视剧各季信息（含特别篇）
+}
+
+// collectionRef 表示电影详情中所属的系列
+type collectionRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Collection 表示电影系列（如流浪地球系列）
+type Collection struct {
+	ID    int              `json:"id"`
+	Name  string           `json:"name"`
+	Parts []CollectionPart `json:"parts"`
+}
+
+// CollectionPart 表示系列中的一部电影
+type CollectionPart struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+}
+
+// Season 表示电视剧的一季
+type Season struct {
+	SeasonNumber int `json:"season_number"`
+	EpisodeCount int `json:"episode_count"`
 }
 
 // ProductionCountry 表示制作国家信息
@@ -143,41 +200,103 @@ type ProductionCountry struct {
 	Name      string `json:"name"`
 }
 
-// GetProductionCountries 获取电影或电视剧的制作国家信息
-func GetProductionCountries(tmdbID string, isTVShow bool) ([]string, error) {
+// SpokenLanguage 表示对白语言信息
+type SpokenLanguage struct {
+	ISO639_1    string `json:"iso_639_1"`
+	Name        string `json:"name"`
+	EnglishName string `json:"english_name"`
+}
+
+// ErrNotFound TMDB返回404，条目不存在或已被删除
+var ErrNotFound = errs.ErrTMDBNotFound
+
+// ErrCachedNotFound 条目之前已返回过404，本次跳过查询
+var ErrCachedNotFound = fmt.Errorf("%w（已记录，跳过查询）", ErrNotFound)
+
+// RefreshNotFound 为true时忽略已记录的404条目，重新查询TMDB
+var RefreshNotFound bool
+
+// BaseURL TMDB API的根地址（以/结尾），为空时按配置的use_tmdb_org选择，测试时指向模拟服务器
+var BaseURL string
+
+// HTTPClient 请求TMDB API使用的HTTP客户端
+var HTTPClient = http.DefaultClient
+
+// requestCount 本进程发送的TMDB请求数
+var requestCount atomic.Int64
+
+// RequestCount 返回本进程已发送的TMDB请求数，用于限制批量操作的请求量
+func RequestCount() int64 {
+	return requestCount.Load()
+}
+
+// fetchTMDB 请求指定路径的TMDB接口（如 "tv/123/episode_groups"），返回响应内容
+func fetchTMDB(path string) ([]byte, error) {
+	return fetchTMDBWithQuery(path, nil)
+}
+
+// fetchTMDBWithQuery 请求指定路径的TMDB接口，附加额外的查询参数
+func fetchTMDBWithQuery(path string, query url.Values) ([]byte, error) {
+	// 之前返回过404的条目不再重复请求
+	if !RefreshNotFound {
+		notFound, err := database.IsTMDBNotFound(path)
+		if err != nil {
+			logging.Warning("%v", err)
+		} else if notFound {
+			return nil, fmt.Errorf("%w: %s", ErrCachedNotFound, path)
+		}
+	}
+
 	// 加载配置
-	cfg := config.LoadConfig()
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
 	apiKey := cfg.TMDBApiKey
 
 	// 构建API URL
 	var baseURL string
-	if cfg.UseTMDBOrg {
+	switch {
+	case BaseURL != "":
+		baseURL = BaseURL // 使用指定的地址
+	case cfg.UseTMDBOrg:
 		baseURL = "https://api.tmdb.org/3/" // 使用tmdb.org
-	} else {
+	default:
 		baseURL = "https://api.themoviedb.org/3/" // 使用themoviedb.org
 	}
 
-	endpoint := "movie/"
-	if isTVShow {
-		endpoint = "tv/"
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = values
 	}
-
-	var apiURL string
-	if apiKey == "" {
-		// 没有API密钥时，尝试不使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?language=zh-CN", baseURL, endpoint, tmdbID)
-	} else {
-		// 有API密钥时，使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?api_key=%s&language=zh-CN", baseURL, endpoint, tmdbID, apiKey)
+	if params.Get("language") == "" {
+		params.Set("language", cfg.TMDBLanguage)
 	}
+	if apiKey != "" {
+		// 有API密钥时，使用密钥访问；没有时尝试不使用密钥访问
+		params.Set("api_key", apiKey)
+	}
+	apiURL := fmt.Sprintf("%s%s?%s", baseURL, path, params.Encode())
 
-	// 发送请求
-	resp, err := http.Get(apiURL)
+	// 发送请求，耗时包括读取响应
+	defer metrics.Start(metrics.OpTMDB, path)()
+	requestCount.Add(1)
+	resp, err := HTTPClient.Get(apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("TMDB API请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// 记录不存在的条目，之后的运行跳过查询
+	if resp.StatusCode == http.StatusNotFound {
+		if err := database.MarkTMDBNotFound(path); err != nil {
+			logging.Warning("%v", err)
+		} else {
+			logging.Warning("TMDB中不存在 %s，已记录，之后的运行将跳过查询（使用 -refresh-tmdb 重新查询）", path)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+
 	// 检查响应状态码
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("TMDB API返回错误状态码: %d", resp.StatusCode)
@@ -189,153 +308,438 @@ func GetProductionCountries(tmdbID string, isTVShow bool) ([]string, error) {
 		return nil, fmt.Errorf("读取TMDB API响应失败: %w", err)
 	}
 
-	// 解析JSON
-	var countries []string
+	// 重新查询时条目已恢复，删除不存在记录
+	if RefreshNotFound {
+		if err := database.ClearTMDBNotFound(path); err != nil {
+			logging.Warning("%v", err)
+		}
+	}
+
+	return body, nil
+}
+
+// 详情请求的合并和缓存
+// 同一次运行中多个函数、多个并发任务请求同一条目的详情时只访问一次TMDB
+var (
+	detailsGroup singleflight.Group
+	detailsCache sync.Map // 键为 "movie/ID" 或 "tv/ID"，值为 *Details
+)
+
+// RefreshCache 为true时不使用缓存目录中的详情，重新查询TMDB后更新缓存（refresh-metadata使用）
+var RefreshCache bool
+
+// ResetCache 清空进程内缓存的详情，之后的请求重新访问TMDB
+func ResetCache() {
+	detailsCache.Range(func(key, _ interface{}) bool {
+		detailsCache.Delete(key)
+		return true
+	})
+}
+
+// GetDetails 获取电影或电视剧的详情
+// 结果在进程内缓存，并发请求同一条目时合并为一次API请求
+func GetDetails(tmdbID string, isTVShow bool) (*Details, error) {
+	key := "movie/" + tmdbID
 	if isTVShow {
-		var tvResp TVShowResponse
-		if err := json.Unmarshal(body, &tvResp); err != nil {
-			return nil, fmt.Errorf("解析TMDB API响应失败: %w", err)
+		key = "tv/" + tmdbID
+	}
+
+	if cached, ok := detailsCache.Load(key); ok {
+		return cached.(*Details), nil
+	}
+
+	result, err, _ := detailsGroup.Do(key, func() (interface{}, error) {
+		// 缓存目录中的详情按语言区分，修改语言配置后重新查询
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
 		}
-		for _, country := range tvResp.ProductionCountries {
-			// 使用国家代码查找中文名称
-			if chineseName, exists := countryCodeToChinese[country.ISO3166_1]; exists {
-				countries = append(countries, chineseName)
-			} else {
-				// 如果没有找到对应的中文名称，使用API返回的名称
-				countries = append(countries, country.Name)
+		diskKey := key + "/" + cfg.TMDBLanguage
+		maxAge := time.Duration(cfg.Cache.TMDBDays) * 24 * time.Hour
+		if cfg.Cache.TMDBDays > 0 && !RefreshCache {
+			var cached Details
+			if cache.Load(cache.KindTMDB, diskKey, maxAge, &cached) {
+				detailsCache.Store(key, &cached)
+				return &cached, nil
 			}
 		}
-	} else {
-		var tmdbResp TMDBResponse
-		if err := json.Unmarshal(body, &tmdbResp); err != nil {
+
+		body, err := fetchTMDB(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp detailsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
 			return nil, fmt.Errorf("解析TMDB API响应失败: %w", err)
 		}
-		for _, country := range tmdbResp.ProductionCountries {
+
+		details := &Details{
+			Title:            resp.title(),
+			Overview:         resp.Overview,
+			ReleaseDate:      resp.releaseDate(),
+			OriginalLanguage: resp.OriginalLanguage,
+			NumberOfSeasons:  resp.NumberOfSeasons,
+			Seasons:          resp.Seasons,
+		}
+		for _, country := range resp.ProductionCountries {
 			// 使用国家代码查找中文名称
 			if chineseName, exists := countryCodeToChinese[country.ISO3166_1]; exists {
-				countries = append(countries, chineseName)
+				details.Countries = append(details.Countries, chineseName)
 			} else {
 				// 如果没有找到对应的中文名称，使用API返回的名称
-				countries = append(countries, country.Name)
+				details.Countries = append(details.Countries, country.Name)
 			}
 		}
+		for _, language := range resp.SpokenLanguages {
+			if language.ISO639_1 != "" {
+				details.SpokenLanguages = append(details.SpokenLanguages, language.ISO639_1)
+			}
+		}
+
+		if resp.BelongsToCollection != nil {
+			details.CollectionID = resp.BelongsToCollection.ID
+			details.CollectionName = resp.BelongsToCollection.Name
+		}
+		if strings.TrimSpace(details.Title) != "" {
+			details.TitleLanguage = cfg.TMDBLanguage
+		}
+		if strings.TrimSpace(details.Overview) != "" {
+			details.OverviewLanguage = cfg.TMDBLanguage
+		}
+		fillFallbackTexts(key, details)
+
+		detailsCache.Store(key, details)
+		if cfg.Cache.TMDBDays > 0 {
+			if err := cache.Store(cache.KindTMDB, diskKey, details); err != nil {
+				logging.Warning("%v", err)
+			}
+		}
+		return details, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return result.(*Details), nil
+}
+
+// title 返回电影或电视剧的标题
+func (r *detailsResponse) title() string {
+	if r.Title != "" {
+		return r.Title
+	}
+	return r.Name
+}
+
+// releaseDate 返回电影的上映日期或电视剧的首播日期
+func (r *detailsResponse) releaseDate() string {
+	if r.ReleaseDate != "" {
+		return r.ReleaseDate
+	}
+	return r.FirstAirDate
+}
 
-	return countries, nil
+// ReleaseYear 返回上映或首播年份，没有日期时返回0
+func (d *Details) ReleaseYear() int {
+	if len(d.ReleaseDate) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(d.ReleaseDate[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// fillFallbackTexts 首选语言缺少标题或简介（为空或只有空白）时，按备用语言顺序补充，并记录补充的内容所用的语言
+// 备用语言请求失败只记录日志，不影响已获取的详情
+func fillFallbackTexts(path string, details *Details) {
+	details.Title = strings.TrimSpace(details.Title)
+	details.Overview = strings.TrimSpace(details.Overview)
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	for _, language := range cfg.TMDBFallbackLanguages {
+		if details.Title != "" && details.Overview != "" {
+			return
+		}
+
+		body, err := fetchTMDBWithQuery(path, url.Values{"language": {language}})
+		if err != nil {
+			logging.Warning("使用备用语言 %s 获取TMDB详情失败: %v", language, err)
+			continue
+		}
+		var resp detailsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			logging.Warning("解析备用语言 %s 的TMDB响应失败: %v", language, err)
+			continue
+		}
+
+		if title := strings.TrimSpace(resp.title()); details.Title == "" && title != "" {
+			details.Title = title
+			details.TitleLanguage = language
+			logging.Info("%s 的标题使用备用语言 %s: %s", path, language, details.Title)
+		}
+		if overview := strings.TrimSpace(resp.Overview); details.Overview == "" && overview != "" {
+			details.Overview = overview
+			details.OverviewLanguage = language
+			logging.Info("%s 的简介使用备用语言 %s", path, language)
+		}
+	}
+}
+
+// OverviewSource 简介来自备用语言时返回记录到NFO注释和数据库plot_source字段的来源，如 tmdb:en-US
+// 简介来自首选语言（与刮削时相同）或没有简介时返回空字符串
+func (d *Details) OverviewSource() string {
+	if d.Overview == "" || d.OverviewLanguage == "" {
+		return ""
+	}
+	if cfg, err := config.Load(); err == nil && d.OverviewLanguage == cfg.TMDBLanguage {
+		return ""
+	}
+	return "tmdb:" + d.OverviewLanguage
+}
+
+// GetCollection 获取电影系列及其包含的电影
+func GetCollection(collectionID int) (*Collection, error) {
+	body, err := fetchTMDB("collection/" + strconv.Itoa(collectionID))
+	if err != nil {
+		return nil, err
+	}
+
+	var collection Collection
+	if err := json.Unmarshal(body, &collection); err != nil {
+		return nil, fmt.Errorf("解析TMDB API响应失败: %w", err)
+	}
+	return &collection, nil
+}
+
+// GetProductionCountries 获取电影或电视剧的制作国家信息
+func GetProductionCountries(tmdbID string, isTVShow bool) ([]string, error) {
+	details, err := GetDetails(tmdbID, isTVShow)
+	if err != nil {
+		return nil, err
+	}
+	return details.Countries, nil
 }
 
 // GetOriginalLanguage 获取原始语言
 func GetOriginalLanguage(tmdbID string, isTVShow bool) (string, error) {
-	// 加载配置
-	cfg := config.LoadConfig()
-	apiKey := cfg.TMDBApiKey
+	originalLanguage, _, err := GetLanguages(tmdbID, isTVShow)
+	return originalLanguage, err
+}
 
-	// 构建API URL
-	var baseURL string
-	if cfg.UseTMDBOrg {
-		baseURL = "https://api.tmdb.org/3/" // 使用tmdb.org
-	} else {
-		baseURL = "https://api.themoviedb.org/3/" // 使用themoviedb.org
+// GetLanguages 获取原始语言和对白语言（ISO 639-1代码）
+func GetLanguages(tmdbID string, isTVShow bool) (string, []string, error) {
+	details, err := GetDetails(tmdbID, isTVShow)
+	if err != nil {
+		return "", nil, err
 	}
+	return details.OriginalLanguage, details.SpokenLanguages, nil
+}
 
-	endpoint := "movie/"
-	if isTVShow {
-		endpoint = "tv/"
+// GetTVShowSeasons 获取电视剧的总季数
+func GetTVShowSeasons(tmdbID string) (int, error) {
+	details, err := GetDetails(tmdbID, true)
+	if err != nil {
+		return 0, err
 	}
+	return details.NumberOfSeasons, nil
+}
 
-	var apiURL string
-	if apiKey == "" {
-		// 没有API密钥时，尝试不使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?language=zh-CN", baseURL, endpoint, tmdbID)
-	} else {
-		// 有API密钥时，使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?api_key=%s&language=zh-CN", baseURL, endpoint, tmdbID, apiKey)
-	}
+// EpisodeRef 表示剧集的季数和集数
+type EpisodeRef struct {
+	Season  int
+	Episode int
+}
+
+// episodeGroupTypeAbsolute TMDB剧集组类型：绝对顺序
+const episodeGroupTypeAbsolute = 2
+
+// episodeGroupsResponse 表示剧集组列表接口的响应
+type episodeGroupsResponse struct {
+	Results []struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		Type         int    `json:"type"`
+		EpisodeCount int    `json:"episode_count"`
+	} `json:"results"`
+}
+
+// episodeGroupResponse 表示剧集组详情接口的响应
+type episodeGroupResponse struct {
+	Groups []struct {
+		Order    int `json:"order"`
+		Episodes []struct {
+			SeasonNumber  int `json:"season_number"`
+			EpisodeNumber int `json:"episode_number"`
+			Order         int `json:"order"`
+		} `json:"episodes"`
+	} `json:"groups"`
+}
 
-	// 发送请求
-	resp, err := http.Get(apiURL)
+// GetAbsoluteEpisodeOrder 获取电视剧按绝对集数排列的季/集对应关系
+// 返回切片的第i个元素对应绝对集数i+1
+// 优先使用TMDB中类型为"绝对顺序"的剧集组，没有时按各季的集数依次累加（跳过特别篇）
+func GetAbsoluteEpisodeOrder(tmdbID string) ([]EpisodeRef, error) {
+	body, err := fetchTMDB("tv/" + tmdbID + "/episode_groups")
 	if err != nil {
-		return "", fmt.Errorf("TMDB API请求失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("TMDB API返回错误状态码: %d", resp.StatusCode)
+	var groups episodeGroupsResponse
+	if err := json.Unmarshal(body, &groups); err != nil {
+		return nil, fmt.Errorf("解析TMDB剧集组列表失败: %w", err)
 	}
 
-	// 读取响应内容
-	body, err := io.ReadAll(resp.Body)
+	for _, group := range groups.Results {
+		if group.Type != episodeGroupTypeAbsolute {
+			continue
+		}
+
+		body, err := fetchTMDB("tv/episode_group/" + group.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var detail episodeGroupResponse
+		if err := json.Unmarshal(body, &detail); err != nil {
+			return nil, fmt.Errorf("解析TMDB剧集组详情失败: %w", err)
+		}
+
+		sort.Slice(detail.Groups, func(i, j int) bool {
+			return detail.Groups[i].Order < detail.Groups[j].Order
+		})
+
+		var episodes []EpisodeRef
+		for _, g := range detail.Groups {
+			sort.Slice(g.Episodes, func(i, j int) bool {
+				return g.Episodes[i].Order < g.Episodes[j].Order
+			})
+			for _, episode := range g.Episodes {
+				episodes = append(episodes, EpisodeRef{Season: episode.SeasonNumber, Episode: episode.EpisodeNumber})
+			}
+		}
+		if len(episodes) > 0 {
+			return episodes, nil
+		}
+	}
+
+	// 没有绝对顺序剧集组，按各季集数累加
+	details, err := GetDetails(tmdbID, true)
 	if err != nil {
-		return "", fmt.Errorf("读取TMDB API响应失败: %w", err)
+		return nil, err
 	}
 
-	// 解析JSON
-	var originalLanguage string
-	if isTVShow {
-		var tvResp TVShowResponse
-		if err := json.Unmarshal(body, &tvResp); err != nil {
-			return "", fmt.Errorf("解析TMDB API响应失败: %w", err)
+	seasons := append([]Season(nil), details.Seasons...)
+	sort.Slice(seasons, func(i, j int) bool {
+		return seasons[i].SeasonNumber < seasons[j].SeasonNumber
+	})
+
+	var episodes []EpisodeRef
+	for _, season := range seasons {
+		if season.SeasonNumber == 0 {
+			continue
 		}
-		originalLanguage = tvResp.OriginalLanguage
-	} else {
-		var tmdbResp TMDBResponse
-		if err := json.Unmarshal(body, &tmdbResp); err != nil {
-			return "", fmt.Errorf("解析TMDB API响应失败: %w", err)
+		for i := 1; i <= season.EpisodeCount; i++ {
+			episodes = append(episodes, EpisodeRef{Season: season.SeasonNumber, Episode: i})
 		}
-		originalLanguage = tmdbResp.OriginalLanguage
 	}
 
-	return originalLanguage, nil
+	return episodes, nil
 }
 
-// GetTVShowSeasons 获取电视剧的总季数
-func GetTVShowSeasons(tmdbID string) (int, error) {
-	// 加载配置
-	cfg := config.LoadConfig()
-	apiKey := cfg.TMDBApiKey
+// Episode 表示电视剧的一集及其播出日期
+type Episode struct {
+	Season   int    `json:"season_number"`
+	Episode  int    `json:"episode_number"`
+	Name     string `json:"name"`
+	Overview string `json:"overview"`
+	AirDate  string `json:"air_date"` // 播出日期（YYYY-MM-DD），未定档时为空
+}
 
-	// 构建API URL
-	var baseURL string
-	if cfg.UseTMDBOrg {
-		baseURL = "https://api.tmdb.org/3/" // 使用tmdb.org
-	} else {
-		baseURL = "https://api.themoviedb.org/3/" // 使用themoviedb.org
+// airingResponse 表示电视剧详情接口中与播出进度有关的字段
+type airingResponse struct {
+	Status           string   `json:"status"` // Returning Series、Ended、Canceled等
+	LastEpisodeToAir *Episode `json:"last_episode_to_air"`
+	NextEpisodeToAir *Episode `json:"next_episode_to_air"`
+}
+
+// seasonResponse 表示季详情接口的响应
+type seasonResponse struct {
+	Episodes []Episode `json:"episodes"`
+}
+
+// GetAiringEpisodes 获取仍在播出的电视剧最近一季和下一季的所有剧集（含播出日期）
+// 已完结或已取消的电视剧返回空列表；播出进度变化较快，结果不缓存
+func GetAiringEpisodes(tmdbID string) ([]Episode, error) {
+	body, err := fetchTMDB("tv/" + tmdbID)
+	if err != nil {
+		return nil, err
 	}
 
-	endpoint := "tv/"
-	var apiURL string
-	if apiKey == "" {
-		// 没有API密钥时，尝试不使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?language=zh-CN", baseURL, endpoint, tmdbID)
-	} else {
-		// 有API密钥时，使用密钥访问
-		apiURL = fmt.Sprintf("%s%s%s?api_key=%s&language=zh-CN", baseURL, endpoint, tmdbID, apiKey)
+	var airing airingResponse
+	if err := json.Unmarshal(body, &airing); err != nil {
+		return nil, fmt.Errorf("解析TMDB API响应失败: %w", err)
+	}
+	if airing.Status == "Ended" || airing.Status == "Canceled" {
+		return nil, nil
 	}
 
-	// 发送请求
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return 0, fmt.Errorf("TMDB API请求失败: %w", err)
+	var seasons []int
+	for _, episode := range []*Episode{airing.LastEpisodeToAir, airing.NextEpisodeToAir} {
+		if episode == nil {
+			continue
+		}
+		if len(seasons) == 0 || seasons[len(seasons)-1] != episode.Season {
+			seasons = append(seasons, episode.Season)
+		}
 	}
-	defer resp.Body.Close()
 
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("TMDB API返回错误状态码: %d", resp.StatusCode)
+	var episodes []Episode
+	for _, season := range seasons {
+		body, err := fetchTMDB(fmt.Sprintf("tv/%s/season/%d", tmdbID, season))
+		if err != nil {
+			return nil, err
+		}
+		var resp seasonResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("解析TMDB季详情失败: %w", err)
+		}
+		episodes = append(episodes, resp.Episodes...)
 	}
+	return episodes, nil
+}
 
-	// 读取响应内容
-	body, err := io.ReadAll(resp.Body)
+// findResponse 表示外部ID查询接口的响应
+type findResponse struct {
+	MovieResults []struct {
+		ID int `json:"id"`
+	} `json:"movie_results"`
+	TVResults []struct {
+		ID int `json:"id"`
+	} `json:"tv_results"`
+}
+
+// FindTMDbIDByIMDbID 通过IMDb ID查询对应的TMDb ID，没有找到时返回空字符串
+func FindTMDbIDByIMDbID(imdbID string, isTVShow bool) (string, error) {
+	body, err := fetchTMDBWithQuery("find/"+url.PathEscape(imdbID), url.Values{"external_source": {"imdb_id"}})
 	if err != nil {
-		return 0, fmt.Errorf("读取TMDB API响应失败: %w", err)
+		return "", err
 	}
 
-	// 解析JSON
-	var tvResp TVShowResponse
-	if err := json.Unmarshal(body, &tvResp); err != nil {
-		return 0, fmt.Errorf("解析TMDB API响应失败: %w", err)
+	var resp findResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("解析TMDB外部ID查询结果失败: %w", err)
 	}
 
-	return tvResp.NumberOfSeasons, nil
+	if isTVShow {
+		if len(resp.TVResults) > 0 {
+			return strconv.Itoa(resp.TVResults[0].ID), nil
+		}
+	} else if len(resp.MovieResults) > 0 {
+		return strconv.Itoa(resp.MovieResults[0].ID), nil
+	}
+	return "", nil
 }
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// existingAncestor 返回路径本身或最近的已存在的上级目录，目标目录还没有创建时用于检查所在的文件系统
+func existingAncestor(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// DirSize 统计目录中所有文件的字节数，按mode处理符号链接
+func DirSize(dir string, mode SymlinkMode) int64 {
+	var size int64
+	Walk(dir, mode, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // 忽略访问错误
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// FormatBytes 将字节数格式化为便于阅读的大小，如 1.5 TB
+func FormatBytes(bytes int64) string {
+	sign := ""
+	if bytes < 0 {
+		sign = "-"
+		bytes = -bytes
+	}
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	value := float64(bytes)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%s%d B", sign, bytes)
+	}
+	return fmt.Sprintf("%s%.1f %s", sign, value, units[unit])
+}
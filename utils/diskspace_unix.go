@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// FreeSpace 返回路径所在文件系统中非特权用户可用的剩余空间（字节），路径不存在时检查最近的已存在的上级目录
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(existingAncestor(path), &stat); err != nil {
+		return 0, fmt.Errorf("获取 %s 的剩余空间失败: %w", path, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// SameFilesystem 检查两个路径是否在同一文件系统上，同一文件系统内移动不占用额外空间
+func SameFilesystem(a, b string) bool {
+	var statA, statB syscall.Stat_t
+	if syscall.Stat(existingAncestor(a), &statA) != nil || syscall.Stat(existingAncestor(b), &statB) != nil {
+		return false
+	}
+	return statA.Dev == statB.Dev
+}
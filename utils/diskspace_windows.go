@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// FreeSpace 返回路径所在磁盘中当前用户可用的剩余空间（字节），路径不存在时检查最近的已存在的上级目录
+func FreeSpace(path string) (uint64, error) {
+	dir, err := syscall.UTF16PtrFromString(existingAncestor(path))
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if ret, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(dir)), uintptr(unsafe.Pointer(&available)), 0, 0); ret == 0 {
+		return 0, fmt.Errorf("获取 %s 的剩余空间失败: %w", path, err)
+	}
+	return available, nil
+}
+
+// SameFilesystem 检查两个路径是否在同一磁盘上，同一磁盘内移动不占用额外空间
+func SameFilesystem(a, b string) bool {
+	volumeA := filepath.VolumeName(existingAncestor(a))
+	volumeB := filepath.VolumeName(existingAncestor(b))
+	return volumeA != "" && strings.EqualFold(volumeA, volumeB)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IgnoreMarkerFiles 放置在目录中即可排除该目录及其子目录的标记文件
+// .nomedia 与 Kodi 的约定一致
+var IgnoreMarkerFiles = []string{".mmignore", ".nomedia"}
+
+// HasIgnoreMarker 检查目录中是否存在忽略标记文件
+func HasIgnoreMarker(dirPath string) bool {
+	for _, marker := range IgnoreMarkerFiles {
+		if _, err := os.Stat(filepath.Join(dirPath, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIgnoredPath 检查目录本身或任一上级目录是否存在忽略标记文件
+func IsIgnoredPath(dirPath string) bool {
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		absPath = dirPath
+	}
+
+	for {
+		if HasIgnoreMarker(absPath) {
+			return true
+		}
+		parent := filepath.Dir(absPath)
+		if parent == absPath {
+			return false
+		}
+		absPath = parent
+	}
+}
+
+// HasIgnoredSubtree 检查目录本身或其下任一子目录是否存在忽略标记文件
+// 移动和删除目录前使用，被排除的子目录需要保留在原位置
+func HasIgnoredSubtree(dirPath string) bool {
+	found := false
+	filepath.WalkDir(dirPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if HasIgnoreMarker(path) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
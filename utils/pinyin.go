@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs 不带声调的拼音，多音字取拼音库中的第一个读音
+var pinyinArgs = pinyin.NewArgs()
+
+// pinyinOverrides 片名中常用读音与拼音库默认读音不同的多音字，如 长安、长城、长津湖
+var pinyinOverrides = map[rune]string{
+	'长': "chang",
+}
+
+// pinyinWords 将字符串拆分为拼音单词：每个汉字是一个单词（不带声调的拼音），连续的字母和数字是一个单词（小写），其他字符作为分隔
+func pinyinWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if reading, ok := pinyinOverrides[r]; ok {
+				words = append(words, reading)
+			} else if readings := pinyin.SinglePinyin(r, pinyinArgs); len(readings) > 0 {
+				words = append(words, readings[0])
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// Pinyin 返回字符串的拼音，各字之间以空格分隔，如 "琅琊榜" 返回 "lang ya bang"，字母和数字保留为小写
+func Pinyin(s string) string {
+	return strings.Join(pinyinWords(s), " ")
+}
+
+// PinyinInitials 返回字符串中每个字（或连续的字母数字）拼音的首字母，如 "琅琊榜" 返回 "lyb"
+func PinyinInitials(s string) string {
+	var initials strings.Builder
+	for _, word := range pinyinWords(s) {
+		initials.WriteString(word[:1])
+	}
+	return initials.String()
+}
+
+// SortByPinyin 按拼音排序字符串
+func SortByPinyin(values []string) {
+	keys := make(map[string]string, len(values))
+	for _, v := range values {
+		keys[v] = Pinyin(v)
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		if keys[values[i]] != keys[values[j]] {
+			return keys[values[i]] < keys[values[j]]
+		}
+		return values[i] < values[j]
+	})
+}
@@ -0,0 +1,85 @@
+package utils
+
+import "strings"
+
+// 常见繁体字及对应的简体字，两个字符串中相同位置的字符一一对应
+// 只用于比较标题，覆盖片名中常见的字，不是完整的繁简转换
+const (
+	traditionalChars = "" +
+		"亂亞來侖侶係俠俬倉個們倫偉側偵偽傑傘備傢傭傳債傷傾僅僑僕價儀儂億儘償優儲兇兌兒內" +
+		"兩冊凍凜凱別刪則剎剛創劃劇劉劊劍劑勁動務勞勢勳勵勸勻匯匱區協卻厭厲參叢吳呂員問啓" +
+		"啞啟喚喪喬單喲嗎嗚嘆嘔嘗嘩嘯噸嚇嚕嚨嚮嚴國圍園圓圖團執堅堯報場塊塵墊墜墮墳墾壇壓" +
+		"壘壞壟壩壯壺壽夠夢夾奪奮妝姍婁婦婭媽嫵嬌嬰孃孫學寢實寧審寫寬寵寶將專尋對導尷屆屍" +
+		"屢層屬岡島崑崗嵐嶺嶼嶽巔巖帥師帳帶幟幣幫幹幾庫廁廂廝廟廠廢廣廬廳張強彈彌彎彙彥彿" +
+		"後徑從復徵徹恆恥悅悵惡惱惻愛愨態慘慚慣慶憂憐憑憤憲憶懇應懲懶懷懸懼戀戇戰戲戶拋掃" +
+		"掛揚換損搖搶撈撐撥撫撲撿擁擇擊擋擔據擠擬擱擲擴擺擾攔攜攝攢攤攪攬敗敘敵數斂斃斬斷" +
+		"於時晉晝暈暉暢暫暱曄曆曉曠曬書會朧朮東桿條棄棗棟棧棲楊楓楨業極榮構槍槓槳樁樂樓標" +
+		"樣樸樹樺橋機橢橫檔檢檯檸櫃櫥櫻欄權欽歎歐歡歲歷歸殘殯殲殺殼毀氈氣氾汙決沒況涼淚淨" +
+		"淪減測渾湊湯溝溫溼滄滅滬滯滲滷滾滿漁漢漣漲漸漿潑潔潛潤潯潰澀澆澗澤濁濃濕濛濟濤濱" +
+		"濺濾瀋瀏瀕瀟瀰瀾灑灘灝灣灤災為烏烴無煉煙煥煩熱熾燈燒營燦燭爍爐爛爭爺爾牽犖犢犧狀" +
+		"狹猙猶猻獄獅獎獨獰獵獸獻現瑣瑤瑪璣環璽瓊瓏甌產甦畝畢畫異當疊痺瘋瘡療癒癡癢癮發皚" +
+		"皺盃盜盞盡監盤盧眥眾睏睜睞瞞瞭矇矯硃碩碭確碼磚礙礦祕祿禍禦禪禮禱禿稅種稱穀穌積穩" +
+		"窩窮窯窺竄竅竊競筆筍箏節範築篩簡簫簽簾籃籠籤籬粵糞糧糰糾紀紂約紅紋紓純紗紙級紛紜" +
+		"紡紮細紳終組結絕絢給絨統絲綁經綠綢維綱網綴綺綻緊緒線緞締緣編緩緯練縛縣縫縮縱縷總" +
+		"繃織繞繡繩繪繭繹繼續纏纖罈罰罷羅羈羨義習翹聖聞聯聰聲聳聶職聽肅脅脈脛腎腦腫腸膚膠" +
+		"膽臉臘臟臥臨臺與興舉舊艙艦艱艷芻莊莖莢華萊萬萵葉葦蒓蒼蓀蓋蓮蔔蔞蔣蔥蔭蕎蕩蕪蕭薈" +
+		"薑薦薩藍藎藝藥蘆蘇蘊蘋蘚蘭蘿處虛虜號虧蛻蝕蝦蝸螢螻蟬蟲蠅蠟蠶蠻衆衊術衛衝袞裊補裝" +
+		"複褲襖襯襲見規覓視覦親覺覽觀訂計訊討訓記訛訝訟訣訪設許詐評詛詞詠詩詭話該詳誅誇誌" +
+		"誕誘語誠誣誤說誰課誼調談請諒論諜諧諷諸諺諾謀謁謂謊謎謙講謝謠謹證譏識譜譯議譴護讀" +
+		"變讓讚豈豎豐豔豬貓貝貞負財貢貧貨販貪貫責貴貶買貸費貼貽貿賀賄資賈賊賑賓賜賞賠賢賣" +
+		"賤賦質賬賭賴賺購賽贈贊贍贏贓贖贛趕趙趨趲跡踐蹤躊躋躍軀車軋軌軍軒軔軟軸較載輓輔輕" +
+		"輛輝輩輪輯輸輻輾轄轉轍轟辦辭辮辯農迴逕這連週進遊運過達違遙遜遞遠適遲遷選遺遼邁還" +
+		"邊邏郵鄉鄧鄭醜醞醫醬釀釋釐針釣鈍鈔鈕鈞鈣鈴鉀鉑鉗鉛鉤銀銅銘銜銳鋒鋤鋪鋸鋼錄錐錘錢" +
+		"錦錫錯鍊鍋鍛鍵鍾鎊鎔鎖鎧鎮鏈鏟鏡鐘鐮鐵鑄鑑鑒鑰鑽鑿長門閃閉開閑閒間閡閣閨閩閱閻闆" +
+		"闈闊闌闕闖關闡闢陘陣陰陳陸陽隊階隕際隨險隱隴隸雖雙雛雜雞離難雲電霧霽靂靄靈靜鞏鞦" +
+		"韃韋韌韓韙韻響頁頂頃項順須頌預頑頒頓頗領頡頤頭頰頷頸頹頻顆題顎顏顓願顛類顧顫顯風" +
+		"颱颳飄飛飯飲飼飾餃餅餉養餌餓餘餚餞餡館饅饋饑饒饞馬馭馮馱馳馴駁駐駕駛駝駭駱駿騎騙" +
+		"騰騷驃驅驕驗驚驟驢骯髏體髮鬆鬍鬢鬥鬧鬱魚魯鮑鮮鯉鯊鯨鰍鱉鱷鳥鳩鳳鳴鳶鴉鴛鴦鴨鴻鴿" +
+		"鵑鵝鵬鵲鶯鶴鷗鷹鸚鸞鹵鹽麗麥麩麵麼麽黃點黨黴黷黽鼉鼕鼴齊齋齒齜齡齣齦齪龍龐龔龕龜"
+	simplifiedChars = "" +
+		"乱亚来仑侣系侠私仓个们伦伟侧侦伪杰伞备家佣传债伤倾仅侨仆价仪侬亿尽偿优储凶兑儿内" +
+		"两册冻凛凯别删则刹刚创划剧刘刽剑剂劲动务劳势勋励劝匀汇匮区协却厌厉参丛吴吕员问启" +
+		"哑启唤丧乔单哟吗呜叹呕尝哗啸吨吓噜咙向严国围园圆图团执坚尧报场块尘垫坠堕坟垦坛压" +
+		"垒坏垄坝壮壶寿够梦夹夺奋妆姗娄妇娅妈妩娇婴娘孙学寝实宁审写宽宠宝将专寻对导尴届尸" +
+		"屡层属冈岛昆岗岚岭屿岳巅岩帅师帐带帜币帮干几库厕厢厮庙厂废广庐厅张强弹弥弯汇彦佛" +
+		"后径从复征彻恒耻悦怅恶恼恻爱悫态惨惭惯庆忧怜凭愤宪忆恳应惩懒怀悬惧恋戆战戏户抛扫" +
+		"挂扬换损摇抢捞撑拨抚扑捡拥择击挡担据挤拟搁掷扩摆扰拦携摄攒摊搅揽败叙敌数敛毙斩断" +
+		"于时晋昼晕晖畅暂昵晔历晓旷晒书会胧术东杆条弃枣栋栈栖杨枫桢业极荣构枪杠桨桩乐楼标" +
+		"样朴树桦桥机椭横档检台柠柜橱樱栏权钦叹欧欢岁历归残殡歼杀壳毁毡气泛污决没况凉泪净" +
+		"沦减测浑凑汤沟温湿沧灭沪滞渗卤滚满渔汉涟涨渐浆泼洁潜润浔溃涩浇涧泽浊浓湿蒙济涛滨" +
+		"溅滤沈浏濒潇弥澜洒滩灏湾滦灾为乌烃无炼烟焕烦热炽灯烧营灿烛烁炉烂争爷尔牵荦犊牺状" +
+		"狭狰犹狲狱狮奖独狞猎兽献现琐瑶玛玑环玺琼珑瓯产苏亩毕画异当叠痹疯疮疗愈痴痒瘾发皑" +
+		"皱杯盗盏尽监盘卢眦众困睁睐瞒了蒙矫朱硕砀确码砖碍矿秘禄祸御禅礼祷秃税种称谷稣积稳" +
+		"窝穷窑窥窜窍窃竞笔笋筝节范筑筛简箫签帘篮笼签篱粤粪粮团纠纪纣约红纹纾纯纱纸级纷纭" +
+		"纺扎细绅终组结绝绚给绒统丝绑经绿绸维纲网缀绮绽紧绪线缎缔缘编缓纬练缚县缝缩纵缕总" +
+		"绷织绕绣绳绘茧绎继续缠纤坛罚罢罗羁羡义习翘圣闻联聪声耸聂职听肃胁脉胫肾脑肿肠肤胶" +
+		"胆脸腊脏卧临台与兴举旧舱舰艰艳刍庄茎荚华莱万莴叶苇莼苍荪盖莲卜蒌蒋葱荫荞荡芜萧荟" +
+		"姜荐萨蓝荩艺药芦苏蕴苹藓兰萝处虚虏号亏蜕蚀虾蜗萤蝼蝉虫蝇蜡蚕蛮众蔑术卫冲衮袅补装" +
+		"复裤袄衬袭见规觅视觎亲觉览观订计讯讨训记讹讶讼诀访设许诈评诅词咏诗诡话该详诛夸志" +
+		"诞诱语诚诬误说谁课谊调谈请谅论谍谐讽诸谚诺谋谒谓谎谜谦讲谢谣谨证讥识谱译议谴护读" +
+		"变让赞岂竖丰艳猪猫贝贞负财贡贫货贩贪贯责贵贬买贷费贴贻贸贺贿资贾贼赈宾赐赏赔贤卖" +
+		"贱赋质账赌赖赚购赛赠赞赡赢赃赎赣赶赵趋趱迹践踪踌跻跃躯车轧轨军轩轫软轴较载挽辅轻" +
+		"辆辉辈轮辑输辐辗辖转辙轰办辞辫辩农回径这连周进游运过达违遥逊递远适迟迁选遗辽迈还" +
+		"边逻邮乡邓郑丑酝医酱酿释厘针钓钝钞钮钧钙铃钾铂钳铅钩银铜铭衔锐锋锄铺锯钢录锥锤钱" +
+		"锦锡错炼锅锻键钟镑熔锁铠镇链铲镜钟镰铁铸鉴鉴钥钻凿长门闪闭开闲闲间阂阁闺闽阅阎板" +
+		"闱阔阑阙闯关阐辟陉阵阴陈陆阳队阶陨际随险隐陇隶虽双雏杂鸡离难云电雾霁雳霭灵静巩秋" +
+		"鞑韦韧韩韪韵响页顶顷项顺须颂预顽颁顿颇领颉颐头颊颔颈颓频颗题颚颜颛愿颠类顾颤显风" +
+		"台刮飘飞饭饮饲饰饺饼饷养饵饿余肴饯馅馆馒馈饥饶馋马驭冯驮驰驯驳驻驾驶驼骇骆骏骑骗" +
+		"腾骚骠驱骄验惊骤驴肮髅体发松胡鬓斗闹郁鱼鲁鲍鲜鲤鲨鲸鳅鳖鳄鸟鸠凤鸣鸢鸦鸳鸯鸭鸿鸽" +
+		"鹃鹅鹏鹊莺鹤鸥鹰鹦鸾卤盐丽麦麸面么么黄点党霉黩黾鼍冬鼹齐斋齿龇龄出龈龊龙庞龚龛龟"
+)
+
+// t2sReplacer 将繁体字替换为简体字
+var t2sReplacer = func() *strings.Replacer {
+	traditional, simplified := []rune(traditionalChars), []rune(simplifiedChars)
+	pairs := make([]string, 0, len(traditional)*2)
+	for i := range traditional {
+		pairs = append(pairs, string(traditional[i]), string(simplified[i]))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// ToSimplified 将字符串中的常见繁体字转换为简体字，如 "臥虎藏龍" 转换为 "卧虎藏龙"
+func ToSimplified(s string) string {
+	return t2sReplacer.Replace(s)
+}
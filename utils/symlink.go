@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SymlinkMode 遍历、移动或复制目录时对符号链接的处理方式
+type SymlinkMode string
+
+const (
+	// SymlinkSkip 忽略符号链接，不遍历、不移动也不复制
+	SymlinkSkip SymlinkMode = "skip"
+	// SymlinkFollow 跟随符号链接，链接指向的目录会被遍历，复制时复制链接指向的内容
+	SymlinkFollow SymlinkMode = "follow"
+	// SymlinkPreserve 保留符号链接本身，遍历时作为普通条目，复制时重建相同的链接
+	SymlinkPreserve SymlinkMode = "preserve"
+)
+
+// IsSymlink 检查路径本身是否为符号链接
+func IsSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// Walk 与filepath.Walk相同，但按mode处理符号链接：
+// skip时符号链接不会传给walkFn；preserve时传入链接本身的信息，不进入链接指向的目录；
+// follow时传入链接指向的文件信息并进入链接指向的目录，已经遍历过的目录（循环链接）会跳过
+func Walk(root string, mode SymlinkMode, walkFn filepath.WalkFunc) error {
+	visited := make(map[string]bool)
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		if info.Mode()&os.ModeSymlink != 0 {
+			// 根目录本身是符号链接时总是跟随，与命令行中直接指定的路径保持一致
+			if target, statErr := os.Stat(root); statErr == nil {
+				info = target
+			}
+		}
+		err = walk(root, info, mode, visited, walkFn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walk 递归遍历目录，visited记录已遍历目录的真实路径
+func walk(path string, info os.FileInfo, mode SymlinkMode, visited map[string]bool, walkFn filepath.WalkFunc) error {
+	if info.IsDir() {
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+		if visited[realPath] {
+			return walkFn(path, info, fmt.Errorf("符号链接形成循环或指向已遍历的目录: %s -> %s", path, realPath))
+		}
+		visited[realPath] = true
+	}
+
+	err := walkFn(path, info, nil)
+	if err != nil {
+		if info.IsDir() && err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		childInfo, err := os.Lstat(childPath)
+		if err != nil {
+			if err := walkFn(childPath, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if childInfo.Mode()&os.ModeSymlink != 0 {
+			switch mode {
+			case SymlinkSkip:
+				continue
+			case SymlinkFollow:
+				targetInfo, err := os.Stat(childPath)
+				if err != nil {
+					// 目标不存在的链接按出错处理，由walkFn决定是否忽略
+					if err := walkFn(childPath, childInfo, err); err != nil && err != filepath.SkipDir {
+						return err
+					}
+					continue
+				}
+				childInfo = targetInfo
+			}
+		}
+
+		if err := walk(childPath, childInfo, mode, visited, walkFn); err != nil {
+			if err == filepath.SkipDir {
+				if !childInfo.IsDir() {
+					return nil // 与filepath.Walk一致，文件返回SkipDir时跳过所在目录的剩余条目
+				}
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
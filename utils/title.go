@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// titleEditionMarkers 不同来源在标题中附加的版本和音轨说明，比较标题时去掉
+var titleEditionMarkers = []string{
+	"国语版", "粤语版", "普通话版", "国语配音版", "粤语配音版", "国粤双语版", "双语版", "中字版",
+	"导演剪辑版", "导演版", "加长版", "未删减版", "完整版", "删减版", "重制版", "修复版", "高清版", "数码修复版",
+	"director's cut", "directors cut", "extended edition", "extended cut", "extended", "unrated", "uncut", "remastered",
+}
+
+// titleEditionRe 匹配版本标记，较长的标记优先
+var titleEditionRe = func() *regexp.Regexp {
+	markers := append([]string(nil), titleEditionMarkers...)
+	sort.Slice(markers, func(i, j int) bool { return len(markers[i]) > len(markers[j]) })
+	for i := range markers {
+		markers[i] = regexp.QuoteMeta(markers[i])
+	}
+	return regexp.MustCompile(strings.Join(markers, "|"))
+}()
+
+// titleBracketRe 匹配标题中括号括起的附加说明，如 （国语版）、[4K]、【蓝光】
+var titleBracketRe = regexp.MustCompile(`\([^()]*\)|\[[^\[\]]*\]|【[^【】]*】`)
+
+// NormalizeTitle 返回用于比较的标题：繁体转换为简体，全角转换为半角并转为小写，
+// 去掉括号中的附加说明和版本标记（如 "（国语版）"、"导演剪辑版"），只保留文字和数字
+// 如 "哪吒之魔童降世（国语版）" 和 "哪吒之魔童降世" 得到相同的结果
+func NormalizeTitle(title string) string {
+	s := strings.ToLower(width.Fold.String(ToSimplified(title)))
+	// 去掉括号中的说明，整个标题都在括号中时保留括号中的内容
+	if stripped := titleBracketRe.ReplaceAllString(s, " "); strings.TrimSpace(stripped) != "" {
+		s = stripped
+	}
+	if stripped := titleEditionRe.ReplaceAllString(s, " "); strings.TrimSpace(stripped) != "" {
+		s = stripped
+	}
+
+	var normalized strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// SameTitle 判断两个标题规范化后是否相同
+func SameTitle(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	na := NormalizeTitle(a)
+	return na != "" && na == NormalizeTitle(b)
+}